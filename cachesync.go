@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	defaultCacheSyncTimeout  = 1 * time.Minute
+	defaultCacheSyncAttempts = 5
+	cacheSyncInitialBackoff  = 2 * time.Second
+	cacheSyncMaxBackoff      = 30 * time.Second
+)
+
+// cacheSyncer waits for the informer caches to sync at startup and retries with backoff
+// when an attempt times out, so a briefly unavailable API server doesn't stop the controller.
+type cacheSyncer struct {
+	timeout  time.Duration
+	attempts int
+
+	wait  func(stopCh <-chan struct{}, cacheSyncs ...cache.InformerSynced) bool
+	sleep func(time.Duration)
+}
+
+func newCacheSyncer() *cacheSyncer {
+	return &cacheSyncer{
+		timeout:  getCacheSyncTimeout(),
+		attempts: getCacheSyncAttempts(),
+		wait:     cache.WaitForCacheSync,
+		sleep:    time.Sleep,
+	}
+}
+
+func (s *cacheSyncer) sync(stopCh <-chan struct{}, cacheSyncs ...cache.InformerSynced) error {
+	backoff := cacheSyncInitialBackoff
+	for attempt := 1; ; attempt++ {
+		if s.waitOnce(stopCh, cacheSyncs...) {
+			return nil
+		}
+		if isStopped(stopCh) {
+			return fmt.Errorf("stopped while waiting for caches to sync")
+		}
+		if attempt >= s.attempts {
+			return fmt.Errorf("failed to wait for caches to sync after %d attempts", attempt)
+		}
+
+		klog.Warningf("Informer caches did not sync within %s (attempt %d/%d), retrying in %s", s.timeout, attempt, s.attempts, backoff)
+		s.sleep(backoff)
+		backoff *= 2
+		if backoff > cacheSyncMaxBackoff {
+			backoff = cacheSyncMaxBackoff
+		}
+	}
+}
+
+// waitOnce waits for the caches until they sync, the timeout passes or stopCh is closed.
+func (s *cacheSyncer) waitOnce(stopCh <-chan struct{}, cacheSyncs ...cache.InformerSynced) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return s.wait(ctx.Done(), cacheSyncs...)
+}
+
+func isStopped(stopCh <-chan struct{}) bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func getCacheSyncTimeout() time.Duration {
+	value := os.Getenv("CACHE_SYNC_TIMEOUT")
+	if value == "" {
+		return defaultCacheSyncTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		klog.Errorf("Invalid CACHE_SYNC_TIMEOUT %q, using default %s", value, defaultCacheSyncTimeout)
+		return defaultCacheSyncTimeout
+	}
+	return timeout
+}
+
+func getCacheSyncAttempts() int {
+	value := os.Getenv("CACHE_SYNC_ATTEMPTS")
+	if value == "" {
+		return defaultCacheSyncAttempts
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts < 1 {
+		klog.Errorf("Invalid CACHE_SYNC_ATTEMPTS %q, using default %d", value, defaultCacheSyncAttempts)
+		return defaultCacheSyncAttempts
+	}
+	return attempts
+}
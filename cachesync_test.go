@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestCacheSyncer(results ...bool) (*cacheSyncer, *int, *[]time.Duration) {
+	calls := 0
+	var sleeps []time.Duration
+	s := &cacheSyncer{
+		timeout:  time.Second,
+		attempts: 5,
+		wait: func(stopCh <-chan struct{}, cacheSyncs ...cache.InformerSynced) bool {
+			result := results[calls]
+			calls++
+			return result
+		},
+		sleep: func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		},
+	}
+	return s, &calls, &sleeps
+}
+
+func TestCacheSyncerRetries(t *testing.T) {
+	s, calls, sleeps := newTestCacheSyncer(false, false, true)
+
+	err := s.sync(make(chan struct{}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *calls)
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second}, *sleeps)
+}
+
+func TestCacheSyncerGivesUp(t *testing.T) {
+	s, calls, sleeps := newTestCacheSyncer(false, false, false)
+	s.attempts = 3
+
+	err := s.sync(make(chan struct{}))
+
+	assert.EqualError(t, err, "failed to wait for caches to sync after 3 attempts")
+	assert.Equal(t, 3, *calls)
+	assert.Len(t, *sleeps, 2)
+}
+
+func TestCacheSyncerStopped(t *testing.T) {
+	s, calls, sleeps := newTestCacheSyncer(false, true)
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	err := s.sync(stopCh)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, *calls)
+	assert.Empty(t, *sleeps)
+}
+
+func TestCacheSyncerWaitOnceTimeout(t *testing.T) {
+	s := newCacheSyncer()
+	s.timeout = 10 * time.Millisecond
+
+	synced := s.waitOnce(make(chan struct{}), func() bool { return false })
+
+	assert.False(t, synced)
+}
+
+func TestGetCacheSyncSettings(t *testing.T) {
+	t.Setenv("CACHE_SYNC_TIMEOUT", "")
+	t.Setenv("CACHE_SYNC_ATTEMPTS", "")
+	assert.Equal(t, defaultCacheSyncTimeout, getCacheSyncTimeout())
+	assert.Equal(t, defaultCacheSyncAttempts, getCacheSyncAttempts())
+
+	t.Setenv("CACHE_SYNC_TIMEOUT", "20s")
+	t.Setenv("CACHE_SYNC_ATTEMPTS", "3")
+	assert.Equal(t, 20*time.Second, getCacheSyncTimeout())
+	assert.Equal(t, 3, getCacheSyncAttempts())
+
+	t.Setenv("CACHE_SYNC_TIMEOUT", "soon")
+	t.Setenv("CACHE_SYNC_ATTEMPTS", "0")
+	assert.Equal(t, defaultCacheSyncTimeout, getCacheSyncTimeout())
+	assert.Equal(t, defaultCacheSyncAttempts, getCacheSyncAttempts())
+}
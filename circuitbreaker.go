@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	"k8s.io/klog"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 5 * time.Minute
+)
+
+// circuitBreaker stops calling a notifier after threshold consecutive failures. Once the
+// cooldown has passed a single call is let through: a success closes the circuit again,
+// a failure keeps it open for another cooldown.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	// trial is set while the call after the cooldown is in flight.
+	trial bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record counts the result of an allowed call. Opening the circuit and every failed trial
+// log a single warning, so that an outage logs once per cooldown instead of once per event.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := b.failures >= b.threshold
+	b.trial = false
+	if err == nil {
+		if wasOpen {
+			klog.Infof("Notifier %s recovered, resuming notifications", b.name)
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	switch {
+	case wasOpen:
+		klog.Warningf("Notifier %s is still failing, skipping its notifications for another %s: %v", b.name, b.cooldown, err)
+		b.openedAt = b.now()
+	case b.failures == b.threshold:
+		klog.Warningf("Notifier %s failed %d times in a row, skipping its notifications for %s: %v", b.name, b.failures, b.cooldown, err)
+		b.openedAt = b.now()
+	}
+}
+
+// breakerNotification guards a notifier with a circuit breaker. Skipped calls report
+// notification.SkippedCircuitOpen.
+type breakerNotification struct {
+	notification.Notification
+	breaker *circuitBreaker
+}
+
+func (b breakerNotification) call(notify func() (notification.NotifyResult, error)) (notification.NotifyResult, error) {
+	if !b.breaker.allow() {
+		return notification.NotifyResult{SkippedReason: notification.SkippedCircuitOpen}, nil
+	}
+	result, err := notify()
+	b.breaker.record(err)
+	return result, err
+}
+
+func (b breakerNotification) NotifyStart(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return b.call(func() (notification.NotifyResult, error) { return b.Notification.NotifyStart(messageParam) })
+}
+
+func (b breakerNotification) NotifySuccess(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return b.call(func() (notification.NotifyResult, error) { return b.Notification.NotifySuccess(messageParam) })
+}
+
+func (b breakerNotification) NotifyFailed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return b.call(func() (notification.NotifyResult, error) { return b.Notification.NotifyFailed(messageParam) })
+}
+
+func (b breakerNotification) NotifySuspended(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return b.call(func() (notification.NotifyResult, error) { return b.Notification.NotifySuspended(messageParam) })
+}
+
+func (b breakerNotification) NotifyResumed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return b.call(func() (notification.NotifyResult, error) { return b.Notification.NotifyResumed(messageParam) })
+}
+
+func (b breakerNotification) NotifySpecChanged(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return b.call(func() (notification.NotifyResult, error) { return b.Notification.NotifySpecChanged(messageParam) })
+}
+
+// withCircuitBreakers guards every notifier with its own circuit breaker, configured by
+// NOTIFIER_BREAKER_THRESHOLD and NOTIFIER_BREAKER_COOLDOWN. A threshold of 0 disables them.
+func withCircuitBreakers(notifications map[string]notification.Notification) map[string]notification.Notification {
+	threshold := getBreakerThreshold()
+	if threshold == 0 {
+		return notifications
+	}
+	cooldown := getBreakerCooldown()
+	res := make(map[string]notification.Notification, len(notifications))
+	for name, n := range notifications {
+		res[name] = breakerNotification{
+			Notification: n,
+			breaker:      &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now},
+		}
+	}
+	return res
+}
+
+func getBreakerThreshold() int {
+	value := os.Getenv("NOTIFIER_BREAKER_THRESHOLD")
+	if value == "" {
+		return defaultBreakerThreshold
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 0 {
+		klog.Errorf("Invalid NOTIFIER_BREAKER_THRESHOLD %q, using default %d: %v", value, defaultBreakerThreshold, err)
+		return defaultBreakerThreshold
+	}
+	return threshold
+}
+
+func getBreakerCooldown() time.Duration {
+	value := os.Getenv("NOTIFIER_BREAKER_COOLDOWN")
+	if value == "" {
+		return defaultBreakerCooldown
+	}
+	cooldown, err := time.ParseDuration(value)
+	if err != nil || cooldown <= 0 {
+		klog.Errorf("Invalid NOTIFIER_BREAKER_COOLDOWN %q, using default %s: %v", value, defaultBreakerCooldown, err)
+		return defaultBreakerCooldown
+	}
+	return cooldown
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+)
+
+type erroringNotification struct {
+	recordingNotification
+	err error
+}
+
+func (n *erroringNotification) NotifyFailed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	_, _ = n.record("failed", messageParam)
+	return notification.NotifyResult{}, n.err
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2020, 9, 6, 1, 0, 0, 0, time.UTC)
+	inner := &erroringNotification{err: errors.New("invalid_auth")}
+	n := breakerNotification{
+		Notification: inner,
+		breaker:      &circuitBreaker{name: "slack", threshold: 2, cooldown: time.Minute, now: func() time.Time { return now }},
+	}
+	notify := func() (notification.NotifyResult, error) {
+		return n.NotifyFailed(notification.MessageTemplateParam{JobName: "the-job"})
+	}
+
+	// The circuit opens after two consecutive failures.
+	_, err := notify()
+	assert.Error(t, err)
+	_, err = notify()
+	assert.Error(t, err)
+	result, err := notify()
+	assert.NoError(t, err)
+	assert.Equal(t, notification.SkippedCircuitOpen, result.SkippedReason)
+	assert.Len(t, inner.events, 2)
+
+	// A single trial after the cooldown, which keeps the circuit open when it fails.
+	now = now.Add(time.Minute)
+	_, err = notify()
+	assert.Error(t, err)
+	result, _ = notify()
+	assert.Equal(t, notification.SkippedCircuitOpen, result.SkippedReason)
+	assert.Len(t, inner.events, 3)
+
+	// A successful trial closes the circuit.
+	now = now.Add(time.Minute)
+	inner.err = nil
+	_, err = notify()
+	assert.NoError(t, err)
+	_, err = notify()
+	assert.NoError(t, err)
+	assert.Len(t, inner.events, 5)
+
+	// Other events share the breaker of the notifier.
+	result, err = n.NotifyStart(notification.MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Empty(t, result.SkippedReason)
+}
+
+func TestCircuitBreakerResetOnSuccess(t *testing.T) {
+	b := &circuitBreaker{name: "slack", threshold: 2, cooldown: time.Minute, now: time.Now}
+
+	b.record(errors.New("timeout"))
+	b.record(nil)
+	b.record(errors.New("timeout"))
+
+	assert.True(t, b.allow())
+}
+
+func TestWithCircuitBreakers(t *testing.T) {
+	notifications := map[string]notification.Notification{"recording": &recordingNotification{}}
+
+	t.Setenv("NOTIFIER_BREAKER_THRESHOLD", "")
+	t.Setenv("NOTIFIER_BREAKER_COOLDOWN", "10m")
+	res := withCircuitBreakers(notifications)
+	assert.IsType(t, breakerNotification{}, res["recording"])
+	assert.Equal(t, defaultBreakerThreshold, res["recording"].(breakerNotification).breaker.threshold)
+	assert.Equal(t, 10*time.Minute, res["recording"].(breakerNotification).breaker.cooldown)
+
+	t.Setenv("NOTIFIER_BREAKER_THRESHOLD", "0")
+	assert.Equal(t, notifications, withCircuitBreakers(notifications))
+}
+
+func TestGetBreakerSettings(t *testing.T) {
+	t.Setenv("NOTIFIER_BREAKER_THRESHOLD", "invalid")
+	t.Setenv("NOTIFIER_BREAKER_COOLDOWN", "-1m")
+
+	assert.Equal(t, defaultBreakerThreshold, getBreakerThreshold())
+	assert.Equal(t, defaultBreakerCooldown, getBreakerCooldown())
+}
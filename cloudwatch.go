@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+// cloudWatchLogMargin widens the time range of the query around the run of the job, so
+// that log lines shipped with a delay are still found.
+const cloudWatchLogMargin = 5 * time.Minute
+
+// getCloudWatchLogURL returns a link to a CloudWatch Logs Insights query of the logs of the
+// job's pods in logGroup, as shipped by Fluent Bit (FireLens or aws-for-fluent-bit) with its
+// kubernetes metadata. The region is AWS_REGION.
+func getCloudWatchLogURL(job *batchv1.Job, logGroup string) string {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		klog.Errorf("AWS_REGION is required with CLOUDWATCH_LOG_GROUP: %s", jobLogFields(job, "log_url"))
+		return ""
+	}
+
+	start := job.CreationTimestamp.Time
+	end := time.Now()
+	if finishTime := getJobFinishTime(job); finishTime != nil {
+		end = finishTime.Time
+	}
+	query := fmt.Sprintf("fields @timestamp, kubernetes.pod_name, log\n"+
+		"| filter kubernetes.namespace_name = %q and kubernetes.labels.job-name = %q\n"+
+		"| sort @timestamp asc", job.Namespace, job.Name)
+
+	// The console keeps the query in the URL fragment in its own encoding: the values are
+	// URL-escaped with * instead of %, and $ stands for % outside of them.
+	detail := fmt.Sprintf("~(end~'%s~start~'%s~timeType~'ABSOLUTE~tz~'UTC~editorString~'%s~isLiveTail~false~source~(~'%s))",
+		cloudWatchEscape(end.Add(cloudWatchLogMargin).UTC().Format(time.RFC3339)),
+		cloudWatchEscape(start.Add(-cloudWatchLogMargin).UTC().Format(time.RFC3339)),
+		cloudWatchEscape(query),
+		cloudWatchEscape(logGroup))
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/cloudwatch/home?region=%s#logsV2:logs-insights$3FqueryDetail$3D%s",
+		region, url.QueryEscape(region), detail)
+}
+
+func cloudWatchEscape(s string) string {
+	escaped := strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	return strings.ReplaceAll(escaped, "%", "*")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetCloudWatchLogURL(t *testing.T) {
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	created := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-123", Namespace: "test-ns", CreationTimestamp: metav1.NewTime(created)},
+		Status:     batchv1.JobStatus{CompletionTime: &metav1.Time{Time: created.Add(10 * time.Minute)}},
+	}
+
+	expected := "https://ap-northeast-1.console.aws.amazon.com/cloudwatch/home?region=ap-northeast-1#logsV2:logs-insights$3FqueryDetail$3D" +
+		"~(end~'2020-11-28T01*3A15*3A00Z~start~'2020-11-28T00*3A55*3A00Z~timeType~'ABSOLUTE~tz~'UTC" +
+		"~editorString~'fields*20*40timestamp*2C*20kubernetes.pod_name*2C*20log*0A" +
+		"*7C*20filter*20kubernetes.namespace_name*20*3D*20*22test-ns*22*20and*20kubernetes.labels.job-name*20*3D*20*22the-job-123*22*0A" +
+		"*7C*20sort*20*40timestamp*20asc" +
+		"~isLiveTail~false~source~(~'*2Faws*2Feks*2Ftest-ns))"
+	assert.Equal(t, expected, getCloudWatchLogURL(job, "/aws/eks/test-ns"))
+
+	t.Setenv("AWS_REGION", "")
+	assert.Empty(t, getCloudWatchLogURL(job, "/aws/eks/test-ns"))
+}
+
+func TestGetLogURLCloudWatch(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("CLOUDWATCH_LOG_GROUP", "/aws/containerinsights/prod/{{.Namespace}}")
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job-123", Namespace: "test-ns"}}
+
+	assert.Contains(t, getLogURL(job, ""), "~source~(~'*2Faws*2Fcontainerinsights*2Fprod*2Ftest-ns))")
+
+	// LOG_URL_TEMPLATE takes precedence.
+	t.Setenv("LOG_URL_TEMPLATE", "https://logs.example.com/{{.JobName}}")
+	assert.Equal(t, "https://logs.example.com/the-job-123", getLogURL(job, ""))
+}
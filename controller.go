@@ -1,12 +1,11 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/thoas/go-funk"
@@ -25,6 +24,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -33,6 +33,16 @@ const (
 	searchLabel         = "controller-uid"
 
 	logModeAnnotationName = "kube-job-notifier/log-mode"
+	skipAnnotationName    = "job-notify-controller/skip"
+	ephemeralLabelName    = "ci.test/ephemeral"
+	helmHookAnnotation    = "helm.sh/hook"
+
+	defaultShutdownGrace = 30 * time.Second
+	redactedValue        = "***"
+
+	oomKilledReason               = "OOMKilled"
+	failureReasonOOMKilled        = "oomkilled"
+	failureReasonDeadlineExceeded = "deadline_exceeded"
 )
 
 type logMode int
@@ -51,6 +61,103 @@ type Controller struct {
 	jobsLister    batcheslisters.JobLister
 	jobsSynced    cache.InformerSynced
 	recorder      record.EventRecorder
+
+	notifications map[string]notification.Notification
+	subscriptions map[string]monitoring.Subscription
+	notifiedJobs  map[string]bool
+	// deferredStarts are the jobs created without work, e.g. suspended, whose start is
+	// notified once they run pods.
+	deferredStarts map[string]bool
+	// waitTimes are the wait times of the jobs, so that their pods are only listed for it once.
+	waitTimes map[string]time.Duration
+	// notifiedMu guards notifiedJobs, deferredStarts and waitTimes, which delayed success
+	// notifications update from their timers.
+	notifiedMu sync.Mutex
+
+	// inflight tracks event handlers that are still sending notifications.
+	inflight sync.WaitGroup
+	// shutdownMu guards shuttingDown so that no event is added to inflight once shutdown
+	// has started waiting for it.
+	shutdownMu    sync.Mutex
+	shuttingDown  bool
+	shutdownGrace time.Duration
+
+	// startupGracePeriod is the time after startup during which jobs that finished
+	// before the controller started are recorded without being notified.
+	startupGracePeriod time.Duration
+
+	// resultEvaluators decide whether a job has succeeded or failed, in order.
+	resultEvaluators []jobResultEvaluator
+
+	// resources is set when succeeded jobs are checked for memory usage close to their limits.
+	resources *resourceMonitor
+
+	// watchErrors handles the watch errors of the job informer.
+	watchErrors *watchErrorHandler
+
+	// errorReporter records internal errors, nil when they are only logged.
+	errorReporter *controllerErrorReporter
+
+	// deduper drops repeated notifications, nil when deduplication is disabled.
+	deduper *notificationDeduper
+
+	// successes delays success notifications, nil when they are sent right away.
+	successes *successConfirmer
+
+	// recoveries tracks failed jobs to notify their next success as a recovery.
+	recoveries *recoveryTracker
+
+	// podStuckThreshold is how long a pod may wait on its image or config before a warning is sent.
+	podStuckThreshold time.Duration
+
+	// eventCount is the number of recent Kubernetes events attached to failure notifications.
+	eventCount int
+
+	// routes are the routing rules of CONFIG_FILE, nil when jobs are routed by the environment only.
+	routes *routingConfig
+
+	// logs streams container logs, nil for the pods/log API of kubeclientset.
+	logs podLogs
+	// fetcher reads the logs of jobs with the redaction patterns compiled at startup, nil
+	// when a fetcher is built for each job.
+	fetcher *logFetcher
+
+	// clusterName is the CLUSTER_NAME shown in notifications, empty when unset.
+	clusterName string
+
+	// attachJobYAML is set when Slack attaches the YAML of failed jobs, SLACK_ATTACH_JOB_YAML=true.
+	// The YAML is only serialized then, since every notifier receives it.
+	attachJobYAML bool
+
+	// podLosses detects running pods lost before completion, nil when they aren't notified.
+	podLosses *podLossTracker
+
+	// resends keeps the last notification of each job for /resend, nil when it is disabled.
+	resends *resendCache
+
+	// mutes reads the mute ConfigMap, nil when the namespace of the controller is unknown.
+	mutes *muteConfigMap
+
+	// retries sends failed notifications again, nil when they are dropped.
+	retries *notificationRetrier
+
+	// rollups notifies the jobs a CronJob created together as one batch, nil when every
+	// job is notified on its own.
+	rollups *cronJobRollup
+}
+
+func (c *Controller) getPodLogs() podLogs {
+	if c.logs != nil {
+		return c.logs
+	}
+	return clientsetPodLogs{clientset: c.kubeclientset}
+}
+
+func (c *Controller) getLogFetcher() *logFetcher {
+	if c.fetcher != nil {
+		return c.fetcher
+	}
+	return newLogFetcher(c.getPodLogs())
 }
 
 // NewController returns a new controller
@@ -65,189 +172,232 @@ func NewController(
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
 
 	controller := &Controller{
-		jobsLister: jobInformer.Lister(),
-		jobsSynced: jobInformer.Informer().HasSynced,
-		recorder:   recorder,
+		kubeclientset: kubeclientset,
+		jobsLister:    jobInformer.Lister(),
+		jobsSynced:    jobInformer.Informer().HasSynced,
+		recorder:      recorder,
+		subscriptions: monitoring.NewSubscription(),
+		shutdownGrace: getShutdownGrace(),
+		notifiedJobs:  make(map[string]bool),
+
+		startupGracePeriod: getStartupGracePeriod(),
+		resultEvaluators:   newJobResultEvaluators(),
+		resources:          getResourceMonitor(kubeclientset),
+		deduper:            newNotificationDeduper(),
+		podStuckThreshold:  getPodStuckThreshold(),
+		recoveries:         newRecoveryTracker(),
+		eventCount:         getNotifyEventCount(),
+		routes:             getRoutingConfig(),
+		clusterName:        os.Getenv("CLUSTER_NAME"),
+		attachJobYAML:      os.Getenv("SLACK_ATTACH_JOB_YAML") == "true",
+		podLosses:          getPodLossTracker(),
+		resends:            newResendCache(),
+		mutes:              getMuteConfigMap(kubeclientset),
+		retries:            newNotificationRetrier(),
 	}
+	controller.fetcher = newLogFetcher(controller.getPodLogs())
+	controller.notifications = withRetries(controller.retries, withCircuitBreakers(notification.NewNotifications()))
+	controller.successes = newSuccessConfirmer(&controller.inflight)
+	controller.rollups = newCronJobRollup(&controller.inflight, controller.notifyRollup)
+	controller.errorReporter = newControllerErrorReporter(controller.subscriptions, notification.NewOpsNotifier())
 	serverStartTime = time.Now().Local()
-	notifiedJobs := make(map[string]bool)
 
-	notifications := notification.NewNotifications()
-	subscriptions := monitoring.NewSubscription()
-	datadogSubscription := subscriptions["datadog"]
+	controller.watchErrors = newWatchErrorHandler(controller.subscriptions)
+	if err := jobInformer.Informer().SetWatchErrorHandler(controller.watchErrors.handle); err != nil {
+		klog.Errorf("Failed to set watch error handler: %v", err)
+	}
 
 	klog.Info("Setting event handlers")
 	jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(new interface{}) {
-			newJob := new.(*batchv1.Job)
-			klog.Infof("Job added: %v", newJob.Status)
-
-			if newJob.CreationTimestamp.Sub(serverStartTime).Seconds() < 0 {
-				return
+			controller.handleAdd(new.(*batchv1.Job))
+		},
+		UpdateFunc: func(old, new interface{}) {
+			controller.handleUpdate(old.(*batchv1.Job), new.(*batchv1.Job))
+		},
+		DeleteFunc: func(obj interface{}) {
+			deletedJob := obj.(*batchv1.Job)
+			controller.successes.cancel(deletedJob)
+			if controller.podLosses != nil {
+				controller.podLosses.forget(deletedJob)
 			}
+			controller.resends.forget(deletedJob)
+			controller.forgetNotified(deletedJob.Name)
+		},
+	})
 
-			if notifiedJobs[newJob.Name] == true {
-				return
-			}
+	return controller
+}
 
-			klog.Infof("Job started: %v", newJob.Status)
+// handleAdd notifies the start of a job.
+func (c *Controller) handleAdd(newJob *batchv1.Job) {
+	if !c.startEvent() {
+		return
+	}
+	defer c.inflight.Done()
+	observedAt := time.Now()
 
-			jobPod, err := getPodFromControllerUID(kubeclientset, newJob)
-			err = waitForPodRunning(kubeclientset, jobPod)
+	klog.Infof("Job added: %s status=%v", jobLogFields(newJob, "added"), newJob.Status)
 
-			if err != nil {
-				klog.Errorf("Error waiting for pod to become running: %v", jobPod)
-				return
-			}
+	if c.skipFinishedBeforeStartup(newJob, observedAt) {
+		return
+	}
+	// The start of a job created before startup was missed.
+	if newJob.CreationTimestamp.Sub(serverStartTime).Seconds() < 0 {
+		return
+	}
+
+	if c.isNotified(newJob.Name) {
+		return
+	}
 
-			cronJob, err := getCronJobNameFromOwnerReferences(kubeclientset, newJob)
+	if isSkippedJob(newJob) {
+		klog.V(4).Infof("Job skipped: %s", jobLogFields(newJob, "skipped"))
+		return
+	}
 
-			if err != nil {
-				klog.Errorf("Get cronjob failed: %v", err)
-			}
-			klog.Infof("Job started: %v", newJob.Status)
-			messageParam := notification.MessageTemplateParam{
-				JobName:     newJob.Name,
-				CronJobName: cronJob,
-				Namespace:   newJob.Namespace,
-				StartTime:   newJob.Status.StartTime,
-				Annotations: newJob.Spec.Template.ObjectMeta.Annotations,
-			}
-			for name, n := range notifications {
-				err := n.NotifyStart(messageParam)
-				if err != nil {
-					klog.Errorf("Failed %s notification: %v", name, err)
-				}
-			}
+	// A job without work yet is notified once it is resumed or scaled up.
+	if !hasWork(newJob) {
+		klog.Infof("Job start deferred until it runs pods: %s", jobLogFields(newJob, notification.START))
+		c.deferStart(newJob.Name)
+		return
+	}
 
-		},
-		UpdateFunc: func(old, new interface{}) {
-			newJob := new.(*batchv1.Job)
-			oldJob := old.(*batchv1.Job)
+	c.notifyStart(newJob, observedAt)
+}
 
-			klog.Infof("oldJob.Status:%v", oldJob.Status)
-			klog.Infof("newJob.Status:%v", newJob.Status)
-			if newJob.CreationTimestamp.Sub(serverStartTime).Seconds() < 0 {
-				return
-			}
+// notifyStart waits for the pod of the job to run and notifies its start.
+func (c *Controller) notifyStart(newJob *batchv1.Job, observedAt time.Time) {
+	jobPod, err := getPodFromControllerUID(c.kubeclientset, newJob)
+	err = waitForPodRunning(c.kubeclientset, jobPod, c.podStuckThreshold, func(pod *corev1.Pod, waiting podWaiting) {
+		c.notifyPodStuck(newJob, pod, waiting, time.Now())
+	})
 
-			if notifiedJobs[newJob.Name] == true {
-				return
-			}
+	if err != nil {
+		klog.Errorf("Error waiting for pod to become running: %s pod=%s: %v", jobLogFields(newJob, notification.START), jobPod.Name, err)
+		return
+	}
 
-			jobPod, err := getPodFromControllerUID(kubeclientset, newJob)
-			err = waitForPodRunning(kubeclientset, jobPod)
+	cronJob, err := getCronJobNameFromOwnerReferences(c.kubeclientset, newJob)
 
-			if err != nil {
-				klog.Errorf("Error waiting for pod to become running: %v", err)
-				return
+	if err != nil {
+		klog.Errorf("Get cronjob failed: %s: %v", jobLogFields(newJob, notification.START), err)
+		c.errorReporter.report(controllerErrorStageOwner, newJob.Name, err)
+	}
+	klog.Infof("Job started: %s status=%v", jobLogFields(newJob, notification.START), newJob.Status)
+	messageParam := notification.MessageTemplateParam{
+		ClusterName:  c.clusterName,
+		Event:        notification.START,
+		JobName:      newJob.Name,
+		CronJobName:  cronJob,
+		Namespace:    newJob.Namespace,
+		StartTime:    newJob.Status.StartTime,
+		Annotations:  c.jobAnnotations(newJob),
+		JobDetailURL: getJobDetailURL(newJob, cronJob, c.clusterName),
+		QueueTime:    c.jobWaitTime(newJob),
+	}
+	if !c.isMutedNotification(newJob, notification.START, messageParam, observedAt) && !c.isDuplicateNotification(newJob, notification.START, messageParam) {
+		c.resends.record(newJob, messageParam)
+		for name, n := range c.jobNotifications(newJob) {
+			result, err := n.NotifyStart(messageParam)
+			c.recordNotifyResult(name, notification.START, newJob, observedAt, result, err)
+		}
+	}
+
+	if monitoring.Enabled() {
+		jobInfo := monitoring.JobInfo{
+			CronJobName: cronJob,
+			Name:        newJob.Name,
+			Namespace:   newJob.Namespace,
+			Annotations: messageParam.Annotations,
+		}
+		for _, s := range c.subscriptions {
+			if err = s.StartEvent(jobInfo); err != nil {
+				klog.Errorf("Fail event subscribe: %s: %v", jobLogFields(newJob, notification.START), err)
 			}
+		}
+	}
+}
 
-			if newJob.Status.Succeeded == intTrue {
-				klog.Infof("Job succeeded: Name: %s: Status: %v", newJob.Name, newJob.Status)
-				jobPod, err := getPodFromControllerUID(kubeclientset, newJob)
-				if err != nil {
-					klog.Errorf("Get pods failed: %v", err)
-					return
-				}
+// handleUpdate notifies suspend transitions and the result of a job.
+func (c *Controller) handleUpdate(oldJob, newJob *batchv1.Job) {
+	if !c.startEvent() {
+		return
+	}
+	defer c.inflight.Done()
+	observedAt := time.Now()
 
-				cronJobName, err := getCronJobNameFromOwnerReferences(kubeclientset, newJob)
+	klog.Infof("Job updated: %s oldStatus=%v newStatus=%v", jobLogFields(newJob, "updated"), oldJob.Status, newJob.Status)
+	// Renotifications are requested for completed jobs, including those created before startup.
+	if renotifyRequested(oldJob, newJob) && c.renotify(newJob, observedAt) {
+		return
+	}
+	if c.skipFinishedBeforeStartup(newJob, observedAt) {
+		return
+	}
+	if newJob.CreationTimestamp.Sub(serverStartTime).Seconds() < 0 {
+		return
+	}
 
-				if err != nil {
-					klog.Errorf("Get cronjob failed: %v", err)
-					return
-				}
-				annotations := newJob.Spec.Template.ObjectMeta.Annotations
-				lm := getLogMode(annotations, logModeAnnotationName)
-				jobLogStr := getJobLogs(kubeclientset, jobPod, cronJobName, lm)
-
-				messageParam := notification.MessageTemplateParam{
-					JobName:        newJob.Name,
-					CronJobName:    cronJobName,
-					Namespace:      newJob.Namespace,
-					StartTime:      newJob.Status.StartTime,
-					CompletionTime: newJob.Status.CompletionTime,
-					Log:            jobLogStr,
-					Annotations:    annotations,
-				}
+	if c.isNotified(newJob.Name) {
+		return
+	}
 
-				for name, n := range notifications {
-					err = n.NotifySuccess(messageParam)
-					if err != nil {
-						klog.Errorf("Failed %s n: %v", name, err)
-					}
-				}
+	if isSkippedJob(newJob) {
+		klog.V(4).Infof("Job skipped: %s", jobLogFields(newJob, "skipped"))
+		return
+	}
 
-				if os.Getenv("DATADOG_ENABLE") == "true" {
-
-					err = datadogSubscription.SuccessEvent(
-						monitoring.JobInfo{
-							CronJobName: cronJobName,
-							Name:        newJob.Name,
-							Namespace:   newJob.Namespace,
-							Annotations: newJob.Spec.Template.ObjectMeta.Annotations,
-						})
-					if err != nil {
-						klog.Errorf("Fail event subscribe.: %v", err)
-					}
-				}
-				klog.V(4).Infof("Job succeeded log: %v", jobLogStr)
-				notifiedJobs[newJob.Name] = isCompletedJob(kubeclientset, newJob)
-			} else if newJob.Status.Failed == intTrue {
-				klog.Infof("Job failed: Name: %s: Status: %v", newJob.Name, newJob.Status)
-				jobPod, err := getPodFromControllerUID(kubeclientset, newJob)
-				if err != nil {
-					klog.Errorf("Get pods failed: %v", err)
-					return
-				}
+	// A success waiting for confirmation is dropped once the job leaves the succeeded state.
+	if c.successes != nil && c.getJobResult(newJob) != jobSucceeded {
+		c.successes.cancel(newJob)
+	}
 
-				cronJobName, err := getCronJobNameFromOwnerReferences(kubeclientset, newJob)
-				if err != nil {
-					klog.Errorf("Get cronjob failed: %v", err)
-					return
-				}
+	c.notifySpecChanged(oldJob, newJob, observedAt)
+	suspendHandled := c.notifySuspendTransition(oldJob, newJob, observedAt)
+	if hasWork(newJob) && c.takeDeferredStart(newJob.Name) {
+		c.notifyStart(newJob, observedAt)
+	}
+	if suspendHandled {
+		return
+	}
 
-				annotations := newJob.Spec.Template.ObjectMeta.Annotations
-				lm := getLogMode(annotations, logModeAnnotationName)
-				jobLogStr := getJobLogs(kubeclientset, jobPod, cronJobName, lm)
-
-				messageParam := notification.MessageTemplateParam{
-					JobName:        newJob.Name,
-					CronJobName:    cronJobName,
-					Namespace:      newJob.Namespace,
-					StartTime:      newJob.Status.StartTime,
-					CompletionTime: newJob.Status.CompletionTime,
-					Log:            jobLogStr,
-					Annotations:    annotations,
-				}
-				for name, n := range notifications {
-					err := n.NotifyFailed(messageParam)
-					if err != nil {
-						klog.Errorf("Failed %s notification: %v", name, err)
-					}
-				}
-				if os.Getenv("DATADOG_ENABLE") == "true" {
-					err = datadogSubscription.FailEvent(
-						monitoring.JobInfo{
-							CronJobName: cronJobName,
-							Name:        newJob.Name,
-							Namespace:   newJob.Namespace,
-							Annotations: newJob.Spec.Template.ObjectMeta.Annotations,
-						})
-					if err != nil {
-						klog.Errorf("Fail event subscribe.: %v", err)
-					}
-				}
-				notifiedJobs[newJob.Name] = isCompletedJob(kubeclientset, newJob)
-			}
-		},
-		DeleteFunc: func(obj interface{}) {
-			deletedJob := obj.(*batchv1.Job)
-			delete(notifiedJobs, deletedJob.Name)
-		},
+	if c.podLosses != nil {
+		// Evicted pods count as failed, so a retrying job may still be losing pods.
+		if result := c.getJobResult(newJob); result == jobSucceeded || result == jobFailed {
+			c.podLosses.forget(newJob)
+		} else if lost, peak := c.podLosses.observe(newJob); lost > 0 {
+			c.notifyPodsLost(newJob, lost, peak, observedAt)
+		}
+	}
+
+	jobPod, err := getPodFromControllerUID(c.kubeclientset, newJob)
+	err = waitForPodRunning(c.kubeclientset, jobPod, c.podStuckThreshold, func(pod *corev1.Pod, waiting podWaiting) {
+		c.notifyPodStuck(newJob, pod, waiting, time.Now())
 	})
 
-	return controller
+	if err != nil {
+		klog.Errorf("Error waiting for pod to become running: %s: %v", jobLogFields(newJob, "updated"), err)
+		return
+	}
+
+	switch c.getJobResult(newJob) {
+	case jobSucceeded:
+		if c.successes != nil {
+			c.successes.schedule(newJob, observedAt, c.handleSucceeded)
+			return
+		}
+		c.handleSucceeded(newJob, observedAt)
+	case jobFailed:
+		c.handleFailed(newJob, observedAt)
+	case jobRetrying:
+		// A job is only notified as failed once its retries are exhausted. NOTIFY_ON_RETRY=true
+		// adds a single warning when its first attempt failed.
+		if os.Getenv("NOTIFY_ON_RETRY") == "true" && oldJob.Status.Failed == 0 {
+			klog.Infof("Job retrying: %s failed=%d", jobLogFields(newJob, notification.FAILED), newJob.Status.Failed)
+			c.handleFailed(newJob, observedAt)
+		}
+	}
 }
 
 // Run is Kubernetes Controller execute method
@@ -257,17 +407,619 @@ func (c *Controller) Run(stopCh <-chan struct{}) error {
 	klog.Info("Starting kubernetes job notify controller")
 
 	klog.Info("Waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(stopCh, c.jobsSynced); !ok {
-		return fmt.Errorf("failed to wait for caches to sync")
+	if err := newCacheSyncer().sync(stopCh, c.jobsSynced); err != nil {
+		return err
+	}
+
+	if c.resources != nil {
+		go c.resources.run(stopCh)
 	}
 
 	klog.Info("Started workers")
 	<-stopCh
 	klog.Info("Shutting down workers")
 
+	c.watchErrors.stop()
+	c.shutdown()
+
 	return nil
 }
 
+// startEvent registers an in-flight event and reports whether it may be handled.
+// Events arriving after shutdown has started are dropped.
+func (c *Controller) startEvent() bool {
+	c.shutdownMu.Lock()
+	defer c.shutdownMu.Unlock()
+	if c.shuttingDown {
+		return false
+	}
+	c.inflight.Add(1)
+	return true
+}
+
+// shutdown stops accepting new events, waits up to the grace period for
+// in-flight notifications to finish and flushes the subscriptions.
+func (c *Controller) shutdown() {
+	c.shutdownMu.Lock()
+	c.shuttingDown = true
+	c.shutdownMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		klog.Info("All in-flight notifications are completed")
+	case <-time.After(c.shutdownGrace):
+		klog.Warningf("Timed out waiting for in-flight notifications after %s", c.shutdownGrace)
+	}
+	c.retries.shutdown()
+
+	for name, s := range c.subscriptions {
+		if err := s.Flush(); err != nil {
+			klog.Errorf("Failed %s flush: %v", name, err)
+		}
+	}
+}
+
+func (c *Controller) isNotified(jobName string) bool {
+	c.notifiedMu.Lock()
+	defer c.notifiedMu.Unlock()
+	return c.notifiedJobs[jobName]
+}
+
+// hasWork reports whether the job may run pods. Jobs created suspended or with parallelism 0
+// have nothing to start yet.
+func hasWork(job *batchv1.Job) bool {
+	if isSuspended(job) {
+		return false
+	}
+	return job.Spec.Parallelism == nil || *job.Spec.Parallelism > 0
+}
+
+func (c *Controller) deferStart(jobName string) {
+	c.notifiedMu.Lock()
+	defer c.notifiedMu.Unlock()
+	if c.deferredStarts == nil {
+		c.deferredStarts = make(map[string]bool)
+	}
+	c.deferredStarts[jobName] = true
+}
+
+// takeDeferredStart reports whether the start of the job was deferred and forgets it.
+func (c *Controller) takeDeferredStart(jobName string) bool {
+	c.notifiedMu.Lock()
+	defer c.notifiedMu.Unlock()
+	if !c.deferredStarts[jobName] {
+		return false
+	}
+	delete(c.deferredStarts, jobName)
+	return true
+}
+
+func (c *Controller) markNotified(jobName string, notified bool) {
+	c.notifiedMu.Lock()
+	defer c.notifiedMu.Unlock()
+	c.notifiedJobs[jobName] = notified
+}
+
+func (c *Controller) forgetNotified(jobName string) {
+	c.notifiedMu.Lock()
+	defer c.notifiedMu.Unlock()
+	delete(c.notifiedJobs, jobName)
+	delete(c.deferredStarts, jobName)
+	delete(c.waitTimes, jobName)
+}
+
+// jobWaitTime returns the wait time of a job, computed the first time it is known, when the
+// job starts, and reused when it finishes.
+func (c *Controller) jobWaitTime(job *batchv1.Job) time.Duration {
+	c.notifiedMu.Lock()
+	waitTime, ok := c.waitTimes[job.Name]
+	c.notifiedMu.Unlock()
+	if ok {
+		return waitTime
+	}
+
+	waitTime = getJobWaitTime(c.kubeclientset, job)
+	if waitTime == 0 {
+		return 0
+	}
+	c.notifiedMu.Lock()
+	defer c.notifiedMu.Unlock()
+	if c.waitTimes == nil {
+		c.waitTimes = make(map[string]time.Duration)
+	}
+	c.waitTimes[job.Name] = waitTime
+	return waitTime
+}
+
+// skipFinishedBeforeStartup records jobs that already finished before the controller started
+// as notified while within the startup grace period, so the initial sync doesn't resend them.
+func (c *Controller) skipFinishedBeforeStartup(job *batchv1.Job, now time.Time) bool {
+	if c.startupGracePeriod <= 0 || now.Sub(serverStartTime) > c.startupGracePeriod {
+		return false
+	}
+	finishTime := getJobFinishTime(job)
+	if finishTime == nil || !finishTime.Time.Before(serverStartTime) {
+		return false
+	}
+	klog.Infof("Job finished before startup, skipping notification: %s", jobLogFields(job, "skipped"))
+	c.markNotified(job.Name, true)
+	return true
+}
+
+func getStartupGracePeriod() time.Duration {
+	value := os.Getenv("STARTUP_GRACE_PERIOD")
+	if value == "" {
+		return 0
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil {
+		klog.Errorf("Invalid STARTUP_GRACE_PERIOD %q, disabling it: %v", value, err)
+		return 0
+	}
+	return period
+}
+
+func getShutdownGrace() time.Duration {
+	value := os.Getenv("SHUTDOWN_GRACE")
+	if value == "" {
+		return defaultShutdownGrace
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil {
+		klog.Errorf("Invalid SHUTDOWN_GRACE %q, using default %s: %v", value, defaultShutdownGrace, err)
+		return defaultShutdownGrace
+	}
+	return grace
+}
+
+func (c *Controller) handleSucceeded(job *batchv1.Job, observedAt time.Time) {
+	c.notifySucceeded(job, observedAt, false)
+}
+
+// notifySucceeded notifies a succeeded job. A renotification is sent again as is: it doesn't
+// update the recovery state, isn't deduplicated and isn't reported to the subscriptions.
+func (c *Controller) notifySucceeded(job *batchv1.Job, observedAt time.Time, renotify bool) {
+	klog.Infof("Job succeeded: %s status=%v", jobLogFields(job, notification.SUCCESS), job.Status)
+	jobPod, err := getPodFromControllerUID(c.kubeclientset, job)
+	if err != nil {
+		klog.Errorf("Get pods failed: %s: %v", jobLogFields(job, notification.SUCCESS), err)
+		c.errorReporter.report(controllerErrorStagePods, job.Name, err)
+		return
+	}
+
+	cronJobName, err := getCronJobNameFromOwnerReferences(c.kubeclientset, job)
+
+	if err != nil {
+		klog.Errorf("Get cronjob failed: %s: %v", jobLogFields(job, notification.SUCCESS), err)
+		c.errorReporter.report(controllerErrorStageOwner, job.Name, err)
+		return
+	}
+	annotations := c.jobAnnotations(job)
+	lm := getLogMode(annotations, logModeAnnotationName)
+	logs := c.getLogFetcher().forJob(annotations)
+	jobLogStr, err := getJobLogs(logs, jobPod, cronJobName, lm)
+	if err != nil {
+		klog.Errorf("Get job logs failed: %s: %v", jobLogFields(job, notification.SUCCESS), err)
+		c.errorReporter.report(controllerErrorStageLogs, job.Name, err)
+	}
+
+	messageParam := notification.MessageTemplateParam{
+		ClusterName:    c.clusterName,
+		Event:          notification.SUCCESS,
+		JobName:        job.Name,
+		CronJobName:    cronJobName,
+		Namespace:      job.Namespace,
+		StartTime:      job.Status.StartTime,
+		CompletionTime: getJobFinishTime(job),
+		Log:            jobLogStr,
+		LogURL:         getLogURL(job, cronJobName),
+		JobDetailURL:   getJobDetailURL(job, cronJobName, c.clusterName),
+		IndexSummary:   getIndexSummary(job),
+		Annotations:    annotations,
+	}
+	// A failed job notified as succeeded for its ignored exit code has no completions to warn about.
+	if c.getJobResult(job) == jobSucceeded {
+		if messageParam.CompletionWarning = getCompletionWarning(job); messageParam.CompletionWarning != "" {
+			klog.Warningf("Suspicious success: %s succeeded=%d", jobLogFields(job, notification.SUCCESS), job.Status.Succeeded)
+		}
+	}
+	if c.resources != nil {
+		if pods, err := getJobPods(c.kubeclientset, job); err != nil {
+			klog.Errorf("Get pods failed: %s: %v", jobLogFields(job, notification.SUCCESS), err)
+		} else {
+			messageParam.ResourceWarnings = c.resources.getWarnings(pods)
+		}
+	}
+
+	notify := true
+	if c.recoveries != nil && !renotify {
+		if failedSince, ok := c.recoveries.recordSuccess(recoveryKey(job.Namespace, cronJobName, job.Name)); ok {
+			klog.Infof("Job recovered: %s failed_since=%s", jobLogFields(job, notification.SUCCESS), failedSince.UTC().Format(time.RFC3339))
+			messageParam.Recovered = true
+			messageParam.FailingFor = observedAt.Sub(failedSince).Truncate(time.Second)
+		} else {
+			notify = !c.recoveries.recoveryOnly
+		}
+	}
+
+	if !notify {
+		klog.Infof("Success not notified, the previous run succeeded too: %s", jobLogFields(job, notification.SUCCESS))
+	} else if renotify || (!c.isMutedNotification(job, notification.SUCCESS, messageParam, observedAt) && !c.isDuplicateNotification(job, notification.SUCCESS, messageParam)) {
+		c.resends.record(job, messageParam)
+		if renotify || !c.rollups.add(job, messageParam, observedAt) {
+			for name, n := range c.jobNotifications(job) {
+				result, err := n.NotifySuccess(messageParam)
+				c.recordNotifyResult(name, notification.SUCCESS, job, observedAt, result, err)
+			}
+		}
+	}
+
+	if monitoring.Enabled() && !renotify && len(c.subscriptions) > 0 {
+		jobInfo := monitoring.JobInfo{
+			CronJobName: cronJobName,
+			Name:        job.Name,
+			Namespace:   job.Namespace,
+			Annotations: annotations,
+			Duration:    getJobDuration(job),
+			WaitTime:    c.jobWaitTime(job),
+		}
+		for _, s := range c.subscriptions {
+			err = s.SuccessEvent(jobInfo)
+			if err != nil {
+				klog.Errorf("Fail event subscribe: %s: %v", jobLogFields(job, notification.SUCCESS), err)
+			}
+		}
+	}
+	klog.V(4).Infof("Job succeeded log: %v", jobLogStr)
+	c.markNotified(job.Name, isCompletedJob(c.kubeclientset, job))
+}
+
+func (c *Controller) handleFailed(job *batchv1.Job, observedAt time.Time) {
+	c.notifyFailed(job, observedAt, false)
+}
+
+// notifyFailed notifies a failed job, see notifySucceeded for renotifications.
+func (c *Controller) notifyFailed(job *batchv1.Job, observedAt time.Time, renotify bool) {
+	retrying := c.getJobResult(job) == jobRetrying
+	if hasIgnoredExitCode(c.kubeclientset, job, c.jobAnnotations(job)) {
+		if retrying {
+			klog.Infof("Job retry not notified, its exit code is ignored: %s", jobLogFields(job, notification.FAILED))
+			return
+		}
+		klog.Infof("Job failed with an ignored exit code, notified as succeeded: %s status=%v", jobLogFields(job, notification.SUCCESS), job.Status)
+		c.notifySucceeded(job, observedAt, renotify)
+		return
+	}
+	klog.Infof("Job failed: %s status=%v", jobLogFields(job, notification.FAILED), job.Status)
+	jobPod, err := getPodFromControllerUID(c.kubeclientset, job)
+	if err != nil {
+		klog.Errorf("Get pods failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		c.errorReporter.report(controllerErrorStagePods, job.Name, err)
+		return
+	}
+
+	cronJobName, err := getCronJobNameFromOwnerReferences(c.kubeclientset, job)
+	if err != nil {
+		klog.Errorf("Get cronjob failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		c.errorReporter.report(controllerErrorStageOwner, job.Name, err)
+		return
+	}
+
+	annotations := c.jobAnnotations(job)
+	lm := getLogMode(annotations, logModeAnnotationName)
+	logs := c.getLogFetcher().forJob(annotations)
+	jobLogStr, err := getJobLogs(logs, jobPod, cronJobName, lm)
+	if err != nil {
+		klog.Errorf("Get job logs failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		c.errorReporter.report(controllerErrorStageLogs, job.Name, err)
+	}
+
+	messageParam := notification.MessageTemplateParam{
+		ClusterName:    c.clusterName,
+		Event:          notification.FAILED,
+		JobName:        job.Name,
+		CronJobName:    cronJobName,
+		Namespace:      job.Namespace,
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+		Log:            jobLogStr,
+		LogURL:         getLogURL(job, cronJobName),
+		JobDetailURL:   getJobDetailURL(job, cronJobName, c.clusterName),
+		IndexSummary:   getIndexSummary(job),
+		FailedIndices:  getFailedIndices(job),
+		Annotations:    annotations,
+		FailedCount:    job.Status.Failed,
+	}
+	if c.attachJobYAML {
+		messageParam.JobYAML = getJobYAML(job)
+	}
+	if messageParam.PodLogs, err = getFailedPodLogs(c.kubeclientset, logs, job, cronJobName, lm); err != nil {
+		klog.Errorf("Get failed pod logs failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		c.errorReporter.report(controllerErrorStageLogs, job.Name, err)
+	}
+	if job.Spec.BackoffLimit != nil {
+		messageParam.BackoffLimit = *job.Spec.BackoffLimit
+	}
+	var failureReason string
+	if failedPods, err := getFailedPods(c.kubeclientset, job); err != nil {
+		klog.Errorf("Get failed pods failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+	} else {
+		if container, memoryLimit, ok := getOOMKilledContainer(failedPods); ok {
+			klog.Infof("Job OOMKilled: %s container=%s", jobLogFields(job, notification.FAILED), container)
+			messageParam.OOMKilledContainer = container
+			messageParam.MemoryLimit = memoryLimit
+			failureReason = failureReasonOOMKilled
+		}
+		messageParam.NodeName, messageParam.Zone = getPodPlacement(c.kubeclientset, failedPods)
+		messageParam.ExitCode = getExitCode(failedPods)
+	}
+	if c.eventCount > 0 {
+		if pods, err := getJobPods(c.kubeclientset, job); err != nil {
+			klog.Errorf("Get pods failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		} else if messageParam.Events, err = getJobEvents(c.kubeclientset, job, pods, c.eventCount); err != nil {
+			klog.Errorf("Get events failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		}
+	}
+	if isDeadlineExceeded(job) {
+		klog.Infof("Job timed out: %s", jobLogFields(job, notification.FAILED))
+		messageParam.TimedOut = true
+		if job.Spec.ActiveDeadlineSeconds != nil {
+			messageParam.ActiveDeadlineSeconds = *job.Spec.ActiveDeadlineSeconds
+		}
+		// The deadline is what terminated the job, even when a pod was OOMKilled earlier.
+		failureReason = failureReasonDeadlineExceeded
+	}
+	messageParam.Reason = getFailedReason(job, messageParam.OOMKilledContainer != "")
+	if renotify || (!c.isMutedNotification(job, notification.FAILED, messageParam, observedAt) && !c.isDuplicateNotification(job, notification.FAILED, messageParam)) {
+		c.resends.record(job, messageParam)
+		// Retry warnings are notified right away, only the final results are batched.
+		if retrying || renotify || !c.rollups.add(job, messageParam, observedAt) {
+			for name, n := range c.jobNotifications(job) {
+				result, err := n.NotifyFailed(messageParam)
+				c.recordNotifyResult(name, notification.FAILED, job, observedAt, result, err)
+			}
+		}
+	}
+	if monitoring.Enabled() && !retrying && !renotify && len(c.subscriptions) > 0 {
+		jobInfo := monitoring.JobInfo{
+			CronJobName:   cronJobName,
+			Name:          job.Name,
+			Namespace:     job.Namespace,
+			Annotations:   annotations,
+			Duration:      getJobDuration(job),
+			WaitTime:      c.jobWaitTime(job),
+			FailureReason: failureReason,
+		}
+		for _, s := range c.subscriptions {
+			err = s.FailEvent(jobInfo)
+			if err != nil {
+				klog.Errorf("Fail event subscribe: %s: %v", jobLogFields(job, notification.FAILED), err)
+			}
+		}
+	}
+	// A retry warning leaves the job to be notified again with its final result.
+	if !retrying {
+		c.markNotified(job.Name, isCompletedJob(c.kubeclientset, job))
+		if c.recoveries != nil && !renotify {
+			c.recoveries.recordFailure(recoveryKey(job.Namespace, cronJobName, job.Name), observedAt)
+		}
+	}
+}
+
+type suspendTransition int
+
+const (
+	noSuspendTransition suspendTransition = iota
+	jobSuspended
+	jobResumed
+)
+
+func isSuspended(job *batchv1.Job) bool {
+	return job.Spec.Suspend != nil && *job.Spec.Suspend
+}
+
+func getSuspendTransition(oldJob, newJob *batchv1.Job) suspendTransition {
+	oldSuspended := isSuspended(oldJob)
+	newSuspended := isSuspended(newJob)
+	switch {
+	case !oldSuspended && newSuspended:
+		return jobSuspended
+	case oldSuspended && !newSuspended:
+		return jobResumed
+	default:
+		return noSuspendTransition
+	}
+}
+
+// notifySuspendTransition sends a suspended/resumed notification when spec.suspend changed
+// between the two versions of the job, and reports whether it changed. NOTIFY_ON_SUSPEND=false
+// disables the notifications for every notifier.
+func (c *Controller) notifySuspendTransition(oldJob, newJob *batchv1.Job, observedAt time.Time) bool {
+	transition := getSuspendTransition(oldJob, newJob)
+	if transition == noSuspendTransition {
+		return false
+	}
+	if os.Getenv("NOTIFY_ON_SUSPEND") == "false" {
+		klog.V(4).Infof("Suspend notifications are disabled, skipping: %s", jobLogFields(newJob, "suspend"))
+		return true
+	}
+
+	cronJobName, err := getCronJobNameFromOwnerReferences(c.kubeclientset, newJob)
+	if err != nil {
+		klog.Errorf("Get cronjob failed: %s: %v", jobLogFields(newJob, "suspend"), err)
+		c.errorReporter.report(controllerErrorStageOwner, newJob.Name, err)
+	}
+
+	messageParam := notification.MessageTemplateParam{
+		ClusterName:  c.clusterName,
+		JobName:      newJob.Name,
+		CronJobName:  cronJobName,
+		Namespace:    newJob.Namespace,
+		StartTime:    newJob.Status.StartTime,
+		Annotations:  c.jobAnnotations(newJob),
+		JobDetailURL: getJobDetailURL(newJob, cronJobName, c.clusterName),
+	}
+	event := notification.RESUMED
+	if transition == jobSuspended {
+		event = notification.SUSPENDED
+	}
+	messageParam.Event = event
+	if c.isMutedNotification(newJob, event, messageParam, observedAt) || c.isDuplicateNotification(newJob, event, messageParam) {
+		return true
+	}
+	c.resends.record(newJob, messageParam)
+	for name, n := range c.jobNotifications(newJob) {
+		var result notification.NotifyResult
+		if transition == jobSuspended {
+			klog.Infof("Job suspended: %s", jobLogFields(newJob, notification.SUSPENDED))
+			result, err = n.NotifySuspended(messageParam)
+			c.recordNotifyResult(name, notification.SUSPENDED, newJob, observedAt, result, err)
+		} else {
+			klog.Infof("Job resumed: %s", jobLogFields(newJob, notification.RESUMED))
+			result, err = n.NotifyResumed(messageParam)
+			c.recordNotifyResult(name, notification.RESUMED, newJob, observedAt, result, err)
+		}
+	}
+	return true
+}
+
+// recordNotifyResult logs the outcome of a notification so it can be correlated across backends
+// and records the latency of sent notifications since the job transition was observed.
+func (c *Controller) recordNotifyResult(name string, event string, job *batchv1.Job, observedAt time.Time, result notification.NotifyResult, err error) {
+	switch {
+	case err != nil:
+		klog.Errorf("Failed notification: %s notifier=%s: %v", jobLogFields(job, event), name, err)
+	case result.SkippedReason == notification.SkippedCircuitOpen:
+		// The paused notifier already warned, one line per event would only add noise.
+		klog.V(4).Infof("Skipped notification: %s notifier=%s reason=%s", jobLogFields(job, event), name, result.SkippedReason)
+	case result.SkippedReason != "":
+		klog.Infof("Skipped notification: %s notifier=%s reason=%s", jobLogFields(job, event), name, result.SkippedReason)
+	default:
+		klog.Infof("Sent notification: %s notifier=%s channel=%s ts=%s permalink=%s",
+			jobLogFields(job, event), name, result.Channel, result.Timestamp, result.Permalink)
+		latency := time.Since(observedAt)
+		notificationLatency.WithLabelValues(name, event).Observe(latency.Seconds())
+		if monitoring.Enabled() {
+			for subscriptionName, s := range c.subscriptions {
+				if err := s.NotificationEvent(name, event, latency); err != nil {
+					klog.Errorf("Failed %s notification subscribe: %s: %v", subscriptionName, jobLogFields(job, event), err)
+				}
+			}
+		}
+	}
+}
+
+// jobLogFields formats the fields identifying a job event in log lines.
+func jobLogFields(job *batchv1.Job, event string) string {
+	return fmt.Sprintf("job=%s namespace=%s event=%s", job.Name, job.Namespace, event)
+}
+
+// isSkippedJob reports whether the job is a test or ephemeral job that never notifies:
+// jobs annotated with job-notify-controller/skip: "true", labeled ci.test/ephemeral=true
+// or created as a Helm test hook.
+func isSkippedJob(job *batchv1.Job) bool {
+	if job.Annotations[skipAnnotationName] == "true" || job.Spec.Template.Annotations[skipAnnotationName] == "true" {
+		return true
+	}
+	if job.Labels[ephemeralLabelName] == "true" {
+		return true
+	}
+	for _, hook := range strings.Split(job.Annotations[helmHookAnnotation], ",") {
+		if strings.TrimSpace(hook) == "test" || strings.TrimSpace(hook) == "test-success" {
+			return true
+		}
+	}
+	return false
+}
+
+// getJobDuration returns the execution time of a finished job. Failed jobs have no
+// completionTime, so the transition time of the Failed condition is used instead.
+func getJobDuration(job *batchv1.Job) time.Duration {
+	if job.Status.StartTime == nil {
+		return 0
+	}
+	finishTime := getJobFinishTime(job)
+	if finishTime == nil {
+		return 0
+	}
+	return finishTime.Sub(job.Status.StartTime.Time)
+}
+
+// getJobWaitTime returns the scheduling latency of a job, the time from its creation to
+// the start of its first pod. It is zero when no pod has a start time.
+func getJobWaitTime(kubeclientset kubernetes.Interface, job *batchv1.Job) time.Duration {
+	labelSelector := metav1.LabelSelector{MatchLabels: map[string]string{searchLabel: string(job.UID)}}
+	jobPodList, err := kubeclientset.CoreV1().Pods(job.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
+	})
+	if err != nil {
+		klog.Errorf("Get pods failed: %v", err)
+		return 0
+	}
+
+	var firstStart *metav1.Time
+	for _, pod := range jobPodList.Items {
+		if pod.Status.StartTime == nil {
+			continue
+		}
+		if firstStart == nil || pod.Status.StartTime.Before(firstStart) {
+			firstStart = pod.Status.StartTime
+		}
+	}
+	if firstStart == nil || job.CreationTimestamp.IsZero() || firstStart.Before(&job.CreationTimestamp) {
+		return 0
+	}
+	return firstStart.Sub(job.CreationTimestamp.Time)
+}
+
+// getJobFinishTime returns when the job succeeded or failed, nil while it is still running.
+// Failed jobs have no completionTime, the Failed condition is used instead.
+func getJobFinishTime(job *batchv1.Job) *metav1.Time {
+	if job.Status.CompletionTime != nil {
+		return job.Status.CompletionTime
+	}
+	for i, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i].LastTransitionTime
+		}
+	}
+	return nil
+}
+
+// isDeadlineExceeded reports whether the job failed by exceeding its activeDeadlineSeconds.
+func isDeadlineExceeded(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue && c.Reason == batchv1.JobReasonDeadlineExceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// getFailedReason returns the reason of the failed condition of the job, e.g.
+// BackoffLimitExceeded, or OOMKilled when a pod was OOMKilled before the job failed.
+// It is empty for a job that is still retrying.
+func getFailedReason(job *batchv1.Job, oomKilled bool) string {
+	if isDeadlineExceeded(job) {
+		return batchv1.JobReasonDeadlineExceeded
+	}
+	if oomKilled {
+		return oomKilledReason
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return c.Reason
+		}
+	}
+	return ""
+}
+
 func isCompletedJob(kubeclientset kubernetes.Interface, job *batchv1.Job) bool {
 
 	if job.Status.Succeeded == intTrue {
@@ -310,6 +1062,155 @@ func getPodFromControllerUID(kubeclientset kubernetes.Interface, job *batchv1.Jo
 	return jobPod, nil
 }
 
+func getJobPods(kubeclientset kubernetes.Interface, job *batchv1.Job) ([]corev1.Pod, error) {
+	labelSelector := metav1.LabelSelector{MatchLabels: map[string]string{searchLabel: string(job.UID)}}
+	jobPodList, err := kubeclientset.CoreV1().Pods(job.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobPodList.Items, nil
+}
+
+func getFailedPods(kubeclientset kubernetes.Interface, job *batchv1.Job) ([]corev1.Pod, error) {
+	pods, err := getJobPods(kubeclientset, job)
+	if err != nil {
+		return nil, err
+	}
+	var failedPods []corev1.Pod
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodFailed {
+			failedPods = append(failedPods, pod)
+		}
+	}
+	return failedPods, nil
+}
+
+// getOOMKilledContainer returns the first failed container terminated with OOMKilled and its memory limit.
+func getOOMKilledContainer(pods []corev1.Pod) (containerName string, memoryLimit string, ok bool) {
+	for _, pod := range pods {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			terminated := status.State.Terminated
+			if terminated == nil {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated == nil || terminated.Reason != oomKilledReason {
+				continue
+			}
+			containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+			for _, container := range containers {
+				if container.Name != status.Name {
+					continue
+				}
+				if limit, found := container.Resources.Limits[corev1.ResourceMemory]; found {
+					memoryLimit = limit.String()
+				}
+			}
+			return status.Name, memoryLimit, true
+		}
+	}
+	return "", "", false
+}
+
+// getExitCode returns the exit code of the first container that failed, 0 when none is known.
+func getExitCode(pods []corev1.Pod) int32 {
+	for _, pod := range pods {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			terminated := status.State.Terminated
+			if terminated == nil {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated != nil && terminated.ExitCode != 0 {
+				return terminated.ExitCode
+			}
+		}
+	}
+	return 0
+}
+
+// getPodPlacement returns the node the last scheduled pod ran on and the zone of that node.
+// The zone is empty when the node has no zone label or can't be read.
+func getPodPlacement(kubeclientset kubernetes.Interface, pods []corev1.Pod) (nodeName string, zone string) {
+	for i := len(pods) - 1; i >= 0; i-- {
+		if pods[i].Spec.NodeName != "" {
+			nodeName = pods[i].Spec.NodeName
+			break
+		}
+	}
+	if nodeName == "" {
+		return "", ""
+	}
+	node, err := kubeclientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("Get node %s failed: %v", nodeName, err)
+		return nodeName, ""
+	}
+	zone = node.Labels[corev1.LabelTopologyZone]
+	if zone == "" {
+		zone = node.Labels[corev1.LabelFailureDomainBetaZone]
+	}
+	return nodeName, zone
+}
+
+// getFailedPodLogs returns the logs of every failed pod of the job, so that parallel
+// jobs can attach one log per pod.
+// The returned error is the first log fetch that failed, its message is used as the log.
+func getFailedPodLogs(kubeclientset kubernetes.Interface, logs *logFetcher, job *batchv1.Job, cronJobName string, mode logMode) ([]notification.PodLog, error) {
+	if logs == nil {
+		return nil, nil
+	}
+	failedPods, err := getFailedPods(kubeclientset, job)
+	if err != nil {
+		klog.Errorf("Get failed pods failed: %v", err)
+		return nil, nil
+	}
+	var logErr error
+	podLogs := make([]notification.PodLog, 0, len(failedPods))
+	for _, pod := range failedPods {
+		log, err := getJobLogs(logs, pod, cronJobName, mode)
+		if err != nil && logErr == nil {
+			logErr = err
+		}
+		podLogs = append(podLogs, notification.PodLog{
+			PodName: pod.Name,
+			Log:     log,
+		})
+	}
+	return podLogs, logErr
+}
+
+// getJobYAML serializes the job for debugging. Literal env values may hold credentials,
+// so they are redacted; references to secrets and config maps are kept. The managed fields
+// and the last applied configuration are dropped, the latter holds the original env values.
+func getJobYAML(job *batchv1.Job) string {
+	redacted := job.DeepCopy()
+	redacted.ManagedFields = nil
+	delete(redacted.Annotations, corev1.LastAppliedConfigAnnotation)
+	delete(redacted.Spec.Template.Annotations, corev1.LastAppliedConfigAnnotation)
+	redacted.APIVersion = batchv1.SchemeGroupVersion.String()
+	redacted.Kind = "Job"
+	podSpec := &redacted.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			for j := range containers[i].Env {
+				if containers[i].Env[j].Value != "" {
+					containers[i].Env[j].Value = redactedValue
+				}
+			}
+		}
+	}
+
+	b, err := yaml.Marshal(redacted)
+	if err != nil {
+		klog.Errorf("Marshal job failed: %v", err)
+		return ""
+	}
+	return string(b)
+}
+
 func getCronJobNameFromOwnerReferences(kubeclientset kubernetes.Interface, job *batchv1.Job) (cronJobName string, err error) {
 
 	if ownerReferences, ok := funk.Filter(job.OwnerReferences,
@@ -363,47 +1264,41 @@ func getLogMode(annotations map[string]string, annotationName string) logMode {
 	}
 }
 
-func getJobLogs(clientset kubernetes.Interface, pod corev1.Pod, cronJobName string, mode logMode) string {
+// getJobLogs returns the logs of the pod. When fetching them fails, the error message
+// is returned in place of the logs along with the error. No logs are returned when
+// fetching them is disabled.
+func getJobLogs(logs *logFetcher, pod corev1.Pod, cronJobName string, mode logMode) (string, error) {
+	if logs == nil {
+		return "", nil
+	}
 	switch mode {
 	case podContainers:
 		if len(pod.Spec.Containers) == 1 {
-			return getPodLogs(clientset, pod, pod.Spec.Containers[0].Name)
+			return logs.fetch(pod, pod.Spec.Containers[0].Name)
 		}
 
+		var logErr error
 		b := strings.Builder{}
 		for _, c := range pod.Spec.Containers {
-			b.WriteString(fmt.Sprintf("Container %s logs:\r\n%s\r\n", c.Name, getPodLogs(clientset, pod, c.Name)))
+			log, err := logs.fetch(pod, c.Name)
+			if err != nil && logErr == nil {
+				logErr = err
+			}
+			b.WriteString(fmt.Sprintf("Container %s logs:\r\n%s\r\n", c.Name, log))
 		}
-		return b.String()
+		return b.String(), logErr
 	case podOnly:
-		return getPodLogs(clientset, pod, "")
+		return logs.fetch(pod, "")
 	default:
-		return getPodLogs(clientset, pod, cronJobName)
+		return logs.fetch(pod, cronJobName)
 	}
 }
 
-func getPodLogs(clientset kubernetes.Interface, pod corev1.Pod, containerName string) string {
-	req := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: containerName})
-	podLogs, err := req.Stream(context.TODO())
-	if err != nil {
-		return err.Error()
-	}
-	buf := new(bytes.Buffer)
-	_, err = io.Copy(buf, podLogs)
-	if err != nil {
-		return err.Error()
-	}
-	str := buf.String()
-	err = podLogs.Close()
-
-	if err != nil {
-		return err.Error()
-	}
-	return str
-}
-
-func waitForPodRunning(clientset kubernetes.Interface, pod corev1.Pod) error {
+// waitForPodRunning waits for the pod to leave the pending phase. onStuck is called once when
+// a container of the pod is stuck in a waiting state for stuckThreshold, 0 never calls it.
+func waitForPodRunning(clientset kubernetes.Interface, pod corev1.Pod, stuckThreshold time.Duration, onStuck func(pod *corev1.Pod, waiting podWaiting)) error {
 	pollInterval := 10 * time.Second
+	stuckNotified := false
 
 	timeout := 20 * time.Minute
 	deadline := time.Now().Add(timeout)
@@ -422,6 +1317,13 @@ func waitForPodRunning(clientset kubernetes.Interface, pod corev1.Pod) error {
 			return nil
 		}
 
+		if stuckThreshold > 0 && !stuckNotified {
+			if waiting, ok := getStuckWaiting(pod, time.Now(), stuckThreshold); ok {
+				onStuck(pod, waiting)
+				stuckNotified = true
+			}
+		}
+
 		time.Sleep(pollInterval)
 	}
 }
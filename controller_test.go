@@ -1,11 +1,18 @@
 package main
 
 import (
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
@@ -307,7 +314,7 @@ func TestGetJobLogs(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			if got := getJobLogs(tt.args.clientset, tt.args.pod, tt.args.cronJobName, tt.args.mode); got != tt.want {
+			if got, _ := getJobLogs(newLogFetcher(clientsetPodLogs{clientset: tt.args.clientset}), tt.args.pod, tt.args.cronJobName, tt.args.mode); got != tt.want {
 				t.Errorf("getJobLogs() = %v, want %v", got, tt.want)
 			}
 		})
@@ -319,3 +326,1047 @@ func addListPodsReactor(fakeClient *fake.Clientset, obj runtime.Object) *fake.Cl
 	})
 	return fakeClient
 }
+
+type blockingNotification struct {
+	release chan struct{}
+	// started counts the notifications waiting for release.
+	started atomic.Int32
+	sent    atomic.Int32
+}
+
+func (n *blockingNotification) NotifyStart(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	n.started.Add(1)
+	<-n.release
+	n.sent.Add(1)
+	return notification.NotifyResult{}, nil
+}
+
+func (n *blockingNotification) NotifySuccess(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.NotifyStart(messageParam)
+}
+
+func (n *blockingNotification) NotifyFailed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.NotifyStart(messageParam)
+}
+
+func (n *blockingNotification) NotifySuspended(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.NotifyStart(messageParam)
+}
+
+func (n *blockingNotification) NotifyResumed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.NotifyStart(messageParam)
+}
+
+func (n *blockingNotification) NotifySpecChanged(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.NotifyStart(messageParam)
+}
+
+type fakeSubscription struct {
+	flushed          atomic.Int32
+	watchErrors      []string
+	controllerErrors []string
+	failed           []monitoring.JobInfo
+	notifications    []string
+}
+
+func (s *fakeSubscription) StartEvent(jobInfo monitoring.JobInfo) (err error)   { return nil }
+func (s *fakeSubscription) SuccessEvent(jobInfo monitoring.JobInfo) (err error) { return nil }
+func (s *fakeSubscription) FailEvent(jobInfo monitoring.JobInfo) (err error) {
+	s.failed = append(s.failed, jobInfo)
+	return nil
+}
+func (s *fakeSubscription) WatchErrorEvent(reason string) (err error) {
+	s.watchErrors = append(s.watchErrors, reason)
+	return nil
+}
+func (s *fakeSubscription) ControllerErrorEvent(stage string) (err error) {
+	s.controllerErrors = append(s.controllerErrors, stage)
+	return nil
+}
+func (s *fakeSubscription) NotificationEvent(notifier string, event string, latency time.Duration) (err error) {
+	s.notifications = append(s.notifications, notifier+"/"+event)
+	return nil
+}
+func (s *fakeSubscription) Flush() (err error) {
+	s.flushed.Add(1)
+	return nil
+}
+
+func newShutdownTestController(n notification.Notification, sub monitoring.Subscription, grace time.Duration) *Controller {
+	runningPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	return &Controller{
+		kubeclientset: fake.NewSimpleClientset(runningPod),
+		notifications: map[string]notification.Notification{"blocking": n},
+		subscriptions: map[string]monitoring.Subscription{"fake": sub},
+		notifiedJobs:  make(map[string]bool),
+		shutdownGrace: grace,
+	}
+}
+
+func newShutdownTestJob(name string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", UID: "test", CreationTimestamp: metav1.Now()},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(1)},
+	}
+}
+
+func TestShutdownDrainsInflightNotifications(t *testing.T) {
+	n := &blockingNotification{release: make(chan struct{})}
+	sub := &fakeSubscription{}
+	c := newShutdownTestController(n, sub, 5*time.Second)
+
+	for _, name := range []string{"job-a", "job-b", "job-c"} {
+		go c.handleAdd(newShutdownTestJob(name))
+	}
+	assert.Eventually(t, func() bool { return n.started.Load() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		c.shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("shutdown completed before in-flight notifications were sent")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Events arriving while shutting down are dropped without being notified.
+	c.handleAdd(newShutdownTestJob("job-d"))
+	c.handleUpdate(newShutdownTestJob("job-a"), newShutdownTestJob("job-a"))
+	assert.Equal(t, int32(3), n.started.Load())
+
+	close(n.release)
+	<-done
+
+	assert.Equal(t, int32(3), n.sent.Load())
+	assert.Equal(t, int32(1), sub.flushed.Load())
+}
+
+func TestShutdownGivesUpAfterGrace(t *testing.T) {
+	n := &blockingNotification{release: make(chan struct{})}
+	defer close(n.release)
+	sub := &fakeSubscription{}
+	c := newShutdownTestController(n, sub, 50*time.Millisecond)
+
+	go c.handleAdd(newShutdownTestJob("the-job"))
+	assert.Eventually(t, func() bool { return n.started.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	c.shutdown()
+
+	assert.Equal(t, int32(0), n.sent.Load())
+	assert.Equal(t, int32(1), sub.flushed.Load())
+}
+
+func TestGetShutdownGrace(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{"default", "", defaultShutdownGrace},
+		{"configured", "10s", 10 * time.Second},
+		{"invalid", "ten seconds", defaultShutdownGrace},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("SHUTDOWN_GRACE", test.value)
+			assert.Equal(t, test.expected, getShutdownGrace())
+		})
+	}
+}
+
+type recordingNotification struct {
+	events []string
+	params []notification.MessageTemplateParam
+}
+
+func (n *recordingNotification) record(event string, messageParam notification.MessageTemplateParam) (notification.NotifyResult, error) {
+	n.events = append(n.events, event)
+	n.params = append(n.params, messageParam)
+	return notification.NotifyResult{Channel: "recording"}, nil
+}
+
+func (n *recordingNotification) NotifyStart(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.record("start", messageParam)
+}
+
+func (n *recordingNotification) NotifySuccess(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.record("success", messageParam)
+}
+
+func (n *recordingNotification) NotifyFailed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.record("failed", messageParam)
+}
+
+func (n *recordingNotification) NotifySuspended(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.record("suspended", messageParam)
+}
+
+func (n *recordingNotification) NotifyResumed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.record("resumed", messageParam)
+}
+
+func (n *recordingNotification) NotifySpecChanged(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return n.record("spec_changed", messageParam)
+}
+
+func TestNotifySuspendTransition(t *testing.T) {
+	newJob := func(suspend *bool) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"},
+			Spec:       batchv1.JobSpec{Suspend: suspend},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		oldJob   *batchv1.Job
+		newJob   *batchv1.Job
+		handled  bool
+		expected []string
+	}{
+		{"suspended", newJob(nil), newJob(utilpointer.Bool(true)), true, []string{"suspended"}},
+		{"resumed", newJob(utilpointer.Bool(true)), newJob(utilpointer.Bool(false)), true, []string{"resumed"}},
+		{"resumed by unsetting", newJob(utilpointer.Bool(true)), newJob(nil), true, []string{"resumed"}},
+		{"still running", newJob(utilpointer.Bool(false)), newJob(nil), false, nil},
+		{"still suspended", newJob(utilpointer.Bool(true)), newJob(utilpointer.Bool(true)), false, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(),
+				notifications: map[string]notification.Notification{"recording": n},
+			}
+
+			handled := c.notifySuspendTransition(test.oldJob, test.newJob, time.Now())
+
+			assert.Equal(t, test.handled, handled)
+			assert.Equal(t, test.expected, n.events)
+			for _, p := range n.params {
+				assert.Equal(t, "the-job", p.JobName)
+				assert.Equal(t, "test-ns", p.Namespace)
+			}
+		})
+	}
+}
+
+func TestNotifySuspendTransitionDisabled(t *testing.T) {
+	t.Setenv("NOTIFY_ON_SUSPEND", "false")
+
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(),
+		notifications: map[string]notification.Notification{"recording": n},
+	}
+	oldJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"}}
+	newJob := oldJob.DeepCopy()
+	newJob.Spec.Suspend = utilpointer.Bool(true)
+
+	// The transition is still handled so that it isn't taken for a job result.
+	assert.True(t, c.notifySuspendTransition(oldJob, newJob, time.Now()))
+	assert.Empty(t, n.events)
+}
+
+func TestGetFailedPodLogs(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-ns",
+				Labels:    map[string]string{searchLabel: "test"},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"}}
+	clientset := fake.NewSimpleClientset(
+		pod("the-job-a", corev1.PodFailed),
+		pod("the-job-b", corev1.PodSucceeded),
+		pod("the-job-c", corev1.PodFailed),
+	)
+
+	actual, err := getFailedPodLogs(clientset, newLogFetcher(clientsetPodLogs{clientset: clientset}), job, "", podOnly)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, []notification.PodLog{
+		{PodName: "the-job-a", Log: "fake logs"},
+		{PodName: "the-job-c", Log: "fake logs"},
+	}, actual)
+}
+
+func TestGetJobYAML(t *testing.T) {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "the-job",
+			Namespace:     "test-ns",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: `{"spec":{"template":{"spec":{"containers":[{"env":[{"name":"PASSWORD","value":"hunter2"}]}]}}}}`,
+				"team":                             "data",
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						{Name: "init", Env: []corev1.EnvVar{{Name: "TOKEN", Value: "init-secret"}}},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  "main",
+							Image: "busybox:1.36",
+							Env: []corev1.EnvVar{
+								{Name: "PASSWORD", Value: "hunter2"},
+								{Name: "FROM_SECRET", ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "creds"},
+										Key:                  "password",
+									},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+		Status: batchv1.JobStatus{Failed: 1},
+	}
+
+	actual := getJobYAML(job)
+
+	assert.Contains(t, actual, "kind: Job")
+	assert.Contains(t, actual, "name: the-job")
+	assert.Contains(t, actual, "image: busybox:1.36")
+	assert.Contains(t, actual, "failed: 1")
+	assert.Contains(t, actual, "name: creds")
+	assert.NotContains(t, actual, "hunter2")
+	assert.NotContains(t, actual, "init-secret")
+	assert.NotContains(t, actual, "managedFields")
+	assert.NotContains(t, actual, corev1.LastAppliedConfigAnnotation)
+	assert.Contains(t, actual, "team: data")
+	assert.Equal(t, "hunter2", job.Spec.Template.Spec.Containers[0].Env[0].Value, "the original job must not be modified")
+	assert.Contains(t, job.Annotations, corev1.LastAppliedConfigAnnotation, "the original job must not be modified")
+}
+
+func TestHandleFailedJobYAML(t *testing.T) {
+	tests := []struct {
+		name   string
+		attach bool
+	}{
+		{"attached", true},
+		{"not attached", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failedPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+				Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+				Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+				Status:     batchv1.JobStatus{Failed: 1},
+			}
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(failedPod),
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+				attachJobYAML: test.attach,
+			}
+
+			c.handleFailed(job, time.Now())
+
+			assert.Equal(t, []string{"failed"}, n.events)
+			if test.attach {
+				assert.Contains(t, n.params[0].JobYAML, "name: the-job")
+			} else {
+				assert.Empty(t, n.params[0].JobYAML)
+			}
+		})
+	}
+}
+
+func TestHandleFailedDeadlineExceeded(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "the-job-abcde",
+			Namespace: "test-ns",
+			Labels:    map[string]string{searchLabel: "test"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	newJob := func(reason string) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+			Spec: batchv1.JobSpec{
+				BackoffLimit:          utilpointer.Int32(0),
+				ActiveDeadlineSeconds: utilpointer.Int64(600),
+			},
+			Status: batchv1.JobStatus{
+				Failed: 1,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: reason},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		job              *batchv1.Job
+		expectedTimedOut bool
+		expectedDeadline int64
+		expectedReason   string
+	}{
+		{"deadline exceeded", newJob(batchv1.JobReasonDeadlineExceeded), true, 600, "deadline_exceeded"},
+		{"backoff limit exceeded", newJob(batchv1.JobReasonBackoffLimitExceeded), false, 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &recordingNotification{}
+			sub := &fakeSubscription{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(failedPod.DeepCopy()),
+				notifications: map[string]notification.Notification{"recording": n},
+				subscriptions: map[string]monitoring.Subscription{"fake": sub},
+				notifiedJobs:  make(map[string]bool),
+			}
+
+			c.handleFailed(test.job, time.Now())
+
+			assert.Equal(t, []string{"failed"}, n.events)
+			assert.Equal(t, test.expectedTimedOut, n.params[0].TimedOut)
+			assert.Equal(t, test.expectedDeadline, n.params[0].ActiveDeadlineSeconds)
+			assert.Len(t, sub.failed, 1)
+			assert.Equal(t, test.expectedReason, sub.failed[0].FailureReason)
+		})
+	}
+}
+
+func TestGetJobDuration(t *testing.T) {
+	startTime := metav1.NewTime(time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC))
+	completionTime := metav1.NewTime(startTime.Add(90 * time.Second))
+	tests := []struct {
+		name     string
+		status   batchv1.JobStatus
+		expected time.Duration
+	}{
+		{"not started", batchv1.JobStatus{}, 0},
+		{"running", batchv1.JobStatus{StartTime: &startTime}, 0},
+		{"succeeded", batchv1.JobStatus{StartTime: &startTime, CompletionTime: &completionTime}, 90 * time.Second},
+		{
+			"failed",
+			batchv1.JobStatus{
+				StartTime: &startTime,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: completionTime},
+				},
+			},
+			90 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getJobDuration(&batchv1.Job{Status: test.status}))
+		})
+	}
+}
+
+func TestGetOOMKilledContainer(t *testing.T) {
+	oomKilled := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}
+	errored := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}
+	newPod := func(state corev1.ContainerState, limits corev1.ResourceList) corev1.Pod {
+		return corev1.Pod{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "sidecar"},
+					{Name: "worker", Resources: corev1.ResourceRequirements{Limits: limits}},
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodFailed,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "sidecar", State: errored},
+					{Name: "worker", State: state},
+				},
+			},
+		}
+	}
+	memoryLimit := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}
+
+	tests := []struct {
+		name              string
+		pods              []corev1.Pod
+		expectedContainer string
+		expectedLimit     string
+		expectedOK        bool
+	}{
+		{"oomkilled with limit", []corev1.Pod{newPod(oomKilled, memoryLimit)}, "worker", "256Mi", true},
+		{"oomkilled without limit", []corev1.Pod{newPod(oomKilled, nil)}, "worker", "", true},
+		{"oomkilled restarted", []corev1.Pod{{
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "worker", LastTerminationState: oomKilled}}},
+		}}, "worker", "", true},
+		{"other failure", []corev1.Pod{newPod(errored, memoryLimit)}, "", "", false},
+		{"no pods", nil, "", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container, limit, ok := getOOMKilledContainer(test.pods)
+			assert.Equal(t, test.expectedContainer, container)
+			assert.Equal(t, test.expectedLimit, limit)
+			assert.Equal(t, test.expectedOK, ok)
+		})
+	}
+}
+
+func TestHandleFailedOOMKilled(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "the-job-abcde",
+			Namespace: "test-ns",
+			Labels:    map[string]string{searchLabel: "test"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "worker",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "worker",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+			}},
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+		Status:     batchv1.JobStatus{Failed: 1},
+	}
+
+	n := &recordingNotification{}
+	sub := &fakeSubscription{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod),
+		notifications: map[string]notification.Notification{"recording": n},
+		subscriptions: map[string]monitoring.Subscription{"fake": sub},
+		notifiedJobs:  make(map[string]bool),
+	}
+
+	c.handleFailed(job, time.Now())
+
+	assert.Equal(t, []string{"failed"}, n.events)
+	assert.Equal(t, "worker", n.params[0].OOMKilledContainer)
+	assert.Equal(t, "1Gi", n.params[0].MemoryLimit)
+	assert.Len(t, sub.failed, 1)
+	assert.Equal(t, "oomkilled", sub.failed[0].FailureReason)
+}
+
+func TestSkipFinishedBeforeStartup(t *testing.T) {
+	startTime := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+	defer func(original time.Time) { serverStartTime = original }(serverStartTime)
+	serverStartTime = startTime
+
+	completedAt := func(at time.Time) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-job"},
+			Status:     batchv1.JobStatus{CompletionTime: &metav1.Time{Time: at}},
+		}
+	}
+	failedAt := func(at time.Time) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-job"},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Time{Time: at}},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		grace    time.Duration
+		job      *batchv1.Job
+		now      time.Time
+		expected bool
+	}{
+		{"completed before startup", time.Minute, completedAt(startTime.Add(-time.Second)), startTime.Add(10 * time.Second), true},
+		{"failed before startup", time.Minute, failedAt(startTime.Add(-time.Second)), startTime.Add(10 * time.Second), true},
+		{"completed after startup", time.Minute, completedAt(startTime.Add(time.Second)), startTime.Add(10 * time.Second), false},
+		{"running", time.Minute, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job"}}, startTime.Add(10 * time.Second), false},
+		{"after grace period", time.Minute, completedAt(startTime.Add(-time.Second)), startTime.Add(2 * time.Minute), false},
+		{"grace period disabled", 0, completedAt(startTime.Add(-time.Second)), startTime.Add(10 * time.Second), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Controller{
+				notifiedJobs:       make(map[string]bool),
+				startupGracePeriod: test.grace,
+			}
+
+			assert.Equal(t, test.expected, c.skipFinishedBeforeStartup(test.job, test.now))
+			assert.Equal(t, test.expected, c.notifiedJobs["the-job"])
+		})
+	}
+}
+
+func TestHandleUpdateCreatedBeforeStartup(t *testing.T) {
+	defer func(original time.Time) { serverStartTime = original }(serverStartTime)
+	serverStartTime = time.Now().Add(-10 * time.Second)
+
+	failedAt := func(at time.Time) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "the-job",
+				Namespace:         "test-ns",
+				UID:               "test",
+				CreationTimestamp: metav1.NewTime(serverStartTime.Add(-time.Hour)),
+			},
+			Spec: batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+			Status: batchv1.JobStatus{
+				Failed: 1,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(at)},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		grace            time.Duration
+		job              *batchv1.Job
+		expectedEvents   []string
+		expectedNotified bool
+	}{
+		{"failed before startup", time.Minute, failedAt(serverStartTime.Add(-time.Second)), nil, true},
+		{"failed after startup", time.Minute, failedAt(serverStartTime.Add(time.Second)), nil, false},
+		{"grace period disabled", 0, failedAt(serverStartTime.Add(-time.Second)), nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failedPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+				Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+			}
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset:      fake.NewSimpleClientset(failedPod),
+				notifications:      map[string]notification.Notification{"recording": n},
+				notifiedJobs:       make(map[string]bool),
+				startupGracePeriod: test.grace,
+			}
+
+			c.handleUpdate(test.job, test.job)
+
+			assert.Equal(t, test.expectedEvents, n.events)
+			assert.Equal(t, test.expectedNotified, c.notifiedJobs["the-job"])
+		})
+	}
+}
+
+func TestGetStartupGracePeriod(t *testing.T) {
+	t.Setenv("STARTUP_GRACE_PERIOD", "")
+	assert.Equal(t, time.Duration(0), getStartupGracePeriod())
+
+	t.Setenv("STARTUP_GRACE_PERIOD", "2m")
+	assert.Equal(t, 2*time.Minute, getStartupGracePeriod())
+
+	t.Setenv("STARTUP_GRACE_PERIOD", "soon")
+	assert.Equal(t, time.Duration(0), getStartupGracePeriod())
+}
+
+func TestGetJobWaitTime(t *testing.T) {
+	created := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "the-job",
+			Namespace:         "test-ns",
+			UID:               "test",
+			CreationTimestamp: metav1.Time{Time: created},
+		},
+	}
+	newPod := func(name string, startTime *metav1.Time) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-ns",
+				Labels:    map[string]string{searchLabel: "test"},
+			},
+			Status: corev1.PodStatus{StartTime: startTime},
+		}
+	}
+	at := func(d time.Duration) *metav1.Time {
+		return &metav1.Time{Time: created.Add(d)}
+	}
+
+	tests := []struct {
+		name     string
+		pods     []runtime.Object
+		expected time.Duration
+	}{
+		{"single pod", []runtime.Object{newPod("the-job-a", at(12*time.Second))}, 12 * time.Second},
+		{"first of several pods", []runtime.Object{
+			newPod("the-job-a", at(40*time.Second)),
+			newPod("the-job-b", at(15*time.Second)),
+			newPod("the-job-c", nil),
+		}, 15 * time.Second},
+		{"pod not started", []runtime.Object{newPod("the-job-a", nil)}, 0},
+		{"no pods", nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getJobWaitTime(fake.NewSimpleClientset(test.pods...), job))
+		})
+	}
+}
+
+func TestNotifyStartQueueTime(t *testing.T) {
+	t.Setenv("PROMETHEUS_ENABLED", "true")
+	created := metav1.NewTime(time.Now().Add(-time.Minute))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-a", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, StartTime: &metav1.Time{Time: created.Add(40 * time.Second)}},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test", CreationTimestamp: created},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(1)},
+	}
+
+	n := &recordingNotification{}
+	sub := &fakeSubscription{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(pod),
+		notifications: map[string]notification.Notification{"recording": n},
+		subscriptions: map[string]monitoring.Subscription{"fake": sub},
+		notifiedJobs:  make(map[string]bool),
+	}
+
+	c.notifyStart(job, time.Now())
+
+	assert.Equal(t, []string{"start"}, n.events)
+	assert.Equal(t, 40*time.Second, n.params[0].QueueTime)
+}
+
+func TestIsSkippedJob(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      *batchv1.Job
+		expected bool
+	}{
+		{"plain job", &batchv1.Job{}, false},
+		{"skip annotation", &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"job-notify-controller/skip": "true"},
+		}}, true},
+		{"skip annotation on pod template", &batchv1.Job{Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"job-notify-controller/skip": "true"}},
+		}}}, true},
+		{"skip annotation false", &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"job-notify-controller/skip": "false"},
+		}}, false},
+		{"ephemeral label", &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"ci.test/ephemeral": "true"},
+		}}, true},
+		{"helm test hook", &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"helm.sh/hook": "test"},
+		}}, true},
+		{"helm install hook", &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"helm.sh/hook": "pre-install,pre-upgrade"},
+		}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isSkippedJob(test.job))
+		})
+	}
+}
+
+func TestSkipAnnotationShortCircuitsNotifications(t *testing.T) {
+	defer func(original time.Time) { serverStartTime = original }(serverStartTime)
+	serverStartTime = time.Now().Add(-time.Hour)
+
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "the-job-abcde",
+			Namespace: "test-ns",
+			Labels:    map[string]string{searchLabel: "test"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	newJob := func(annotations map[string]string) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "the-job",
+				Namespace:         "test-ns",
+				UID:               "test",
+				CreationTimestamp: metav1.Now(),
+				Annotations:       annotations,
+			},
+			Spec:   batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+			Status: batchv1.JobStatus{Failed: 1},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		expectedEvents []string
+	}{
+		{"skipped", map[string]string{"job-notify-controller/skip": "true"}, nil},
+		{"not skipped", nil, []string{"start", "failed"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(failedPod.DeepCopy()),
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+			}
+			job := newJob(test.annotations)
+
+			c.handleAdd(job)
+			c.handleUpdate(job, job)
+
+			assert.Equal(t, test.expectedEvents, n.events)
+		})
+	}
+}
+
+func TestFailedOnlyWhenRetriesAreExhausted(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	job := func(backoffLimit int32, succeeded int32, failed int32, conditions ...batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test", CreationTimestamp: metav1.Now()},
+			Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(backoffLimit)},
+			Status:     batchv1.JobStatus{Succeeded: succeeded, Failed: failed},
+		}
+		for _, c := range conditions {
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{Type: c, Status: corev1.ConditionTrue})
+		}
+		return job
+	}
+
+	tests := []struct {
+		name           string
+		notifyOnRetry  string
+		dedupWindow    string
+		pods           []runtime.Object
+		versions       []*batchv1.Job
+		expectedEvents []string
+		expectedFailed []int32
+	}{
+		{
+			"fails twice then succeeds",
+			"",
+			"",
+			[]runtime.Object{pod("the-job-a", corev1.PodFailed), pod("the-job-b", corev1.PodFailed), pod("the-job-c", corev1.PodSucceeded)},
+			[]*batchv1.Job{job(3, 0, 0), job(3, 0, 1), job(3, 0, 2), job(3, 1, 2, batchv1.JobComplete)},
+			[]string{"success"},
+			nil,
+		},
+		{
+			"exhausts retries",
+			"",
+			"",
+			[]runtime.Object{pod("the-job-a", corev1.PodFailed), pod("the-job-b", corev1.PodFailed)},
+			[]*batchv1.Job{job(1, 0, 0), job(1, 0, 1), job(1, 0, 2, batchv1.JobFailed)},
+			[]string{"failed"},
+			[]int32{2},
+		},
+		{
+			"warns once on the first failed attempt",
+			"true",
+			"",
+			[]runtime.Object{pod("the-job-a", corev1.PodFailed), pod("the-job-b", corev1.PodFailed), pod("the-job-c", corev1.PodFailed)},
+			[]*batchv1.Job{job(2, 0, 0), job(2, 0, 1), job(2, 0, 2), job(2, 0, 3, batchv1.JobFailed)},
+			[]string{"failed", "failed"},
+			[]int32{1, 3},
+		},
+		{
+			"retry warning doesn't dedup the failure",
+			"true",
+			"10m",
+			[]runtime.Object{pod("the-job-a", corev1.PodFailed), pod("the-job-b", corev1.PodFailed), pod("the-job-c", corev1.PodFailed)},
+			[]*batchv1.Job{job(2, 0, 0), job(2, 0, 1), job(2, 0, 2), job(2, 0, 3, batchv1.JobFailed)},
+			[]string{"failed", "failed"},
+			[]int32{1, 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_ON_RETRY", test.notifyOnRetry)
+			t.Setenv("DEDUP_WINDOW", test.dedupWindow)
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(test.pods...),
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+				deduper:       newNotificationDeduper(),
+			}
+
+			for i := 1; i < len(test.versions); i++ {
+				c.handleUpdate(test.versions[i-1], test.versions[i])
+			}
+
+			assert.Equal(t, test.expectedEvents, n.events)
+			var failedCounts []int32
+			for i, event := range n.events {
+				if event == "failed" {
+					failedCounts = append(failedCounts, n.params[i].FailedCount)
+				}
+			}
+			assert.Equal(t, test.expectedFailed, failedCounts)
+		})
+	}
+}
+
+func TestGetPodPlacement(t *testing.T) {
+	node := func(name string, labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	scheduled := func(nodeName string) corev1.Pod {
+		return corev1.Pod{Spec: corev1.PodSpec{NodeName: nodeName}}
+	}
+	clientset := fake.NewSimpleClientset(
+		node("node-a", map[string]string{corev1.LabelTopologyZone: "us-east-1a"}),
+		node("node-b", map[string]string{corev1.LabelFailureDomainBetaZone: "us-east-1b"}),
+		node("node-c", nil),
+	)
+
+	tests := []struct {
+		name     string
+		pods     []corev1.Pod
+		nodeName string
+		zone     string
+	}{
+		{"zone label", []corev1.Pod{scheduled("node-a")}, "node-a", "us-east-1a"},
+		{"beta zone label", []corev1.Pod{scheduled("node-b")}, "node-b", "us-east-1b"},
+		{"no zone label", []corev1.Pod{scheduled("node-c")}, "node-c", ""},
+		{"node is gone", []corev1.Pod{scheduled("node-d")}, "node-d", ""},
+		{"last scheduled pod", []corev1.Pod{scheduled("node-a"), scheduled("node-b"), scheduled("")}, "node-b", "us-east-1b"},
+		{"not scheduled", []corev1.Pod{scheduled("")}, "", ""},
+		{"no pods", nil, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodeName, zone := getPodPlacement(clientset, test.pods)
+			assert.Equal(t, test.nodeName, nodeName)
+			assert.Equal(t, test.zone, zone)
+		})
+	}
+}
+
+func TestGetExitCode(t *testing.T) {
+	terminated := func(name string, exitCode int32) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name:  name,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+		}
+	}
+	pod := func(statuses ...corev1.ContainerStatus) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: statuses}}
+	}
+
+	assert.Equal(t, int32(0), getExitCode(nil))
+	assert.Equal(t, int32(0), getExitCode([]corev1.Pod{pod(terminated("sidecar", 0))}))
+	assert.Equal(t, int32(137), getExitCode([]corev1.Pod{pod(terminated("sidecar", 0), terminated("worker", 137)), pod(terminated("worker", 1))}))
+
+	restarted := corev1.ContainerStatus{
+		Name:                 "worker",
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 2}},
+	}
+	assert.Equal(t, int32(2), getExitCode([]corev1.Pod{pod(restarted)}))
+}
+
+func TestGetFailedReason(t *testing.T) {
+	failed := func(reason string) *batchv1.Job {
+		return &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: reason},
+		}}}
+	}
+
+	assert.Equal(t, "BackoffLimitExceeded", getFailedReason(failed("BackoffLimitExceeded"), false))
+	assert.Equal(t, "OOMKilled", getFailedReason(failed("BackoffLimitExceeded"), true))
+	// The deadline is what terminated the job, even when a pod was OOMKilled earlier.
+	assert.Equal(t, "DeadlineExceeded", getFailedReason(failed("DeadlineExceeded"), true))
+	assert.Equal(t, "", getFailedReason(&batchv1.Job{}, false))
+}
+
+func TestStartDeferredUntilJobHasWork(t *testing.T) {
+	runningPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-a", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	job := func(suspend *bool, parallelism *int32) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test", CreationTimestamp: metav1.Now()},
+			Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(1), Suspend: suspend, Parallelism: parallelism},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		created         *batchv1.Job
+		started         *batchv1.Job
+		expectedOnAdd   []string
+		expectedEvents  []string
+		expectedPending bool
+	}{
+		{"suspended at creation", job(utilpointer.Bool(true), nil), job(utilpointer.Bool(false), nil), nil, []string{"resumed", "start"}, false},
+		{"zero parallelism", job(nil, utilpointer.Int32(0)), job(nil, utilpointer.Int32(2)), nil, []string{"start"}, false},
+		{"still zero parallelism", job(nil, utilpointer.Int32(0)), job(nil, utilpointer.Int32(0)), nil, nil, true},
+		{"running at creation", job(nil, utilpointer.Int32(1)), job(nil, utilpointer.Int32(1)), []string{"start"}, []string{"start"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(runningPod.DeepCopy()),
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+			}
+
+			c.handleAdd(test.created)
+			assert.Equal(t, test.expectedOnAdd, n.events)
+
+			c.handleUpdate(test.created, test.started)
+			assert.Equal(t, test.expectedEvents, n.events)
+			for i, event := range n.events {
+				assert.Equal(t, event, n.params[i].Event)
+			}
+			assert.Equal(t, test.expectedPending, c.deferredStarts["the-job"])
+		})
+	}
+}
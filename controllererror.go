@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	"k8s.io/klog"
+)
+
+const (
+	controllerErrorStageLogs  = "logs"
+	controllerErrorStageOwner = "owner"
+	controllerErrorStagePods  = "pods"
+
+	// opsNotifyInterval is the minimum time between two ops messages for the same stage.
+	opsNotifyInterval = 10 * time.Minute
+)
+
+// controllerErrorReporter records internal errors the controller degrades on, such as a
+// failed log fetch, so that operators notice it is having trouble.
+type controllerErrorReporter struct {
+	subscriptions map[string]monitoring.Subscription
+	// ops is nil when no ops channel is configured.
+	ops notification.OpsNotifier
+
+	mu           sync.Mutex
+	lastNotified map[string]time.Time
+
+	now func() time.Time
+}
+
+func newControllerErrorReporter(subscriptions map[string]monitoring.Subscription, ops notification.OpsNotifier) *controllerErrorReporter {
+	return &controllerErrorReporter{
+		subscriptions: subscriptions,
+		ops:           ops,
+		lastNotified:  make(map[string]time.Time),
+		now:           time.Now,
+	}
+}
+
+func (r *controllerErrorReporter) report(stage string, jobName string, err error) {
+	if r == nil {
+		return
+	}
+	if monitoring.Enabled() {
+		for name, s := range r.subscriptions {
+			if err := s.ControllerErrorEvent(stage); err != nil {
+				klog.Errorf("Failed %s controller error subscribe: %v", name, err)
+			}
+		}
+	}
+
+	if r.ops == nil || !r.allowOpsNotify(stage) {
+		return
+	}
+	message := fmt.Sprintf("kube-job-notifier failed at stage %s for job %s: %v", stage, jobName, err)
+	if err := r.ops.NotifyOps(message); err != nil {
+		klog.Errorf("Failed ops notification: %v", err)
+	}
+}
+
+// allowOpsNotify rate limits the ops messages per stage.
+func (r *controllerErrorReporter) allowOpsNotify(stage string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if last, ok := r.lastNotified[stage]; ok && now.Sub(last) < opsNotifyInterval {
+		return false
+	}
+	r.lastNotified[stage] = now
+	return true
+}
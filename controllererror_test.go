@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+type recordingOpsNotifier struct {
+	messages []string
+}
+
+func (n *recordingOpsNotifier) NotifyOps(message string) (err error) {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func TestLogFetchFailureIsReported(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "the-job-abcde",
+			Namespace: "test-ns",
+			Labels:    map[string]string{searchLabel: "test"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+		Status:     batchv1.JobStatus{Failed: 1},
+	}
+
+	n := &recordingNotification{}
+	sub := &fakeSubscription{}
+	ops := &recordingOpsNotifier{}
+	subscriptions := map[string]monitoring.Subscription{"fake": sub}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod),
+		logs: &fakePodLogs{
+			respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+				return nil, errors.New("connection refused")
+			},
+		},
+		notifications: map[string]notification.Notification{"recording": n},
+		subscriptions: subscriptions,
+		notifiedJobs:  make(map[string]bool),
+		errorReporter: newControllerErrorReporter(subscriptions, ops),
+	}
+
+	c.handleFailed(job, time.Now())
+
+	// Once for the job log and once for the failed pod logs, the failure is still notified.
+	assert.Equal(t, []string{"logs", "logs"}, sub.controllerErrors)
+	assert.Equal(t, []string{"failed"}, n.events)
+	assert.Contains(t, n.params[0].Log, "connection refused")
+	assert.Len(t, ops.messages, 1)
+	assert.Contains(t, ops.messages[0], "stage logs for job the-job")
+}
+
+func TestControllerErrorReporterRateLimitsOps(t *testing.T) {
+	now := time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC)
+	ops := &recordingOpsNotifier{}
+	r := newControllerErrorReporter(nil, ops)
+	r.now = func() time.Time { return now }
+
+	r.report(controllerErrorStageLogs, "job-a", errors.New("timeout"))
+	r.report(controllerErrorStageLogs, "job-b", errors.New("timeout"))
+	r.report(controllerErrorStageOwner, "job-b", errors.New("forbidden"))
+
+	now = now.Add(opsNotifyInterval)
+	r.report(controllerErrorStageLogs, "job-c", errors.New("timeout"))
+
+	assert.Equal(t, []string{
+		"kube-job-notifier failed at stage logs for job job-a: timeout",
+		"kube-job-notifier failed at stage owner for job job-b: forbidden",
+		"kube-job-notifier failed at stage logs for job job-c: timeout",
+	}, ops.messages)
+}
+
+func TestNilControllerErrorReporter(t *testing.T) {
+	var r *controllerErrorReporter
+	assert.NotPanics(t, func() { r.report(controllerErrorStageLogs, "the-job", errors.New("timeout")) })
+}
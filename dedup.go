@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+// dedupWarningEvent is the event of the dedup key of the retry, stuck pod and lost pods
+// warnings sent through the failed notification.
+const dedupWarningEvent = "warning"
+
+var (
+	defaultDedupKeyFields = []string{"namespace", "job", "event"}
+	dedupKeyFields        = map[string]bool{"namespace": true, "job": true, "cronjob": true, "event": true}
+)
+
+// notificationDeduper drops notifications whose key was already notified within the window.
+type notificationDeduper struct {
+	window time.Duration
+	// fields make up the key, e.g. namespace, cronjob and event to notify once per schedule.
+	fields []string
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+
+	now func() time.Time
+}
+
+// newNotificationDeduper returns nil unless DEDUP_WINDOW is set. DEDUP_KEY_FIELDS picks the
+// key fields among namespace, job, cronjob and event.
+func newNotificationDeduper() *notificationDeduper {
+	value := os.Getenv("DEDUP_WINDOW")
+	if value == "" {
+		return nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		klog.Errorf("Invalid DEDUP_WINDOW %q, disabling deduplication: %v", value, err)
+		return nil
+	}
+	return &notificationDeduper{
+		window: window,
+		fields: parseDedupKeyFields(os.Getenv("DEDUP_KEY_FIELDS")),
+		sent:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+func parseDedupKeyFields(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !dedupKeyFields[field] {
+			klog.Errorf("Invalid DEDUP_KEY_FIELDS field %q, using the default fields", field)
+			return defaultDedupKeyFields
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return defaultDedupKeyFields
+	}
+	return fields
+}
+
+func (d *notificationDeduper) key(event string, messageParam notification.MessageTemplateParam) string {
+	values := make([]string, 0, len(d.fields))
+	for _, field := range d.fields {
+		switch field {
+		case "namespace":
+			values = append(values, messageParam.Namespace)
+		case "job":
+			values = append(values, messageParam.JobName)
+		case "cronjob":
+			// Jobs not created by a CronJob are their own schedule.
+			if messageParam.CronJobName != "" {
+				values = append(values, messageParam.CronJobName)
+			} else {
+				values = append(values, messageParam.JobName)
+			}
+		case "event":
+			// Warnings about a job that may still succeed don't dedup its final failure.
+			if event == notification.FAILED && messageParam.IsWarning() {
+				values = append(values, dedupWarningEvent)
+			} else {
+				values = append(values, event)
+			}
+		}
+	}
+	return strings.Join(values, "/")
+}
+
+// isDuplicate reports whether the notification was already sent within the window and
+// records it otherwise.
+func (d *notificationDeduper) isDuplicate(event string, messageParam notification.MessageTemplateParam) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for key, sentAt := range d.sent {
+		if now.Sub(sentAt) >= d.window {
+			delete(d.sent, key)
+		}
+	}
+	key := d.key(event, messageParam)
+	if _, ok := d.sent[key]; ok {
+		return true
+	}
+	d.sent[key] = now
+	return false
+}
+
+// isDuplicateNotification logs and reports notifications the deduper drops.
+func (c *Controller) isDuplicateNotification(job *batchv1.Job, event string, messageParam notification.MessageTemplateParam) bool {
+	if !c.deduper.isDuplicate(event, messageParam) {
+		return false
+	}
+	klog.Infof("Duplicate notification skipped: %s key=%s", jobLogFields(job, event), c.deduper.key(event, messageParam))
+	return true
+}
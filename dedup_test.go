@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func TestNewNotificationDeduper(t *testing.T) {
+	assert.Nil(t, newNotificationDeduper())
+
+	t.Setenv("DEDUP_WINDOW", "invalid")
+	assert.Nil(t, newNotificationDeduper())
+
+	t.Setenv("DEDUP_WINDOW", "10m")
+	d := newNotificationDeduper()
+	assert.Equal(t, 10*time.Minute, d.window)
+	assert.Equal(t, defaultDedupKeyFields, d.fields)
+
+	t.Setenv("DEDUP_KEY_FIELDS", " namespace, cronjob ,event")
+	assert.Equal(t, []string{"namespace", "cronjob", "event"}, newNotificationDeduper().fields)
+
+	t.Setenv("DEDUP_KEY_FIELDS", "namespace,unknown")
+	assert.Equal(t, defaultDedupKeyFields, newNotificationDeduper().fields)
+}
+
+func TestNotificationDeduperIsDuplicate(t *testing.T) {
+	now := time.Now()
+	d := &notificationDeduper{
+		window: time.Minute,
+		fields: []string{"namespace", "cronjob", "event"},
+		sent:   make(map[string]time.Time),
+		now:    func() time.Time { return now },
+	}
+	first := notification.MessageTemplateParam{JobName: "the-job-1", CronJobName: "the-cronjob", Namespace: "test-ns"}
+	second := notification.MessageTemplateParam{JobName: "the-job-2", CronJobName: "the-cronjob", Namespace: "test-ns"}
+	standalone := notification.MessageTemplateParam{JobName: "the-job-1", Namespace: "test-ns"}
+
+	assert.False(t, d.isDuplicate(notification.FAILED, first))
+	assert.True(t, d.isDuplicate(notification.FAILED, second))
+	assert.False(t, d.isDuplicate(notification.SUCCESS, second))
+	// A job without a CronJob is keyed by its own name.
+	assert.False(t, d.isDuplicate(notification.FAILED, standalone))
+
+	now = now.Add(time.Minute)
+	assert.False(t, d.isDuplicate(notification.FAILED, second))
+
+	// A retry warning doesn't dedup the final failure of the job.
+	retry := notification.MessageTemplateParam{JobName: "the-job-3", CronJobName: "the-cronjob", Namespace: "test-ns", FailedCount: 1, BackoffLimit: 3}
+	assert.False(t, d.isDuplicate(notification.FAILED, retry))
+	assert.True(t, d.isDuplicate(notification.FAILED, retry))
+	assert.Equal(t, "test-ns/the-cronjob/warning", d.key(notification.FAILED, retry))
+
+	var disabled *notificationDeduper
+	assert.False(t, disabled.isDuplicate(notification.FAILED, first))
+}
+
+func TestDuplicateNotificationsAreSkipped(t *testing.T) {
+	t.Setenv("DEDUP_WINDOW", "10m")
+
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(),
+		notifications: map[string]notification.Notification{"recording": n},
+		deduper:       newNotificationDeduper(),
+	}
+	oldJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"}}
+	newJob := oldJob.DeepCopy()
+	newJob.Spec.Suspend = utilpointer.Bool(true)
+
+	assert.True(t, c.notifySuspendTransition(oldJob, newJob, time.Now()))
+	assert.True(t, c.notifySuspendTransition(oldJob, newJob, time.Now()))
+	assert.True(t, c.notifySuspendTransition(newJob, oldJob, time.Now()))
+
+	assert.Equal(t, []string{"suspended", "resumed"}, n.events)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const defaultNotifyEventCount = 5
+
+// getNotifyEventCount reads NOTIFY_EVENT_COUNT, the number of Kubernetes events attached to
+// failure notifications. 0 attaches none.
+func getNotifyEventCount() int {
+	value := os.Getenv("NOTIFY_EVENT_COUNT")
+	if value == "" {
+		return defaultNotifyEventCount
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		klog.Errorf("Invalid NOTIFY_EVENT_COUNT %q, using default %d", value, defaultNotifyEventCount)
+		return defaultNotifyEventCount
+	}
+	return count
+}
+
+// getJobEvents returns the most recent count events of the job and its pods, oldest first,
+// formatted as "Reason (Kind/name): message". Warnings such as BackOff or FailedScheduling
+// are picked before normal events, which mostly report the pods being created and started.
+func getJobEvents(kubeclientset kubernetes.Interface, job *batchv1.Job, pods []corev1.Pod, count int) ([]string, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	eventList, err := kubeclientset.CoreV1().Events(job.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podNames := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		podNames[pod.Name] = true
+	}
+	var events []corev1.Event
+	for _, event := range eventList.Items {
+		involved := event.InvolvedObject
+		if (involved.Kind == "Job" && involved.Name == job.Name) || (involved.Kind == "Pod" && podNames[involved.Name]) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		iWarning, jWarning := events[i].Type == corev1.EventTypeWarning, events[j].Type == corev1.EventTypeWarning
+		if iWarning != jWarning {
+			return jWarning
+		}
+		return getEventTime(events[i]).Before(getEventTime(events[j]))
+	})
+	if len(events) > count {
+		events = events[len(events)-count:]
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return getEventTime(events[i]).Before(getEventTime(events[j]))
+	})
+	formatted := make([]string, 0, len(events))
+	for _, event := range events {
+		formatted = append(formatted, formatEvent(event))
+	}
+	return formatted, nil
+}
+
+func formatEvent(event corev1.Event) string {
+	s := fmt.Sprintf("%s (%s/%s): %s", event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name, event.Message)
+	if event.Count > 1 {
+		s += fmt.Sprintf(" (x%d)", event.Count)
+	}
+	return s
+}
+
+// getEventTime is the last time the event occurred. Events recorded with the events.k8s.io
+// API only set EventTime.
+func getEventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
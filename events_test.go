@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func TestGetNotifyEventCount(t *testing.T) {
+	assert.Equal(t, defaultNotifyEventCount, getNotifyEventCount())
+
+	t.Setenv("NOTIFY_EVENT_COUNT", "2")
+	assert.Equal(t, 2, getNotifyEventCount())
+
+	t.Setenv("NOTIFY_EVENT_COUNT", "0")
+	assert.Equal(t, 0, getNotifyEventCount())
+
+	t.Setenv("NOTIFY_EVENT_COUNT", "-1")
+	assert.Equal(t, defaultNotifyEventCount, getNotifyEventCount())
+
+	t.Setenv("NOTIFY_EVENT_COUNT", "many")
+	assert.Equal(t, defaultNotifyEventCount, getNotifyEventCount())
+}
+
+func newTestEvent(name, kind, objectName, reason, message string, at time.Time) *corev1.Event {
+	eventType := corev1.EventTypeWarning
+	if reason == "SuccessfulCreate" || reason == "Scheduled" || reason == "Pulled" {
+		eventType = corev1.EventTypeNormal
+	}
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: objectName, Namespace: "test-ns"},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		LastTimestamp:  metav1.NewTime(at),
+	}
+}
+
+func TestGetJobEvents(t *testing.T) {
+	at := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"}}
+	pods := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns"}}}
+	client := fake.NewSimpleClientset(
+		newTestEvent("e1", "Job", "the-job", "SuccessfulCreate", "Created pod: the-job-abcde", at),
+		newTestEvent("e2", "Pod", "the-job-abcde", "BackOff", "Back-off restarting failed container", at.Add(2*time.Minute)),
+		newTestEvent("e3", "Pod", "the-job-abcde", "FailedScheduling", "0/3 nodes are available", at.Add(time.Minute)),
+		newTestEvent("e4", "Pod", "other-job-fghij", "BackOff", "Back-off restarting failed container", at.Add(3*time.Minute)),
+		newTestEvent("e5", "Job", "the-job", "BackoffLimitExceeded", "Job has reached the specified backoff limit", at.Add(4*time.Minute)),
+	)
+
+	events, err := getJobEvents(client, job, pods, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"FailedScheduling (Pod/the-job-abcde): 0/3 nodes are available",
+		"BackOff (Pod/the-job-abcde): Back-off restarting failed container",
+		"BackoffLimitExceeded (Job/the-job): Job has reached the specified backoff limit",
+	}, events)
+
+	events, err = getJobEvents(client, job, pods, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestGetJobEventsPrefersWarnings(t *testing.T) {
+	at := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"}}
+	pods := []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns"}}}
+	backOff := newTestEvent("e2", "Pod", "the-job-abcde", "BackOff", "Back-off pulling image \"busybox:nope\"", at.Add(time.Minute))
+	backOff.Count = 4
+	client := fake.NewSimpleClientset(
+		newTestEvent("e1", "Pod", "the-job-abcde", "Scheduled", "Successfully assigned test-ns/the-job-abcde to node-a", at),
+		backOff,
+		newTestEvent("e3", "Pod", "the-job-abcde", "Pulled", "Container image already present on machine", at.Add(2*time.Minute)),
+		newTestEvent("e4", "Pod", "the-job-abcde", "Unhealthy", "Readiness probe failed", at.Add(3*time.Minute)),
+		newTestEvent("e5", "Job", "the-job", "SuccessfulCreate", "Created pod: the-job-abcde", at.Add(4*time.Minute)),
+	)
+
+	events, err := getJobEvents(client, job, pods, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BackOff (Pod/the-job-abcde): Back-off pulling image \"busybox:nope\" (x4)",
+		"Unhealthy (Pod/the-job-abcde): Readiness probe failed",
+		"SuccessfulCreate (Job/the-job): Created pod: the-job-abcde",
+	}, events)
+}
+
+func TestHandleFailedEvents(t *testing.T) {
+	at := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+		Status: batchv1.JobStatus{
+			Failed:     1,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "the-job-abcde",
+			Namespace: "test-ns",
+			Labels:    map[string]string{searchLabel: "test"},
+		},
+	}
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(pod,
+			newTestEvent("e1", "Pod", "the-job-abcde", "BackOff", "Back-off restarting failed container", at)),
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+		eventCount:    defaultNotifyEventCount,
+	}
+
+	c.handleFailed(job, at)
+
+	assert.Equal(t, []string{"failed"}, n.events)
+	assert.Equal(t, []string{"BackOff (Pod/the-job-abcde): Back-off restarting failed container"}, n.params[0].Events)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+// ignoreExitCodesAnnotationName lists exit codes, e.g. "2,75", that the job exits with on
+// runs that aren't failures, such as having no work to do. A job whose pods all failed with
+// one of them is notified as succeeded.
+const ignoreExitCodesAnnotationName = "job-notify/ignore-exit-codes"
+
+// getIgnoredExitCodes returns the exit codes of the ignore-exit-codes annotation. Invalid
+// codes are logged and skipped.
+func getIgnoredExitCodes(annotations map[string]string) []int32 {
+	value := annotations[ignoreExitCodesAnnotationName]
+	if value == "" {
+		return nil
+	}
+	var codes []int32
+	for _, s := range strings.Split(value, ",") {
+		code, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+		if err != nil || code == 0 {
+			klog.Warningf("Invalid exit code %q in %s annotation, ignoring it", s, ignoreExitCodesAnnotationName)
+			continue
+		}
+		codes = append(codes, int32(code))
+	}
+	return codes
+}
+
+// hasIgnoredExitCode reports whether every failed pod of the job exited with one of the
+// ignored exit codes. A job that timed out or has a pod with an unknown exit code failed.
+func hasIgnoredExitCode(kubeclientset kubernetes.Interface, job *batchv1.Job, annotations map[string]string) bool {
+	codes := getIgnoredExitCodes(annotations)
+	if len(codes) == 0 || isDeadlineExceeded(job) {
+		return false
+	}
+	failedPods, err := getFailedPods(kubeclientset, job)
+	if err != nil {
+		klog.Errorf("Get failed pods failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		return false
+	}
+	if len(failedPods) == 0 {
+		return false
+	}
+	for _, pod := range failedPods {
+		if !slices.Contains(codes, getExitCode([]corev1.Pod{pod})) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func TestGetIgnoredExitCodes(t *testing.T) {
+	assert.Nil(t, getIgnoredExitCodes(nil))
+	assert.Equal(t, []int32{2, 75}, getIgnoredExitCodes(map[string]string{ignoreExitCodesAnnotationName: "2, 75"}))
+	assert.Equal(t, []int32{3}, getIgnoredExitCodes(map[string]string{ignoreExitCodesAnnotationName: "0,nope,3"}))
+}
+
+func TestHandleFailedIgnoredExitCode(t *testing.T) {
+	failedPod := func(name string, exitCode int32) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-ns",
+				Labels:    map[string]string{searchLabel: "test"},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodFailed,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "worker",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pods     []*corev1.Pod
+		expected string
+	}{
+		{"ignored exit code", []*corev1.Pod{failedPod("the-job-a", 2)}, "success"},
+		{"other exit code", []*corev1.Pod{failedPod("the-job-a", 1)}, "failed"},
+		{"one attempt with another exit code", []*corev1.Pod{failedPod("the-job-a", 75), failedPod("the-job-b", 1)}, "failed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: utilpointer.Int32(0),
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ignoreExitCodesAnnotationName: "2,75"}},
+					},
+				},
+				Status: batchv1.JobStatus{Failed: int32(len(test.pods))},
+			}
+			clientset := fake.NewSimpleClientset()
+			for _, pod := range test.pods {
+				clientset.Tracker().Add(pod)
+			}
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: clientset,
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+			}
+
+			c.handleFailed(job, time.Now())
+
+			assert.Equal(t, []string{test.expected}, n.events)
+			assert.Empty(t, n.params[0].CompletionWarning)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// getIndexSummary summarizes the per-index results of an Indexed job, e.g.
+// "3/5 succeeded, 2 failed: index 1, 4". It is empty for other jobs.
+func getIndexSummary(job *batchv1.Job) string {
+	if !isIndexedJob(job) {
+		return ""
+	}
+	completions := int(*job.Spec.Completions)
+	completed := parseIndexes(job.Status.CompletedIndexes, completions)
+	summary := fmt.Sprintf("%d/%d succeeded", len(completed), completions)
+
+	label := "not completed"
+	remaining, failed := getRemainingIndexes(job, completions, completed)
+	if failed {
+		label = "failed"
+	}
+	if len(remaining) == 0 {
+		return summary
+	}
+	indexes := make([]string, 0, len(remaining))
+	for _, i := range remaining {
+		indexes = append(indexes, strconv.Itoa(i))
+	}
+	return fmt.Sprintf("%s, %d %s: index %s", summary, len(remaining), label, strings.Join(indexes, ", "))
+}
+
+// getFailedIndices lists the failed indexes of an Indexed job as ranges, e.g. "1,4-6",
+// so that large fan-out jobs stay readable. It is empty for other jobs.
+func getFailedIndices(job *batchv1.Job) string {
+	if !isIndexedJob(job) {
+		return ""
+	}
+	completions := int(*job.Spec.Completions)
+	remaining, _ := getRemainingIndexes(job, completions, parseIndexes(job.Status.CompletedIndexes, completions))
+	return formatIndexRanges(remaining)
+}
+
+func isIndexedJob(job *batchv1.Job) bool {
+	return job.Spec.CompletionMode != nil && *job.Spec.CompletionMode == batchv1.IndexedCompletion && job.Spec.Completions != nil
+}
+
+// getRemainingIndexes returns the indexes that didn't succeed. failedIndexes is only tracked
+// with backoffLimitPerIndex, failed reports whether it was used. Otherwise every index that
+// didn't complete is returned.
+func getRemainingIndexes(job *batchv1.Job, completions int, completed []int) (remaining []int, failed bool) {
+	if job.Status.FailedIndexes != nil {
+		return parseIndexes(*job.Status.FailedIndexes, completions), true
+	}
+	done := make(map[int]bool, len(completed))
+	for _, i := range completed {
+		done[i] = true
+	}
+	for i := 0; i < completions; i++ {
+		if !done[i] {
+			remaining = append(remaining, i)
+		}
+	}
+	return remaining, false
+}
+
+// formatIndexRanges formats sorted indexes in the job status format, e.g. "1,3-5".
+func formatIndexRanges(indexes []int) string {
+	var ranges []string
+	for start := 0; start < len(indexes); {
+		end := start
+		for end+1 < len(indexes) && indexes[end+1] == indexes[end]+1 {
+			end++
+		}
+		if end == start {
+			ranges = append(ranges, strconv.Itoa(indexes[start]))
+		} else {
+			ranges = append(ranges, strconv.Itoa(indexes[start])+"-"+strconv.Itoa(indexes[end]))
+		}
+		start = end + 1
+	}
+	return strings.Join(ranges, ",")
+}
+
+// parseIndexes expands an index list such as "1,3-5" of the job status. Indexes outside
+// of [0, completions) and malformed items are ignored.
+func parseIndexes(value string, completions int) []int {
+	var indexes []int
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(item, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			continue
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil {
+				continue
+			}
+		}
+		for i := from; i <= to && i < completions; i++ {
+			if i >= 0 {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	return indexes
+}
+
+// getCompletionWarning flags a succeeded job whose succeeded pods fall short of its
+// completions, e.g. a misconfigured job that succeeds without running any pod.
+// Jobs with a success policy may legitimately succeed early and are not flagged.
+func getCompletionWarning(job *batchv1.Job) string {
+	if job.Spec.SuccessPolicy != nil {
+		return ""
+	}
+	expected := int32(1)
+	if job.Spec.Completions != nil {
+		expected = *job.Spec.Completions
+	}
+	if job.Status.Succeeded >= expected {
+		return ""
+	}
+	return fmt.Sprintf("Job succeeded with %d of %d completions", job.Status.Succeeded, expected)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func TestGetIndexSummary(t *testing.T) {
+	indexed := batchv1.IndexedCompletion
+	nonIndexed := batchv1.NonIndexedCompletion
+	job := func(mode *batchv1.CompletionMode, completions int32, completed string, failed *string) *batchv1.Job {
+		return &batchv1.Job{
+			Spec:   batchv1.JobSpec{CompletionMode: mode, Completions: utilpointer.Int32(completions)},
+			Status: batchv1.JobStatus{CompletedIndexes: completed, FailedIndexes: failed},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		job      *batchv1.Job
+		expected string
+	}{
+		{"Not indexed", job(&nonIndexed, 5, "", nil), ""},
+		{"No completion mode", job(nil, 5, "", nil), ""},
+		{"All succeeded", job(&indexed, 5, "0-4", nil), "5/5 succeeded"},
+		{"Failed indexes", job(&indexed, 5, "0,2-3", utilpointer.String("1,4")), "3/5 succeeded, 2 failed: index 1, 4"},
+		{"Without failed indexes", job(&indexed, 5, "0,2-3", nil), "3/5 succeeded, 2 not completed: index 1, 4"},
+		{"Nothing completed", job(&indexed, 3, "", utilpointer.String("")), "0/3 succeeded"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getIndexSummary(test.job))
+		})
+	}
+}
+
+func TestGetFailedIndices(t *testing.T) {
+	indexed := batchv1.IndexedCompletion
+	job := func(mode *batchv1.CompletionMode, completions int32, completed string, failed *string) *batchv1.Job {
+		return &batchv1.Job{
+			Spec:   batchv1.JobSpec{CompletionMode: mode, Completions: utilpointer.Int32(completions)},
+			Status: batchv1.JobStatus{CompletedIndexes: completed, FailedIndexes: failed},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		job      *batchv1.Job
+		expected string
+	}{
+		{"Not indexed", job(nil, 5, "", utilpointer.String("1")), ""},
+		{"Failed indexes", job(&indexed, 1000, "0-2,4,8-999", utilpointer.String("3,5-7")), "3,5-7"},
+		{"Without failed indexes", job(&indexed, 10, "0-2,4,8", nil), "3,5-7,9"},
+		{"Nothing failed", job(&indexed, 3, "0-2", utilpointer.String("")), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getFailedIndices(test.job))
+		})
+	}
+}
+
+func TestFormatIndexRanges(t *testing.T) {
+	assert.Equal(t, "", formatIndexRanges(nil))
+	assert.Equal(t, "1", formatIndexRanges([]int{1}))
+	assert.Equal(t, "0-2,4,6-7", formatIndexRanges([]int{0, 1, 2, 4, 6, 7}))
+}
+
+func TestParseIndexes(t *testing.T) {
+	assert.Nil(t, parseIndexes("", 5))
+	assert.Equal(t, []int{0, 2, 3, 4}, parseIndexes("0,2-4", 5))
+	assert.Equal(t, []int{1, 3}, parseIndexes("1,x,3-y,3,7", 5))
+}
+
+func TestGetCompletionWarning(t *testing.T) {
+	job := func(completions *int32, succeeded int32) *batchv1.Job {
+		return &batchv1.Job{
+			Spec:   batchv1.JobSpec{Completions: completions},
+			Status: batchv1.JobStatus{Succeeded: succeeded},
+		}
+	}
+	withPolicy := job(utilpointer.Int32(5), 1)
+	withPolicy.Spec.SuccessPolicy = &batchv1.SuccessPolicy{Rules: []batchv1.SuccessPolicyRule{{SucceededCount: utilpointer.Int32(1)}}}
+
+	tests := []struct {
+		name     string
+		job      *batchv1.Job
+		expected string
+	}{
+		{"Completed", job(utilpointer.Int32(3), 3), ""},
+		{"Work queue", job(nil, 1), ""},
+		{"No pod succeeded", job(nil, 0), "Job succeeded with 0 of 1 completions"},
+		{"Fewer completions", job(utilpointer.Int32(5), 2), "Job succeeded with 2 of 5 completions"},
+		{"Success policy", withPolicy, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getCompletionWarning(test.job))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type jobResult int
+
+const (
+	jobRunning jobResult = iota
+	jobSucceeded
+	jobFailed
+	// jobRetrying is a job with failed pods that is still within its backoff limit.
+	jobRetrying
+)
+
+// defaultBackoffLimit is the backoff limit Kubernetes defaults an unset spec.backoffLimit to.
+const defaultBackoffLimit = 6
+
+// jobResultEvaluator decides whether a job has succeeded or failed. It returns jobRunning
+// when it can't tell, so the next evaluator gets a chance.
+type jobResultEvaluator func(job *batchv1.Job) jobResult
+
+// newJobResultEvaluators returns the conditions based evaluator followed by the pod counts
+// the controller has always used. Older clusters never set SuccessCriteriaMet, so they are
+// still evaluated by the counts unless JOB_SUCCESS_CONDITIONS is set. A job only fails on its
+// terminal Failed or FailureTarget condition, or JOB_FAILURE_CONDITIONS.
+func newJobResultEvaluators() []jobResultEvaluator {
+	successConditions := append([]batchv1.JobConditionType{batchv1.JobSuccessCriteriaMet},
+		parseJobConditionTypes(os.Getenv("JOB_SUCCESS_CONDITIONS"))...)
+	failureConditions := append([]batchv1.JobConditionType{batchv1.JobFailed, batchv1.JobFailureTarget},
+		parseJobConditionTypes(os.Getenv("JOB_FAILURE_CONDITIONS"))...)
+	return []jobResultEvaluator{
+		conditionResultEvaluator(successConditions, failureConditions),
+		podCountResultEvaluator,
+	}
+}
+
+func (c *Controller) getJobResult(job *batchv1.Job) jobResult {
+	evaluators := c.resultEvaluators
+	if evaluators == nil {
+		evaluators = newJobResultEvaluators()
+	}
+	for _, evaluate := range evaluators {
+		if result := evaluate(job); result != jobRunning {
+			return result
+		}
+	}
+	return jobRunning
+}
+
+// podCountResultEvaluator treats failed pods as retries until the job has failed more
+// times than its backoff limit allows.
+func podCountResultEvaluator(job *batchv1.Job) jobResult {
+	if job.Status.Succeeded == intTrue {
+		return jobSucceeded
+	}
+	if job.Status.Failed == 0 {
+		return jobRunning
+	}
+	backoffLimit := int32(defaultBackoffLimit)
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = *job.Spec.BackoffLimit
+	}
+	if job.Status.Failed > backoffLimit {
+		return jobFailed
+	}
+	return jobRetrying
+}
+
+// conditionResultEvaluator treats a job as succeeded or failed once one of the given conditions is true.
+func conditionResultEvaluator(successConditions, failureConditions []batchv1.JobConditionType) jobResultEvaluator {
+	return func(job *batchv1.Job) jobResult {
+		if hasJobCondition(job, successConditions) {
+			return jobSucceeded
+		}
+		if hasJobCondition(job, failureConditions) {
+			return jobFailed
+		}
+		return jobRunning
+	}
+}
+
+func hasJobCondition(job *batchv1.Job, conditionTypes []batchv1.JobConditionType) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		for _, conditionType := range conditionTypes {
+			if c.Type == conditionType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseJobConditionTypes(value string) []batchv1.JobConditionType {
+	var res []batchv1.JobConditionType
+	for _, conditionType := range strings.Split(value, ",") {
+		conditionType = strings.TrimSpace(conditionType)
+		if conditionType != "" {
+			res = append(res, batchv1.JobConditionType(conditionType))
+		}
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func TestGetJobResult(t *testing.T) {
+	newJob := func(succeeded, failed int32, conditions ...batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{Status: batchv1.JobStatus{Succeeded: succeeded, Failed: failed}}
+		for _, c := range conditions {
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{Type: c, Status: corev1.ConditionTrue})
+		}
+		return job
+	}
+
+	tests := []struct {
+		name              string
+		successConditions string
+		failureConditions string
+		job               *batchv1.Job
+		expected          jobResult
+	}{
+		{"running", "", "", newJob(0, 0), jobRunning},
+		{"succeeded pod", "", "", newJob(1, 0, batchv1.JobComplete), jobSucceeded},
+		{"failed pod", "", "", newJob(0, 1, batchv1.JobFailed), jobFailed},
+		{"failed pod without condition is retrying", "", "", newJob(0, 1), jobRetrying},
+		{"failed pods beyond the default backoff limit", "", "", newJob(0, 7), jobFailed},
+		{"failure target", "", "", newJob(0, 1, batchv1.JobFailureTarget), jobFailed},
+		{"success policy met", "", "", newJob(2, 0, batchv1.JobSuccessCriteriaMet), jobSucceeded},
+		{"success policy met despite a failed index", "", "", newJob(1, 1, batchv1.JobSuccessCriteriaMet), jobSucceeded},
+		{"false condition is ignored", "", "", &batchv1.Job{Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionFalse}},
+		}}, jobRunning},
+		{"custom success condition", "Verified", "", newJob(0, 0, "Verified"), jobSucceeded},
+		{"custom failure condition", "", "Rejected, FailureTarget", newJob(0, 0, batchv1.JobFailureTarget), jobFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("JOB_SUCCESS_CONDITIONS", test.successConditions)
+			t.Setenv("JOB_FAILURE_CONDITIONS", test.failureConditions)
+			c := &Controller{resultEvaluators: newJobResultEvaluators()}
+
+			assert.Equal(t, test.expected, c.getJobResult(test.job))
+		})
+	}
+}
+
+func TestGetJobResultCustomEvaluators(t *testing.T) {
+	alwaysFailed := func(job *batchv1.Job) jobResult { return jobFailed }
+	c := &Controller{resultEvaluators: []jobResultEvaluator{alwaysFailed, podCountResultEvaluator}}
+
+	assert.Equal(t, jobFailed, c.getJobResult(&batchv1.Job{Status: batchv1.JobStatus{Succeeded: 1}}))
+}
+
+func TestPodCountResultEvaluatorBackoffLimit(t *testing.T) {
+	job := &batchv1.Job{
+		Spec:   batchv1.JobSpec{BackoffLimit: utilpointer.Int32(2)},
+		Status: batchv1.JobStatus{Failed: 2},
+	}
+	assert.Equal(t, jobRetrying, podCountResultEvaluator(job))
+
+	job.Status.Failed = 3
+	assert.Equal(t, jobFailed, podCountResultEvaluator(job))
+}
+
+func TestParseJobConditionTypes(t *testing.T) {
+	assert.Nil(t, parseJobConditionTypes(""))
+	assert.Equal(t, []batchv1.JobConditionType{"Verified", "Approved"}, parseJobConditionTypes("Verified, Approved,"))
+}
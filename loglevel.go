@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// logFlags is the flag set klog registered its flags on.
+var logFlags = flag.CommandLine
+
+// setLogVerbosity changes the klog verbosity of the running process.
+func setLogVerbosity(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return fmt.Errorf("invalid log verbosity %q", value)
+	}
+	f := logFlags.Lookup("v")
+	if f == nil {
+		return fmt.Errorf("klog flags are not registered")
+	}
+	return f.Value.Set(value)
+}
+
+func getLogVerbosity() string {
+	f := logFlags.Lookup("v")
+	if f == nil {
+		return ""
+	}
+	return f.Value.String()
+}
+
+// newLogLevelHandler returns the handler of /debug/loglevel, nil unless LOG_LEVEL_TOKEN is set.
+// Requests must carry the token, e.g.
+// curl -X PUT -H "Authorization: Bearer $LOG_LEVEL_TOKEN" -d 4 localhost:9090/debug/loglevel.
+func newLogLevelHandler() http.HandlerFunc {
+	token := os.Getenv("LOG_LEVEL_TOKEN")
+	if token == "" {
+		return nil
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !bearerAuthorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		logLevelHandler(w, r)
+	}
+}
+
+// logLevelHandler serves /debug/loglevel. GET returns the klog verbosity, PUT sets it from
+// the request body.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		fmt.Fprintln(w, getLogVerbosity())
+	case http.MethodPut, http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 16))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		value := strings.TrimSpace(string(body))
+		if err := setLogVerbosity(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		klog.Infof("Log verbosity set to %s", value)
+		fmt.Fprintln(w, value)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
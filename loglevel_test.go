@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/klog"
+)
+
+func useLogFlags(t *testing.T) {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	original := logFlags
+	logFlags = fs
+	t.Cleanup(func() {
+		logFlags = original
+		fs.Set("v", "0")
+	})
+}
+
+func TestSetLogVerbosity(t *testing.T) {
+	useLogFlags(t)
+
+	assert.NoError(t, setLogVerbosity("4"))
+	assert.Equal(t, "4", getLogVerbosity())
+	assert.True(t, bool(klog.V(4)))
+
+	assert.Error(t, setLogVerbosity("-1"))
+	assert.Error(t, setLogVerbosity("debug"))
+	assert.Equal(t, "4", getLogVerbosity())
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	useLogFlags(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		code     int
+		expected string
+	}{
+		{"get", http.MethodGet, "", http.StatusOK, "0\n"},
+		{"put", http.MethodPut, "5\n", http.StatusOK, "5\n"},
+		{"get after put", http.MethodGet, "", http.StatusOK, "5\n"},
+		{"invalid", http.MethodPut, "verbose", http.StatusBadRequest, "invalid log verbosity \"verbose\"\n"},
+		{"method not allowed", http.MethodDelete, "", http.StatusMethodNotAllowed, "method not allowed\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			logLevelHandler(rec, httptest.NewRequest(test.method, "/debug/loglevel", strings.NewReader(test.body)))
+
+			assert.Equal(t, test.code, rec.Code)
+			assert.Equal(t, test.expected, rec.Body.String())
+		})
+	}
+}
+
+func TestNewLogLevelHandler(t *testing.T) {
+	useLogFlags(t)
+
+	t.Setenv("LOG_LEVEL_TOKEN", "")
+	assert.Nil(t, newLogLevelHandler())
+
+	t.Setenv("LOG_LEVEL_TOKEN", "secret")
+	handler := newLogLevelHandler()
+
+	tests := []struct {
+		name     string
+		token    string
+		code     int
+		expected string
+	}{
+		{"no token", "", http.StatusUnauthorized, "unauthorized\n"},
+		{"invalid token", "wrong", http.StatusUnauthorized, "unauthorized\n"},
+		{"valid token", "secret", http.StatusOK, "3\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/debug/loglevel", strings.NewReader("3"))
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			assert.Equal(t, test.code, rec.Code)
+			assert.Equal(t, test.expected, rec.Body.String())
+		})
+	}
+	// Only the authorized request changed the verbosity.
+	assert.Equal(t, "3", getLogVerbosity())
+}
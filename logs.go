@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	defaultLogTailLines    = 1000
+	defaultLogFetchTimeout = 30 * time.Second
+	// logHardLimitBytes caps the logs read when LOG_LIMIT_BYTES doesn't.
+	logHardLimitBytes      = 10 << 20
+	logFetchTimedOutMarker = "\n[log fetch timed out]"
+
+	// logTailLinesAnnotationName overrides LOG_TAIL_LINES for the logs of the job.
+	logTailLinesAnnotationName = "job-notify-controller/log-tail-lines"
+	// maxLogTailLines caps the annotation, so that a job can't post huge logs.
+	maxLogTailLines = 10000
+)
+
+// podLogs streams the logs of a container.
+type podLogs interface {
+	stream(ctx context.Context, pod corev1.Pod, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+}
+
+// clientsetPodLogs reads container logs through the pods/log API.
+type clientsetPodLogs struct {
+	clientset kubernetes.Interface
+}
+
+func (l clientsetPodLogs) stream(ctx context.Context, pod corev1.Pod, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return l.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+}
+
+// logFetcher reads container logs, bounded and redacted.
+type logFetcher struct {
+	logs podLogs
+	// tailLines and limitBytes bound the fetched logs, nil when unbounded.
+	tailLines  *int64
+	limitBytes *int64
+	// timeout bounds a fetch including the fallback to the previous container.
+	timeout time.Duration
+	// redactPatterns are the compiled LOG_REDACT_PATTERNS.
+	redactPatterns []*regexp.Regexp
+}
+
+// newLogFetcher returns nil when DISABLE_LOG_FETCH=true, so that the controller runs
+// without permission to get pods/log.
+func newLogFetcher(logs podLogs) *logFetcher {
+	if os.Getenv("DISABLE_LOG_FETCH") == "true" {
+		return nil
+	}
+	return &logFetcher{
+		logs:       logs,
+		tailLines:  getLogLimit("LOG_TAIL_LINES", defaultLogTailLines),
+		limitBytes: getLogLimit("LOG_LIMIT_BYTES", 0),
+		timeout:    getLogFetchTimeout(),
+
+		redactPatterns: getLogRedactPatterns(),
+	}
+}
+
+// forJob returns the fetcher with the tail lines of the log-tail-lines annotation, capped at
+// maxLogTailLines. An invalid annotation is logged and LOG_TAIL_LINES is used.
+func (f *logFetcher) forJob(annotations map[string]string) *logFetcher {
+	value := annotations[logTailLinesAnnotationName]
+	if f == nil || value == "" {
+		return f
+	}
+	tailLines, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || tailLines <= 0 {
+		klog.Errorf("Invalid %s annotation %q, using LOG_TAIL_LINES", logTailLinesAnnotationName, value)
+		return f
+	}
+	if tailLines > maxLogTailLines {
+		klog.Warningf("%s annotation %d exceeds the maximum, using %d", logTailLinesAnnotationName, tailLines, maxLogTailLines)
+		tailLines = maxLogTailLines
+	}
+	jobFetcher := *f
+	jobFetcher.tailLines = &tailLines
+	return &jobFetcher
+}
+
+func getLogFetchTimeout() time.Duration {
+	value := os.Getenv("LOG_FETCH_TIMEOUT")
+	if value == "" {
+		return defaultLogFetchTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		klog.Errorf("Invalid LOG_FETCH_TIMEOUT %q, using default %s: %v", value, defaultLogFetchTimeout, err)
+		return defaultLogFetchTimeout
+	}
+	return timeout
+}
+
+// getLogLimit reads a positive limit from the environment, 0 disables it.
+func getLogLimit(key string, defaultValue int64) *int64 {
+	limit := defaultValue
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			klog.Errorf("Invalid %s %q, using default %d", key, value, defaultValue)
+		} else {
+			limit = n
+		}
+	}
+	if limit == 0 {
+		return nil
+	}
+	return &limit
+}
+
+// fetch returns the redacted logs of the container. When the current container is gone
+// the logs of its previous instance are returned instead. On failure the error message is
+// returned in place of the logs along with the error. When the fetch times out the logs
+// read so far are returned with a marker.
+func (f *logFetcher) fetch(pod corev1.Pod, containerName string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	str, err := f.stream(ctx, pod, containerName, false)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("Logs of pod %s not found, trying the previous container: %v", pod.Name, err)
+		str, err = f.stream(ctx, pod, containerName, true)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		klog.Warningf("Log fetch of pod %s timed out after %s", pod.Name, f.timeout)
+		return redactLog(f.redactPatterns, str) + logFetchTimedOutMarker, nil
+	}
+	if err != nil {
+		return err.Error(), err
+	}
+	return redactLog(f.redactPatterns, str), nil
+}
+
+// stream reads at most LOG_LIMIT_BYTES, or logHardLimitBytes when unset. When ctx is done
+// the stream is closed and the logs read so far are returned with the context error.
+func (f *logFetcher) stream(ctx context.Context, pod corev1.Pod, containerName string, previous bool) (string, error) {
+	podLogs, err := f.logs.stream(ctx, pod, &corev1.PodLogOptions{
+		Container:  containerName,
+		TailLines:  f.tailLines,
+		LimitBytes: f.limitBytes,
+		Previous:   previous,
+	})
+	if err != nil {
+		return "", err
+	}
+	maxBytes := int64(logHardLimitBytes)
+	if f.limitBytes != nil {
+		maxBytes = *f.limitBytes
+	}
+
+	buf := &logBuffer{}
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(buf, io.LimitReader(podLogs, maxBytes))
+		copied <- err
+	}()
+	select {
+	case err = <-copied:
+	case <-ctx.Done():
+		podLogs.Close()
+		return buf.String(), ctx.Err()
+	}
+	if err != nil {
+		podLogs.Close()
+		return "", err
+	}
+	err = podLogs.Close()
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// logBuffer is a bytes.Buffer that can be read while a stream is still being copied into it.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
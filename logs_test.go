@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+// fakePodLogs answers log requests with respond and records their options.
+type fakePodLogs struct {
+	respond  func(opts *corev1.PodLogOptions) (io.ReadCloser, error)
+	requests []corev1.PodLogOptions
+}
+
+func (l *fakePodLogs) stream(ctx context.Context, pod corev1.Pod, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	l.requests = append(l.requests, *opts)
+	return l.respond(opts)
+}
+
+func logsBody(body string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(body))
+}
+
+func TestClientsetPodLogs(t *testing.T) {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+
+	log, err := newLogFetcher(clientsetPodLogs{clientset: fake.NewSimpleClientset()}).fetch(pod, "worker")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "fake logs", log)
+}
+
+func TestLogFetcherFetch(t *testing.T) {
+	t.Setenv("LOG_TAIL_LINES", "")
+	t.Setenv("LOG_LIMIT_BYTES", "65536")
+
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return logsBody("job done"), nil
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+
+	log, err := newLogFetcher(logs).fetch(pod, "worker")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "job done", log)
+	assert.Equal(t, []corev1.PodLogOptions{{
+		Container:  "worker",
+		TailLines:  utilpointer.Int64(1000),
+		LimitBytes: utilpointer.Int64(65536),
+	}}, logs.requests)
+}
+
+func TestLogFetcherFetchRedacts(t *testing.T) {
+	t.Setenv("LOG_REDACT_PATTERNS", "card-[0-9]{4}")
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return logsBody("charged card-1234 with password=hunter2"), nil
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+	f := newLogFetcher(logs)
+
+	// The patterns are compiled when the fetcher is built.
+	t.Setenv("LOG_REDACT_PATTERNS", "")
+	log, err := f.fetch(pod, "worker")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "charged *** with ***", log)
+}
+
+func TestLogFetcherFetchPrevious(t *testing.T) {
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			if !opts.Previous {
+				return nil, apierrors.NewNotFound(corev1.Resource("pods/log"), "test-pod")
+			}
+			return logsBody("previous logs"), nil
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+
+	log, err := newLogFetcher(logs).fetch(pod, "worker")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "previous logs", log)
+	assert.Len(t, logs.requests, 2)
+	assert.True(t, logs.requests[1].Previous)
+}
+
+func TestLogFetcherFetchNotFound(t *testing.T) {
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return nil, apierrors.NewNotFound(corev1.Resource("pods"), "test-pod")
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+
+	log, err := newLogFetcher(logs).fetch(pod, "worker")
+
+	assert.Error(t, err)
+	assert.Equal(t, err.Error(), log)
+	assert.Len(t, logs.requests, 2)
+}
+
+// stalledReader returns its data, then blocks until released like a stream that stopped sending.
+type stalledReader struct {
+	data    *strings.Reader
+	release chan struct{}
+}
+
+func (r *stalledReader) Read(p []byte) (int, error) {
+	if r.data.Len() > 0 {
+		return r.data.Read(p)
+	}
+	<-r.release
+	return 0, io.EOF
+}
+
+func TestLogFetcherFetchTimeout(t *testing.T) {
+	t.Setenv("LOG_FETCH_TIMEOUT", "50ms")
+
+	release := make(chan struct{})
+	defer close(release)
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return io.NopCloser(&stalledReader{data: strings.NewReader("first line\n"), release: release}), nil
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+
+	log, err := newLogFetcher(logs).fetch(pod, "worker")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "first line\n\n[log fetch timed out]", log)
+}
+
+func TestLogFetcherFetchHardLimit(t *testing.T) {
+	t.Setenv("LOG_LIMIT_BYTES", "")
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return logsBody(strings.Repeat("x", logHardLimitBytes+100)), nil
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}}
+
+	log, err := newLogFetcher(logs).fetch(pod, "worker")
+
+	assert.NoError(t, err)
+	assert.Len(t, log, logHardLimitBytes)
+}
+
+func TestGetLogFetchTimeout(t *testing.T) {
+	assert.Equal(t, defaultLogFetchTimeout, getLogFetchTimeout())
+
+	t.Setenv("LOG_FETCH_TIMEOUT", "5s")
+	assert.Equal(t, 5*time.Second, getLogFetchTimeout())
+
+	t.Setenv("LOG_FETCH_TIMEOUT", "-1s")
+	assert.Equal(t, defaultLogFetchTimeout, getLogFetchTimeout())
+}
+
+func TestGetLogLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected *int64
+	}{
+		{"Unset uses default", "", utilpointer.Int64(1000)},
+		{"Explicit limit", "200", utilpointer.Int64(200)},
+		{"Zero disables the limit", "0", nil},
+		{"Negative is invalid", "-1", utilpointer.Int64(1000)},
+		{"Not a number", "all", utilpointer.Int64(1000)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("LOG_TAIL_LINES", test.value)
+			assert.Equal(t, test.expected, getLogLimit("LOG_TAIL_LINES", defaultLogTailLines))
+		})
+	}
+}
+
+func TestLogFetcherForJob(t *testing.T) {
+	t.Setenv("LOG_TAIL_LINES", "")
+
+	tests := []struct {
+		name     string
+		value    string
+		expected *int64
+	}{
+		{"Unset uses LOG_TAIL_LINES", "", utilpointer.Int64(1000)},
+		{"Override", "5000", utilpointer.Int64(5000)},
+		{"Capped", "1000000", utilpointer.Int64(maxLogTailLines)},
+		{"Zero is invalid", "0", utilpointer.Int64(1000)},
+		{"Not a number", "all", utilpointer.Int64(1000)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newLogFetcher(&fakePodLogs{})
+			assert.Equal(t, test.expected, f.forJob(map[string]string{logTailLinesAnnotationName: test.value}).tailLines)
+			// The global fetcher is left as is.
+			assert.Equal(t, utilpointer.Int64(1000), f.tailLines)
+		})
+	}
+
+	var disabled *logFetcher
+	assert.Nil(t, disabled.forJob(map[string]string{logTailLinesAnnotationName: "5000"}))
+}
+
+func TestDisableLogFetch(t *testing.T) {
+	t.Setenv("DISABLE_LOG_FETCH", "true")
+	t.Setenv("LOG_URL_TEMPLATE", "https://logs.example.com/?ns={{.Namespace}}&job={{.JobName | urlquery}}")
+
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "worker"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return nil, apierrors.NewForbidden(corev1.Resource("pods/log"), "the-job-abcde", nil)
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+		Status:     batchv1.JobStatus{Failed: 1},
+	}
+	sub := &fakeSubscription{}
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod),
+		logs:          logs,
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+		errorReporter: newControllerErrorReporter(map[string]monitoring.Subscription{"fake": sub}, nil),
+	}
+
+	c.handleFailed(job, time.Now())
+
+	assert.Empty(t, logs.requests)
+	assert.Empty(t, sub.controllerErrors)
+	assert.Equal(t, []string{"failed"}, n.events)
+	assert.Empty(t, n.params[0].Log)
+	assert.Empty(t, n.params[0].PodLogs)
+	assert.Equal(t, "https://logs.example.com/?ns=test-ns&job=the-job", n.params[0].LogURL)
+}
+
+func TestHandleFailedPodLogs(t *testing.T) {
+	failedPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+		}
+	}
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return logsBody("panic: boom"), nil
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(1)},
+		Status:     batchv1.JobStatus{Failed: 2},
+	}
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod("the-job-abcde"), failedPod("the-job-fghij")),
+		logs:          logs,
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+	}
+
+	c.handleFailed(job, time.Now())
+
+	assert.Equal(t, []string{"failed"}, n.events)
+	assert.Equal(t, "panic: boom", n.params[0].Log)
+	assert.ElementsMatch(t, []notification.PodLog{
+		{PodName: "the-job-abcde", Log: "panic: boom"},
+		{PodName: "the-job-fghij", Log: "panic: boom"},
+	}, n.params[0].PodLogs)
+	assert.Len(t, logs.requests, 3)
+}
+
+func TestHandleFailedLogTailLines(t *testing.T) {
+	t.Setenv("LOG_TAIL_LINES", "200")
+
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	logs := &fakePodLogs{
+		respond: func(opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			return logsBody("panic: boom"), nil
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: utilpointer.Int32(0),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{logTailLinesAnnotationName: "5000"}},
+			},
+		},
+		Status: batchv1.JobStatus{Failed: 1},
+	}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod),
+		logs:          logs,
+		notifications: map[string]notification.Notification{"recording": &recordingNotification{}},
+		notifiedJobs:  make(map[string]bool),
+	}
+
+	c.handleFailed(job, time.Now())
+
+	assert.NotEmpty(t, logs.requests)
+	for _, request := range logs.requests {
+		assert.Equal(t, utilpointer.Int64(5000), request.TailLines)
+	}
+}
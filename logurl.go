@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"text/template"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+// logURLParam is the data LOG_URL_TEMPLATE and CLOUDWATCH_LOG_GROUP are rendered with.
+type logURLParam struct {
+	JobName     string
+	CronJobName string
+	Namespace   string
+}
+
+// jobDetailURLParam is the data JOB_DETAIL_URL_TEMPLATE is rendered with.
+type jobDetailURLParam struct {
+	logURLParam
+	ClusterName string
+	UID         string
+}
+
+// urlTemplateFuncs are the functions available in the URL templates besides the builtin
+// urlquery, which escapes query values.
+var urlTemplateFuncs = template.FuncMap{"pathescape": url.PathEscape}
+
+// getLogURL renders LOG_URL_TEMPLATE into a link to the logs of the job in an external
+// logging system, e.g. https://grafana.example.com/explore?job={{.JobName | urlquery}}.
+// Without it, CLOUDWATCH_LOG_GROUP links to a CloudWatch Logs Insights query of the job.
+func getLogURL(job *batchv1.Job, cronJobName string) string {
+	param := logURLParam{
+		JobName:     job.Name,
+		CronJobName: cronJobName,
+		Namespace:   job.Namespace,
+	}
+	if value := os.Getenv("LOG_URL_TEMPLATE"); value != "" {
+		return renderURLTemplate(job, "LOG_URL_TEMPLATE", value, param)
+	}
+	if value := os.Getenv("CLOUDWATCH_LOG_GROUP"); value != "" {
+		logGroup := renderURLTemplate(job, "CLOUDWATCH_LOG_GROUP", value, param)
+		if logGroup == "" {
+			return ""
+		}
+		return getCloudWatchLogURL(job, logGroup)
+	}
+	return ""
+}
+
+// getJobDetailURL renders JOB_DETAIL_URL_TEMPLATE into a link to the job in another tool,
+// e.g. Headlamp: https://headlamp.example.com/c/{{.ClusterName}}/jobs/{{.Namespace}}/{{.JobName}}.
+func getJobDetailURL(job *batchv1.Job, cronJobName string, clusterName string) string {
+	value := os.Getenv("JOB_DETAIL_URL_TEMPLATE")
+	if value == "" {
+		return ""
+	}
+	return renderURLTemplate(job, "JOB_DETAIL_URL_TEMPLATE", value, jobDetailURLParam{
+		logURLParam: logURLParam{
+			JobName:     job.Name,
+			CronJobName: cronJobName,
+			Namespace:   job.Namespace,
+		},
+		ClusterName: clusterName,
+		UID:         string(job.UID),
+	})
+}
+
+// renderURLTemplate renders the template set in the environment variable key, or
+// returns an empty string when it is invalid.
+func renderURLTemplate(job *batchv1.Job, key string, value string, param any) string {
+	tpl, err := template.New(key).Funcs(urlTemplateFuncs).Parse(value)
+	if err != nil {
+		klog.Errorf("Invalid %s %q: %v", key, value, err)
+		return ""
+	}
+	var b bytes.Buffer
+	if err = tpl.Execute(&b, param); err != nil {
+		klog.Errorf("Render %s failed: %s: %v", key, jobLogFields(job, "log_url"), err)
+		return ""
+	}
+	return b.String()
+}
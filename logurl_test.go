@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetLogURL(t *testing.T) {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job-123", Namespace: "test-ns"}}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"Unset", "", ""},
+		{"Job", "https://logs.example.com/{{.Namespace}}/{{.JobName}}", "https://logs.example.com/test-ns/the-job-123"},
+		{"CronJob", "https://logs.example.com/?q={{.CronJobName | urlquery}}", "https://logs.example.com/?q=the+cronjob"},
+		{"Invalid template", "https://logs.example.com/{{.JobName", ""},
+		{"Unknown field", "https://logs.example.com/{{.Pod}}", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("LOG_URL_TEMPLATE", test.template)
+			assert.Equal(t, test.expected, getLogURL(job, "the cronjob"))
+		})
+	}
+}
+
+func TestGetJobDetailURL(t *testing.T) {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job-123", Namespace: "test ns", UID: "abc-123"}}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"Unset", "", ""},
+		{"Headlamp", "https://headlamp.example.com/c/{{.ClusterName}}/jobs/{{.Namespace | pathescape}}/{{.JobName | pathescape}}", "https://headlamp.example.com/c/prod/jobs/test%20ns/the-job-123"},
+		{"Query", "https://argo.example.com/jobs?namespace={{.Namespace | urlquery}}&cronjob={{.CronJobName | urlquery}}&uid={{.UID}}", "https://argo.example.com/jobs?namespace=test+ns&cronjob=the+cronjob%2Fdaily&uid=abc-123"},
+		{"Invalid template", "https://headlamp.example.com/{{.JobName", ""},
+		{"Unknown field", "https://headlamp.example.com/{{.Pod}}", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("JOB_DETAIL_URL_TEMPLATE", test.template)
+			assert.Equal(t, test.expected, getJobDetailURL(job, "the cronjob/daily", "prod"))
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
 	"github.com/yutachaos/kube-job-notifier/pkg/signals"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -14,15 +15,30 @@ import (
 )
 
 var (
-	masterURL  string
-	kubeconfig string
-	kubeClient kubernetes.Interface
+	masterURL        string
+	kubeconfig       string
+	kubeClient       kubernetes.Interface
+	testNotification bool
 )
 
 func main() {
 	klog.InitFlags(nil)
 	flag.Parse()
 
+	if v := os.Getenv("LOG_VERBOSITY"); v != "" {
+		if err := setLogVerbosity(v); err != nil {
+			klog.Errorf("Invalid LOG_VERBOSITY: %v", err)
+		}
+	}
+
+	if testNotification {
+		if err := sendTestNotifications(notification.NewNotifications()); err != nil {
+			klog.Fatalf("Error sending test notification: %s", err.Error())
+		}
+		klog.Info("Test notifications sent")
+		return
+	}
+
 	stopCh := signals.SetupSignalHandler()
 
 	if _, err := rest.InClusterConfig(); err != nil {
@@ -54,11 +70,16 @@ func main() {
 
 	controller := NewController(kubeClient, kubeInformerFactory.Batch().V1().Jobs())
 
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go serveMetrics(addr, controller)
+	}
+
 	kubeInformerFactory.Start(stopCh)
 
 	if err := controller.Run(stopCh); err != nil {
 		klog.Fatalf("Error running controller: %s", err.Error())
 	}
+	kubeInformerFactory.Shutdown()
 }
 
 func init() {
@@ -67,4 +88,5 @@ func init() {
 	// set kubeconfig flag
 	flag.StringVar(&kubeconfig, "kubeconfig", defaultPath, "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flag.BoolVar(&testNotification, "test-notification", false, "Send sample start, success and failed notifications to the configured backends and exit.")
 }
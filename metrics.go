@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog"
+)
+
+var notificationLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "kube_job_notifier_notification_latency_seconds",
+	Help:    "Time from a job transition being observed to its notification being sent, per notifier.",
+	Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 1200},
+}, []string{"notifier", "event"})
+
+func init() {
+	prometheus.MustRegister(notificationLatency)
+}
+
+// serveMetrics exposes the Prometheus metrics and, when LOG_LEVEL_TOKEN and RESEND_TOKEN are
+// set, the log level and resend handlers of the controller on addr until the process exits.
+func serveMetrics(addr string, controller *Controller) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if handler := newLogLevelHandler(); handler != nil {
+		mux.HandleFunc("/debug/loglevel", handler)
+	}
+	if controller.resends != nil {
+		mux.HandleFunc("/resend", controller.resendHandler)
+	}
+	klog.Infof("Serving metrics on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		klog.Errorf("Serve metrics failed: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func getNotificationLatency(t *testing.T, name string, event string) *dto.Histogram {
+	m := &dto.Metric{}
+	assert.NoError(t, notificationLatency.WithLabelValues(name, event).(prometheus.Metric).Write(m))
+	return m.GetHistogram()
+}
+
+func TestRecordNotifyResultLatency(t *testing.T) {
+	notificationLatency.Reset()
+	observedAt := time.Now().Add(-2 * time.Second)
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"}}
+	c := &Controller{}
+
+	c.recordNotifyResult("slack", notification.FAILED, job, observedAt, notification.NotifyResult{Channel: "C0123"}, nil)
+	c.recordNotifyResult("slack", notification.START, job, observedAt, notification.NotifyResult{SkippedReason: notification.SkippedDisabled}, nil)
+	c.recordNotifyResult("lark", notification.FAILED, job, observedAt, notification.NotifyResult{}, errors.New("timeout"))
+
+	sent := getNotificationLatency(t, "slack", notification.FAILED)
+	assert.Equal(t, uint64(1), sent.GetSampleCount())
+	assert.GreaterOrEqual(t, sent.GetSampleSum(), 2.0)
+	assert.Equal(t, uint64(0), getNotificationLatency(t, "slack", notification.START).GetSampleCount())
+	assert.Equal(t, uint64(0), getNotificationLatency(t, "lark", notification.FAILED).GetSampleCount())
+}
+
+func TestHandleFailedRecordsLatency(t *testing.T) {
+	notificationLatency.Reset()
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "the-job-abcde",
+			Namespace: "test-ns",
+			Labels:    map[string]string{searchLabel: "test"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+		Status:     batchv1.JobStatus{Failed: 1},
+	}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod),
+		notifications: map[string]notification.Notification{"recording": &recordingNotification{}},
+		notifiedJobs:  make(map[string]bool),
+	}
+
+	c.handleFailed(job, time.Now())
+
+	assert.Equal(t, uint64(1), getNotificationLatency(t, "recording", notification.FAILED).GetSampleCount())
+}
+
+func TestRecordNotifyResultSubscriptions(t *testing.T) {
+	t.Setenv("OTEL_ENABLED", "true")
+	sub := &fakeSubscription{}
+	c := &Controller{subscriptions: map[string]monitoring.Subscription{"fake": sub}}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"}}
+
+	c.recordNotifyResult("slack", notification.FAILED, job, time.Now(), notification.NotifyResult{Channel: "C0123"}, nil)
+	c.recordNotifyResult("lark", notification.FAILED, job, time.Now(), notification.NotifyResult{}, errors.New("timeout"))
+
+	assert.Equal(t, []string{"slack/failed"}, sub.notifications)
+}
+
+// getJobWaitSeconds returns the kube_job_notifier_job_wait_seconds histogram of a job from
+// the default registry the Prometheus subscription records in.
+func getJobWaitSeconds(t *testing.T, jobName string, status string) *dto.Histogram {
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "kube_job_notifier_job_wait_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["job_name"] == jobName && labels["status"] == status {
+				return m.GetHistogram()
+			}
+		}
+	}
+	return nil
+}
+
+func TestHandleSucceededRecordsWaitSeconds(t *testing.T) {
+	t.Setenv("PROMETHEUS_ENABLED", "true")
+	created := metav1.NewTime(time.Now().Add(-time.Minute))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "wait-seconds-job-a", Namespace: "test-ns", Labels: map[string]string{searchLabel: "wait-seconds"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, StartTime: &metav1.Time{Time: created.Add(12 * time.Second)}},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "wait-seconds-job", Namespace: "test-ns", UID: "wait-seconds", CreationTimestamp: created},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+	}
+	clientset := fake.NewSimpleClientset(pod)
+	c := &Controller{
+		kubeclientset: clientset,
+		notifications: map[string]notification.Notification{"recording": &recordingNotification{}},
+		subscriptions: monitoring.NewSubscription(),
+		notifiedJobs:  make(map[string]bool),
+	}
+
+	c.notifyStart(job, time.Now())
+
+	// The wait time computed when the job started is reused when it completes.
+	pod.Status.Phase = corev1.PodSucceeded
+	pod.Status.StartTime = &metav1.Time{Time: created.Add(30 * time.Second)}
+	_, err := clientset.CoreV1().Pods("test-ns").UpdateStatus(context.TODO(), pod, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	job.Status = batchv1.JobStatus{Succeeded: 1, StartTime: &created, CompletionTime: &metav1.Time{Time: created.Add(time.Minute)}}
+
+	c.handleSucceeded(job, time.Now())
+
+	waitSeconds := getJobWaitSeconds(t, "wait-seconds-job", "success")
+	if assert.NotNil(t, waitSeconds) {
+		assert.Equal(t, uint64(1), waitSeconds.GetSampleCount())
+		assert.Equal(t, 12.0, waitSeconds.GetSampleSum())
+	}
+}
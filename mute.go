@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+// muteConfigMapName is the ConfigMap that mutes every notification of the cluster, e.g.
+// during a planned maintenance. It is read before each notification, so that operators can
+// flip it without redeploying the controller.
+const muteConfigMapName = "job-notify-controller-mute"
+
+// muteConfigMap reads the mute ConfigMap from the namespace of the controller.
+type muteConfigMap struct {
+	kubeclientset kubernetes.Interface
+	namespace     string
+}
+
+// muteState is the content of the mute ConfigMap.
+type muteState struct {
+	muted bool
+	// logFailures logs the muted failures with their details, so they can be reviewed
+	// after the maintenance.
+	logFailures bool
+}
+
+// getMuteConfigMap returns nil when the namespace of the controller is unknown. It is
+// MUTE_NAMESPACE, or POD_NAMESPACE set by the chart.
+func getMuteConfigMap(kubeclientset kubernetes.Interface) *muteConfigMap {
+	namespace := os.Getenv("MUTE_NAMESPACE")
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if namespace == "" {
+		return nil
+	}
+	return &muteConfigMap{kubeclientset: kubeclientset, namespace: namespace}
+}
+
+// state returns whether notifications are muted at now. Without the ConfigMap, or when it
+// can't be read, notifications are sent.
+func (m *muteConfigMap) state(now time.Time) muteState {
+	configMap, err := m.kubeclientset.CoreV1().ConfigMaps(m.namespace).Get(context.TODO(), muteConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.Errorf("Get mute ConfigMap failed: namespace=%s name=%s: %v", m.namespace, muteConfigMapName, err)
+		}
+		return muteState{}
+	}
+	return muteState{
+		muted:       configMap.Data["muted"] == "true" || inMuteWindow(configMap, now),
+		logFailures: configMap.Data["logFailures"] == "true",
+	}
+}
+
+// inMuteWindow reports whether now is between the start and end RFC 3339 times of the
+// ConfigMap. An omitted bound leaves the window open on that side, an invalid one doesn't mute.
+func inMuteWindow(configMap *corev1.ConfigMap, now time.Time) bool {
+	start, end := configMap.Data["start"], configMap.Data["end"]
+	if start == "" && end == "" {
+		return false
+	}
+	if start != "" {
+		startTime, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			klog.Errorf("Invalid start %q in mute ConfigMap, not muting: %v", start, err)
+			return false
+		}
+		if now.Before(startTime) {
+			return false
+		}
+	}
+	if end != "" {
+		endTime, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			klog.Errorf("Invalid end %q in mute ConfigMap, not muting: %v", end, err)
+			return false
+		}
+		if !now.Before(endTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// isMutedNotification reports whether the notification is muted by the mute ConfigMap.
+// Monitoring still records the job.
+func (c *Controller) isMutedNotification(job *batchv1.Job, event string, messageParam notification.MessageTemplateParam, observedAt time.Time) bool {
+	if c.mutes == nil {
+		return false
+	}
+	state := c.mutes.state(observedAt)
+	if !state.muted {
+		return false
+	}
+	if event == notification.FAILED && state.logFailures {
+		klog.Warningf("Muted failure: %s failed=%d backoff_limit=%d exit_code=%d",
+			jobLogFields(job, event), messageParam.FailedCount, messageParam.BackoffLimit, messageParam.ExitCode)
+	} else {
+		klog.V(4).Infof("Muted notification: %s", jobLogFields(job, event))
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func newMuteConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: muteConfigMapName, Namespace: "kube-job-notifier"},
+		Data:       data,
+	}
+}
+
+func TestGetMuteConfigMap(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	t.Setenv("MUTE_NAMESPACE", "")
+	t.Setenv("POD_NAMESPACE", "")
+	assert.Nil(t, getMuteConfigMap(clientset))
+
+	t.Setenv("POD_NAMESPACE", "kube-job-notifier")
+	assert.Equal(t, "kube-job-notifier", getMuteConfigMap(clientset).namespace)
+
+	t.Setenv("MUTE_NAMESPACE", "ops")
+	assert.Equal(t, "ops", getMuteConfigMap(clientset).namespace)
+}
+
+func TestMuteConfigMapState(t *testing.T) {
+	now := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		expected muteState
+	}{
+		{"no ConfigMap", nil, muteState{}},
+		{"muted", []runtime.Object{newMuteConfigMap(map[string]string{"muted": "true"})}, muteState{muted: true}},
+		{"unmuted", []runtime.Object{newMuteConfigMap(map[string]string{"muted": "false"})}, muteState{}},
+		{"muted logging failures", []runtime.Object{newMuteConfigMap(map[string]string{"muted": "true", "logFailures": "true"})}, muteState{muted: true, logFailures: true}},
+		{"within window", []runtime.Object{newMuteConfigMap(map[string]string{"start": "2020-11-28T00:00:00Z", "end": "2020-11-28T02:00:00Z"})}, muteState{muted: true}},
+		{"before window", []runtime.Object{newMuteConfigMap(map[string]string{"start": "2020-11-28T01:30:00Z", "end": "2020-11-28T02:00:00Z"})}, muteState{}},
+		{"after window", []runtime.Object{newMuteConfigMap(map[string]string{"start": "2020-11-28T00:00:00Z", "end": "2020-11-28T01:00:00Z"})}, muteState{}},
+		{"open ended window", []runtime.Object{newMuteConfigMap(map[string]string{"start": "2020-11-28T09:00:00+09:00"})}, muteState{muted: true}},
+		{"invalid window", []runtime.Object{newMuteConfigMap(map[string]string{"start": "tonight"})}, muteState{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &muteConfigMap{kubeclientset: fake.NewSimpleClientset(test.objects...), namespace: "kube-job-notifier"}
+			assert.Equal(t, test.expected, m.state(now))
+		})
+	}
+}
+
+func TestHandleFailedMuted(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+
+	tests := []struct {
+		name     string
+		muted    string
+		expected []string
+	}{
+		{"muted", "true", nil},
+		{"unmuted", "false", []string{"failed"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failedPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "the-job-abcde",
+					Namespace: "test-ns",
+					Labels:    map[string]string{searchLabel: "test"},
+				},
+				Status: corev1.PodStatus{Phase: corev1.PodFailed},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+				Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+				Status:     batchv1.JobStatus{Failed: 1},
+			}
+			clientset := fake.NewSimpleClientset(failedPod, newMuteConfigMap(map[string]string{"muted": test.muted}))
+			n := &recordingNotification{}
+			sub := &fakeSubscription{}
+			c := &Controller{
+				kubeclientset: clientset,
+				notifications: map[string]notification.Notification{"recording": n},
+				subscriptions: map[string]monitoring.Subscription{"fake": sub},
+				notifiedJobs:  make(map[string]bool),
+				mutes:         &muteConfigMap{kubeclientset: clientset, namespace: "kube-job-notifier"},
+			}
+
+			c.handleFailed(job, time.Now())
+
+			assert.Equal(t, test.expected, n.events)
+			// Muting only silences the notifications, the job is still monitored.
+			assert.Len(t, sub.failed, 1)
+		})
+	}
+}
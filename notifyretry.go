@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+const (
+	defaultNotifyMaxAttempts    = 1
+	defaultNotifyRetryBaseDelay = 5 * time.Second
+	notifyRetryMaxDelay         = 5 * time.Minute
+)
+
+// errCircuitOpen fails an attempt skipped by the circuit breaker of the notifier.
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// notificationRetry is a notification to send again.
+type notificationRetry struct {
+	name         string
+	event        string
+	messageParam notification.MessageTemplateParam
+	notify       func() (notification.NotifyResult, error)
+}
+
+// notificationRetrier sends failed notifications again from a rate limited queue, with an
+// exponential backoff, until they have been attempted maxAttempts times.
+type notificationRetrier struct {
+	maxAttempts int
+	queue       workqueue.TypedRateLimitingInterface[*notificationRetry]
+	// pending counts the queued notifications, including those waiting for their backoff.
+	pending atomic.Int32
+}
+
+// newNotificationRetrier returns nil when NOTIFY_MAX_ATTEMPTS is 1, the default, and failed
+// notifications are dropped.
+func newNotificationRetrier() *notificationRetrier {
+	maxAttempts := getNotifyMaxAttempts()
+	if maxAttempts <= 1 {
+		return nil
+	}
+	r := &notificationRetrier{
+		maxAttempts: maxAttempts,
+		queue: workqueue.NewTypedRateLimitingQueue(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[*notificationRetry](defaultNotifyRetryBaseDelay, notifyRetryMaxDelay)),
+	}
+	go r.run()
+	return r
+}
+
+func getNotifyMaxAttempts() int {
+	value := os.Getenv("NOTIFY_MAX_ATTEMPTS")
+	if value == "" {
+		return defaultNotifyMaxAttempts
+	}
+	maxAttempts, err := strconv.Atoi(value)
+	if err != nil || maxAttempts < 1 {
+		klog.Errorf("Invalid NOTIFY_MAX_ATTEMPTS %q, using default %d: %v", value, defaultNotifyMaxAttempts, err)
+		return defaultNotifyMaxAttempts
+	}
+	return maxAttempts
+}
+
+// requeue schedules a notification whose first attempt failed.
+func (r *notificationRetrier) requeue(retry *notificationRetry) {
+	klog.Infof("Notification will be retried: notifier=%s event=%s namespace=%s job=%s",
+		retry.name, retry.event, retry.messageParam.Namespace, retry.messageParam.JobName)
+	r.pending.Add(1)
+	r.queue.AddRateLimited(retry)
+}
+
+func (r *notificationRetrier) run() {
+	for r.process() {
+	}
+}
+
+func (r *notificationRetrier) process() bool {
+	retry, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(retry)
+
+	// The first attempt was made before the notification was queued.
+	attempt := r.queue.NumRequeues(retry) + 1
+	result, err := retry.notify()
+	err = attemptError(result, err)
+	switch {
+	case err == nil:
+		r.pending.Add(-1)
+		r.queue.Forget(retry)
+		klog.Infof("Sent notification on attempt %d: notifier=%s event=%s namespace=%s job=%s skipped=%s",
+			attempt, retry.name, retry.event, retry.messageParam.Namespace, retry.messageParam.JobName, result.SkippedReason)
+	case attempt < r.maxAttempts:
+		klog.Warningf("Failed notification on attempt %d/%d, retrying: notifier=%s event=%s namespace=%s job=%s: %v",
+			attempt, r.maxAttempts, retry.name, retry.event, retry.messageParam.Namespace, retry.messageParam.JobName, err)
+		r.queue.AddRateLimited(retry)
+	default:
+		r.pending.Add(-1)
+		r.queue.Forget(retry)
+		klog.Errorf("Failed notification after %d attempts, dropping it: notifier=%s event=%s namespace=%s job=%s: %v",
+			attempt, retry.name, retry.event, retry.messageParam.Namespace, retry.messageParam.JobName, err)
+	}
+	return true
+}
+
+// attemptError fails an attempt that a notifier paused by its circuit breaker skipped, so
+// that it is made again later.
+func attemptError(result notification.NotifyResult, err error) error {
+	if err == nil && result.SkippedReason == notification.SkippedCircuitOpen {
+		return errCircuitOpen
+	}
+	return err
+}
+
+// shutdown drops the notifications waiting to be retried.
+func (r *notificationRetrier) shutdown() {
+	if r == nil {
+		return
+	}
+	if pending := r.pending.Load(); pending > 0 {
+		klog.Warningf("Dropping %d notifications waiting to be retried", pending)
+	}
+	r.queue.ShutDown()
+}
+
+// retryNotification queues the failed notifications of a notifier to be sent again.
+type retryNotification struct {
+	notification.Notification
+	name    string
+	retrier *notificationRetrier
+}
+
+func (r retryNotification) call(event string, messageParam notification.MessageTemplateParam, notify func() (notification.NotifyResult, error)) (notification.NotifyResult, error) {
+	result, err := notify()
+	if attemptError(result, err) != nil {
+		r.retrier.requeue(&notificationRetry{name: r.name, event: event, messageParam: messageParam, notify: notify})
+	}
+	return result, err
+}
+
+func (r retryNotification) NotifyStart(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return r.call(notification.START, messageParam, func() (notification.NotifyResult, error) { return r.Notification.NotifyStart(messageParam) })
+}
+
+func (r retryNotification) NotifySuccess(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return r.call(notification.SUCCESS, messageParam, func() (notification.NotifyResult, error) { return r.Notification.NotifySuccess(messageParam) })
+}
+
+func (r retryNotification) NotifyFailed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return r.call(notification.FAILED, messageParam, func() (notification.NotifyResult, error) { return r.Notification.NotifyFailed(messageParam) })
+}
+
+func (r retryNotification) NotifySuspended(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return r.call(notification.SUSPENDED, messageParam, func() (notification.NotifyResult, error) { return r.Notification.NotifySuspended(messageParam) })
+}
+
+func (r retryNotification) NotifyResumed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return r.call(notification.RESUMED, messageParam, func() (notification.NotifyResult, error) { return r.Notification.NotifyResumed(messageParam) })
+}
+
+func (r retryNotification) NotifySpecChanged(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return r.call(notification.SPEC_CHANGED, messageParam, func() (notification.NotifyResult, error) { return r.Notification.NotifySpecChanged(messageParam) })
+}
+
+// withRetries retries the failed notifications of every notifier, nil retrier disables it.
+// It wraps the circuit breakers, so that attempts skipped by an open circuit are retried
+// like failed ones once it closes.
+func withRetries(retrier *notificationRetrier, notifications map[string]notification.Notification) map[string]notification.Notification {
+	if retrier == nil {
+		return notifications
+	}
+	res := make(map[string]notification.Notification, len(notifications))
+	for name, n := range notifications {
+		res[name] = retryNotification{Notification: n, name: name, retrier: retrier}
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// flakyNotification fails the first failures calls of NotifyFailed.
+type flakyNotification struct {
+	recordingNotification
+	failures int32
+	calls    atomic.Int32
+}
+
+func (n *flakyNotification) NotifyFailed(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	if n.calls.Add(1) <= n.failures {
+		return notification.NotifyResult{}, errors.New("service unavailable")
+	}
+	return notification.NotifyResult{Channel: "recording"}, nil
+}
+
+func newTestNotificationRetrier(maxAttempts int) *notificationRetrier {
+	r := &notificationRetrier{
+		maxAttempts: maxAttempts,
+		queue: workqueue.NewTypedRateLimitingQueue(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[*notificationRetry](time.Millisecond, 10*time.Millisecond)),
+	}
+	go r.run()
+	return r
+}
+
+func TestNotificationRetrier(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int32
+		maxAttempts   int
+		expectedCalls int32
+	}{
+		{"sent on retry", 2, 3, 3},
+		{"dropped after max attempts", 10, 3, 3},
+		{"sent right away", 0, 3, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			retrier := newTestNotificationRetrier(test.maxAttempts)
+			defer retrier.shutdown()
+			inner := &flakyNotification{failures: test.failures}
+			n := withRetries(retrier, map[string]notification.Notification{"slack": inner})["slack"]
+
+			_, err := n.NotifyFailed(notification.MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+
+			assert.Equal(t, test.failures > 0, err != nil)
+			assert.Eventually(t, func() bool { return inner.calls.Load() == test.expectedCalls }, time.Second, time.Millisecond)
+			// No attempt is made past the maximum.
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, test.expectedCalls, inner.calls.Load())
+		})
+	}
+}
+
+func TestNotificationRetrierCircuitOpen(t *testing.T) {
+	retrier := newTestNotificationRetrier(20)
+	defer retrier.shutdown()
+	inner := &flakyNotification{failures: 1}
+	// The first failure opens the circuit, the retries are skipped until the cooldown passed.
+	breaker := &circuitBreaker{name: "slack", threshold: 1, cooldown: 30 * time.Millisecond, now: time.Now}
+	n := withRetries(retrier, map[string]notification.Notification{
+		"slack": breakerNotification{Notification: inner, breaker: breaker},
+	})["slack"]
+
+	_, err := n.NotifyFailed(notification.MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+
+	assert.Error(t, err)
+	// The skipped retries are kept and the notification is sent once the circuit closes.
+	assert.Eventually(t, func() bool { return inner.calls.Load() == 2 }, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return retrier.pending.Load() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestNotificationRetrierFirstAttemptCircuitOpen(t *testing.T) {
+	retrier := newTestNotificationRetrier(20)
+	defer retrier.shutdown()
+	inner := &flakyNotification{}
+	// An earlier failure opened the circuit, so the first attempt is skipped.
+	breaker := &circuitBreaker{name: "slack", threshold: 1, cooldown: 30 * time.Millisecond, now: time.Now}
+	breaker.record(errors.New("service unavailable"))
+	n := withRetries(retrier, map[string]notification.Notification{
+		"slack": breakerNotification{Notification: inner, breaker: breaker},
+	})["slack"]
+
+	result, err := n.NotifyFailed(notification.MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, notification.SkippedCircuitOpen, result.SkippedReason)
+	assert.Eventually(t, func() bool { return inner.calls.Load() == 1 }, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool { return retrier.pending.Load() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestWithRetriesDisabled(t *testing.T) {
+	notifications := map[string]notification.Notification{"slack": &recordingNotification{}}
+
+	assert.Equal(t, notifications, withRetries(nil, notifications))
+}
+
+func TestGetNotifyMaxAttempts(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"", defaultNotifyMaxAttempts},
+		{"5", 5},
+		{"1", 1},
+		{"0", defaultNotifyMaxAttempts},
+		{"many", defaultNotifyMaxAttempts},
+	}
+	assert.Equal(t, 1, defaultNotifyMaxAttempts, "retries are opt-in")
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv("NOTIFY_MAX_ATTEMPTS", test.value)
+			assert.Equal(t, test.expected, getNotifyMaxAttempts())
+		})
+	}
+
+	t.Setenv("NOTIFY_MAX_ATTEMPTS", "")
+	assert.Nil(t, newNotificationRetrier())
+}
@@ -1,21 +1,57 @@
 package monitoring
 
 import (
+	"bytes"
+	"os"
+	"strconv"
+	"text/template"
+	"time"
+
 	"github.com/DataDog/datadog-go/statsd"
 	"k8s.io/klog"
-	"os"
 )
 
 const (
 	defaultStatsAddrUDS           = "unix:///var/run/datadog/dsd.socket"
 	hostName                      = "kube-job-notifier"
 	serviceCheckName              = "kube_job_notifier.job.status"
+	watchErrorsMetricName         = "kube_job_notifier.watch.errors"
+	controllerErrorsMetricName    = "kube_job_notifier.controller.errors"
+	jobCountMetricName            = "kube_job_notifier.job.count"
+	jobStartedMetricName          = "kube_job_notifier.job.started"
+	jobDurationMetricName         = "kube_job_notifier.job.duration"
+	jobWaitMetricName             = "kube_job_notifier.job.wait_seconds"
+	statusSuccess                 = "success"
+	statusFailed                  = "failed"
+	defaultSampleRate             = 1.0
 	suppressSuccessAnnotationName = "kube-job-notifier/suppress-success-datadog-subscription"
 	suppressFailedAnnotationName  = "kube-job-notifier/suppress-failed-datadog-subscription"
+	severityAnnotationName        = "kube-job-notifier/severity"
 )
 
+type statsdClient interface {
+	ServiceCheck(sc *statsd.ServiceCheck) error
+	Incr(name string, tags []string, rate float64) error
+	Histogram(name string, value float64, tags []string, rate float64) error
+	Flush() error
+}
+
 type datadog struct {
-	client *statsd.Client
+	client statsdClient
+	// rate is the sample rate of metrics. Service checks are always sent.
+	rate float64
+	// message renders the service check message, nil for the fixed messages.
+	message *template.Template
+	// tagJobName tags the metrics of jobs without a CronJob with their job name.
+	tagJobName bool
+}
+
+// datadogMessageParam is rendered by DD_MESSAGE_TEMPLATE. JobName is the CronJob name when
+// available and Status is success or failed.
+type datadogMessageParam struct {
+	JobInfo
+	JobName string
+	Status  string
 }
 
 func newDatadog() datadog {
@@ -30,6 +66,10 @@ func newDatadog() datadog {
 		client.Tags = []string{tags}
 	}
 
+	if cluster := os.Getenv("CLUSTER_NAME"); cluster != "" {
+		client.Tags = append(client.Tags, "cluster:"+cluster)
+	}
+
 	namespace := os.Getenv("DD_NAMESPACE")
 
 	if namespace != "" {
@@ -37,11 +77,87 @@ func newDatadog() datadog {
 	}
 
 	return datadog{
-		client: client,
+		client:  client,
+		rate:    getSampleRate(),
+		message: getMessageTemplate(),
+
+		tagJobName: os.Getenv("DD_TAG_JOB_NAME") == "true",
+	}
+}
+
+// metricTags returns the job_name and namespace tags of the job metrics. job_name is the
+// CronJob name, the name of a job without a CronJob, unbounded for generated names, is only
+// tagged with DD_TAG_JOB_NAME=true.
+func (d datadog) metricTags(jobInfo JobInfo) []string {
+	var tags []string
+	if jobInfo.CronJobName != "" || d.tagJobName {
+		tags = append(tags, "job_name:"+jobInfo.getJobName())
+	}
+	return append(tags, "namespace:"+jobInfo.Namespace)
+}
+
+// getMessageTemplate parses DD_MESSAGE_TEMPLATE at startup so that a broken template is
+// reported once. An invalid template falls back to the fixed messages.
+func getMessageTemplate() *template.Template {
+	value := os.Getenv("DD_MESSAGE_TEMPLATE")
+	if value == "" {
+		return nil
+	}
+	tpl, err := template.New("DD_MESSAGE_TEMPLATE").Option("missingkey=error").Parse(value)
+	if err == nil {
+		err = tpl.Execute(&bytes.Buffer{}, datadogMessageParam{Status: statusFailed})
+	}
+	if err != nil {
+		klog.Errorf("Invalid DD_MESSAGE_TEMPLATE %q, using the default messages: %v", value, err)
+		return nil
 	}
+	return tpl
+}
+
+// getMessage renders the service check message of the job, or defaultMessage without a template.
+func (d datadog) getMessage(jobInfo JobInfo, status string, defaultMessage string) string {
+	if d.message == nil {
+		return defaultMessage
+	}
+	var b bytes.Buffer
+	err := d.message.Execute(&b, datadogMessageParam{JobInfo: jobInfo, JobName: jobInfo.getJobName(), Status: status})
+	if err != nil {
+		klog.Errorf("Render DD_MESSAGE_TEMPLATE failed for %s: %v", jobInfo.Name, err)
+		return defaultMessage
+	}
+	return b.String()
+}
+
+func getSampleRate() float64 {
+	value := os.Getenv("DD_SAMPLE_RATE")
+	if value == "" {
+		return defaultSampleRate
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 || rate > 1 {
+		klog.Errorf("Invalid DD_SAMPLE_RATE %q, must be within (0,1]. Using %v", value, defaultSampleRate)
+		return defaultSampleRate
+	}
+	return rate
+}
+
+func (d datadog) StartEvent(jobInfo JobInfo) (err error) {
+	if !isSubscribeFromEnv("DD_NOTIFY_ON_START") {
+		return nil
+	}
+	err = d.client.Incr(jobStartedMetricName, d.metricTags(jobInfo), d.rate)
+	if err != nil {
+		klog.Errorf("Failed subscribe job start. error: %v", err)
+		return err
+	}
+	klog.Infof("Event subscribe successfully %s", jobInfo.Name)
+	return nil
 }
 
 func (d datadog) SuccessEvent(jobInfo JobInfo) (err error) {
+	if !isSubscribeFromEnv("DD_NOTIFY_ON_SUCCESS") {
+		return nil
+	}
 	if isSubscriptionSuppressed(jobInfo.Annotations, suppressSuccessAnnotationName) {
 		klog.Infof("Notification for %s is suppressed", jobInfo.Name)
 		return nil
@@ -49,7 +165,7 @@ func (d datadog) SuccessEvent(jobInfo JobInfo) (err error) {
 	sc := &statsd.ServiceCheck{
 		Name:     serviceCheckName,
 		Status:   statsd.Ok,
-		Message:  "Job succeed",
+		Message:  d.getMessage(jobInfo, statusSuccess, "Job succeed"),
 		Hostname: hostName,
 		Tags: []string{
 			"job_name:" + jobInfo.getJobName(),
@@ -61,6 +177,10 @@ func (d datadog) SuccessEvent(jobInfo JobInfo) (err error) {
 		klog.Errorf("Failed subscribe custom event. error: %v", err)
 		return err
 	}
+	err = d.jobMetrics(jobInfo, statusSuccess)
+	if err != nil {
+		return err
+	}
 	klog.Infof("Event subscribe successfully %s", jobInfo.Name)
 	return nil
 }
@@ -72,23 +192,105 @@ func (d datadog) FailEvent(jobInfo JobInfo) (err error) {
 	}
 	sc := &statsd.ServiceCheck{
 		Name:     serviceCheckName,
-		Status:   statsd.Critical,
-		Message:  "Job failed",
+		Status:   getFailedStatus(jobInfo.Annotations),
+		Message:  d.getMessage(jobInfo, statusFailed, "Job failed"),
 		Hostname: hostName,
 		Tags: []string{
 			"job_name:" + jobInfo.getJobName(),
 			"namespace:" + jobInfo.Namespace,
 		},
 	}
+	if jobInfo.FailureReason != "" {
+		sc.Tags = append(sc.Tags, "failure_reason:"+jobInfo.FailureReason)
+	}
 	err = d.client.ServiceCheck(sc)
 	if err != nil {
 		klog.Errorf("Failed subscribe custom event. error: %v", err)
 		return err
 	}
+	err = d.jobMetrics(jobInfo, statusFailed)
+	if err != nil {
+		return err
+	}
 	klog.Infof("Event subscribe successfully %s", jobInfo.getJobName())
 	return nil
 }
 
+// getFailedStatus maps the severity annotation of a failed job to the service check status,
+// so that monitors on warning or info jobs don't alert. Failures are critical by default.
+func getFailedStatus(annotations map[string]string) statsd.ServiceCheckStatus {
+	switch annotations[severityAnnotationName] {
+	case "warning":
+		return statsd.Warn
+	case "info":
+		return statsd.Ok
+	default:
+		return statsd.Critical
+	}
+}
+
+// jobMetrics emits the job count and duration tagged with the job outcome.
+func (d datadog) jobMetrics(jobInfo JobInfo, status string) (err error) {
+	tags := append(d.metricTags(jobInfo), "status:"+status)
+	if jobInfo.FailureReason != "" {
+		tags = append(tags, "failure_reason:"+jobInfo.FailureReason)
+	}
+	err = d.client.Incr(jobCountMetricName, tags, d.rate)
+	if err != nil {
+		klog.Errorf("Failed subscribe job count. error: %v", err)
+		return err
+	}
+	if jobInfo.Duration > 0 {
+		err = d.client.Histogram(jobDurationMetricName, jobInfo.Duration.Seconds(), tags, d.rate)
+		if err != nil {
+			klog.Errorf("Failed subscribe job duration. error: %v", err)
+			return err
+		}
+	}
+	if jobInfo.WaitTime > 0 {
+		err = d.client.Histogram(jobWaitMetricName, jobInfo.WaitTime.Seconds(), tags, d.rate)
+		if err != nil {
+			klog.Errorf("Failed subscribe job wait time. error: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (d datadog) WatchErrorEvent(reason string) (err error) {
+	err = d.client.Incr(watchErrorsMetricName, []string{"reason:" + reason}, d.rate)
+	if err != nil {
+		klog.Errorf("Failed subscribe watch error. error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (d datadog) ControllerErrorEvent(stage string) (err error) {
+	err = d.client.Incr(controllerErrorsMetricName, []string{"stage:" + stage}, d.rate)
+	if err != nil {
+		klog.Errorf("Failed subscribe controller error. error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// NotificationEvent is a no-op, the notification latency is exported by Prometheus and OpenTelemetry.
+func (d datadog) NotificationEvent(notifier string, event string, latency time.Duration) (err error) {
+	return nil
+}
+
+func (d datadog) Flush() (err error) {
+	if d.client == nil {
+		return nil
+	}
+	return d.client.Flush()
+}
+
+func isSubscribeFromEnv(key string) bool {
+	return os.Getenv(key) != "false"
+}
+
 func isSubscriptionSuppressed(annotations map[string]string, annotationName string) bool {
 	a, ok := annotations[annotationName]
 	if !ok {
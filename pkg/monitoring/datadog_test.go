@@ -1,9 +1,12 @@
 package monitoring
 
 import (
+	"github.com/DataDog/datadog-go/statsd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestNewDatadog(t *testing.T) {
@@ -12,18 +15,93 @@ func TestNewDatadog(t *testing.T) {
 
 	actual := newDatadog()
 
-	assert.Equal(t, "namespace", actual.client.Namespace)
-	assert.Equal(t, []string{"tag"}, actual.client.Tags)
+	assert.Equal(t, "namespace", actual.client.(*statsd.Client).Namespace)
+	assert.Equal(t, []string{"tag"}, actual.client.(*statsd.Client).Tags)
+	assert.Equal(t, 1.0, actual.rate)
 
 	os.Unsetenv("DD_TAGS")
 	os.Unsetenv("DD_NAMESPACE")
 
 	actual = newDatadog()
-	assert.Empty(t, actual.client.Namespace)
-	assert.Equal(t, []string{}, actual.client.Tags)
+	assert.Empty(t, actual.client.(*statsd.Client).Namespace)
+	assert.Equal(t, []string{}, actual.client.(*statsd.Client).Tags)
 }
 
-func TestisSubscriptionSuppressed(t *testing.T) {
+func TestNewDatadogClusterName(t *testing.T) {
+	t.Setenv("CLUSTER_NAME", "prod-tokyo")
+
+	actual := newDatadog()
+	assert.Equal(t, []string{"cluster:prod-tokyo"}, actual.client.(*statsd.Client).Tags)
+
+	t.Setenv("DD_TAGS", "env:prod")
+	actual = newDatadog()
+	assert.Equal(t, []string{"env:prod", "cluster:prod-tokyo"}, actual.client.(*statsd.Client).Tags)
+}
+
+func TestGetSampleRate(t *testing.T) {
+	tests := []struct {
+		Name     string
+		value    string
+		expected float64
+	}{
+		{"Default", "", 1.0},
+		{"Configured", "0.25", 0.25},
+		{"Full rate", "1", 1.0},
+		{"Zero is invalid", "0", 1.0},
+		{"Above one is invalid", "1.5", 1.0},
+		{"Negative is invalid", "-0.5", 1.0},
+		{"Not a number", "half", 1.0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Setenv("DD_SAMPLE_RATE", test.value)
+			assert.Equal(t, test.expected, getSampleRate())
+		})
+	}
+}
+
+func TestSampleRatePassedThrough(t *testing.T) {
+	mc := &MockStatsdClient{}
+	mc.On("Incr", "kube_job_notifier.watch.errors", []string{"reason:other"}, 0.25).Return(nil)
+	mc.On("Incr", "kube_job_notifier.controller.errors", []string{"stage:logs"}, 0.25).Return(nil)
+	mc.On("ServiceCheck", mock.AnythingOfType("*statsd.ServiceCheck")).Return(nil)
+	mc.On("Incr", "kube_job_notifier.job.count", mock.Anything, 0.25).Return(nil)
+	mc.On("Histogram", "kube_job_notifier.job.duration", 90.0, mock.Anything, 0.25).Return(nil)
+
+	d := datadog{client: mc, rate: 0.25}
+
+	assert.NoError(t, d.WatchErrorEvent("other"))
+	assert.NoError(t, d.ControllerErrorEvent("logs"))
+	assert.NoError(t, d.FailEvent(JobInfo{Name: "the-job", Namespace: "namespace", Duration: 90 * time.Second}))
+	mc.AssertExpectations(t)
+}
+
+type MockStatsdClient struct {
+	mock.Mock
+}
+
+func (c *MockStatsdClient) ServiceCheck(sc *statsd.ServiceCheck) error {
+	args := c.Called(sc)
+	return args.Error(0)
+}
+
+func (c *MockStatsdClient) Incr(name string, tags []string, rate float64) error {
+	args := c.Called(name, tags, rate)
+	return args.Error(0)
+}
+
+func (c *MockStatsdClient) Histogram(name string, value float64, tags []string, rate float64) error {
+	args := c.Called(name, value, tags, rate)
+	return args.Error(0)
+}
+
+func (c *MockStatsdClient) Flush() error {
+	args := c.Called()
+	return args.Error(0)
+}
+
+func TestIsSubscriptionSuppressed(t *testing.T) {
 	tests := []struct {
 		Name                   string
 		annotations            map[string]string
@@ -86,3 +164,222 @@ func TestisSubscriptionSuppressed(t *testing.T) {
 		})
 	}
 }
+
+func TestJobMetricsStatusTag(t *testing.T) {
+	tests := []struct {
+		Name           string
+		fail           bool
+		duration       time.Duration
+		failureReason  string
+		expectedStatus string
+	}{
+		{"Success", false, 30 * time.Second, "", "status:success"},
+		{"Failed", true, 30 * time.Second, "", "status:failed"},
+		{"Failed without duration", true, 0, "", "status:failed"},
+		{"Failed with reason", true, 30 * time.Second, "oomkilled", "status:failed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			expectedTags := []string{"job_name:the-cronjob", "namespace:namespace", test.expectedStatus}
+			if test.failureReason != "" {
+				expectedTags = append(expectedTags, "failure_reason:"+test.failureReason)
+			}
+			mc := &MockStatsdClient{}
+			mc.On("ServiceCheck", mock.AnythingOfType("*statsd.ServiceCheck")).Return(nil)
+			mc.On("Incr", "kube_job_notifier.job.count", expectedTags, 1.0).Return(nil)
+			if test.duration > 0 {
+				mc.On("Histogram", "kube_job_notifier.job.duration", test.duration.Seconds(), expectedTags, 1.0).Return(nil)
+			}
+
+			d := datadog{client: mc, rate: 1.0}
+			jobInfo := JobInfo{Name: "the-cronjob-28472940", CronJobName: "the-cronjob", Namespace: "namespace", Duration: test.duration, FailureReason: test.failureReason}
+			var err error
+			if test.fail {
+				err = d.FailEvent(jobInfo)
+			} else {
+				err = d.SuccessEvent(jobInfo)
+			}
+
+			assert.NoError(t, err)
+			mc.AssertExpectations(t)
+			if test.duration == 0 {
+				mc.AssertNotCalled(t, "Histogram", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestEventToggles(t *testing.T) {
+	tests := []struct {
+		Name            string
+		startEnv        string
+		successEnv      string
+		expectedStart   bool
+		expectedSuccess bool
+	}{
+		{"Default", "", "", true, true},
+		{"Success turned off", "", "false", true, false},
+		{"Start turned off", "false", "true", false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Setenv("DD_NOTIFY_ON_START", test.startEnv)
+			t.Setenv("DD_NOTIFY_ON_SUCCESS", test.successEnv)
+
+			mc := &MockStatsdClient{}
+			mc.On("Incr", "kube_job_notifier.job.started", []string{"namespace:namespace"}, 1.0).Return(nil).Maybe()
+			mc.On("Incr", "kube_job_notifier.job.count", mock.Anything, 1.0).Return(nil)
+			mc.On("ServiceCheck", mock.AnythingOfType("*statsd.ServiceCheck")).Return(nil)
+
+			d := datadog{client: mc, rate: 1.0}
+			jobInfo := JobInfo{Name: "the-job", Namespace: "namespace"}
+
+			assert.NoError(t, d.StartEvent(jobInfo))
+			assert.NoError(t, d.SuccessEvent(jobInfo))
+			assert.NoError(t, d.FailEvent(jobInfo))
+
+			expectedChecks := 1
+			if test.expectedSuccess {
+				expectedChecks++
+			}
+			mc.AssertNumberOfCalls(t, "ServiceCheck", expectedChecks)
+			if test.expectedStart {
+				mc.AssertCalled(t, "Incr", "kube_job_notifier.job.started", []string{"namespace:namespace"}, 1.0)
+			} else {
+				mc.AssertNotCalled(t, "Incr", "kube_job_notifier.job.started", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestJobMetricsWaitTime(t *testing.T) {
+	expectedTags := []string{"job_name:the-job", "namespace:namespace", "status:success"}
+	mc := &MockStatsdClient{}
+	mc.On("ServiceCheck", mock.AnythingOfType("*statsd.ServiceCheck")).Return(nil)
+	mc.On("Incr", "kube_job_notifier.job.count", expectedTags, 1.0).Return(nil)
+	mc.On("Histogram", "kube_job_notifier.job.wait_seconds", 12.0, expectedTags, 1.0).Return(nil)
+
+	d := datadog{client: mc, rate: 1.0, tagJobName: true}
+	err := d.SuccessEvent(JobInfo{Name: "the-job", Namespace: "namespace", WaitTime: 12 * time.Second})
+
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+	mc.AssertNotCalled(t, "Histogram", "kube_job_notifier.job.duration", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMetricTags(t *testing.T) {
+	tests := []struct {
+		name       string
+		jobInfo    JobInfo
+		tagJobName bool
+		expected   []string
+	}{
+		{"cronjob", JobInfo{Name: "the-cronjob-28472940", CronJobName: "the-cronjob", Namespace: "namespace"}, false, []string{"job_name:the-cronjob", "namespace:namespace"}},
+		{"job", JobInfo{Name: "the-job-x7k2p", Namespace: "namespace"}, false, []string{"namespace:namespace"}},
+		{"job with DD_TAG_JOB_NAME", JobInfo{Name: "the-job-x7k2p", Namespace: "namespace"}, true, []string{"job_name:the-job-x7k2p", "namespace:namespace"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := datadog{tagJobName: test.tagJobName}
+			assert.Equal(t, test.expected, d.metricTags(test.jobInfo))
+		})
+	}
+}
+
+func TestFailEventSeverity(t *testing.T) {
+	tests := []struct {
+		Name           string
+		severity       string
+		expectedStatus statsd.ServiceCheckStatus
+	}{
+		{"Default", "", statsd.Critical},
+		{"Critical", "critical", statsd.Critical},
+		{"Warning", "warning", statsd.Warn},
+		{"Info", "info", statsd.Ok},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var status statsd.ServiceCheckStatus
+			mc := &MockStatsdClient{}
+			mc.On("ServiceCheck", mock.AnythingOfType("*statsd.ServiceCheck")).
+				Run(func(args mock.Arguments) {
+					status = args.Get(0).(*statsd.ServiceCheck).Status
+				}).
+				Return(nil)
+			mc.On("Incr", "kube_job_notifier.job.count", mock.Anything, 1.0).Return(nil)
+
+			annotations := map[string]string{}
+			if test.severity != "" {
+				annotations[severityAnnotationName] = test.severity
+			}
+			d := datadog{client: mc, rate: 1.0}
+			assert.NoError(t, d.FailEvent(JobInfo{Name: "the-job", Namespace: "namespace", Annotations: annotations}))
+
+			assert.Equal(t, test.expectedStatus, status)
+		})
+	}
+}
+
+func TestGetMessageTemplate(t *testing.T) {
+	os.Setenv("DD_MESSAGE_TEMPLATE", "{{ .Missing }")
+	defer os.Unsetenv("DD_MESSAGE_TEMPLATE")
+	assert.Nil(t, getMessageTemplate())
+
+	os.Setenv("DD_MESSAGE_TEMPLATE", "{{ .Unknown }}")
+	assert.Nil(t, getMessageTemplate())
+
+	os.Setenv("DD_MESSAGE_TEMPLATE", "{{ .JobName }} {{ .Status }}")
+	assert.NotNil(t, getMessageTemplate())
+
+	os.Unsetenv("DD_MESSAGE_TEMPLATE")
+	assert.Nil(t, getMessageTemplate())
+}
+
+func TestServiceCheckMessage(t *testing.T) {
+	tests := []struct {
+		Name     string
+		template string
+		success  bool
+		expected string
+	}{
+		{"DefaultSuccess", "", true, "Job succeed"},
+		{"DefaultFailure", "", false, "Job failed"},
+		{"TemplatedSuccess", "{{ .Namespace }}/{{ .JobName }} {{ .Status }}", true, "namespace/the-cronjob success"},
+		{"TemplatedFailure", "{{ .Namespace }}/{{ .JobName }} {{ .Status }}: {{ .FailureReason }}", false, "namespace/the-cronjob failed: BackoffLimitExceeded"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			os.Setenv("DD_MESSAGE_TEMPLATE", test.template)
+			defer os.Unsetenv("DD_MESSAGE_TEMPLATE")
+
+			var message string
+			mc := &MockStatsdClient{}
+			mc.On("ServiceCheck", mock.AnythingOfType("*statsd.ServiceCheck")).
+				Run(func(args mock.Arguments) {
+					message = args.Get(0).(*statsd.ServiceCheck).Message
+				}).
+				Return(nil)
+			mc.On("Incr", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mc.On("Histogram", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			d := datadog{client: mc, rate: 1.0, message: getMessageTemplate()}
+			jobInfo := JobInfo{
+				Name:          "the-cronjob-123",
+				CronJobName:   "the-cronjob",
+				Namespace:     "namespace",
+				FailureReason: "BackoffLimitExceeded",
+			}
+			if test.success {
+				assert.NoError(t, d.SuccessEvent(jobInfo))
+			} else {
+				assert.NoError(t, d.FailEvent(jobInfo))
+			}
+
+			assert.Equal(t, test.expected, message)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package monitoring
+
+import (
+	"time"
+
+	"k8s.io/klog"
+)
+
+// NoopMonitoring is a subscription that sends nothing. It is used with ENABLED_NOTIFIERS=none
+// and is the reference implementation of Subscription.
+type NoopMonitoring struct{}
+
+func (NoopMonitoring) StartEvent(jobInfo JobInfo) (err error) {
+	klog.V(4).Infof("Noop monitoring: job=%s namespace=%s event=start", jobInfo.Name, jobInfo.Namespace)
+	return nil
+}
+
+func (NoopMonitoring) SuccessEvent(jobInfo JobInfo) (err error) {
+	klog.V(4).Infof("Noop monitoring: job=%s namespace=%s event=success", jobInfo.Name, jobInfo.Namespace)
+	return nil
+}
+
+func (NoopMonitoring) FailEvent(jobInfo JobInfo) (err error) {
+	klog.V(4).Infof("Noop monitoring: job=%s namespace=%s event=failed", jobInfo.Name, jobInfo.Namespace)
+	return nil
+}
+
+func (NoopMonitoring) WatchErrorEvent(reason string) (err error) {
+	klog.V(4).Infof("Noop monitoring: watch error reason=%s", reason)
+	return nil
+}
+
+func (NoopMonitoring) ControllerErrorEvent(stage string) (err error) {
+	klog.V(4).Infof("Noop monitoring: controller error stage=%s", stage)
+	return nil
+}
+
+func (NoopMonitoring) NotificationEvent(notifier string, event string, latency time.Duration) (err error) {
+	klog.V(4).Infof("Noop monitoring: notifier=%s event=%s latency=%s", notifier, event, latency)
+	return nil
+}
+
+func (NoopMonitoring) Flush() (err error) {
+	return nil
+}
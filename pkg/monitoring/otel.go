@@ -0,0 +1,140 @@
+package monitoring
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"k8s.io/klog"
+)
+
+const (
+	meterName                     = "github.com/yutachaos/kube-job-notifier"
+	notificationLatencyMetricName = "kube_job_notifier.notification.latency"
+	otelFlushTimeout              = 10 * time.Second
+)
+
+// openTelemetry exports the job metrics over OTLP, configured by the standard OTEL_EXPORTER_OTLP_* variables.
+type openTelemetry struct {
+	provider *sdkmetric.MeterProvider
+
+	jobStarted          metric.Int64Counter
+	jobCount            metric.Int64Counter
+	jobDuration         metric.Float64Histogram
+	jobWait             metric.Float64Histogram
+	watchErrors         metric.Int64Counter
+	controllerErrors    metric.Int64Counter
+	notificationLatency metric.Float64Histogram
+}
+
+func newOpenTelemetry() (*openTelemetry, error) {
+	exporter, err := otlpmetricgrpc.New(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return newOpenTelemetryWithReader(sdkmetric.NewPeriodicReader(exporter))
+}
+
+func newOpenTelemetryWithReader(reader sdkmetric.Reader) (*openTelemetry, error) {
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(resource.Default()),
+	)
+	meter := provider.Meter(meterName)
+
+	o := &openTelemetry{provider: provider}
+	var err error
+	if o.jobStarted, err = meter.Int64Counter(jobStartedMetricName, metric.WithDescription("Number of started jobs.")); err != nil {
+		return nil, err
+	}
+	if o.jobCount, err = meter.Int64Counter(jobCountMetricName, metric.WithDescription("Number of finished jobs.")); err != nil {
+		return nil, err
+	}
+	if o.jobDuration, err = meter.Float64Histogram(jobDurationMetricName, metric.WithUnit("s"), metric.WithDescription("Execution time of finished jobs.")); err != nil {
+		return nil, err
+	}
+	if o.jobWait, err = meter.Float64Histogram(jobWaitMetricName, metric.WithUnit("s"), metric.WithDescription("Time from the job creation to its first pod start.")); err != nil {
+		return nil, err
+	}
+	if o.watchErrors, err = meter.Int64Counter(watchErrorsMetricName, metric.WithDescription("Number of job watch errors.")); err != nil {
+		return nil, err
+	}
+	if o.controllerErrors, err = meter.Int64Counter(controllerErrorsMetricName, metric.WithDescription("Number of internal controller errors.")); err != nil {
+		return nil, err
+	}
+	if o.notificationLatency, err = meter.Float64Histogram(notificationLatencyMetricName, metric.WithUnit("s"), metric.WithDescription("Time from a job transition being observed to its notification being sent.")); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *openTelemetry) StartEvent(jobInfo JobInfo) (err error) {
+	o.jobStarted.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("job_name", jobInfo.getJobName()),
+		attribute.String("namespace", jobInfo.Namespace),
+	))
+	return nil
+}
+
+func (o *openTelemetry) SuccessEvent(jobInfo JobInfo) (err error) {
+	o.jobMetrics(jobInfo, statusSuccess)
+	return nil
+}
+
+func (o *openTelemetry) FailEvent(jobInfo JobInfo) (err error) {
+	o.jobMetrics(jobInfo, statusFailed)
+	return nil
+}
+
+// jobMetrics records the job count and duration with the same attributes as the Datadog tags.
+func (o *openTelemetry) jobMetrics(jobInfo JobInfo, status string) {
+	attrs := []attribute.KeyValue{
+		attribute.String("job_name", jobInfo.getJobName()),
+		attribute.String("namespace", jobInfo.Namespace),
+		attribute.String("status", status),
+	}
+	if jobInfo.FailureReason != "" {
+		attrs = append(attrs, attribute.String("failure_reason", jobInfo.FailureReason))
+	}
+	ctx := context.Background()
+	opt := metric.WithAttributes(attrs...)
+	o.jobCount.Add(ctx, 1, opt)
+	if jobInfo.Duration > 0 {
+		o.jobDuration.Record(ctx, jobInfo.Duration.Seconds(), opt)
+	}
+	if jobInfo.WaitTime > 0 {
+		o.jobWait.Record(ctx, jobInfo.WaitTime.Seconds(), opt)
+	}
+}
+
+func (o *openTelemetry) WatchErrorEvent(reason string) (err error) {
+	o.watchErrors.Add(context.Background(), 1, metric.WithAttributes(attribute.String("reason", reason)))
+	return nil
+}
+
+func (o *openTelemetry) ControllerErrorEvent(stage string) (err error) {
+	o.controllerErrors.Add(context.Background(), 1, metric.WithAttributes(attribute.String("stage", stage)))
+	return nil
+}
+
+func (o *openTelemetry) NotificationEvent(notifier string, event string, latency time.Duration) (err error) {
+	o.notificationLatency.Record(context.Background(), latency.Seconds(), metric.WithAttributes(
+		attribute.String("notifier", notifier),
+		attribute.String("event", event),
+	))
+	return nil
+}
+
+func (o *openTelemetry) Flush() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), otelFlushTimeout)
+	defer cancel()
+	if err := o.provider.ForceFlush(ctx); err != nil {
+		klog.Errorf("Failed flush OpenTelemetry metrics. error: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	rm := metricdata.ResourceMetrics{}
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+	res := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			res[m.Name] = m.Data
+		}
+	}
+	return res
+}
+
+func TestOpenTelemetryJobMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	o, err := newOpenTelemetryWithReader(reader)
+	assert.NoError(t, err)
+
+	assert.NoError(t, o.StartEvent(JobInfo{Name: "the-job-1", CronJobName: "the-cronjob", Namespace: "namespace"}))
+	assert.NoError(t, o.SuccessEvent(JobInfo{Name: "the-job-1", CronJobName: "the-cronjob", Namespace: "namespace", Duration: 90 * time.Second}))
+	assert.NoError(t, o.FailEvent(JobInfo{Name: "the-job-2", Namespace: "namespace", FailureReason: "oomkilled"}))
+	assert.NoError(t, o.NotificationEvent("slack", "failed", 2*time.Second))
+	assert.NoError(t, o.ControllerErrorEvent("logs"))
+
+	metrics := collectMetrics(t, reader)
+
+	started := metrics[jobStartedMetricName].(metricdata.Sum[int64])
+	assert.Len(t, started.DataPoints, 1)
+	assert.Equal(t, int64(1), started.DataPoints[0].Value)
+	assert.Equal(t, attribute.NewSet(
+		attribute.String("job_name", "the-cronjob"),
+		attribute.String("namespace", "namespace"),
+	), started.DataPoints[0].Attributes)
+
+	counts := map[attribute.Set]int64{}
+	for _, dp := range metrics[jobCountMetricName].(metricdata.Sum[int64]).DataPoints {
+		counts[dp.Attributes] = dp.Value
+	}
+	assert.Equal(t, map[attribute.Set]int64{
+		attribute.NewSet(
+			attribute.String("job_name", "the-cronjob"),
+			attribute.String("namespace", "namespace"),
+			attribute.String("status", statusSuccess),
+		): 1,
+		attribute.NewSet(
+			attribute.String("job_name", "the-job-2"),
+			attribute.String("namespace", "namespace"),
+			attribute.String("status", statusFailed),
+			attribute.String("failure_reason", "oomkilled"),
+		): 1,
+	}, counts)
+
+	// Failed jobs without a duration don't record one.
+	duration := metrics[jobDurationMetricName].(metricdata.Histogram[float64])
+	assert.Len(t, duration.DataPoints, 1)
+	assert.Equal(t, 90.0, duration.DataPoints[0].Sum)
+
+	latency := metrics[notificationLatencyMetricName].(metricdata.Histogram[float64])
+	assert.Len(t, latency.DataPoints, 1)
+	assert.Equal(t, uint64(1), latency.DataPoints[0].Count)
+	assert.Equal(t, 2.0, latency.DataPoints[0].Sum)
+	assert.Equal(t, attribute.NewSet(
+		attribute.String("notifier", "slack"),
+		attribute.String("event", "failed"),
+	), latency.DataPoints[0].Attributes)
+
+	errors := metrics[controllerErrorsMetricName].(metricdata.Sum[int64])
+	assert.Equal(t, int64(1), errors.DataPoints[0].Value)
+}
+
+func TestNewSubscription(t *testing.T) {
+	t.Setenv("OTEL_ENABLED", "true")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4317")
+
+	res := NewSubscription()
+
+	assert.Contains(t, res, "otel")
+	assert.NotContains(t, res, "datadog")
+	assert.True(t, Enabled())
+}
@@ -0,0 +1,106 @@
+package monitoring
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var jobSecondsBuckets = []float64{1, 10, 30, 60, 300, 600, 1800, 3600, 7200, 21600, 86400}
+
+// prometheusMonitoring records the job metrics in a Prometheus registry, served on /metrics
+// with METRICS_ADDR. The notification latency is always recorded by the controller as
+// kube_job_notifier_notification_latency_seconds.
+type prometheusMonitoring struct {
+	jobStarted       *prometheus.CounterVec
+	jobCount         *prometheus.CounterVec
+	jobDuration      *prometheus.HistogramVec
+	jobWait          *prometheus.HistogramVec
+	watchErrors      *prometheus.CounterVec
+	controllerErrors *prometheus.CounterVec
+}
+
+// defaultPrometheus registers the job metrics in the default registry once.
+var defaultPrometheus = sync.OnceValue(func() *prometheusMonitoring {
+	return newPrometheus(prometheus.DefaultRegisterer)
+})
+
+func newPrometheus(registerer prometheus.Registerer) *prometheusMonitoring {
+	p := &prometheusMonitoring{
+		jobStarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kube_job_notifier_job_started_total",
+			Help: "Number of started jobs.",
+		}, []string{"job_name", "namespace"}),
+		jobCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kube_job_notifier_job_total",
+			Help: "Number of finished jobs.",
+		}, []string{"job_name", "namespace", "status", "failure_reason"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kube_job_notifier_job_duration_seconds",
+			Help:    "Execution time of finished jobs.",
+			Buckets: jobSecondsBuckets,
+		}, []string{"job_name", "namespace", "status"}),
+		jobWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kube_job_notifier_job_wait_seconds",
+			Help:    "Time from the job creation to its first pod start.",
+			Buckets: jobSecondsBuckets,
+		}, []string{"job_name", "namespace", "status"}),
+		watchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kube_job_notifier_watch_errors_total",
+			Help: "Number of job watch errors.",
+		}, []string{"reason"}),
+		controllerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kube_job_notifier_controller_errors_total",
+			Help: "Number of internal controller errors.",
+		}, []string{"stage"}),
+	}
+	registerer.MustRegister(p.jobStarted, p.jobCount, p.jobDuration, p.jobWait, p.watchErrors, p.controllerErrors)
+	return p
+}
+
+func (p *prometheusMonitoring) StartEvent(jobInfo JobInfo) (err error) {
+	p.jobStarted.WithLabelValues(jobInfo.getJobName(), jobInfo.Namespace).Inc()
+	return nil
+}
+
+func (p *prometheusMonitoring) SuccessEvent(jobInfo JobInfo) (err error) {
+	p.jobMetrics(jobInfo, statusSuccess)
+	return nil
+}
+
+func (p *prometheusMonitoring) FailEvent(jobInfo JobInfo) (err error) {
+	p.jobMetrics(jobInfo, statusFailed)
+	return nil
+}
+
+// jobMetrics records the job count and duration with the same labels as the Datadog tags.
+func (p *prometheusMonitoring) jobMetrics(jobInfo JobInfo, status string) {
+	name := jobInfo.getJobName()
+	p.jobCount.WithLabelValues(name, jobInfo.Namespace, status, jobInfo.FailureReason).Inc()
+	if jobInfo.Duration > 0 {
+		p.jobDuration.WithLabelValues(name, jobInfo.Namespace, status).Observe(jobInfo.Duration.Seconds())
+	}
+	if jobInfo.WaitTime > 0 {
+		p.jobWait.WithLabelValues(name, jobInfo.Namespace, status).Observe(jobInfo.WaitTime.Seconds())
+	}
+}
+
+func (p *prometheusMonitoring) WatchErrorEvent(reason string) (err error) {
+	p.watchErrors.WithLabelValues(reason).Inc()
+	return nil
+}
+
+func (p *prometheusMonitoring) ControllerErrorEvent(stage string) (err error) {
+	p.controllerErrors.WithLabelValues(stage).Inc()
+	return nil
+}
+
+func (p *prometheusMonitoring) NotificationEvent(notifier string, event string, latency time.Duration) (err error) {
+	return nil
+}
+
+// Flush does nothing, the metrics are scraped.
+func (p *prometheusMonitoring) Flush() (err error) {
+	return nil
+}
@@ -0,0 +1,41 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusMonitoring(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	p := newPrometheus(registry)
+
+	jobInfo := JobInfo{Name: "the-job-28472940", CronJobName: "the-job", Namespace: "test-ns", Duration: time.Minute, WaitTime: 5 * time.Second}
+	assert.NoError(t, p.StartEvent(jobInfo))
+	assert.NoError(t, p.SuccessEvent(jobInfo))
+	jobInfo.FailureReason = "oomkilled"
+	assert.NoError(t, p.FailEvent(jobInfo))
+	assert.NoError(t, p.ControllerErrorEvent("logs"))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.jobStarted.WithLabelValues("the-job", "test-ns")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.jobCount.WithLabelValues("the-job", "test-ns", statusSuccess, "")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.jobCount.WithLabelValues("the-job", "test-ns", statusFailed, "oomkilled")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.controllerErrors.WithLabelValues("logs")))
+	assert.Equal(t, 2, testutil.CollectAndCount(p.jobDuration))
+	assert.Equal(t, 2, testutil.CollectAndCount(p.jobWait))
+}
+
+func TestNewSubscriptionMultiple(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+	t.Setenv("PROMETHEUS_ENABLED", "true")
+
+	res := NewSubscription()
+
+	assert.Contains(t, res, "datadog")
+	assert.Contains(t, res, "prometheus")
+	assert.Same(t, res["prometheus"], NewSubscription()["prometheus"])
+	assert.True(t, Enabled())
+}
@@ -1,10 +1,24 @@
 package monitoring
 
+import (
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
 type JobInfo struct {
 	Name        string
 	CronJobName string
 	Namespace   string
 	Annotations map[string]string
+	// Duration is the execution time of the job, zero when unknown.
+	Duration time.Duration
+	// WaitTime is the time from the job creation to its first pod start, zero when unknown.
+	WaitTime time.Duration
+	// FailureReason is set for failures with a distinctive cause, "oomkilled" or "deadline_exceeded".
+	FailureReason string
 }
 
 func (j JobInfo) getJobName() string {
@@ -15,14 +29,52 @@ func (j JobInfo) getJobName() string {
 }
 
 type Subscription interface {
+	StartEvent(jobInfo JobInfo) (err error)
 	SuccessEvent(jobInfo JobInfo) (err error)
 	FailEvent(jobInfo JobInfo) (err error)
+	WatchErrorEvent(reason string) (err error)
+	ControllerErrorEvent(stage string) (err error)
+	// NotificationEvent records the latency of a sent notification.
+	NotificationEvent(notifier string, event string, latency time.Duration) (err error)
+	Flush() (err error)
 }
 
-// NewSubscription Support for returning multiple event notifications in one
+// NewSubscription Support for returning multiple event notifications in one.
+// Every enabled backend gets the events, e.g. Datadog and Prometheus during a migration.
+// ENABLED_NOTIFIERS=none replaces them with NoopMonitoring.
 func NewSubscription() map[string]Subscription {
+	if notifiersDisabled() {
+		return map[string]Subscription{"none": NoopMonitoring{}}
+	}
 	res := make(map[string]Subscription)
-	// default notification
-	res["datadog"] = newDatadog()
+	if os.Getenv("DATADOG_ENABLE") == "true" {
+		res["datadog"] = newDatadog()
+	}
+	if os.Getenv("OTEL_ENABLED") == "true" {
+		o, err := newOpenTelemetry()
+		if err != nil {
+			klog.Errorf("Failed create OpenTelemetry exporter. error: %v", err)
+		} else {
+			res["otel"] = o
+		}
+	}
+	if os.Getenv("PROMETHEUS_ENABLED") == "true" {
+		res["prometheus"] = defaultPrometheus()
+	}
 	return res
 }
+
+// notifiersDisabled reports whether ENABLED_NOTIFIERS lists none.
+func notifiersDisabled() bool {
+	for _, name := range strings.Split(os.Getenv("ENABLED_NOTIFIERS"), ",") {
+		if strings.TrimSpace(name) == "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether events are sent to the subscriptions.
+func Enabled() bool {
+	return os.Getenv("DATADOG_ENABLE") == "true" || os.Getenv("OTEL_ENABLED") == "true" || os.Getenv("PROMETHEUS_ENABLED") == "true"
+}
@@ -0,0 +1,18 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSubscriptionNone(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+	t.Setenv("ENABLED_NOTIFIERS", "none")
+
+	res := NewSubscription()
+
+	assert.Equal(t, map[string]Subscription{"none": NoopMonitoring{}}, res)
+	assert.NoError(t, res["none"].FailEvent(JobInfo{Name: "the-job", Namespace: "test-ns"}))
+	assert.NoError(t, res["none"].Flush())
+}
@@ -0,0 +1,264 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// slackConfig is the Slack configuration, read from the environment once at startup by
+// loadSlackConfig. The zero value posts every event with the default message template,
+// so tests only set the fields they exercise.
+type slackConfig struct {
+	token    string
+	channel  string
+	username string
+	// succeedChannel overrides channel for start and success notifications.
+	succeedChannel string
+	// failedChannel overrides channel for failure notifications.
+	failedChannel string
+	// opsChannel receives messages about the controller itself, empty when they are not sent.
+	opsChannel string
+
+	disableStarted   bool
+	disableSucceeded bool
+	disableFailed    bool
+	disableSuspended bool
+
+	// uploadLogsOn is the SLACK_UPLOAD_LOGS_ON mode, empty to upload the logs of every event.
+	uploadLogsOn string
+	// maxLogFiles is the number of pod logs uploaded per job, 0 for all of them.
+	maxLogFiles          int
+	maxConcurrentUploads int
+	// logDedupWindow is how long an uploaded log is linked again instead of uploading the
+	// same content, 0 when every log is uploaded.
+	logDedupWindow time.Duration
+	// maxMessageLength is the length of the message text beyond which it is moved to a file,
+	// 0 when messages are never shortened.
+	maxMessageLength int
+	// successScheduleAt is the time of day (UTC) success notifications are scheduled for,
+	// nil when they are posted immediately.
+	successScheduleAt *time.Duration
+	// failedMentions are the user group handles or IDs mentioned in failure messages.
+	failedMentions []string
+	// failedColors maps the failed count to the color of earlier failures.
+	failedColors map[int]string
+	// failureReaction is the emoji added to failure messages, empty when none is added.
+	failureReaction string
+	// namespaceThread posts every job event of a namespace into a daily thread.
+	namespaceThread bool
+	attachJobYAML   bool
+	convertMarkdown bool
+	pretext         string
+	// failureDetailsFirst puts the reason, the exit code and the logs of failures before the
+	// job metadata.
+	failureDetailsFirst bool
+	// eventEmojis are prepended to the message text by event, e.g. ":x:" for failures.
+	eventEmojis map[string]string
+	// locale selects the bundled titles and message template, empty for English.
+	locale string
+	// messageFormat is the SLACK_MESSAGE_FORMAT, empty to render the whole message as text.
+	messageFormat string
+	// messageTemplate replaces SlackMessageTemplate, nil for the default.
+	messageTemplate *template.Template
+	// timeFormat renders the times of messages, parsed from NOTIFY_TZ and NOTIFY_TIME_FORMAT.
+	timeFormat timeFormat
+	// templates are the default message templates rendering times with timeFormat, nil for
+	// the default time format.
+	templates *slackTemplates
+
+	// channelUsernames overrides the username for specific channels.
+	channelUsernames map[string]string
+	// namespaceChannels routes the jobs of a namespace to a channel.
+	namespaceChannels map[string]string
+	// namespaceWorkspaces routes the jobs of a namespace to a workspace.
+	namespaceWorkspaces map[string]string
+	workspaceTokens     map[string]string
+	workspaceChannels   map[string]string
+}
+
+// loadSlackConfig reads the SLACK_* variables. Invalid values are reported together in
+// the returned error and replaced with their defaults, the config is always usable.
+func loadSlackConfig() (slackConfig, error) {
+	var errs []error
+	config := slackConfig{
+		token:          os.Getenv("SLACK_TOKEN"),
+		channel:        os.Getenv("SLACK_CHANNEL"),
+		username:       os.Getenv("SLACK_USERNAME"),
+		succeedChannel: os.Getenv("SLACK_SUCCEED_CHANNEL"),
+		failedChannel:  os.Getenv("SLACK_FAILED_CHANNEL"),
+		opsChannel:     os.Getenv("SLACK_OPS_CHANNEL"),
+
+		disableStarted:   os.Getenv("SLACK_STARTED_NOTIFY") == "false",
+		disableSucceeded: os.Getenv("SLACK_SUCCEEDED_NOTIFY") == "false",
+		disableFailed:    os.Getenv("SLACK_FAILED_NOTIFY") == "false",
+		disableSuspended: os.Getenv("SLACK_SUSPENDED_NOTIFY") == "false",
+
+		failedMentions:  parseMentions(os.Getenv("SLACK_FAILED_MENTIONS")),
+		failureReaction: strings.Trim(os.Getenv("SLACK_FAILURE_REACTION"), ":"),
+		namespaceThread: os.Getenv("SLACK_NAMESPACE_THREAD") == "true",
+		attachJobYAML:   os.Getenv("SLACK_ATTACH_JOB_YAML") == "true",
+		convertMarkdown: os.Getenv("SLACK_CONVERT_MARKDOWN") == "true",
+		pretext:         os.Getenv("SLACK_PRETEXT"),
+
+		failureDetailsFirst: os.Getenv("SLACK_FAILURE_DETAILS_FIRST") == "true",
+
+		channelUsernames:    parseKeyValues(os.Getenv("SLACK_CHANNEL_USERNAMES")),
+		namespaceChannels:   parseKeyValues(os.Getenv("SLACK_NAMESPACE_CHANNELS")),
+		namespaceWorkspaces: parseKeyValues(os.Getenv("SLACK_NAMESPACE_WORKSPACES")),
+		workspaceTokens:     parseKeyValues(os.Getenv("SLACK_WORKSPACE_TOKENS")),
+		workspaceChannels:   parseKeyValues(os.Getenv("SLACK_WORKSPACE_CHANNELS")),
+	}
+
+	config.eventEmojis = make(map[string]string)
+	for event, emoji := range defaultEventEmojis {
+		if v, ok := os.LookupEnv("SLACK_EMOJI_" + strings.ToUpper(event)); ok {
+			emoji = v
+		}
+		if emoji != "" {
+			config.eventEmojis[event] = emoji
+		}
+	}
+
+	var err error
+	config.timeFormat, err = loadTimeFormat()
+	errs = append(errs, err)
+	config.templates = newSlackTemplates(config.timeFormat)
+	config.locale, err = parseLocale(os.Getenv("LOCALE"))
+	errs = append(errs, err)
+	config.messageFormat, err = parseMessageFormat(os.Getenv("SLACK_MESSAGE_FORMAT"))
+	errs = append(errs, err)
+	config.uploadLogsOn, err = parseUploadLogsOn(os.Getenv("SLACK_UPLOAD_LOGS_ON"))
+	errs = append(errs, err)
+	config.maxLogFiles, err = getIntEnv("SLACK_MAX_LOG_FILES", defaultMaxLogFiles, 0)
+	errs = append(errs, err)
+	config.maxConcurrentUploads, err = getIntEnv("SLACK_MAX_CONCURRENT_UPLOADS", defaultMaxConcurrentUploads, 1)
+	errs = append(errs, err)
+	config.maxMessageLength, err = getIntEnv("SLACK_MAX_MESSAGE_LENGTH", defaultMaxMessageLength, 0)
+	errs = append(errs, err)
+
+	if v := os.Getenv("SLACK_SUCCEEDED_SCHEDULE_AT"); v != "" {
+		at, err := parseTimeOfDay(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid SLACK_SUCCEEDED_SCHEDULE_AT %q, posting successes immediately: %w", v, err))
+		} else {
+			config.successScheduleAt = &at
+		}
+	}
+
+	if v := os.Getenv("SLACK_LOG_DEDUP_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			errs = append(errs, fmt.Errorf("invalid SLACK_LOG_DEDUP_WINDOW %q, uploading every log", v))
+		} else {
+			config.logDedupWindow = window
+		}
+	}
+
+	config.failedColors, err = parseFailedColors(os.Getenv("SLACK_FAILED_COLORS"))
+	errs = append(errs, err)
+
+	if v := os.Getenv("SLACK_MESSAGE_TEMPLATE"); v != "" {
+		config.messageTemplate, err = template.New("slack").Funcs(slackTemplateFuncs(config.timeFormat)).Parse(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid SLACK_MESSAGE_TEMPLATE %q, using the default: %w", v, err))
+		}
+	}
+
+	for name := range config.workspaceTokens {
+		if config.workspaceChannels[name] == "" {
+			errs = append(errs, fmt.Errorf("no channel is set for Slack workspace %s in SLACK_WORKSPACE_CHANNELS", name))
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
+
+// defaultEventEmojis are the emojis of the events, overridden by SLACK_EMOJI_<EVENT>. Setting
+// the variable empty drops the emoji of the event.
+var defaultEventEmojis = map[string]string{
+	START:        ":rocket:",
+	SUCCESS:      ":white_check_mark:",
+	FAILED:       ":x:",
+	SUSPENDED:    "",
+	RESUMED:      "",
+	SPEC_CHANGED: "",
+}
+
+const (
+	messageFormatText   = "text"
+	messageFormatFields = "fields"
+)
+
+// parseMessageFormat validates SLACK_MESSAGE_FORMAT, rendering the message as text when it
+// is unset or invalid.
+func parseMessageFormat(value string) (string, error) {
+	switch value {
+	case "":
+		return messageFormatText, nil
+	case messageFormatText, messageFormatFields:
+		return value, nil
+	}
+	return messageFormatText, fmt.Errorf("invalid SLACK_MESSAGE_FORMAT %q, must be text or fields", value)
+}
+
+const (
+	uploadLogsAlways  = "always"
+	uploadLogsFailure = "failure"
+	uploadLogsNever   = "never"
+)
+
+// parseUploadLogsOn validates SLACK_UPLOAD_LOGS_ON, uploading always when it is unset or invalid.
+func parseUploadLogsOn(value string) (string, error) {
+	switch value {
+	case "":
+		return uploadLogsAlways, nil
+	case uploadLogsAlways, uploadLogsFailure, uploadLogsNever:
+		return value, nil
+	}
+	return uploadLogsAlways, fmt.Errorf("invalid SLACK_UPLOAD_LOGS_ON %q, must be always, failure or never", value)
+}
+
+// uploadsLogs reports whether the job logs are uploaded with notifications of the event.
+func (c slackConfig) uploadsLogs(event string) bool {
+	switch c.uploadLogsOn {
+	case uploadLogsNever:
+		return false
+	case uploadLogsFailure:
+		return event == FAILED
+	}
+	return true
+}
+
+// translate returns the English title or label s in the configured locale.
+func (c slackConfig) translate(s string) string {
+	return translate(c.locale, s)
+}
+
+// getIntEnv reads an integer variable of at least minimum, returning def when it is unset or invalid.
+func getIntEnv(key string, def int, minimum int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < minimum {
+		return def, fmt.Errorf("invalid %s %q, using default %d", key, v, def)
+	}
+	return n, nil
+}
+
+// getSlackConfig loads the Slack configuration for a backend, reporting invalid settings.
+func getSlackConfig() slackConfig {
+	config, err := loadSlackConfig()
+	if err != nil {
+		klog.Errorf("Invalid Slack configuration: %v", err)
+	}
+	return config
+}
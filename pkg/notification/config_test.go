@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSlackConfigDefaults(t *testing.T) {
+	config, err := loadSlackConfig()
+
+	assert.NoError(t, err)
+	assert.False(t, config.disableStarted)
+	assert.False(t, config.disableFailed)
+	assert.Equal(t, defaultMaxLogFiles, config.maxLogFiles)
+	assert.Equal(t, defaultMaxConcurrentUploads, config.maxConcurrentUploads)
+	assert.Equal(t, defaultMaxMessageLength, config.maxMessageLength)
+	assert.Nil(t, config.successScheduleAt)
+	assert.Nil(t, config.messageTemplate)
+	assert.Empty(t, config.failedColors)
+	assert.Equal(t, uploadLogsAlways, config.uploadLogsOn)
+	assert.Equal(t, messageFormatText, config.messageFormat)
+	assert.Zero(t, config.logDedupWindow)
+	assert.Equal(t, map[string]string{START: ":rocket:", SUCCESS: ":white_check_mark:", FAILED: ":x:"}, config.eventEmojis)
+}
+
+func TestLoadSlackConfig(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-token")
+	t.Setenv("SLACK_CHANNEL", "default_channel")
+	t.Setenv("SLACK_SUCCEED_CHANNEL", "succeed_channel")
+	t.Setenv("SLACK_FAILED_CHANNEL", "failed_channel")
+	t.Setenv("SLACK_STARTED_NOTIFY", "false")
+	t.Setenv("SLACK_FAILED_NOTIFY", "true")
+	t.Setenv("SLACK_MAX_LOG_FILES", "0")
+	t.Setenv("SLACK_UPLOAD_LOGS_ON", "failure")
+	t.Setenv("SLACK_MESSAGE_FORMAT", "fields")
+	t.Setenv("SLACK_MAX_CONCURRENT_UPLOADS", "4")
+	t.Setenv("SLACK_LOG_DEDUP_WINDOW", "1h")
+	t.Setenv("SLACK_SUCCEEDED_SCHEDULE_AT", "09:30")
+	t.Setenv("SLACK_FAILED_COLORS", "2:Danger")
+	t.Setenv("SLACK_FAILURE_REACTION", ":fire:")
+	t.Setenv("SLACK_MESSAGE_TEMPLATE", "{{.JobName}}")
+	t.Setenv("SLACK_NAMESPACE_CHANNELS", "payments:payments-jobs")
+	t.Setenv("SLACK_EMOJI_SUCCESS", ":tada:")
+	t.Setenv("SLACK_EMOJI_FAILED", "")
+	t.Setenv("SLACK_EMOJI_SUSPENDED", ":pause_button:")
+
+	config, err := loadSlackConfig()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "xoxb-token", config.token)
+	assert.Equal(t, "default_channel", config.channel)
+	assert.Equal(t, "succeed_channel", config.succeedChannel)
+	assert.Equal(t, "failed_channel", config.failedChannel)
+	assert.True(t, config.disableStarted)
+	assert.False(t, config.disableFailed)
+	assert.Equal(t, 0, config.maxLogFiles)
+	assert.Equal(t, uploadLogsFailure, config.uploadLogsOn)
+	assert.Equal(t, messageFormatFields, config.messageFormat)
+	assert.Equal(t, 4, config.maxConcurrentUploads)
+	assert.Equal(t, time.Hour, config.logDedupWindow)
+	at := 9*time.Hour + 30*time.Minute
+	assert.Equal(t, &at, config.successScheduleAt)
+	assert.Equal(t, map[int]string{2: "danger"}, config.failedColors)
+	assert.Equal(t, "fire", config.failureReaction)
+	assert.Equal(t, map[string]string{"payments": "payments-jobs"}, config.namespaceChannels)
+	assert.Equal(t, map[string]string{START: ":rocket:", SUCCESS: ":tada:", SUSPENDED: ":pause_button:"}, config.eventEmojis)
+
+	message, err := config.getMessage(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Equal(t, "the-job", message)
+}
+
+func TestLoadSlackConfigInvalid(t *testing.T) {
+	t.Setenv("SLACK_MAX_LOG_FILES", "many")
+	t.Setenv("SLACK_UPLOAD_LOGS_ON", "success")
+	t.Setenv("SLACK_MESSAGE_FORMAT", "blocks")
+	t.Setenv("SLACK_MAX_CONCURRENT_UPLOADS", "0")
+	t.Setenv("SLACK_LOG_DEDUP_WINDOW", "-1h")
+	t.Setenv("SLACK_MAX_MESSAGE_LENGTH", "-1")
+	t.Setenv("SLACK_SUCCEEDED_SCHEDULE_AT", "25:00")
+	t.Setenv("SLACK_FAILED_COLORS", "x:Danger,3:Danger")
+	t.Setenv("SLACK_MESSAGE_TEMPLATE", "{{if .JobName}}")
+	t.Setenv("SLACK_WORKSPACE_TOKENS", "team-a:xoxb-a")
+
+	config, err := loadSlackConfig()
+
+	for _, name := range []string{
+		"SLACK_MAX_LOG_FILES",
+		"SLACK_UPLOAD_LOGS_ON",
+		"SLACK_MESSAGE_FORMAT",
+		"SLACK_MAX_CONCURRENT_UPLOADS",
+		"SLACK_LOG_DEDUP_WINDOW",
+		"SLACK_MAX_MESSAGE_LENGTH",
+		"SLACK_SUCCEEDED_SCHEDULE_AT",
+		"SLACK_FAILED_COLORS",
+		"SLACK_MESSAGE_TEMPLATE",
+		"SLACK_WORKSPACE_CHANNELS",
+	} {
+		assert.ErrorContains(t, err, name)
+	}
+	assert.Equal(t, defaultMaxLogFiles, config.maxLogFiles)
+	assert.Equal(t, uploadLogsAlways, config.uploadLogsOn)
+	assert.Equal(t, messageFormatText, config.messageFormat)
+	assert.Equal(t, defaultMaxConcurrentUploads, config.maxConcurrentUploads)
+	assert.Zero(t, config.logDedupWindow)
+	assert.Equal(t, defaultMaxMessageLength, config.maxMessageLength)
+	assert.Nil(t, config.successScheduleAt)
+	assert.Equal(t, map[int]string{3: "danger"}, config.failedColors)
+	assert.Nil(t, config.messageTemplate)
+}
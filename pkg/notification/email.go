@@ -0,0 +1,185 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// ownerEmailAnnotationName adds the owner of a job to the recipients of its emails.
+	ownerEmailAnnotationName = "job-notify-controller/owner-email"
+
+	defaultEmailBodyTemplate = `Job {{.JobName}} failed in {{.Namespace}}.
+{{if .CronJobName}}
+CronJobName: {{.CronJobName}}{{end}}{{if .ClusterName}}
+Cluster: {{.ClusterName}}{{end}}{{if .StartTime}}
+StartTime: {{formatTime .StartTime}}{{end}}{{if .CompletionTime}}
+CompletionTime: {{formatTime .CompletionTime}}{{end}}{{if .ExitCode}}
+ExitCode: {{.ExitCode}}{{end}}{{if .JobDetailURL}}
+Details: {{.JobDetailURL}}{{end}}{{if .LogURL}}
+Logs: {{.LogURL}}{{end}}{{if .Log}}
+
+{{.Log}}{{end}}
+`
+)
+
+// sendMailFunc sends a message over SMTP, smtp.SendMail outside of tests.
+type sendMailFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// email sends failed jobs to EMAIL_TO, plus the owner of the job named by its
+// job-notify-controller/owner-email annotation.
+type email struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	body     *template.Template
+	sendMail sendMailFunc
+}
+
+func init() {
+	Register("email", func() (Notification, bool) {
+		addr := os.Getenv("SMTP_ADDR")
+		from := os.Getenv("EMAIL_FROM")
+		if addr == "" || from == "" {
+			return nil, false
+		}
+		return newEmail(addr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from, parseEmailAddresses(os.Getenv("EMAIL_TO")), smtp.SendMail), true
+	})
+}
+
+func newEmail(addr string, username string, password string, from string, to []string, sendMail sendMailFunc) email {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			klog.Errorf("Invalid SMTP_ADDR %q, sending without authentication: %v", addr, err)
+		} else {
+			auth = smtp.PlainAuth("", username, password, host)
+		}
+	}
+	return email{
+		addr:     addr,
+		auth:     auth,
+		from:     from,
+		to:       to,
+		body:     parseTextTemplate("EMAIL_BODY_TEMPLATE", defaultEmailBodyTemplate),
+		sendMail: sendMail,
+	}
+}
+
+func (e email) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (e email) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (e email) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	to := e.getRecipients(messageParam)
+	if len(to) == 0 {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+
+	var body bytes.Buffer
+	if err := e.body.Execute(&body, messageParam); err != nil {
+		klog.Errorf("Template execute failed %s\n", err)
+		return NotifyResult{}, err
+	}
+	if err := e.sendMail(e.addr, e.auth, e.from, to, e.getMessage(to, messageParam, body.String())); err != nil {
+		klog.Errorf("Email send failed %s\n", err)
+		return NotifyResult{}, err
+	}
+	klog.Infof("Email successfully sent for %s to %s", messageParam.JobName, strings.Join(to, ","))
+	return NotifyResult{}, nil
+}
+
+func (e email) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (e email) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (e email) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+// getRecipients returns EMAIL_TO with the owner of the job added, an invalid owner address
+// is logged and ignored.
+func (e email) getRecipients(messageParam MessageTemplateParam) []string {
+	to := append([]string(nil), e.to...)
+	owner := messageParam.Annotations[ownerEmailAnnotationName]
+	if owner == "" {
+		return to
+	}
+	address, err := mail.ParseAddress(owner)
+	if err != nil {
+		klog.Errorf("Invalid %s annotation of %s, using the default recipients: %v", ownerEmailAnnotationName, messageParam.JobName, err)
+		return to
+	}
+	for _, recipient := range to {
+		if strings.EqualFold(recipient, address.Address) {
+			return to
+		}
+	}
+	return append(to, address.Address)
+}
+
+func (e email) getMessage(to []string, messageParam MessageTemplateParam, body string) []byte {
+	name := messageParam.CronJobName
+	if name == "" {
+		name = messageParam.JobName
+	}
+	subject := fmt.Sprintf("Job failed: %s/%s", messageParam.Namespace, name)
+	if messageParam.IsWarning() {
+		subject = fmt.Sprintf("Job warning: %s/%s", messageParam.Namespace, name)
+	}
+	if messageParam.ClusterName != "" {
+		subject = "[" + messageParam.ClusterName + "] " + subject
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// parseEmailAddresses parses the comma separated EMAIL_TO, invalid addresses are logged and
+// left out.
+func parseEmailAddresses(value string) []string {
+	var res []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		address, err := mail.ParseAddress(v)
+		if err != nil {
+			klog.Errorf("Invalid EMAIL_TO address %q: %v", v, err)
+			continue
+		}
+		res = append(res, address.Address)
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"errors"
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sentMail is a message passed to the fake SMTP sender.
+type sentMail struct {
+	addr string
+	from string
+	to   []string
+	msg  string
+}
+
+func newTestEmail(to []string, sent *[]sentMail, err error) email {
+	return newEmail("smtp.example.com:587", "", "", "jobs@example.com", to, func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		*sent = append(*sent, sentMail{addr: addr, from: from, to: to, msg: string(msg)})
+		return err
+	})
+}
+
+func TestEmailOwnerRecipient(t *testing.T) {
+	tests := []struct {
+		name        string
+		to          []string
+		annotations map[string]string
+		expected    []string
+	}{
+		{"default recipients", []string{"team@example.com"}, nil, []string{"team@example.com"}},
+		{"owner added", []string{"team@example.com"}, map[string]string{ownerEmailAnnotationName: "Jane <jane@example.com>"}, []string{"team@example.com", "jane@example.com"}},
+		{"owner only", nil, map[string]string{ownerEmailAnnotationName: "jane@example.com"}, []string{"jane@example.com"}},
+		{"owner already a recipient", []string{"jane@example.com"}, map[string]string{ownerEmailAnnotationName: "Jane@example.com"}, []string{"jane@example.com"}},
+		{"invalid owner", []string{"team@example.com"}, map[string]string{ownerEmailAnnotationName: "not an address"}, []string{"team@example.com"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent []sentMail
+			e := newTestEmail(test.to, &sent, nil)
+
+			_, err := e.NotifyFailed(MessageTemplateParam{JobName: "the-job", Namespace: "test-ns", Annotations: test.annotations})
+
+			assert.NoError(t, err)
+			assert.Len(t, sent, 1)
+			assert.Equal(t, test.expected, sent[0].to)
+			// The default list isn't changed by the owner of another job.
+			assert.Equal(t, test.to, e.to)
+		})
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	var sent []sentMail
+	e := newTestEmail([]string{"team@example.com"}, &sent, nil)
+
+	_, err := e.NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job-28472940",
+		CronJobName: "the-job",
+		Namespace:   "test-ns",
+		ClusterName: "prod",
+		FailedCount: 1,
+		ExitCode:    2,
+		LogURL:      "https://logs.example.com/the-job",
+		Log:         "panic: boom",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", sent[0].addr)
+	assert.Equal(t, "jobs@example.com", sent[0].from)
+	assert.Contains(t, sent[0].msg, "To: team@example.com\r\n")
+	assert.Contains(t, sent[0].msg, "Subject: [prod] Job failed: test-ns/the-job\r\n")
+	assert.Contains(t, sent[0].msg, "Job the-job-28472940 failed in test-ns.\r\n")
+	assert.Contains(t, sent[0].msg, "ExitCode: 2\r\n")
+	assert.Contains(t, sent[0].msg, "Logs: https://logs.example.com/the-job\r\n")
+	assert.Contains(t, sent[0].msg, "panic: boom")
+}
+
+func TestEmailSkipped(t *testing.T) {
+	var sent []sentMail
+
+	result, err := newTestEmail(nil, &sent, nil).NotifyFailed(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, result.SkippedReason)
+
+	result, err = newTestEmail([]string{"team@example.com"}, &sent, nil).NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{suppressFailedAnnotationName: "true"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, result.SkippedReason)
+
+	result, err = newTestEmail([]string{"team@example.com"}, &sent, nil).NotifySuccess(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, result.SkippedReason)
+	assert.Empty(t, sent)
+}
+
+func TestEmailSendError(t *testing.T) {
+	var sent []sentMail
+
+	_, err := newTestEmail([]string{"team@example.com"}, &sent, errors.New("connection refused")).NotifyFailed(MessageTemplateParam{JobName: "the-job"})
+
+	assert.Error(t, err)
+}
+
+func TestParseEmailAddresses(t *testing.T) {
+	assert.Nil(t, parseEmailAddresses(""))
+	assert.Equal(t, []string{"team@example.com", "jane@example.com"}, parseEmailAddresses("team@example.com, Jane <jane@example.com>,,invalid"))
+}
@@ -0,0 +1,203 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"k8s.io/klog"
+)
+
+const (
+	defaultEventBridgeSource = "kube-job-notifier"
+	eventBridgeTimeout       = 10 * time.Second
+)
+
+// eventBridgeAPI is the part of the EventBridge client used to put events.
+type eventBridgeAPI interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// eventBridgeDetail is the detail of the events put onto the bus.
+type eventBridgeDetail struct {
+	Event                string            `json:"event"`
+	JobName              string            `json:"jobName"`
+	CronJobName          string            `json:"cronJobName,omitempty"`
+	Namespace            string            `json:"namespace"`
+	StartTime            *time.Time        `json:"startTime,omitempty"`
+	CompletionTime       *time.Time        `json:"completionTime,omitempty"`
+	ExecutionTimeSeconds int64             `json:"executionTimeSeconds,omitempty"`
+	FailedCount          int32             `json:"failedCount"`
+	BackoffLimit         int32             `json:"backoffLimit"`
+	ExitCode             int32             `json:"exitCode,omitempty"`
+	TimedOut             bool              `json:"timedOut,omitempty"`
+	OOMKilledContainer   string            `json:"oomKilledContainer,omitempty"`
+	WaitingReason        string            `json:"waitingReason,omitempty"`
+	FailedIndices        string            `json:"failedIndices,omitempty"`
+	NodeName             string            `json:"nodeName,omitempty"`
+	SpecChanges          []string          `json:"specChanges,omitempty"`
+	BatchSummary         string            `json:"batchSummary,omitempty"`
+	LogURL               string            `json:"logUrl,omitempty"`
+	JobDetailURL         string            `json:"jobDetailUrl,omitempty"`
+	Annotations          map[string]string `json:"annotations,omitempty"`
+}
+
+// eventBridge puts every job event onto an EventBridge event bus, e.g. to trigger Lambda
+// functions or Step Functions. Credentials come from the standard AWS credential chain.
+type eventBridge struct {
+	client  eventBridgeAPI
+	busName string
+	source  string
+}
+
+func init() {
+	Register("eventbridge", func() (Notification, bool) {
+		busName := os.Getenv("EVENTBRIDGE_BUS_NAME")
+		if busName == "" {
+			return nil, false
+		}
+		cfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			klog.Errorf("Failed load AWS config for EventBridge: %v", err)
+			return nil, false
+		}
+		source := os.Getenv("EVENTBRIDGE_SOURCE")
+		if source == "" {
+			source = defaultEventBridgeSource
+		}
+		return newEventBridge(eventbridge.NewFromConfig(cfg), busName, source), true
+	})
+}
+
+func newEventBridge(client eventBridgeAPI, busName string, source string) eventBridge {
+	return eventBridge{client: client, busName: busName, source: source}
+}
+
+func (e eventBridge) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressStartedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, e.put(START, "Job Started", messageParam)
+}
+
+func (e eventBridge) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	return NotifyResult{}, e.put(SUCCESS, "Job Succeeded", messageParam)
+}
+
+func (e eventBridge) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	return NotifyResult{}, e.put(FAILED, "Job Failed", messageParam)
+}
+
+func (e eventBridge) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, e.put(SUSPENDED, "Job Suspended", messageParam)
+}
+
+func (e eventBridge) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, e.put(RESUMED, "Job Resumed", messageParam)
+}
+
+func (e eventBridge) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSpecChangedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, e.put(SPEC_CHANGED, "Job Spec Changed", messageParam)
+}
+
+func (e eventBridge) put(event string, detailType string, messageParam MessageTemplateParam) error {
+	detail, err := json.Marshal(getEventBridgeDetail(event, messageParam))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventBridgeTimeout)
+	defer cancel()
+	output, err := e.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []types.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(e.busName),
+				Source:       aws.String(e.source),
+				DetailType:   aws.String(detailType),
+				Detail:       aws.String(string(detail)),
+			},
+		},
+	})
+	if err != nil {
+		klog.Errorf("Put EventBridge event failed %s\n", err)
+		return err
+	}
+	// PutEvents succeeds as a call even when the entry was rejected.
+	if output.FailedEntryCount > 0 {
+		err = fmt.Errorf("eventbridge rejected the event: %s", getEventBridgeEntryError(output.Entries))
+		klog.Errorf("Put EventBridge event failed %s\n", err)
+		return err
+	}
+	klog.Infof("EventBridge %s event successfully put for %s", event, messageParam.JobName)
+	return nil
+}
+
+func getEventBridgeEntryError(entries []types.PutEventsResultEntry) string {
+	for _, entry := range entries {
+		if entry.ErrorCode != nil {
+			return aws.ToString(entry.ErrorCode) + ": " + aws.ToString(entry.ErrorMessage)
+		}
+	}
+	return "unknown error"
+}
+
+func getEventBridgeDetail(event string, messageParam MessageTemplateParam) eventBridgeDetail {
+	detail := eventBridgeDetail{
+		Event:                event,
+		JobName:              messageParam.JobName,
+		CronJobName:          messageParam.CronJobName,
+		Namespace:            messageParam.Namespace,
+		ExecutionTimeSeconds: int64(messageParam.ExecutionTime.Seconds()),
+		FailedCount:          messageParam.FailedCount,
+		BackoffLimit:         messageParam.BackoffLimit,
+		ExitCode:             messageParam.ExitCode,
+		TimedOut:             messageParam.TimedOut,
+		OOMKilledContainer:   messageParam.OOMKilledContainer,
+		WaitingReason:        messageParam.WaitingReason,
+		FailedIndices:        messageParam.FailedIndices,
+		NodeName:             messageParam.NodeName,
+		SpecChanges:          messageParam.SpecChanges,
+		BatchSummary:         messageParam.BatchSummary,
+		LogURL:               messageParam.LogURL,
+		JobDetailURL:         messageParam.JobDetailURL,
+		Annotations:          messageParam.Annotations,
+	}
+	if messageParam.StartTime != nil {
+		startTime := messageParam.StartTime.UTC()
+		detail.StartTime = &startTime
+	}
+	if messageParam.CompletionTime != nil {
+		completionTime := messageParam.CompletionTime.UTC()
+		detail.CompletionTime = &completionTime
+	}
+	return detail
+}
@@ -0,0 +1,95 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeEventBridge struct {
+	inputs []*eventbridge.PutEventsInput
+	output *eventbridge.PutEventsOutput
+}
+
+func (f *fakeEventBridge) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	f.inputs = append(f.inputs, params)
+	if f.output != nil {
+		return f.output, nil
+	}
+	return &eventbridge.PutEventsOutput{}, nil
+}
+
+func TestEventBridgeNotifyFailed(t *testing.T) {
+	client := &fakeEventBridge{}
+	e := newEventBridge(client, "the-bus", "kube-job-notifier")
+	startTime := metav1.NewTime(time.Date(2020, 9, 6, 1, 0, 0, 0, time.UTC))
+	completionTime := metav1.NewTime(startTime.Add(90 * time.Second))
+
+	_, err := e.NotifyFailed(MessageTemplateParam{
+		JobName:        "the-job-123",
+		CronJobName:    "the-job",
+		Namespace:      "test-ns",
+		StartTime:      &startTime,
+		CompletionTime: &completionTime,
+		FailedCount:    3,
+		BackoffLimit:   3,
+		ExitCode:       2,
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, client.inputs, 1)
+	entry := client.inputs[0].Entries[0]
+	assert.Equal(t, "the-bus", aws.ToString(entry.EventBusName))
+	assert.Equal(t, "kube-job-notifier", aws.ToString(entry.Source))
+	assert.Equal(t, "Job Failed", aws.ToString(entry.DetailType))
+
+	var detail map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(aws.ToString(entry.Detail)), &detail))
+	assert.Equal(t, map[string]interface{}{
+		"event":                "failed",
+		"jobName":              "the-job-123",
+		"cronJobName":          "the-job",
+		"namespace":            "test-ns",
+		"startTime":            "2020-09-06T01:00:00Z",
+		"completionTime":       "2020-09-06T01:01:30Z",
+		"executionTimeSeconds": float64(90),
+		"failedCount":          float64(3),
+		"backoffLimit":         float64(3),
+		"exitCode":             float64(2),
+	}, detail)
+}
+
+func TestEventBridgeRejectedEntry(t *testing.T) {
+	client := &fakeEventBridge{output: &eventbridge.PutEventsOutput{
+		FailedEntryCount: 1,
+		Entries: []types.PutEventsResultEntry{
+			{ErrorCode: aws.String("AccessDeniedException"), ErrorMessage: aws.String("not authorized")},
+		},
+	}}
+	e := newEventBridge(client, "the-bus", "kube-job-notifier")
+
+	_, err := e.NotifyStart(MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+
+	assert.EqualError(t, err, "eventbridge rejected the event: AccessDeniedException: not authorized")
+}
+
+func TestEventBridgeSuppressed(t *testing.T) {
+	client := &fakeEventBridge{}
+	e := newEventBridge(client, "the-bus", "kube-job-notifier")
+
+	result, err := e.NotifySuccess(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{suppressSuccessAnnotationName: "true"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, result.SkippedReason)
+	assert.Empty(t, client.inputs)
+}
@@ -0,0 +1,192 @@
+package notification
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification/jobevent"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/klog"
+)
+
+const (
+	grpcSinkOpenTimeout     = 10 * time.Second
+	grpcSinkMaxReconnectGap = 30 * time.Second
+)
+
+// grpcSink streams job events to a JobEventSink service. The connection reconnects with
+// backoff on its own, the stream is reopened after it broke. Events are sent at most once.
+type grpcSink struct {
+	client jobevent.JobEventSinkClient
+
+	mu     sync.Mutex
+	stream jobevent.JobEventSink_SendClient
+	cancel context.CancelFunc
+}
+
+func init() {
+	Register("grpc", func() (Notification, bool) {
+		addr := os.Getenv("GRPC_SINK_ADDR")
+		if addr == "" {
+			return nil, false
+		}
+		sink, err := newGRPCSink(addr, os.Getenv("GRPC_SINK_TLS") == "true")
+		if err != nil {
+			klog.Errorf("Failed create gRPC sink for %s: %v", addr, err)
+			return nil, false
+		}
+		return sink, true
+	})
+}
+
+func newGRPCSink(addr string, useTLS bool) (*grpcSink, error) {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	backoffConfig := backoff.DefaultConfig
+	backoffConfig.MaxDelay = grpcSinkMaxReconnectGap
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoffConfig, MinConnectTimeout: grpcSinkOpenTimeout}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcSink{client: jobevent.NewJobEventSinkClient(conn)}, nil
+}
+
+func (s *grpcSink) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressStartedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, s.send(START, messageParam)
+}
+
+func (s *grpcSink) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	return NotifyResult{}, s.send(SUCCESS, messageParam)
+}
+
+func (s *grpcSink) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	return NotifyResult{}, s.send(FAILED, messageParam)
+}
+
+func (s *grpcSink) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, s.send(SUSPENDED, messageParam)
+}
+
+func (s *grpcSink) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, s.send(RESUMED, messageParam)
+}
+
+func (s *grpcSink) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSpecChangedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, s.send(SPEC_CHANGED, messageParam)
+}
+
+// send writes the event to the stream. A broken stream is reopened once, which waits up
+// to grpcSinkOpenTimeout for the connection to come back.
+func (s *grpcSink) send(event string, messageParam MessageTemplateParam) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobEvent := getJobEvent(event, messageParam)
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.stream == nil {
+			if err = s.open(); err != nil {
+				klog.Errorf("gRPC sink stream open failed %s\n", err)
+				return err
+			}
+		}
+		err = s.stream.Send(jobEvent)
+		if err == nil {
+			klog.Infof("gRPC sink event successfully sent for %s", messageParam.JobName)
+			return nil
+		}
+		// Send only reports io.EOF for a broken stream, the cause comes from CloseAndRecv.
+		if errors.Is(err, io.EOF) {
+			_, err = s.stream.CloseAndRecv()
+		}
+		klog.Errorf("gRPC sink stream broken, reopening: %v", err)
+		s.reset()
+	}
+	return err
+}
+
+func (s *grpcSink) open() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(grpcSinkOpenTimeout, cancel)
+	stream, err := s.client.Send(ctx, grpc.WaitForReady(true))
+	if !timer.Stop() || err != nil {
+		cancel()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return err
+	}
+	s.stream = stream
+	s.cancel = cancel
+	return nil
+}
+
+func (s *grpcSink) reset() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stream = nil
+	s.cancel = nil
+}
+
+func getJobEvent(event string, messageParam MessageTemplateParam) *jobevent.JobEvent {
+	jobEvent := &jobevent.JobEvent{
+		Event:                event,
+		JobName:              messageParam.JobName,
+		CronjobName:          messageParam.CronJobName,
+		Namespace:            messageParam.Namespace,
+		ExecutionTimeSeconds: int64(messageParam.ExecutionTime.Seconds()),
+		FailedCount:          messageParam.FailedCount,
+		BackoffLimit:         messageParam.BackoffLimit,
+		TimedOut:             messageParam.TimedOut,
+		OomKilledContainer:   messageParam.OOMKilledContainer,
+		Log:                  messageParam.Log,
+		Annotations:          messageParam.Annotations,
+	}
+	if messageParam.StartTime != nil {
+		jobEvent.StartTime = timestamppb.New(messageParam.StartTime.Time)
+	}
+	if messageParam.CompletionTime != nil {
+		jobEvent.CompletionTime = timestamppb.New(messageParam.CompletionTime.Time)
+	}
+	return jobEvent
+}
@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification/jobevent"
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeJobEventSink struct {
+	jobevent.UnimplementedJobEventSinkServer
+	events chan *jobevent.JobEvent
+}
+
+func (s *fakeJobEventSink) Send(stream jobevent.JobEventSink_SendServer) error {
+	var received int64
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&jobevent.SendSummary{Received: received})
+		}
+		if err != nil {
+			return err
+		}
+		received++
+		s.events <- event
+	}
+}
+
+func startJobEventSink(t *testing.T, addr string) (*grpc.Server, string, chan *jobevent.JobEvent) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &fakeJobEventSink{events: make(chan *jobevent.JobEvent, 10)}
+	server := grpc.NewServer()
+	jobevent.RegisterJobEventSinkServer(server, sink)
+	go server.Serve(lis)
+	return server, lis.Addr().String(), sink.events
+}
+
+func receiveJobEvent(t *testing.T, events chan *jobevent.JobEvent) *jobevent.JobEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestGRPCSinkSend(t *testing.T) {
+	server, addr, events := startJobEventSink(t, "127.0.0.1:0")
+	defer server.Stop()
+
+	sink, err := newGRPCSink(addr, false)
+	assert.NoError(t, err)
+
+	startTime := metav1.NewTime(time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC))
+	completionTime := metav1.NewTime(time.Date(2020, 11, 28, 1, 1, 30, 0, time.UTC))
+	_, err = sink.NotifyFailed(MessageTemplateParam{
+		JobName:            "the-job",
+		CronJobName:        "the-cronjob",
+		Namespace:          "test-ns",
+		StartTime:          &startTime,
+		CompletionTime:     &completionTime,
+		FailedCount:        3,
+		BackoffLimit:       2,
+		OOMKilledContainer: "worker",
+		Annotations:        map[string]string{"team": "payments"},
+	})
+	assert.NoError(t, err)
+
+	event := receiveJobEvent(t, events)
+	assert.Equal(t, "failed", event.Event)
+	assert.Equal(t, "the-job", event.JobName)
+	assert.Equal(t, "the-cronjob", event.CronjobName)
+	assert.Equal(t, "test-ns", event.Namespace)
+	assert.Equal(t, int64(90), event.ExecutionTimeSeconds)
+	assert.Equal(t, startTime.Time, event.StartTime.AsTime())
+	assert.Equal(t, int32(3), event.FailedCount)
+	assert.Equal(t, "worker", event.OomKilledContainer)
+	assert.Equal(t, map[string]string{"team": "payments"}, event.Annotations)
+}
+
+func TestGRPCSinkReconnect(t *testing.T) {
+	server, addr, events := startJobEventSink(t, "127.0.0.1:0")
+
+	sink, err := newGRPCSink(addr, false)
+	assert.NoError(t, err)
+
+	_, err = sink.NotifyStart(MessageTemplateParam{JobName: "first"})
+	assert.NoError(t, err)
+	assert.Equal(t, "first", receiveJobEvent(t, events).JobName)
+
+	server.Stop()
+	server, _, events = startJobEventSink(t, addr)
+	defer server.Stop()
+
+	// The first send after the restart may still go to the broken stream and be lost.
+	assert.Eventually(t, func() bool {
+		_, err := sink.NotifyStart(MessageTemplateParam{JobName: "second"})
+		if err != nil {
+			return false
+		}
+		select {
+		case event := <-events:
+			return event.JobName == "second"
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestGRPCSinkSuppressed(t *testing.T) {
+	sink := &grpcSink{}
+	result, err := sink.NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{suppressFailedAnnotationName: "true"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, result.SkippedReason)
+}
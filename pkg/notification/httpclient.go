@@ -0,0 +1,59 @@
+package notification
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	slackapi "github.com/slack-go/slack"
+	"golang.org/x/net/http/httpproxy"
+	"k8s.io/klog"
+)
+
+const defaultSlackHTTPTimeout = 30 * time.Second
+
+// newSlackAPI returns a Slack API client using newSlackHTTPClient.
+func newSlackAPI(token string) *slackapi.Client {
+	return slackapi.New(token, slackapi.OptionHTTPClient(newSlackHTTPClient()))
+}
+
+// newSlackHTTPClient returns the HTTP client used to reach Slack. Requests go through
+// SLACK_PROXY_URL when set, otherwise through the proxy of HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+// SLACK_HTTP_TIMEOUT limits every request.
+func newSlackHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = getSlackProxy()
+	return &http.Client{
+		Transport: transport,
+		Timeout:   getSlackHTTPTimeout(),
+	}
+}
+
+func getSlackProxy() func(*http.Request) (*url.URL, error) {
+	if value := os.Getenv("SLACK_PROXY_URL"); value != "" {
+		proxyURL, err := url.Parse(value)
+		if err == nil && proxyURL.Host != "" {
+			return http.ProxyURL(proxyURL)
+		}
+		klog.Errorf("Invalid SLACK_PROXY_URL %q, using the proxy environment variables: %v", value, err)
+	}
+	// http.ProxyFromEnvironment caches the environment on first use, read it per client instead.
+	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+func getSlackHTTPTimeout() time.Duration {
+	value := os.Getenv("SLACK_HTTP_TIMEOUT")
+	if value == "" {
+		return defaultSlackHTTPTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		klog.Errorf("Invalid SLACK_HTTP_TIMEOUT %q, using default %s: %v", value, defaultSlackHTTPTimeout, err)
+		return defaultSlackHTTPTimeout
+	}
+	return timeout
+}
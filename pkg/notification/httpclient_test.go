@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	slackapi "github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func getProxy(t *testing.T, client *http.Client, target string) string {
+	req, err := http.NewRequest(http.MethodPost, target, nil)
+	assert.NoError(t, err)
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	assert.NoError(t, err)
+	if proxyURL == nil {
+		return ""
+	}
+	return proxyURL.String()
+}
+
+func TestNewSlackHTTPClientProxy(t *testing.T) {
+	tests := []struct {
+		Name     string
+		proxyURL string
+		https    string
+		noProxy  string
+		expected string
+	}{
+		{"No proxy", "", "", "", ""},
+		{"HTTPS_PROXY", "", "http://proxy.example:3128", "", "http://proxy.example:3128"},
+		{"NO_PROXY", "", "http://proxy.example:3128", "slack.com", ""},
+		{"SLACK_PROXY_URL", "http://slack-proxy.example:8080", "http://proxy.example:3128", "slack.com", "http://slack-proxy.example:8080"},
+		{"Invalid SLACK_PROXY_URL", "not a url", "http://proxy.example:3128", "", "http://proxy.example:3128"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Setenv("SLACK_PROXY_URL", test.proxyURL)
+			t.Setenv("HTTPS_PROXY", test.https)
+			t.Setenv("NO_PROXY", test.noProxy)
+
+			client := newSlackHTTPClient()
+
+			assert.Equal(t, test.expected, getProxy(t, client, "https://slack.com/api/chat.postMessage"))
+		})
+	}
+}
+
+func TestNewSlackHTTPClientTimeout(t *testing.T) {
+	assert.Equal(t, defaultSlackHTTPTimeout, newSlackHTTPClient().Timeout)
+
+	t.Setenv("SLACK_HTTP_TIMEOUT", "5s")
+	assert.Equal(t, 5*time.Second, newSlackHTTPClient().Timeout)
+
+	t.Setenv("SLACK_HTTP_TIMEOUT", "invalid")
+	assert.Equal(t, defaultSlackHTTPTimeout, newSlackHTTPClient().Timeout)
+}
+
+func TestSlackRequestsGoThroughProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute URL of the target.
+		requested = r.URL.String()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C0123","ts":"1700000000.000100"}`))
+	}))
+	defer proxy.Close()
+	t.Setenv("SLACK_PROXY_URL", proxy.URL)
+
+	client := slackapi.New("token",
+		slackapi.OptionHTTPClient(newSlackHTTPClient()),
+		slackapi.OptionAPIURL("http://slack.example/api/"),
+	)
+	channel, _, err := client.PostMessage("C0123", slackapi.MsgOptionText("hello", false))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "C0123", channel)
+	assert.Equal(t, "http://slack.example/api/chat.postMessage", requested)
+}
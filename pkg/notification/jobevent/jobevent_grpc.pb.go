@@ -0,0 +1,116 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: jobevent.proto
+
+package jobevent
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	JobEventSink_Send_FullMethodName = "/kubejobnotifier.v1.JobEventSink/Send"
+)
+
+// JobEventSinkClient is the client API for JobEventSink service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type JobEventSinkClient interface {
+	// Send streams job events to the sink.
+	Send(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[JobEvent, SendSummary], error)
+}
+
+type jobEventSinkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJobEventSinkClient(cc grpc.ClientConnInterface) JobEventSinkClient {
+	return &jobEventSinkClient{cc}
+}
+
+func (c *jobEventSinkClient) Send(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[JobEvent, SendSummary], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JobEventSink_ServiceDesc.Streams[0], JobEventSink_Send_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[JobEvent, SendSummary]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobEventSink_SendClient = grpc.ClientStreamingClient[JobEvent, SendSummary]
+
+// JobEventSinkServer is the server API for JobEventSink service.
+// All implementations must embed UnimplementedJobEventSinkServer
+// for forward compatibility.
+type JobEventSinkServer interface {
+	// Send streams job events to the sink.
+	Send(grpc.ClientStreamingServer[JobEvent, SendSummary]) error
+	mustEmbedUnimplementedJobEventSinkServer()
+}
+
+// UnimplementedJobEventSinkServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedJobEventSinkServer struct{}
+
+func (UnimplementedJobEventSinkServer) Send(grpc.ClientStreamingServer[JobEvent, SendSummary]) error {
+	return status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedJobEventSinkServer) mustEmbedUnimplementedJobEventSinkServer() {}
+func (UnimplementedJobEventSinkServer) testEmbeddedByValue()                      {}
+
+// UnsafeJobEventSinkServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JobEventSinkServer will
+// result in compilation errors.
+type UnsafeJobEventSinkServer interface {
+	mustEmbedUnimplementedJobEventSinkServer()
+}
+
+func RegisterJobEventSinkServer(s grpc.ServiceRegistrar, srv JobEventSinkServer) {
+	// If the following call pancis, it indicates UnimplementedJobEventSinkServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&JobEventSink_ServiceDesc, srv)
+}
+
+func _JobEventSink_Send_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JobEventSinkServer).Send(&grpc.GenericServerStream[JobEvent, SendSummary]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobEventSink_SendServer = grpc.ClientStreamingServer[JobEvent, SendSummary]
+
+// JobEventSink_ServiceDesc is the grpc.ServiceDesc for JobEventSink service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var JobEventSink_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubejobnotifier.v1.JobEventSink",
+	HandlerType: (*JobEventSinkServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Send",
+			Handler:       _JobEventSink_Send_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "jobevent.proto",
+}
@@ -0,0 +1,205 @@
+package notification
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"k8s.io/klog"
+)
+
+const kafkaTimeout = 10 * time.Second
+
+// kafkaWriter is the part of the Kafka writer used to produce messages.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+}
+
+// kafkaEvent is the value of the messages produced to the topic. The logs, the job YAML and
+// the annotations are left out, they may hold secrets and exceed the message size limit.
+type kafkaEvent struct {
+	Event                string     `json:"event"`
+	ClusterName          string     `json:"clusterName,omitempty"`
+	JobName              string     `json:"jobName"`
+	CronJobName          string     `json:"cronJobName,omitempty"`
+	Namespace            string     `json:"namespace"`
+	StartTime            *time.Time `json:"startTime,omitempty"`
+	CompletionTime       *time.Time `json:"completionTime,omitempty"`
+	ExecutionTimeSeconds int64      `json:"executionTimeSeconds,omitempty"`
+	FailedCount          int32      `json:"failedCount"`
+	BackoffLimit         int32      `json:"backoffLimit"`
+	Reason               string     `json:"reason,omitempty"`
+	ExitCode             int32      `json:"exitCode,omitempty"`
+	TimedOut             bool       `json:"timedOut,omitempty"`
+	OOMKilledContainer   string     `json:"oomKilledContainer,omitempty"`
+	WaitingReason        string     `json:"waitingReason,omitempty"`
+	FailedIndices        string     `json:"failedIndices,omitempty"`
+	NodeName             string     `json:"nodeName,omitempty"`
+	SpecChanges          []string   `json:"specChanges,omitempty"`
+	BatchSummary         string     `json:"batchSummary,omitempty"`
+	LogURL               string     `json:"logUrl,omitempty"`
+	JobDetailURL         string     `json:"jobDetailUrl,omitempty"`
+}
+
+// kafka produces a JSON message per job event to KAFKA_TOPIC, keyed by namespace/jobName so
+// that the events of a job land in the same partition, in order.
+type kafka struct {
+	writer kafkaWriter
+}
+
+func init() {
+	Register("kafka", func() (Notification, bool) {
+		brokers, topic := os.Getenv("KAFKA_BROKERS"), os.Getenv("KAFKA_TOPIC")
+		if brokers == "" || topic == "" {
+			return nil, false
+		}
+		mechanism, err := getKafkaSASLMechanism(os.Getenv("KAFKA_SASL_MECHANISM"), os.Getenv("KAFKA_SASL_USERNAME"), os.Getenv("KAFKA_SASL_PASSWORD"))
+		if err != nil {
+			klog.Errorf("Invalid Kafka SASL configuration: %v", err)
+			return nil, false
+		}
+		transport := &kafkago.Transport{SASL: mechanism}
+		if os.Getenv("KAFKA_TLS") == "true" {
+			transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		return newKafka(&kafkago.Writer{
+			Addr:         kafkago.TCP(strings.Split(brokers, ",")...),
+			Topic:        topic,
+			Balancer:     &kafkago.Hash{},
+			RequiredAcks: kafkago.RequireAll,
+			WriteTimeout: kafkaTimeout,
+			Transport:    transport,
+		}), true
+	})
+}
+
+func newKafka(writer kafkaWriter) kafka {
+	return kafka{writer: writer}
+}
+
+// getKafkaSASLMechanism returns the SASL mechanism of KAFKA_SASL_MECHANISM, plain,
+// scram-sha-256 or scram-sha-512, nil when SASL is not used.
+func getKafkaSASLMechanism(name string, username string, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	}
+	return nil, fmt.Errorf("unknown KAFKA_SASL_MECHANISM %q, must be plain, scram-sha-256 or scram-sha-512", name)
+}
+
+func (k kafka) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressStartedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, k.produce(START, messageParam)
+}
+
+func (k kafka) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	return NotifyResult{}, k.produce(SUCCESS, messageParam)
+}
+
+func (k kafka) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	return NotifyResult{}, k.produce(FAILED, messageParam)
+}
+
+func (k kafka) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, k.produce(SUSPENDED, messageParam)
+}
+
+func (k kafka) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, k.produce(RESUMED, messageParam)
+}
+
+func (k kafka) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSpecChangedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, k.produce(SPEC_CHANGED, messageParam)
+}
+
+func (k kafka) produce(event string, messageParam MessageTemplateParam) error {
+	value, err := json.Marshal(getKafkaEvent(event, messageParam))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaTimeout)
+	defer cancel()
+	err = k.writer.WriteMessages(ctx, kafkago.Message{
+		Key:   []byte(messageParam.Namespace + "/" + messageParam.JobName),
+		Value: value,
+	})
+	if err != nil {
+		klog.Errorf("Kafka produce failed %s\n", err)
+		return err
+	}
+	klog.Infof("Kafka event produced for %s", messageParam.JobName)
+	return nil
+}
+
+func getKafkaEvent(event string, messageParam MessageTemplateParam) kafkaEvent {
+	value := kafkaEvent{
+		Event:                event,
+		ClusterName:          messageParam.ClusterName,
+		JobName:              messageParam.JobName,
+		CronJobName:          messageParam.CronJobName,
+		Namespace:            messageParam.Namespace,
+		ExecutionTimeSeconds: int64(messageParam.ExecutionTime.Seconds()),
+		FailedCount:          messageParam.FailedCount,
+		BackoffLimit:         messageParam.BackoffLimit,
+		Reason:               messageParam.Reason,
+		ExitCode:             messageParam.ExitCode,
+		TimedOut:             messageParam.TimedOut,
+		OOMKilledContainer:   messageParam.OOMKilledContainer,
+		WaitingReason:        messageParam.WaitingReason,
+		FailedIndices:        messageParam.FailedIndices,
+		NodeName:             messageParam.NodeName,
+		SpecChanges:          messageParam.SpecChanges,
+		BatchSummary:         messageParam.BatchSummary,
+		LogURL:               messageParam.LogURL,
+		JobDetailURL:         messageParam.JobDetailURL,
+	}
+	if messageParam.StartTime != nil {
+		startTime := messageParam.StartTime.UTC()
+		value.StartTime = &startTime
+	}
+	if messageParam.CompletionTime != nil {
+		completionTime := messageParam.CompletionTime.UTC()
+		value.CompletionTime = &completionTime
+	}
+	return value
+}
@@ -0,0 +1,108 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeKafkaWriter struct {
+	messages []kafkago.Message
+	err      error
+}
+
+func (f *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	f.messages = append(f.messages, msgs...)
+	return f.err
+}
+
+func TestKafkaNotifyFailed(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	k := newKafka(writer)
+	startTime := metav1.NewTime(time.Date(2020, 9, 6, 1, 0, 0, 0, time.UTC))
+	completionTime := metav1.NewTime(startTime.Add(90 * time.Second))
+
+	_, err := k.NotifyFailed(MessageTemplateParam{
+		JobName:        "the-job-123",
+		CronJobName:    "the-job",
+		Namespace:      "test-ns",
+		StartTime:      &startTime,
+		CompletionTime: &completionTime,
+		FailedCount:    3,
+		BackoffLimit:   3,
+		ExitCode:       2,
+		Log:            "password=hunter2",
+		PodLogs:        []PodLog{{PodName: "the-job-123-abcde", Log: "password=hunter2"}},
+		JobYAML:        "kind: Job",
+		Annotations:    map[string]string{"team": "data"},
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, writer.messages, 1)
+	assert.Equal(t, "test-ns/the-job-123", string(writer.messages[0].Key))
+
+	var value kafkaEvent
+	assert.NoError(t, json.Unmarshal(writer.messages[0].Value, &value))
+	assert.Equal(t, FAILED, value.Event)
+	assert.Equal(t, "the-job-123", value.JobName)
+	assert.Equal(t, "the-job", value.CronJobName)
+	assert.Equal(t, "test-ns", value.Namespace)
+	assert.Equal(t, int32(3), value.FailedCount)
+	assert.Equal(t, int32(2), value.ExitCode)
+	assert.Equal(t, int64(90), value.ExecutionTimeSeconds)
+	assert.Equal(t, startTime.UTC(), *value.StartTime)
+	// Logs, the job YAML and annotations are not produced.
+	assert.NotContains(t, string(writer.messages[0].Value), "hunter2")
+	assert.NotContains(t, string(writer.messages[0].Value), "kind: Job")
+	assert.NotContains(t, string(writer.messages[0].Value), "team")
+}
+
+func TestKafkaNotifySuppressed(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	k := newKafka(writer)
+
+	result, err := k.NotifyStart(MessageTemplateParam{
+		JobName:     "the-job-123",
+		Namespace:   "test-ns",
+		Annotations: map[string]string{suppressStartedAnnotationName: "true"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, result.SkippedReason)
+	assert.Empty(t, writer.messages)
+}
+
+func TestKafkaProduceFailed(t *testing.T) {
+	k := newKafka(&fakeKafkaWriter{err: errors.New("leader not available")})
+
+	_, err := k.NotifySuccess(MessageTemplateParam{JobName: "the-job-123", Namespace: "test-ns"})
+
+	assert.EqualError(t, err, "leader not available")
+}
+
+func TestGetKafkaSASLMechanism(t *testing.T) {
+	mechanism, err := getKafkaSASLMechanism("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, mechanism)
+
+	mechanism, err = getKafkaSASLMechanism("PLAIN", "user", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, plain.Mechanism{Username: "user", Password: "secret"}, mechanism)
+
+	for _, name := range []string{"scram-sha-256", "scram-sha-512"} {
+		mechanism, err = getKafkaSASLMechanism(name, "user", "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, name, strings.ToLower(mechanism.Name()))
+	}
+
+	_, err = getKafkaSASLMechanism("gssapi", "user", "secret")
+	assert.ErrorContains(t, err, "KAFKA_SASL_MECHANISM")
+}
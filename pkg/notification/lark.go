@@ -0,0 +1,297 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"k8s.io/klog"
+)
+
+// larkCard is an interactive card message of a Lark (Feishu) group bot.
+type larkCard struct {
+	Timestamp string          `json:"timestamp,omitempty"`
+	Sign      string          `json:"sign,omitempty"`
+	MsgType   string          `json:"msg_type"`
+	Card      larkCardContent `json:"card"`
+}
+
+type larkCardContent struct {
+	Header   larkCardHeader    `json:"header"`
+	Elements []larkCardElement `json:"elements"`
+}
+
+type larkCardHeader struct {
+	Title    larkText `json:"title"`
+	Template string   `json:"template"`
+}
+
+type larkCardElement struct {
+	Tag  string   `json:"tag"`
+	Text larkText `json:"text"`
+}
+
+type larkText struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type larkResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// lark posts interactive cards to a Lark (Feishu) group through a custom bot webhook.
+type lark struct {
+	url string
+	// secret signs every request when the bot has signature verification enabled.
+	secret string
+	// locale selects the bundled titles and labels, empty for English.
+	locale     string
+	timeFormat timeFormat
+	client     *http.Client
+}
+
+func init() {
+	Register("lark", func() (Notification, bool) {
+		url := os.Getenv("LARK_WEBHOOK_URL")
+		locale, err := parseLocale(os.Getenv("LOCALE"))
+		if err != nil && url != "" {
+			klog.Errorf("Invalid Lark configuration: %v", err)
+		}
+		return newLark(url, os.Getenv("LARK_SECRET"), locale), url != ""
+	})
+}
+
+func newLark(url string, secret string, locale string) lark {
+	return lark{
+		url:        url,
+		secret:     secret,
+		locale:     locale,
+		timeFormat: getTimeFormat(),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (l lark) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressStartedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, l.notify(translate(l.locale, "Job Start"), "blue", messageParam)
+}
+
+func (l lark) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	title := "Job Success"
+	if messageParam.Recovered {
+		title = "Job Recovered"
+	}
+	return NotifyResult{}, l.notify(translate(l.locale, title), "green", messageParam)
+}
+
+func (l lark) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	messageParam.RunbookURL = messageParam.Annotations[runbookURLAnnotationName]
+	messageParam.KubectlCommands = messageParam.kubectlCommands()
+	if messageParam.WaitingReason != "" {
+		return NotifyResult{}, l.notify(translate(l.locale, "Job Stuck")+" ("+messageParam.WaitingReason+")", "orange", messageParam)
+	}
+	if messageParam.LostPods > 0 {
+		return NotifyResult{}, l.notify(translate(l.locale, "Job Pods Lost"), "orange", messageParam)
+	}
+	if messageParam.TimedOut {
+		return NotifyResult{}, l.notify(translate(l.locale, "Job Timed Out"), "orange", messageParam)
+	}
+	return NotifyResult{}, l.notify(translate(l.locale, "Job Failed"), "red", messageParam)
+}
+
+func (l lark) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, l.notify(translate(l.locale, "Job Suspended"), "orange", messageParam)
+}
+
+func (l lark) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, l.notify(translate(l.locale, "Job Resumed"), "blue", messageParam)
+}
+
+func (l lark) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSpecChangedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, l.notify(translate(l.locale, "Job Spec Changed"), "blue", messageParam)
+}
+
+func (l lark) notify(title string, template string, messageParam MessageTemplateParam) (err error) {
+	card := larkCard{
+		MsgType: "interactive",
+		Card: larkCardContent{
+			Header: larkCardHeader{
+				Title:    larkText{Tag: "plain_text", Content: title},
+				Template: template,
+			},
+			Elements: []larkCardElement{
+				{Tag: "div", Text: larkText{Tag: "lark_md", Content: getLarkMessage(l.locale, l.timeFormat, messageParam)}},
+			},
+		},
+	}
+	if l.secret != "" {
+		timestamp := strconv.FormatInt(flextime.Now().Unix(), 10)
+		card.Timestamp = timestamp
+		card.Sign, err = getLarkSign(timestamp, l.secret)
+		if err != nil {
+			return err
+		}
+	}
+
+	body, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	resp, err := l.client.Post(l.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("Send lark message failed %s\n", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Lark reports most errors, e.g. a wrong signature, with a 200 status and a non-zero code.
+	var res larkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil && resp.StatusCode == http.StatusOK {
+		klog.Errorf("Decode lark response failed %s\n", err)
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || res.Code != 0 {
+		err = fmt.Errorf("lark returned %s: code %d %s", resp.Status, res.Code, res.Msg)
+		klog.Errorf("Send lark message failed %s\n", err)
+		return err
+	}
+
+	klog.Infof("Lark message successfully sent for %s", messageParam.JobName)
+	return nil
+}
+
+// getLarkSign signs the timestamp as described in the Lark custom bot documentation:
+// the HMAC-SHA256 key is "timestamp\nsecret" and the signed data is empty.
+func getLarkSign(timestamp string, secret string) (string, error) {
+	h := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	if _, err := h.Write(nil); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func getLarkMessage(locale string, format timeFormat, messageParam MessageTemplateParam) string {
+	label := func(name string) string {
+		return "**" + translate(locale, name) + "**: "
+	}
+	var lines []string
+	if messageParam.ClusterName != "" {
+		lines = append(lines, label("Cluster")+messageParam.ClusterName)
+	}
+	if messageParam.CronJobName != "" {
+		lines = append(lines, label("CronJobName")+messageParam.CronJobName)
+	}
+	lines = append(lines, label("JobName")+messageParam.JobName)
+	if messageParam.Namespace != "" {
+		lines = append(lines, label("Namespace")+messageParam.Namespace)
+	}
+	if messageParam.StartTime != nil {
+		lines = append(lines, label("StartTime")+format.format(messageParam.StartTime))
+	}
+	if messageParam.CompletionTime != nil {
+		lines = append(lines, label("CompletionTime")+format.format(messageParam.CompletionTime))
+	}
+	if messageParam.ExecutionTime > 0 {
+		lines = append(lines, label("ExecutionTime")+messageParam.ExecutionTime.String())
+	}
+	if messageParam.QueueTime > 0 {
+		lines = append(lines, label("QueueTime")+messageParam.QueueTime.String())
+	}
+	if messageParam.FailingFor > 0 {
+		lines = append(lines, label("FailingFor")+messageParam.FailingFor.String())
+	}
+	if messageParam.TimedOut {
+		lines = append(lines, label("ActiveDeadlineSeconds")+strconv.FormatInt(messageParam.ActiveDeadlineSeconds, 10))
+	}
+	if messageParam.OOMKilledContainer != "" {
+		oomKilled := label("OOMKilled") + messageParam.OOMKilledContainer
+		if messageParam.MemoryLimit != "" {
+			oomKilled += " (" + translate(locale, "memory limit") + " " + messageParam.MemoryLimit + ")"
+		}
+		lines = append(lines, oomKilled)
+	}
+	if messageParam.NodeName != "" {
+		node := label("Node") + messageParam.NodeName
+		if messageParam.Zone != "" {
+			node += " (" + messageParam.Zone + ")"
+		}
+		lines = append(lines, node)
+	}
+	if messageParam.WaitingReason != "" {
+		waiting := label(messageParam.WaitingReason) + messageParam.WaitingContainer
+		if messageParam.WaitingMessage != "" {
+			waiting += " (" + messageParam.WaitingMessage + ")"
+		}
+		lines = append(lines, waiting)
+	}
+	if messageParam.LostPods > 0 {
+		lines = append(lines, label("LostPods")+fmt.Sprintf("%d/%d", messageParam.LostPods, messageParam.PeakActivePods))
+	}
+	if messageParam.CompletionWarning != "" {
+		lines = append(lines, label("Warning")+messageParam.CompletionWarning)
+	}
+	if messageParam.BatchSummary != "" {
+		lines = append(lines, label("Batch")+messageParam.BatchSummary)
+	}
+	if messageParam.IndexSummary != "" {
+		lines = append(lines, label("Indexes")+messageParam.IndexSummary)
+	}
+	if messageParam.FailedIndices != "" {
+		lines = append(lines, label("FailedIndices")+messageParam.FailedIndices)
+	}
+	if len(messageParam.SpecChanges) > 0 {
+		lines = append(lines, label("Changes"))
+		for _, change := range messageParam.SpecChanges {
+			lines = append(lines, "- "+change)
+		}
+	}
+	if len(messageParam.Events) > 0 {
+		lines = append(lines, label("Events"))
+		for _, event := range messageParam.Events {
+			lines = append(lines, "- "+event)
+		}
+	}
+	if messageParam.RunbookURL != "" {
+		lines = append(lines, label("Runbook")+messageParam.RunbookURL)
+	}
+	if len(messageParam.KubectlCommands) > 0 {
+		lines = append(lines, label("Investigate"), codeBlock(messageParam.KubectlCommands))
+	}
+	return strings.Join(lines, "\n")
+}
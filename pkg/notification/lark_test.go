@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLarkServer(t *testing.T, received *[]larkCard, response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var card larkCard
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&card))
+		*received = append(*received, card)
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestLarkCardHeader(t *testing.T) {
+	var received []larkCard
+	server := newLarkServer(t, &received, `{"code":0,"msg":"success"}`)
+	defer server.Close()
+
+	l := newLark(server.URL, "", "")
+	param := MessageTemplateParam{JobName: "the-job", CronJobName: "the-cronjob", Namespace: "namespace"}
+	_, err := l.NotifyStart(param)
+	assert.NoError(t, err)
+	_, err = l.NotifySuccess(param)
+	assert.NoError(t, err)
+	_, err = l.NotifyFailed(param)
+	assert.NoError(t, err)
+
+	assert.Len(t, received, 3)
+	assert.Equal(t, "Job Start", received[0].Card.Header.Title.Content)
+	assert.Equal(t, "blue", received[0].Card.Header.Template)
+	assert.Equal(t, "green", received[1].Card.Header.Template)
+	assert.Equal(t, "Job Failed", received[2].Card.Header.Title.Content)
+	assert.Equal(t, "red", received[2].Card.Header.Template)
+	for _, card := range received {
+		assert.Equal(t, "interactive", card.MsgType)
+		assert.Empty(t, card.Sign)
+		assert.True(t, strings.HasPrefix(card.Card.Elements[0].Text.Content, "**CronJobName**: the-cronjob\n**JobName**: the-job\n**Namespace**: namespace"))
+	}
+	assert.Equal(t, "**CronJobName**: the-cronjob\n**JobName**: the-job\n**Namespace**: namespace", received[0].Card.Elements[0].Text.Content)
+	assert.True(t, strings.HasSuffix(received[2].Card.Elements[0].Text.Content,
+		"\n**Investigate**: \n```\nkubectl logs -n namespace job/the-job\nkubectl describe job -n namespace the-job\n```"))
+}
+
+func TestLarkTimedOut(t *testing.T) {
+	var received []larkCard
+	server := newLarkServer(t, &received, `{"code":0,"msg":"success"}`)
+	defer server.Close()
+
+	_, err := newLark(server.URL, "", "").NotifyFailed(MessageTemplateParam{JobName: "the-job", TimedOut: true, ActiveDeadlineSeconds: 600})
+
+	assert.NoError(t, err)
+	assert.Len(t, received, 1)
+	assert.Equal(t, "Job Timed Out", received[0].Card.Header.Title.Content)
+	assert.Equal(t, "orange", received[0].Card.Header.Template)
+	assert.Contains(t, received[0].Card.Elements[0].Text.Content, "**ActiveDeadlineSeconds**: 600")
+}
+
+func TestLarkSign(t *testing.T) {
+	restore := flextime.Set(time.Unix(1599360473, 0))
+	defer restore()
+
+	var received []larkCard
+	server := newLarkServer(t, &received, `{"code":0,"msg":"success"}`)
+	defer server.Close()
+
+	l := newLark(server.URL, "demo", "")
+	_, err := l.NotifyStart(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+
+	expected, err := getLarkSign("1599360473", "demo")
+	assert.NoError(t, err)
+	assert.Equal(t, "1599360473", received[0].Timestamp)
+	assert.Equal(t, expected, received[0].Sign)
+}
+
+func TestLarkErrorCode(t *testing.T) {
+	var received []larkCard
+	server := newLarkServer(t, &received, `{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`)
+	defer server.Close()
+
+	l := newLark(server.URL, "wrong", "")
+	_, err := l.NotifyFailed(MessageTemplateParam{JobName: "the-job"})
+
+	assert.EqualError(t, err, "lark returned 200 OK: code 19021 sign match fail or timestamp is not within one hour from current time")
+}
+
+func TestLarkSuppressed(t *testing.T) {
+	var received []larkCard
+	server := newLarkServer(t, &received, `{"code":0,"msg":"success"}`)
+	defer server.Close()
+
+	l := newLark(server.URL, "", "")
+	result, err := l.NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{suppressFailedAnnotationName: "true"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, result.SkippedReason)
+	assert.Empty(t, received)
+}
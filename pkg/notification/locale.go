@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"fmt"
+)
+
+const (
+	localeEnglish  = "en"
+	localeJapanese = "ja"
+
+	// SlackMessageTemplateJa is SlackMessageTemplate with Japanese labels, used with LOCALE=ja.
+	SlackMessageTemplateJa = slackMessageHeaderTemplateJa + slackMessageDetailsTemplateJa
+	// SlackFailureMessageTemplateJa is SlackFailureMessageTemplate with Japanese labels.
+	SlackFailureMessageTemplateJa = slackFailureLeadTemplateJa + slackMessageHeaderTemplateJa + slackMessageDiagnosticsTemplateJa + slackMessageLinksTemplateJa
+	slackFailureLeadTemplateJa    = `
+{{if .Reason }} *理由*: {{.Reason | mrkdwn}}
+{{end}}{{if .ExitCode }} *終了コード*: {{.ExitCode}}
+{{end}}` + slackMessageLogTemplateJa
+	// slackMessageHeaderTemplateJa renders the lines shown as attachment fields with SLACK_MESSAGE_FORMAT=fields.
+	slackMessageHeaderTemplateJa = `
+{{if .ClusterName}} *クラスター*: {{.ClusterName | mrkdwn}}
+{{end}}{{if .CronJobName}} *CronJob名*: {{.CronJobName | mrkdwn}}{{end}}
+ *Job名*: {{.JobName | mrkdwn}}
+{{if .Namespace}} *Namespace*: {{.Namespace | mrkdwn}}{{end}}
+{{if .StartTime }} *開始時刻*: {{.StartTime | formatTime}}{{end}}
+{{if .CompletionTime }} *完了時刻*: {{.CompletionTime | formatTime}}{{end}}
+{{if .ExecutionTime }} *実行時間*: {{.ExecutionTime}}{{end}}`
+	slackMessageDetailsTemplateJa     = slackMessageDiagnosticsTemplateJa + slackMessageLogTemplateJa + slackMessageLinksTemplateJa
+	slackMessageDiagnosticsTemplateJa = `{{if .QueueTime }}
+ *待ち時間*: {{.QueueTime}}{{end}}{{if .FailingFor }}
+ *失敗期間*: {{.FailingFor}}{{end}}{{if .TimedOut }}
+ *ActiveDeadlineSeconds*: {{.ActiveDeadlineSeconds}}{{end}}{{if .OOMKilledContainer }}
+ :boom: *OOMKilled*: {{.OOMKilledContainer | mrkdwn}}{{if .MemoryLimit }} (メモリ上限 {{.MemoryLimit}}){{end}}{{end}}{{if .NodeName }}
+ *ノード*: {{.NodeName | mrkdwn}}{{if .Zone }} ({{.Zone | mrkdwn}}){{end}}{{end}}{{if .IndexSummary }}
+ *インデックス*: {{.IndexSummary}}{{end}}{{if .FailedIndices }}
+ *失敗したインデックス*: {{.FailedIndices}}{{end}}{{if .WaitingReason }}
+ :warning: *{{.WaitingReason}}*: {{.WaitingContainer | mrkdwn}}{{if .WaitingMessage }} ({{.WaitingMessage | mrkdwn}}){{end}}{{end}}{{if .LostPods }}
+ :warning: *失われたPod*: 実行中の{{.PeakActivePods}}個のうち{{.LostPods}}個{{end}}{{if .CompletionWarning }}
+ :warning: {{.CompletionWarning}}{{end}}{{if .BatchSummary }}
+ *バッチ*: {{.BatchSummary | mrkdwn}}{{end}}{{range .ResourceWarnings }}
+ :warning: {{. | mrkdwn}}{{end}}{{if .SpecChanges }}
+ *変更*:{{range .SpecChanges }}
+ • {{. | mrkdwn}}{{end}}{{end}}{{if .Events }}
+ *イベント*:{{range .Events }}
+ • {{. | mrkdwn}}{{end}}{{end}}
+`
+	slackMessageLogTemplateJa = `{{if .Log }} *ログ*: {{.Log}}{{end}}{{if .LogURL }}
+ *ログURL*: {{.LogURL}}{{end}}`
+	slackMessageLinksTemplateJa = `{{if .JobDetailURL }}
+ *詳細*: {{.JobDetailURL}}{{end}}{{if .JobYAMLLink }}
+ *JobのYAML*: {{.JobYAMLLink}}{{end}}{{if .RunbookURL }}
+ *Runbook*: {{.RunbookURL}}{{end}}{{if .KubectlCommands }}
+ *調査*:
+{{codeBlock .KubectlCommands}}{{end}}`
+)
+
+// translations maps the English titles and labels to the other bundled locales.
+// Missing entries are shown in English.
+var translations = map[string]map[string]string{
+	localeJapanese: {
+		"Job Start":              "ジョブ開始",
+		"Job Success":            "ジョブ成功",
+		"Job Failed":             "ジョブ失敗",
+		"Job Failed, Retrying":   "ジョブ失敗 (リトライ中)",
+		"Job Failed (OOMKilled)": "ジョブ失敗 (OOMKilled)",
+		"Job Timed Out":          "ジョブタイムアウト",
+		"Job Stuck":              "ジョブ停滞",
+		"Job Pods Lost":          "ジョブのPod喪失",
+		"Job Suspended":          "ジョブ一時停止",
+		"Job Resumed":            "ジョブ再開",
+		"Job Spec Changed":       "ジョブ仕様変更",
+		"Job Recovered":          "ジョブ復旧",
+		"Open runbook":           "Runbookを開く",
+		"Logs":                   "ログ",
+
+		"Cluster":        "クラスター",
+		"CronJobName":    "CronJob名",
+		"JobName":        "Job名",
+		"StartTime":      "開始時刻",
+		"CompletionTime": "完了時刻",
+		"ExecutionTime":  "実行時間",
+		"QueueTime":      "待ち時間",
+		"FailingFor":     "失敗期間",
+		"Node":           "ノード",
+		"Indexes":        "インデックス",
+		"FailedIndices":  "失敗したインデックス",
+		"Warning":        "警告",
+		"Status":         "ステータス",
+		"LostPods":       "失われたPod",
+		"Events":         "イベント",
+		"Reason":         "理由",
+		"ExitCode":       "終了コード",
+		"Changes":        "変更",
+		"Batch":          "バッチ",
+		"Investigate":    "調査",
+		"memory limit":   "メモリ上限",
+	},
+}
+
+// slackMessageTemplateSources are the bundled default Slack message templates by locale.
+var slackMessageTemplateSources = map[string]string{
+	localeEnglish:  SlackMessageTemplate,
+	localeJapanese: SlackMessageTemplateJa,
+}
+
+// slackDetailsTemplateSources are the bundled templates of the text below the attachment
+// fields with SLACK_MESSAGE_FORMAT=fields.
+var slackDetailsTemplateSources = map[string]string{
+	localeEnglish:  slackMessageDetailsTemplate,
+	localeJapanese: slackMessageDetailsTemplateJa,
+}
+
+// slackFailureTemplateSources are the bundled templates of failures with
+// SLACK_FAILURE_DETAILS_FIRST=true, with the reason, the exit code and the logs first.
+var slackFailureTemplateSources = map[string]string{
+	localeEnglish:  SlackFailureMessageTemplate,
+	localeJapanese: SlackFailureMessageTemplateJa,
+}
+
+// slackFailureDetailsTemplateSources are slackFailureTemplateSources without the lines
+// shown as attachment fields.
+var slackFailureDetailsTemplateSources = map[string]string{
+	localeEnglish:  slackFailureLeadTemplate + slackMessageDiagnosticsTemplate + slackMessageLinksTemplate,
+	localeJapanese: slackFailureLeadTemplateJa + slackMessageDiagnosticsTemplateJa + slackMessageLinksTemplateJa,
+}
+
+// parseLocale validates LOCALE. An empty or unknown locale is English.
+func parseLocale(value string) (string, error) {
+	if value == "" {
+		return localeEnglish, nil
+	}
+	if _, ok := slackMessageTemplateSources[value]; !ok {
+		return localeEnglish, fmt.Errorf("unknown LOCALE %q, using %s", value, localeEnglish)
+	}
+	return value, nil
+}
+
+// translate returns the English text s in the locale.
+func translate(locale string, s string) string {
+	if t, ok := translations[locale][s]; ok {
+		return t
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"encoding/json"
+	"testing"
+
+	slackapi "github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseLocale(t *testing.T) {
+	locale, err := parseLocale("")
+	assert.NoError(t, err)
+	assert.Equal(t, localeEnglish, locale)
+
+	locale, err = parseLocale("ja")
+	assert.NoError(t, err)
+	assert.Equal(t, localeJapanese, locale)
+
+	locale, err = parseLocale("fr")
+	assert.Error(t, err)
+	assert.Equal(t, localeEnglish, locale)
+}
+
+func TestSlackMessageLocale(t *testing.T) {
+	param := MessageTemplateParam{JobName: "the-job", CronJobName: "the-cronjob", Namespace: "namespace"}
+
+	message, err := slackConfig{locale: localeJapanese}.getMessage(param)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "*CronJob名*: the-cronjob")
+	assert.Contains(t, message, "*Job名*: the-job")
+
+	message, err = slackConfig{}.getMessage(param)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "*JobName*: the-job")
+}
+
+func TestNotifyFailedLocale(t *testing.T) {
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, config: slackConfig{locale: localeJapanese}, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: 1, BackoffLimit: 3})
+	assert.NoError(t, err)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	var attachments []slackapi.Attachment
+	assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+	assert.Equal(t, "ジョブ失敗 (リトライ中)", attachments[0].Title)
+	assert.Equal(t, "ジョブ失敗 (リトライ中): the-job", attachments[0].Fallback)
+}
+
+func TestLarkLocale(t *testing.T) {
+	var received []larkCard
+	server := newLarkServer(t, &received, `{"code":0,"msg":"success"}`)
+	defer server.Close()
+
+	_, err := newLark(server.URL, "", localeJapanese).NotifySuccess(MessageTemplateParam{JobName: "the-job", Namespace: "namespace"})
+
+	assert.NoError(t, err)
+	assert.Len(t, received, 1)
+	assert.Equal(t, "ジョブ成功", received[0].Card.Header.Title.Content)
+	assert.Equal(t, "**Job名**: the-job\n**Namespace**: namespace", received[0].Card.Elements[0].Text.Content)
+}
@@ -0,0 +1,63 @@
+package notification
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/Songmu/flextime"
+)
+
+// logUploads remembers the last log uploaded for each job, so that a flapping job posting
+// the same log again within window links the previous file instead of uploading it again.
+type logUploads struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	last map[string]logUpload
+}
+
+type logUpload struct {
+	hash       [sha256.Size]byte
+	permalink  string
+	uploadedAt time.Time
+}
+
+// newLogUploads returns nil when window is 0 and every log is uploaded.
+func newLogUploads(window time.Duration) *logUploads {
+	if window <= 0 {
+		return nil
+	}
+	return &logUploads{window: window, last: make(map[string]logUpload)}
+}
+
+// permalink returns the permalink of the log uploaded under key within the window when it
+// has the same content.
+func (u *logUploads) permalink(key string, content string) (string, bool) {
+	if u == nil {
+		return "", false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := flextime.Now()
+	for k, upload := range u.last {
+		if now.Sub(upload.uploadedAt) >= u.window {
+			delete(u.last, k)
+		}
+	}
+	upload, ok := u.last[key]
+	if !ok || upload.hash != sha256.Sum256([]byte(content)) {
+		return "", false
+	}
+	return upload.permalink, true
+}
+
+// record keeps the permalink of a log uploaded under key.
+func (u *logUploads) record(key string, content string, permalink string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.last[key] = logUpload{hash: sha256.Sum256([]byte(content)), permalink: permalink, uploadedAt: flextime.Now()}
+}
@@ -0,0 +1,25 @@
+package notification
+
+import "regexp"
+
+// markdownReplacements convert common Markdown to Slack mrkdwn, in order.
+// A single *text* is left alone, it is already bold in mrkdwn and the default
+// template relies on it. _text_ is italic in both.
+var markdownReplacements = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`), "<$2|$1>"},
+	{regexp.MustCompile(`\*\*\*([^*\n]+)\*\*\*`), "*_${1}_*"},
+	{regexp.MustCompile(`\*\*([^*\n]+)\*\*`), "*$1*"},
+	{regexp.MustCompile(`__([^_\n]+)__`), "*$1*"},
+	{regexp.MustCompile(`~~([^~\n]+)~~`), "~$1~"},
+}
+
+// convertMarkdown converts bold, italic, strikethrough and links from Markdown to mrkdwn.
+func convertMarkdown(s string) string {
+	for _, r := range markdownReplacements {
+		s = r.pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
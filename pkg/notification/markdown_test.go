@@ -0,0 +1,31 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertMarkdown(t *testing.T) {
+	tests := []struct {
+		Name     string
+		input    string
+		expected string
+	}{
+		{"Bold", "**JobName**: the-job", "*JobName*: the-job"},
+		{"Underscore bold", "__important__", "*important*"},
+		{"Italic", "_retry later_", "_retry later_"},
+		{"Bold italic", "***very important***", "*_very important_*"},
+		{"Strikethrough", "~~obsolete~~", "~obsolete~"},
+		{"Link", "see [the runbook](https://runbooks.example.com/the-job?a=1&amp;b=2)", "see <https://runbooks.example.com/the-job?a=1&amp;b=2|the runbook>"},
+		{"Bold link", "**[dashboard](https://example.com)**", "*<https://example.com|dashboard>*"},
+		{"mrkdwn is kept", "*JobName*: the-job\n <https://example.com|link>", "*JobName*: the-job\n <https://example.com|link>"},
+		{"Escaped names are kept", escapeMrkdwn("**name**"), escapeMrkdwn("**name**")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.expected, convertMarkdown(test.input))
+		})
+	}
+}
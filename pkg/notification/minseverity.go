@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"os"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// The severities of the events, from lowest to highest: starts, successes, suspensions and
+// resumptions are info, retries, stuck pods and lost pods are warnings and jobs that
+// exhausted their retries are failures.
+const (
+	eventSeverityInfo = iota
+	eventSeverityWarning
+	eventSeverityFailure
+)
+
+var eventSeverityNames = []string{"info", "warning", "failure"}
+
+// SkippedBelowMinSeverity is reported for the events below the minimum severity of a notifier.
+const SkippedBelowMinSeverity = "below_min_severity"
+
+// getMinSeverity returns the minimum severity of the events sent to the notifier, set by
+// <NAME>_MIN_SEVERITY, e.g. PAGERDUTY_MIN_SEVERITY=failure. Every event is sent by default.
+func getMinSeverity(name string) int {
+	key := strings.ToUpper(name) + "_MIN_SEVERITY"
+	value := os.Getenv(key)
+	if value == "" {
+		return eventSeverityInfo
+	}
+	for severity, severityName := range eventSeverityNames {
+		if strings.EqualFold(strings.TrimSpace(value), severityName) {
+			return severity
+		}
+	}
+	klog.Errorf("Invalid %s %q, must be info, warning or failure, sending every event", key, value)
+	return eventSeverityInfo
+}
+
+// withMinSeverity drops the events of the notifier below its minimum severity.
+func withMinSeverity(name string, n Notification) Notification {
+	minSeverity := getMinSeverity(name)
+	if minSeverity == eventSeverityInfo {
+		return n
+	}
+	klog.Infof("Notifier %s only sends %s events and above", name, eventSeverityNames[minSeverity])
+	return minSeverityNotification{Notification: n, minSeverity: minSeverity}
+}
+
+type minSeverityNotification struct {
+	Notification
+	minSeverity int
+}
+
+func (m minSeverityNotification) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if m.minSeverity > eventSeverityInfo {
+		return NotifyResult{SkippedReason: SkippedBelowMinSeverity}, nil
+	}
+	return m.Notification.NotifyStart(messageParam)
+}
+
+func (m minSeverityNotification) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if m.minSeverity > eventSeverityInfo {
+		return NotifyResult{SkippedReason: SkippedBelowMinSeverity}, nil
+	}
+	return m.Notification.NotifySuccess(messageParam)
+}
+
+func (m minSeverityNotification) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	severity := eventSeverityFailure
+	if messageParam.IsWarning() {
+		severity = eventSeverityWarning
+	}
+	if m.minSeverity > severity {
+		return NotifyResult{SkippedReason: SkippedBelowMinSeverity}, nil
+	}
+	return m.Notification.NotifyFailed(messageParam)
+}
+
+func (m minSeverityNotification) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if m.minSeverity > eventSeverityInfo {
+		return NotifyResult{SkippedReason: SkippedBelowMinSeverity}, nil
+	}
+	return m.Notification.NotifySuspended(messageParam)
+}
+
+func (m minSeverityNotification) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if m.minSeverity > eventSeverityInfo {
+		return NotifyResult{SkippedReason: SkippedBelowMinSeverity}, nil
+	}
+	return m.Notification.NotifyResumed(messageParam)
+}
+
+func (m minSeverityNotification) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if m.minSeverity > eventSeverityInfo {
+		return NotifyResult{SkippedReason: SkippedBelowMinSeverity}, nil
+	}
+	return m.Notification.NotifySpecChanged(messageParam)
+}
@@ -0,0 +1,95 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotification records the events it was asked to send.
+type recordingNotification struct {
+	events []string
+}
+
+func (r *recordingNotification) record(event string) (NotifyResult, error) {
+	r.events = append(r.events, event)
+	return NotifyResult{}, nil
+}
+
+func (r *recordingNotification) NotifyStart(messageParam MessageTemplateParam) (NotifyResult, error) {
+	return r.record(START)
+}
+
+func (r *recordingNotification) NotifySuccess(messageParam MessageTemplateParam) (NotifyResult, error) {
+	return r.record(SUCCESS)
+}
+
+func (r *recordingNotification) NotifyFailed(messageParam MessageTemplateParam) (NotifyResult, error) {
+	if messageParam.IsWarning() {
+		return r.record("warning")
+	}
+	return r.record(FAILED)
+}
+
+func (r *recordingNotification) NotifySuspended(messageParam MessageTemplateParam) (NotifyResult, error) {
+	return r.record(SUSPENDED)
+}
+
+func (r *recordingNotification) NotifyResumed(messageParam MessageTemplateParam) (NotifyResult, error) {
+	return r.record(RESUMED)
+}
+
+func (r *recordingNotification) NotifySpecChanged(messageParam MessageTemplateParam) (NotifyResult, error) {
+	return r.record(SPEC_CHANGED)
+}
+
+func TestGetMinSeverity(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"", eventSeverityInfo},
+		{"info", eventSeverityInfo},
+		{"warning", eventSeverityWarning},
+		{"Failure", eventSeverityFailure},
+		{"critical", eventSeverityInfo},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv("SLACK_WORKFLOW_MIN_SEVERITY", test.value)
+			assert.Equal(t, test.expected, getMinSeverity("slack_workflow"))
+		})
+	}
+}
+
+func TestWithMinSeverity(t *testing.T) {
+	t.Setenv("PAGERDUTY_MIN_SEVERITY", "failure")
+	t.Setenv("LARK_MIN_SEVERITY", "warning")
+
+	slack, pagerDuty, lark := &recordingNotification{}, &recordingNotification{}, &recordingNotification{}
+	notifications := map[string]Notification{
+		"slack":     withMinSeverity("slack", slack),
+		"pagerduty": withMinSeverity("pagerduty", pagerDuty),
+		"lark":      withMinSeverity("lark", lark),
+	}
+	assert.Same(t, slack, notifications["slack"])
+
+	for _, n := range notifications {
+		n.NotifyStart(MessageTemplateParam{})
+		n.NotifySuspended(MessageTemplateParam{})
+		n.NotifyResumed(MessageTemplateParam{})
+		n.NotifySpecChanged(MessageTemplateParam{})
+		// A failed attempt that is retried.
+		n.NotifyFailed(MessageTemplateParam{FailedCount: 1, BackoffLimit: 3})
+		n.NotifyFailed(MessageTemplateParam{FailedCount: 4, BackoffLimit: 3})
+		n.NotifySuccess(MessageTemplateParam{})
+	}
+
+	assert.Equal(t, []string{START, SUSPENDED, RESUMED, SPEC_CHANGED, "warning", FAILED, SUCCESS}, slack.events)
+	assert.Equal(t, []string{FAILED}, pagerDuty.events)
+	assert.Equal(t, []string{"warning", FAILED}, lark.events)
+
+	result, err := notifications["pagerduty"].NotifySuccess(MessageTemplateParam{})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedBelowMinSeverity, result.SkippedReason)
+}
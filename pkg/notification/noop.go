@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"k8s.io/klog"
+)
+
+// noneNotifier in ENABLED_NOTIFIERS replaces every notifier with NoopSlack.
+const noneNotifier = "none"
+
+// NoopSlack is a notifier that sends nothing. It runs the controller with notifications
+// disabled and is the reference implementation of Notification.
+type NoopSlack struct{}
+
+func (NoopSlack) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return noopNotify(START, messageParam)
+}
+
+func (NoopSlack) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return noopNotify(SUCCESS, messageParam)
+}
+
+func (NoopSlack) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return noopNotify(FAILED, messageParam)
+}
+
+func (NoopSlack) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return noopNotify(SUSPENDED, messageParam)
+}
+
+func (NoopSlack) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return noopNotify(RESUMED, messageParam)
+}
+
+func (NoopSlack) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return noopNotify(SPEC_CHANGED, messageParam)
+}
+
+func noopNotify(event string, messageParam MessageTemplateParam) (NotifyResult, error) {
+	klog.V(4).Infof("Noop notification: job=%s namespace=%s event=%s", messageParam.JobName, messageParam.Namespace, event)
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
@@ -1,12 +1,27 @@
 package notification
 
 import (
+	"bytes"
+	"os"
+	"text/template"
+	"time"
+
 	"github.com/Songmu/flextime"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"time"
+	"k8s.io/klog"
 )
 
+// PodLog is the log of a single pod of the job.
+type PodLog struct {
+	PodName string
+	Log     string
+}
+
 type MessageTemplateParam struct {
+	// Event is the notified event, e.g. START or FAILED, so that one template can render every event.
+	Event string
+	// ClusterName is the CLUSTER_NAME of the controller, empty when unset.
+	ClusterName    string
 	JobName        string
 	CronJobName    string
 	Namespace      string
@@ -14,7 +29,66 @@ type MessageTemplateParam struct {
 	CompletionTime *metav1.Time
 	ExecutionTime  time.Duration
 	Log            string
-	Annotations    map[string]string
+	// LogURL links to the logs of the job in an external logging system, empty when unset.
+	LogURL string
+	// JobDetailURL links to the job in another tool, e.g. Headlamp, empty when unset.
+	JobDetailURL string
+	Annotations  map[string]string
+	FailedCount  int32
+	BackoffLimit int32
+	RunbookURL   string
+	PodLogs      []PodLog
+	JobYAML      string
+	JobYAMLLink  string
+	// Recovered is set on a success following a failed run, FailingFor is the time since
+	// the first of the failed runs.
+	Recovered  bool
+	FailingFor time.Duration
+	// TimedOut is set when the job was terminated by its activeDeadlineSeconds.
+	TimedOut              bool
+	ActiveDeadlineSeconds int64
+	// OOMKilledContainer is the name of a failed container terminated with OOMKilled.
+	OOMKilledContainer string
+	// MemoryLimit is the memory limit of the OOMKilled container, empty when unset.
+	MemoryLimit string
+	// ResourceWarnings notes containers whose memory usage came close to their limits.
+	ResourceWarnings []string
+	// NodeName and Zone are where the failed pod was scheduled, empty when unknown.
+	NodeName string
+	Zone     string
+	// Reason is why the job failed, e.g. BackoffLimitExceeded or OOMKilled, empty when unknown.
+	Reason string
+	// ExitCode is the exit code of the failed container, 0 when unknown.
+	ExitCode int32
+	// IndexSummary summarizes the per-index results of an Indexed job, empty for other jobs.
+	IndexSummary string
+	// FailedIndices lists the failed indexes of an Indexed job as ranges, e.g. "1,4-6".
+	// It is only set on failures.
+	FailedIndices string
+	// CompletionWarning flags a success with fewer succeeded pods than completions.
+	CompletionWarning string
+	// WaitingContainer, WaitingReason and WaitingMessage describe a container stuck before it
+	// could start, e.g. in ImagePullBackOff. They are only set on such warnings.
+	WaitingContainer string
+	WaitingReason    string
+	WaitingMessage   string
+	// LostPods is the number of running pods the job lost before completion, e.g. to
+	// evictions, since it ran PeakActivePods at once. They are only set on such warnings.
+	LostPods       int32
+	PeakActivePods int32
+	// QueueTime is the time from the job creation to the start of its first pod, the
+	// scheduling latency. It is only set on start notifications.
+	QueueTime time.Duration
+	// Events are the most recent Kubernetes events of a failed job and its pods.
+	Events []string
+	// KubectlCommands are commands to start investigating a failed job with, e.g. kubectl logs.
+	KubectlCommands []string
+	// BatchSummary summarizes the results of the jobs a CronJob created together, e.g.
+	// "4 jobs: 3 succeeded, 1 failed (nightly-28472940-b)". It is only set on batch notifications.
+	BatchSummary string
+	// SpecChanges describes the changes to the spec of a running job, e.g.
+	// "parallelism: 1 → 3". It is only set on spec change notifications.
+	SpecChanges []string
 }
 
 func (m MessageTemplateParam) calculateExecutionTime() (completionTime *metav1.Time, executionTime time.Duration) {
@@ -28,15 +102,106 @@ func (m MessageTemplateParam) calculateExecutionTime() (completionTime *metav1.T
 	return completionTime, executionTime.Truncate(time.Second)
 }
 
+// kubectlCommands returns the commands showing the logs and the status of the job.
+func (m MessageTemplateParam) kubectlCommands() []string {
+	return []string{
+		"kubectl logs -n " + m.Namespace + " job/" + m.JobName,
+		"kubectl describe job -n " + m.Namespace + " " + m.JobName,
+	}
+}
+
+// retriesExhausted reports whether the job has failed more times than its backoff limit allows.
+// An unknown failed count is treated as exhausted.
+func (m MessageTemplateParam) retriesExhausted() bool {
+	return m.FailedCount == 0 || m.FailedCount > m.BackoffLimit
+}
+
+// pages reports whether a failure should reach the on-call: exhausted retries by default,
+// never for info jobs and every failure of critical jobs. Stuck pod and lost pod warnings
+// only page for critical jobs.
+func (m MessageTemplateParam) pages() bool {
+	switch getSeverity(m.Annotations) {
+	case severityInfo:
+		return false
+	case severityCritical:
+		return true
+	}
+	return !m.IsWarning()
+}
+
+// IsWarning reports whether a failure notification only warns about a job that may still
+// succeed: a failed attempt that is retried, a stuck pod or lost pods.
+func (m MessageTemplateParam) IsWarning() bool {
+	return !m.retriesExhausted() || m.WaitingReason != "" || m.LostPods > 0
+}
+
+// getSeverity returns the severity declared by the severityAnnotationName annotation,
+// or an empty string when it is unset or invalid.
+func getSeverity(annotations map[string]string) string {
+	severity, ok := annotations[severityAnnotationName]
+	if !ok {
+		return ""
+	}
+	switch severity {
+	case severityCritical, severityWarning, severityInfo:
+		return severity
+	}
+	klog.Errorf("Invalid %s annotation %q, must be critical, warning or info", severityAnnotationName, severity)
+	return ""
+}
+
+const (
+	severityAnnotationName = "kube-job-notifier/severity"
+	severityCritical       = "critical"
+	severityWarning        = "warning"
+	severityInfo           = "info"
+)
+
+const (
+	SkippedDisabled   = "disabled"
+	SkippedSuppressed = "suppressed"
+	// SkippedCircuitOpen is reported while a notifier that keeps failing is paused.
+	SkippedCircuitOpen = "circuit_open"
+)
+
+// NotifyResult describes where a notification was delivered. Backends fill in what they know.
+type NotifyResult struct {
+	Channel   string
+	Timestamp string
+	Permalink string
+	// SkippedReason is set when nothing was sent, e.g. SkippedDisabled or SkippedSuppressed.
+	SkippedReason string
+}
+
 type Notification interface {
-	NotifyStart(messageParam MessageTemplateParam) (err error)
-	NotifySuccess(messageParam MessageTemplateParam) (err error)
-	NotifyFailed(messageParam MessageTemplateParam) (err error)
+	NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error)
+	NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error)
+	NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error)
+	NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error)
+	NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error)
+	NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error)
 }
 
-func NewNotifications() map[string]Notification {
-	res := make(map[string]Notification)
-	// default notification
-	res["slack"] = newSlack()
-	return res
+// parseTextTemplate parses the plain text template in the environment variable key,
+// falling back to defaultText when it is unset or invalid.
+func parseTextTemplate(key string, defaultText string) *template.Template {
+	// The functions available in the plain text templates.
+	textTemplateFuncs := template.FuncMap{"formatTime": getTimeFormat().format}
+	if value := os.Getenv(key); value != "" {
+		tpl, err := template.New(key).Funcs(textTemplateFuncs).Parse(value)
+		if err == nil {
+			return tpl
+		}
+		klog.Errorf("Invalid %s %q, using the default: %v", key, value, err)
+	}
+	return template.Must(template.New(key).Funcs(textTemplateFuncs).Parse(defaultText))
+}
+
+// renderTextTemplate renders a plain text template, e.g. an alert summary, with the message parameters.
+func renderTextTemplate(tpl *template.Template, messageParam MessageTemplateParam) (string, error) {
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, messageParam); err != nil {
+		return "", err
+	}
+	return b.String(), nil
 }
@@ -52,3 +52,22 @@ func TestSetExecutionTime(t *testing.T) {
 	assert.Equal(t, completionTime.Truncate(time.Second), actual.CompletionTime.Truncate(time.Second))
 	assert.NotEmpty(t, actual.ExecutionTime)
 }
+
+func TestGetSeverity(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"critical", "critical"},
+		{"warning", "warning"},
+		{"info", "info"},
+		{"page-everyone", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			assert.Equal(t, test.expected, getSeverity(map[string]string{severityAnnotationName: test.value}))
+		})
+	}
+	assert.Equal(t, "", getSeverity(nil))
+}
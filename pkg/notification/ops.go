@@ -0,0 +1,38 @@
+package notification
+
+import (
+	slackapi "github.com/slack-go/slack"
+)
+
+// OpsNotifier posts messages about the controller itself rather than about jobs.
+type OpsNotifier interface {
+	NotifyOps(message string) (err error)
+}
+
+type slackOps struct {
+	client   slackClient
+	channel  string
+	username string
+}
+
+// NewOpsNotifier returns a notifier posting to SLACK_OPS_CHANNEL, or nil when it is not set.
+func NewOpsNotifier() OpsNotifier {
+	// Invalid settings are reported by the Slack backend, none of them is used here.
+	config, _ := loadSlackConfig()
+	if config.opsChannel == "" {
+		return nil
+	}
+	return slackOps{
+		client:   newSlackAPI(config.token),
+		channel:  config.opsChannel,
+		username: config.username,
+	}
+}
+
+func (s slackOps) NotifyOps(message string) (err error) {
+	_, _, err = s.client.PostMessage(s.channel,
+		slackapi.MsgOptionText(message, false),
+		slackapi.MsgOptionUsername(s.username),
+	)
+	return err
+}
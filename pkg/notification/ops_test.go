@@ -0,0 +1,28 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewOpsNotifier(t *testing.T) {
+	t.Setenv("SLACK_OPS_CHANNEL", "")
+	assert.Nil(t, NewOpsNotifier())
+
+	t.Setenv("SLACK_OPS_CHANNEL", "ops")
+	assert.Equal(t, "ops", NewOpsNotifier().(slackOps).channel)
+}
+
+func TestSlackOpsNotifyOps(t *testing.T) {
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "ops", mock.Anything).Return("ops", "1234.5678", nil).Once()
+	mc.On("PostMessage", "ops", mock.Anything).Return("", "", errors.New("channel_not_found")).Once()
+	s := slackOps{client: mc, channel: "ops"}
+
+	assert.NoError(t, s.NotifyOps("log fetch failed"))
+	assert.EqualError(t, s.NotifyOps("log fetch failed"), "channel_not_found")
+	mc.AssertExpectations(t)
+}
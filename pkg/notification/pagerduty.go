@@ -0,0 +1,190 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	// defaultPagerDutySummaryTemplate names the CronJob, or the job when it has none.
+	defaultPagerDutySummaryTemplate  = `{{if .CronJobName}}{{.CronJobName}}{{else}}{{.JobName}}{{end}} failed in {{.Namespace}}`
+	defaultPagerDutySeverityTemplate = `error`
+)
+
+var pagerDutySeverities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// pagerDutyEvent is an event of the PagerDuty Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// pagerDuty triggers an incident when a job failed and resolves it once the job succeeds.
+type pagerDuty struct {
+	url        string
+	routingKey string
+	summary    *template.Template
+	severity   *template.Template
+	client     *http.Client
+}
+
+func init() {
+	Register("pagerduty", func() (Notification, bool) {
+		routingKey := os.Getenv("PAGERDUTY_ROUTING_KEY")
+		return newPagerDuty(defaultPagerDutyEventsURL, routingKey), routingKey != ""
+	})
+}
+
+func newPagerDuty(url string, routingKey string) pagerDuty {
+	return pagerDuty{
+		url:        url,
+		routingKey: routingKey,
+		summary:    parseTextTemplate("PAGERDUTY_SUMMARY_TEMPLATE", defaultPagerDutySummaryTemplate),
+		severity:   parseTextTemplate("PAGERDUTY_SEVERITY_TEMPLATE", defaultPagerDutySeverityTemplate),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p pagerDuty) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (p pagerDuty) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{}, p.send(pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "resolve",
+		DedupKey:    getPagerDutyDedupKey(messageParam),
+	}, messageParam)
+}
+
+func (p pagerDuty) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	// Retry warnings and info jobs don't page anyone.
+	if !messageParam.pages() {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	messageParam.RunbookURL = messageParam.Annotations[runbookURLAnnotationName]
+
+	payload, err := p.getPayload(messageParam)
+	if err != nil {
+		klog.Errorf("Template execute failed %s\n", err)
+		return NotifyResult{}, err
+	}
+	return NotifyResult{}, p.send(pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    getPagerDutyDedupKey(messageParam),
+		Payload:     payload,
+	}, messageParam)
+}
+
+func (p pagerDuty) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (p pagerDuty) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (p pagerDuty) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+// getPagerDutyDedupKey groups the runs of a CronJob into one incident.
+func getPagerDutyDedupKey(messageParam MessageTemplateParam) string {
+	name := messageParam.CronJobName
+	if name == "" {
+		name = messageParam.JobName
+	}
+	return "kube-job-notifier/" + messageParam.Namespace + "/" + name
+}
+
+func (p pagerDuty) getPayload(messageParam MessageTemplateParam) (*pagerDutyPayload, error) {
+	summary, err := renderTextTemplate(p.summary, messageParam)
+	if err != nil {
+		return nil, err
+	}
+	// The severity annotation of the job takes precedence over the template.
+	severity := getSeverity(messageParam.Annotations)
+	if severity == "" {
+		severity, err = renderTextTemplate(p.severity, messageParam)
+		if err != nil {
+			return nil, err
+		}
+	}
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	if !pagerDutySeverities[severity] {
+		klog.Errorf("Invalid PagerDuty severity %q for %s, using error", severity, messageParam.JobName)
+		severity = "error"
+	}
+
+	details := map[string]string{
+		"job_name":     messageParam.JobName,
+		"namespace":    messageParam.Namespace,
+		"failed_count": fmt.Sprint(messageParam.FailedCount),
+	}
+	if messageParam.CronJobName != "" {
+		details["cronjob_name"] = messageParam.CronJobName
+	}
+	if messageParam.ExitCode != 0 {
+		details["exit_code"] = fmt.Sprint(messageParam.ExitCode)
+	}
+	if messageParam.NodeName != "" {
+		details["node_name"] = messageParam.NodeName
+	}
+	if messageParam.FailedIndices != "" {
+		details["failed_indices"] = messageParam.FailedIndices
+	}
+	if messageParam.RunbookURL != "" {
+		details["runbook_url"] = messageParam.RunbookURL
+	}
+	return &pagerDutyPayload{
+		Summary:       summary,
+		Source:        messageParam.Namespace,
+		Severity:      severity,
+		CustomDetails: details,
+	}, nil
+}
+
+func (p pagerDuty) send(event pagerDutyEvent, messageParam MessageTemplateParam) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post(p.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("Send PagerDuty event failed %s\n", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		err = fmt.Errorf("pagerduty returned %s", resp.Status)
+		klog.Errorf("Send PagerDuty event failed %s\n", err)
+		return err
+	}
+	klog.Infof("PagerDuty %s event successfully sent for %s", event.EventAction, messageParam.JobName)
+	return nil
+}
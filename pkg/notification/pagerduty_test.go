@@ -0,0 +1,165 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPagerDutyServer(t *testing.T, received *[]pagerDutyEvent, statusCode int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		*received = append(*received, event)
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(`{"status":"success","message":"Event processed"}`))
+	}))
+}
+
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	var received []pagerDutyEvent
+	server := newPagerDutyServer(t, &received, http.StatusAccepted)
+	defer server.Close()
+
+	p := newPagerDuty(server.URL, "routing-key")
+	param := MessageTemplateParam{JobName: "payments-etl-123", CronJobName: "payments-etl", Namespace: "prod", ExitCode: 137, FailedCount: 1}
+	_, err := p.NotifyFailed(param)
+	assert.NoError(t, err)
+	_, err = p.NotifySuccess(param)
+	assert.NoError(t, err)
+
+	assert.Len(t, received, 2)
+	assert.Equal(t, "trigger", received[0].EventAction)
+	assert.Equal(t, "routing-key", received[0].RoutingKey)
+	assert.Equal(t, "kube-job-notifier/prod/payments-etl", received[0].DedupKey)
+	assert.Equal(t, "payments-etl failed in prod", received[0].Payload.Summary)
+	assert.Equal(t, "error", received[0].Payload.Severity)
+	assert.Equal(t, "137", received[0].Payload.CustomDetails["exit_code"])
+	assert.Equal(t, "resolve", received[1].EventAction)
+	assert.Equal(t, received[0].DedupKey, received[1].DedupKey)
+	assert.Nil(t, received[1].Payload)
+}
+
+func TestPagerDutyTemplates(t *testing.T) {
+	tests := []struct {
+		Name             string
+		summaryTemplate  string
+		severityTemplate string
+		expectedSummary  string
+		expectedSeverity string
+	}{
+		{
+			"Defaults", "", "",
+			"payments-etl failed in prod", "error",
+		},
+		{
+			"Templated",
+			`[{{.Namespace}}] {{.CronJobName}} failed{{if .ExitCode}} (exit {{.ExitCode}}){{end}}`,
+			`{{if eq .Namespace "prod"}}critical{{else}}warning{{end}}`,
+			"[prod] payments-etl failed (exit 137)", "critical",
+		},
+		{
+			"Invalid templates fall back to the defaults",
+			`{{.CronJobName`, `{{.Severity}`,
+			"payments-etl failed in prod", "error",
+		},
+		{
+			"Unknown severity",
+			"", "page-everyone",
+			"payments-etl failed in prod", "error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Setenv("PAGERDUTY_SUMMARY_TEMPLATE", test.summaryTemplate)
+			t.Setenv("PAGERDUTY_SEVERITY_TEMPLATE", test.severityTemplate)
+
+			p := newPagerDuty("", "routing-key")
+			payload, err := p.getPayload(MessageTemplateParam{JobName: "payments-etl-123", CronJobName: "payments-etl", Namespace: "prod", ExitCode: 137})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedSummary, payload.Summary)
+			assert.Equal(t, test.expectedSeverity, payload.Severity)
+		})
+	}
+}
+
+func TestPagerDutySkipped(t *testing.T) {
+	var received []pagerDutyEvent
+	server := newPagerDutyServer(t, &received, http.StatusAccepted)
+	defer server.Close()
+
+	p := newPagerDuty(server.URL, "routing-key")
+	retrying, err := p.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: 1, BackoffLimit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, retrying.SkippedReason)
+	suppressed, err := p.NotifyFailed(MessageTemplateParam{JobName: "the-job", Annotations: map[string]string{suppressFailedAnnotationName: "true"}})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, suppressed.SkippedReason)
+	started, err := p.NotifyStart(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, started.SkippedReason)
+
+	assert.Empty(t, received)
+}
+
+func TestPagerDutyError(t *testing.T) {
+	var received []pagerDutyEvent
+	server := newPagerDutyServer(t, &received, http.StatusBadRequest)
+	defer server.Close()
+
+	_, err := newPagerDuty(server.URL, "wrong").NotifyFailed(MessageTemplateParam{JobName: "the-job"})
+
+	assert.EqualError(t, err, "pagerduty returned 400 Bad Request")
+}
+
+func TestPagerDutySeverityAnnotation(t *testing.T) {
+	var received []pagerDutyEvent
+	server := newPagerDutyServer(t, &received, http.StatusAccepted)
+	defer server.Close()
+
+	p := newPagerDuty(server.URL, "routing-key")
+	info, err := p.NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job",
+		FailedCount: 3,
+		Annotations: map[string]string{severityAnnotationName: "info"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, info.SkippedReason)
+	assert.Empty(t, received)
+
+	// Critical jobs page on their first failed attempt.
+	_, err = p.NotifyFailed(MessageTemplateParam{
+		JobName:      "the-job",
+		FailedCount:  1,
+		BackoffLimit: 3,
+		Annotations:  map[string]string{severityAnnotationName: "critical"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, received, 1)
+	assert.Equal(t, "critical", received[0].Payload.Severity)
+
+	t.Setenv("PAGERDUTY_SEVERITY_TEMPLATE", "critical")
+	payload, err := newPagerDuty("", "routing-key").getPayload(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{severityAnnotationName: "warning"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "warning", payload.Severity)
+}
+
+func TestPagerDutySkipsStuckPods(t *testing.T) {
+	var received []pagerDutyEvent
+	server := newPagerDutyServer(t, &received, http.StatusAccepted)
+	defer server.Close()
+
+	result, err := newPagerDuty(server.URL, "routing-key").NotifyFailed(MessageTemplateParam{JobName: "the-job", WaitingReason: "ImagePullBackOff"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, result.SkippedReason)
+	assert.Empty(t, received)
+}
@@ -0,0 +1,83 @@
+package notification
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// Factory builds a notification backend. configured is false when the backend is missing
+// its settings, e.g. a webhook URL, and should not be used.
+type Factory func() (n Notification, configured bool)
+
+var factories = make(map[string]Factory)
+
+// Register makes a notification backend available under name. Backends register
+// themselves from init, registering the same name twice panics.
+func Register(name string, factory Factory) {
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("notification: Register called twice for %s", name))
+	}
+	factories[name] = factory
+}
+
+// IsRegistered reports whether a notification backend is registered under name.
+func IsRegistered(name string) bool {
+	_, ok := factories[name]
+	return ok
+}
+
+// NewNotifications Support for returning multiple event notifications in one.
+// ENABLED_NOTIFIERS (e.g. "slack,lark") selects the backends, otherwise every
+// configured backend is used. ENABLED_NOTIFIERS=none disables them all.
+func NewNotifications() map[string]Notification {
+	return newNotifications(os.Getenv("ENABLED_NOTIFIERS"))
+}
+
+func newNotifications(enabled string) map[string]Notification {
+	var names []string
+	for _, name := range strings.Split(enabled, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if name != noneNotifier {
+			continue
+		}
+		if len(names) > 1 {
+			klog.Errorf("ENABLED_NOTIFIERS lists %q, ignoring the other notifiers", noneNotifier)
+		}
+		klog.Info("Every notifier is disabled")
+		return map[string]Notification{noneNotifier: NoopSlack{}}
+	}
+	explicit := len(names) > 0
+	if !explicit {
+		for name := range factories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	res := make(map[string]Notification)
+	for _, name := range names {
+		factory, ok := factories[name]
+		if !ok {
+			klog.Errorf("Unknown notifier %q in ENABLED_NOTIFIERS", name)
+			continue
+		}
+		n, configured := factory()
+		if !configured {
+			if explicit {
+				klog.Errorf("Notifier %s is enabled but not configured", name)
+			}
+			continue
+		}
+		klog.Infof("Notifier %s enabled", name)
+		res[name] = withMinSeverity(name, n)
+	}
+	return res
+}
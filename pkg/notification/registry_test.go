@@ -0,0 +1,85 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNotificationsEnabled(t *testing.T) {
+	t.Setenv("SLACK_WORKFLOW_URL", "https://hooks.slack.com/triggers/T0/1/abc")
+	t.Setenv("LARK_WEBHOOK_URL", "")
+
+	tests := []struct {
+		Name     string
+		enabled  string
+		expected []string
+	}{
+		{"Only listed notifiers", "slack_workflow", []string{"slack_workflow"}},
+		{"Unconfigured notifier is skipped", "slack_workflow, lark", []string{"slack_workflow"}},
+		{"Unknown notifier is skipped", "slack_workflow,pager", []string{"slack_workflow"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			res := newNotifications(test.enabled)
+
+			var names []string
+			for name := range res {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, test.expected, names)
+		})
+	}
+}
+
+func TestNewNotificationsDefault(t *testing.T) {
+	t.Setenv("SLACK_TOKEN", "xoxb-token")
+	t.Setenv("SLACK_WORKFLOW_URL", "")
+	t.Setenv("LARK_WEBHOOK_URL", "https://open.larksuite.com/open-apis/bot/v2/hook/abc")
+
+	res := newNotifications("")
+
+	assert.Len(t, res, 2)
+	assert.IsType(t, slack{}, res["slack"])
+	assert.IsType(t, lark{}, res["lark"])
+}
+
+func TestNewNotificationsNone(t *testing.T) {
+	t.Setenv("SLACK_WORKFLOW_URL", "https://hooks.slack.com/triggers/T0/1/abc")
+
+	for _, enabled := range []string{"none", "slack_workflow, none"} {
+		t.Run(enabled, func(t *testing.T) {
+			res := newNotifications(enabled)
+
+			assert.Equal(t, map[string]Notification{"none": NoopSlack{}}, res)
+		})
+	}
+}
+
+func TestNoopSlack(t *testing.T) {
+	var n Notification = NoopSlack{}
+	param := MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"}
+
+	for _, notify := range []func(MessageTemplateParam) (NotifyResult, error){
+		n.NotifyStart, n.NotifySuccess, n.NotifyFailed, n.NotifySuspended, n.NotifyResumed, n.NotifySpecChanged,
+	} {
+		result, err := notify(param)
+		assert.NoError(t, err)
+		assert.Equal(t, NotifyResult{SkippedReason: SkippedDisabled}, result)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer delete(factories, "recording")
+	Register("recording", func() (Notification, bool) {
+		return slackWorkflow{url: "recording"}, true
+	})
+
+	res := newNotifications("recording")
+
+	assert.Equal(t, slackWorkflow{url: "recording"}, res["recording"])
+	assert.Panics(t, func() {
+		Register("recording", func() (Notification, bool) { return nil, false })
+	})
+}
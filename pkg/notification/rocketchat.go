@@ -0,0 +1,194 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// rocketChatColors maps the Slack attachment colors, which Rocket.Chat doesn't know,
+// to the hex colors Slack renders them with.
+var rocketChatColors = map[string]string{
+	slackColors["Normal"]:  "#2eb886",
+	slackColors["Warning"]: "#daa038",
+	slackColors["Danger"]:  "#a30200",
+}
+
+// rocketChatMessage is the payload of a Rocket.Chat incoming webhook.
+type rocketChatMessage struct {
+	Text        string                 `json:"text"`
+	Attachments []rocketChatAttachment `json:"attachments"`
+}
+
+type rocketChatAttachment struct {
+	Title     string `json:"title,omitempty"`
+	Text      string `json:"text"`
+	Color     string `json:"color,omitempty"`
+	Collapsed bool   `json:"collapsed,omitempty"`
+}
+
+type rocketChatResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// rocketChat posts Slack-like attachments to a Rocket.Chat incoming webhook.
+type rocketChat struct {
+	url    string
+	client *http.Client
+	// slack renders the message like the Slack backend.
+	slack slackConfig
+}
+
+func init() {
+	Register("rocketchat", func() (Notification, bool) {
+		url := os.Getenv("ROCKETCHAT_WEBHOOK_URL")
+		return newRocketChat(url, getSlackConfig()), url != ""
+	})
+}
+
+func newRocketChat(url string, slack slackConfig) rocketChat {
+	// Rocket.Chat messages are always rendered as text, SLACK_MESSAGE_FORMAT only applies to Slack.
+	slack.messageFormat = messageFormatText
+	return rocketChat{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		slack:  slack,
+	}
+}
+
+func (r rocketChat) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressStartedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, r.notify(r.slack.translate("Job Start"), slackColors["Normal"], messageParam)
+}
+
+func (r rocketChat) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	title := "Job Success"
+	if messageParam.Recovered {
+		title = "Job Recovered"
+	}
+	return NotifyResult{}, r.notify(r.slack.translate(title), slackColors["Normal"], messageParam)
+}
+
+func (r rocketChat) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	messageParam.RunbookURL = messageParam.Annotations[runbookURLAnnotationName]
+	messageParam.KubectlCommands = messageParam.kubectlCommands()
+
+	title, color := r.slack.translate("Job Failed"), r.slack.getFailedColor(messageParam)
+	if !messageParam.retriesExhausted() {
+		title = r.slack.translate("Job Failed, Retrying")
+	}
+	if messageParam.TimedOut {
+		title, color = r.slack.translate("Job Timed Out"), slackColors["Warning"]
+	}
+	if messageParam.WaitingReason != "" {
+		title, color = r.slack.translate("Job Stuck")+" ("+messageParam.WaitingReason+")", slackColors["Warning"]
+	}
+	if messageParam.LostPods > 0 {
+		title, color = r.slack.translate("Job Pods Lost"), slackColors["Warning"]
+	}
+	return NotifyResult{}, r.notify(title, color, messageParam)
+}
+
+func (r rocketChat) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, r.notify(r.slack.translate("Job Suspended"), slackColors["Warning"], messageParam)
+}
+
+func (r rocketChat) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, r.notify(r.slack.translate("Job Resumed"), slackColors["Normal"], messageParam)
+}
+
+func (r rocketChat) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSpecChangedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, r.notify(r.slack.translate("Job Spec Changed"), slackColors["Normal"], messageParam)
+}
+
+func (r rocketChat) notify(title string, color string, messageParam MessageTemplateParam) (err error) {
+	message, err := getRocketChatMessage(r.slack, title, color, messageParam)
+	if err != nil {
+		klog.Errorf("Template execute failed %s\n", err)
+		return err
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Post(r.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("Send Rocket.Chat message failed %s\n", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	var res rocketChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil && resp.StatusCode == http.StatusOK {
+		klog.Errorf("Decode Rocket.Chat response failed %s\n", err)
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || !res.Success {
+		err = fmt.Errorf("rocket.chat returned %s: %s", resp.Status, res.Error)
+		klog.Errorf("Send Rocket.Chat message failed %s\n", err)
+		return err
+	}
+
+	klog.Infof("Rocket.Chat message successfully sent for %s", messageParam.JobName)
+	return nil
+}
+
+// getRocketChatMessage renders the message with the Slack template, Rocket.Chat understands
+// the same markup. The log is attached below as a collapsed text snippet instead of a link.
+func getRocketChatMessage(slack slackConfig, title string, color string, messageParam MessageTemplateParam) (rocketChatMessage, error) {
+	log := messageParam.Log
+	messageParam.Log = ""
+	text, err := slack.getMessage(messageParam)
+	if err != nil {
+		return rocketChatMessage{}, err
+	}
+	if c, ok := rocketChatColors[color]; ok {
+		color = c
+	}
+
+	message := rocketChatMessage{
+		Text: title + ": " + messageParam.JobName,
+		Attachments: []rocketChatAttachment{
+			{Title: title, Text: text, Color: color},
+		},
+	}
+	if log != "" {
+		message.Attachments = append(message.Attachments, rocketChatAttachment{
+			Title:     slack.translate("Logs"),
+			Text:      "```\n" + log + "\n```",
+			Collapsed: true,
+		})
+	}
+	return message, nil
+}
@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRocketChatServer(t *testing.T, received *[]rocketChatMessage, statusCode int, response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message rocketChatMessage
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		*received = append(*received, message)
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestRocketChatEvents(t *testing.T) {
+	param := MessageTemplateParam{JobName: "the-job", CronJobName: "the-cronjob", Namespace: "namespace"}
+	commands := "\n *Investigate*:\n```\nkubectl logs -n namespace job/the-job\nkubectl describe job -n namespace the-job\n```"
+	tests := []struct {
+		Name          string
+		notify        func(r rocketChat, messageParam MessageTemplateParam) (NotifyResult, error)
+		messageParam  MessageTemplateParam
+		expectedTitle string
+		expectedColor string
+		// expectedCommands is the kubectl hint of the failures.
+		expectedCommands string
+	}{
+		{"Start", rocketChat.NotifyStart, param, "Job Start", "#2eb886", ""},
+		{"Success", rocketChat.NotifySuccess, param, "Job Success", "#2eb886", ""},
+		{"Failed", rocketChat.NotifyFailed, param, "Job Failed", "#a30200", commands},
+		{
+			"Retrying", rocketChat.NotifyFailed,
+			MessageTemplateParam{JobName: "the-job", CronJobName: "the-cronjob", Namespace: "namespace", FailedCount: 1, BackoffLimit: 3},
+			"Job Failed, Retrying", "#daa038", commands,
+		},
+		{"Suspended", rocketChat.NotifySuspended, param, "Job Suspended", "#daa038", ""},
+		{"Resumed", rocketChat.NotifyResumed, param, "Job Resumed", "#2eb886", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var received []rocketChatMessage
+			server := newRocketChatServer(t, &received, http.StatusOK, `{"success":true}`)
+			defer server.Close()
+
+			_, err := test.notify(newRocketChat(server.URL, slackConfig{}), test.messageParam)
+
+			assert.NoError(t, err)
+			assert.Len(t, received, 1)
+			assert.Equal(t, test.expectedTitle+": the-job", received[0].Text)
+			assert.Equal(t, []rocketChatAttachment{
+				{
+					Title: test.expectedTitle,
+					Text:  "\n *CronJobName*: the-cronjob\n *JobName*: the-job\n *Namespace*: namespace\n\n\n\n" + test.expectedCommands,
+					Color: test.expectedColor,
+				},
+			}, received[0].Attachments)
+		})
+	}
+}
+
+func TestRocketChatLogSnippet(t *testing.T) {
+	var received []rocketChatMessage
+	server := newRocketChatServer(t, &received, http.StatusOK, `{"success":true}`)
+	defer server.Close()
+
+	_, err := newRocketChat(server.URL, slackConfig{}).NotifyFailed(MessageTemplateParam{JobName: "the-job", Log: "panic: boom"})
+
+	assert.NoError(t, err)
+	assert.Len(t, received[0].Attachments, 2)
+	assert.NotContains(t, received[0].Attachments[0].Text, "Loglink")
+	assert.Equal(t, rocketChatAttachment{Title: "Logs", Text: "```\npanic: boom\n```", Collapsed: true}, received[0].Attachments[1])
+}
+
+func TestRocketChatError(t *testing.T) {
+	tests := []struct {
+		Name        string
+		statusCode  int
+		response    string
+		expectedErr string
+	}{
+		{"Not successful", http.StatusOK, `{"success":false,"error":"Invalid integration"}`, "rocket.chat returned 200 OK: Invalid integration"},
+		{"Bad status", http.StatusNotFound, `{"success":false}`, "rocket.chat returned 404 Not Found: "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var received []rocketChatMessage
+			server := newRocketChatServer(t, &received, test.statusCode, test.response)
+			defer server.Close()
+
+			_, err := newRocketChat(server.URL, slackConfig{}).NotifyStart(MessageTemplateParam{JobName: "the-job"})
+
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestRocketChatSuppressed(t *testing.T) {
+	var received []rocketChatMessage
+	server := newRocketChatServer(t, &received, http.StatusOK, `{"success":true}`)
+	defer server.Close()
+
+	result, err := newRocketChat(server.URL, slackConfig{}).NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{suppressFailedAnnotationName: "true"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, result.SkippedReason)
+	assert.Empty(t, received)
+}
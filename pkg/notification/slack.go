@@ -2,32 +2,87 @@ package notification
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Songmu/flextime"
 	slackapi "github.com/slack-go/slack"
 	"html/template"
 	"k8s.io/klog"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 const (
 	START                = "start"
 	SUCCESS              = "success"
 	FAILED               = "failed"
-	SlackMessageTemplate = `
-{{if .CronJobName}} *CronJobName*: {{.CronJobName}}{{end}}
- *JobName*: {{.JobName}}
-{{if .Namespace}} *Namespace*: {{.Namespace}}{{end}}
-{{if .StartTime }} *StartTime*: {{.StartTime.Format "2006/1/2 15:04:05 UTC"}}{{end}}
-{{if .CompletionTime }} *CompletionTime*: {{.CompletionTime.Format "2006/1/2 15:04:05 UTC"}}{{end}}
-{{if .ExecutionTime }} *ExecutionTime*: {{.ExecutionTime}}{{end}}
-{{if .Log }} *Loglink*: {{.Log}}{{end}}`
-
-	defaultAnnotationName         = "kube-job-notifier/default-channel"
-	successAnnotationName         = "kube-job-notifier/success-channel"
-	startedAnnotationName         = "kube-job-notifier/started-channel"
-	failedAnnotationName          = "kube-job-notifier/failed-channel"
-	suppressSuccessAnnotationName = "kube-job-notifier/suppress-success-notification"
-	suppressStartedAnnotationName = "kube-job-notifier/suppress-started-notification"
-	suppressFailedAnnotationName  = "kube-job-notifier/suppress-failed-notification"
+	SlackMessageTemplate = slackMessageHeaderTemplate + slackMessageDetailsTemplate
+	// SlackFailureMessageTemplate is SlackMessageTemplate with the failure details and the
+	// logs first, used for failures with SLACK_FAILURE_DETAILS_FIRST=true.
+	SlackFailureMessageTemplate = slackFailureLeadTemplate + slackMessageHeaderTemplate + slackMessageDiagnosticsTemplate + slackMessageLinksTemplate
+	// slackFailureLeadTemplate renders the lines needed to triage a failure.
+	slackFailureLeadTemplate = `
+{{if .Reason }} *Reason*: {{.Reason | mrkdwn}}
+{{end}}{{if .ExitCode }} *ExitCode*: {{.ExitCode}}
+{{end}}` + slackMessageLogTemplate
+	// slackMessageHeaderTemplate renders the lines shown as attachment fields with SLACK_MESSAGE_FORMAT=fields.
+	slackMessageHeaderTemplate = `
+{{if .ClusterName}} *Cluster*: {{.ClusterName | mrkdwn}}
+{{end}}{{if .CronJobName}} *CronJobName*: {{.CronJobName | mrkdwn}}{{end}}
+ *JobName*: {{.JobName | mrkdwn}}
+{{if .Namespace}} *Namespace*: {{.Namespace | mrkdwn}}{{end}}
+{{if .StartTime }} *StartTime*: {{.StartTime | formatTime}}{{end}}
+{{if .CompletionTime }} *CompletionTime*: {{.CompletionTime | formatTime}}{{end}}
+{{if .ExecutionTime }} *ExecutionTime*: {{.ExecutionTime}}{{end}}`
+	slackMessageDetailsTemplate     = slackMessageDiagnosticsTemplate + slackMessageLogTemplate + slackMessageLinksTemplate
+	slackMessageDiagnosticsTemplate = `{{if .QueueTime }}
+ *QueueTime*: {{.QueueTime}}{{end}}{{if .FailingFor }}
+ *FailingFor*: {{.FailingFor}}{{end}}{{if .TimedOut }}
+ *ActiveDeadlineSeconds*: {{.ActiveDeadlineSeconds}}{{end}}{{if .OOMKilledContainer }}
+ :boom: *OOMKilled*: {{.OOMKilledContainer | mrkdwn}}{{if .MemoryLimit }} (memory limit {{.MemoryLimit}}){{end}}{{end}}{{if .NodeName }}
+ *Node*: {{.NodeName | mrkdwn}}{{if .Zone }} ({{.Zone | mrkdwn}}){{end}}{{end}}{{if .IndexSummary }}
+ *Indexes*: {{.IndexSummary}}{{end}}{{if .FailedIndices }}
+ *FailedIndices*: {{.FailedIndices}}{{end}}{{if .WaitingReason }}
+ :warning: *{{.WaitingReason}}*: {{.WaitingContainer | mrkdwn}}{{if .WaitingMessage }} ({{.WaitingMessage | mrkdwn}}){{end}}{{end}}{{if .LostPods }}
+ :warning: *LostPods*: {{.LostPods}} of {{.PeakActivePods}} running pods{{end}}{{if .CompletionWarning }}
+ :warning: {{.CompletionWarning}}{{end}}{{if .BatchSummary }}
+ *Batch*: {{.BatchSummary | mrkdwn}}{{end}}{{range .ResourceWarnings }}
+ :warning: {{. | mrkdwn}}{{end}}{{if .SpecChanges }}
+ *Changes*:{{range .SpecChanges }}
+ • {{. | mrkdwn}}{{end}}{{end}}{{if .Events }}
+ *Events*:{{range .Events }}
+ • {{. | mrkdwn}}{{end}}{{end}}
+`
+	slackMessageLogTemplate = `{{if .Log }} *Loglink*: {{.Log}}{{end}}{{if .LogURL }}
+ *Logs*: {{.LogURL}}{{end}}`
+	slackMessageLinksTemplate = `{{if .JobDetailURL }}
+ *Details*: {{.JobDetailURL}}{{end}}{{if .JobYAMLLink }}
+ *JobYAML*: {{.JobYAMLLink}}{{end}}{{if .RunbookURL }}
+ *Runbook*: {{.RunbookURL}}{{end}}{{if .KubectlCommands }}
+ *Investigate*:
+{{codeBlock .KubectlCommands}}{{end}}`
+
+	defaultAnnotationName           = "kube-job-notifier/default-channel"
+	successAnnotationName           = "kube-job-notifier/success-channel"
+	startedAnnotationName           = "kube-job-notifier/started-channel"
+	failedAnnotationName            = "kube-job-notifier/failed-channel"
+	suspendedAnnotationName         = "kube-job-notifier/suspended-channel"
+	failedMentionsAnnotationName    = "kube-job-notifier/failed-mentions"
+	suppressSuccessAnnotationName   = "kube-job-notifier/suppress-success-notification"
+	suppressStartedAnnotationName   = "kube-job-notifier/suppress-started-notification"
+	suppressFailedAnnotationName    = "kube-job-notifier/suppress-failed-notification"
+	suppressSuspendedAnnotationName = "kube-job-notifier/suppress-suspended-notification"
+	// suppressSpecChangedAnnotationName suppresses the notifications of changes to the spec of a running job.
+	suppressSpecChangedAnnotationName = "kube-job-notifier/suppress-spec-changed-notification"
+	runbookURLAnnotationName          = "job-notify/runbook-url"
+	templateAnnotationName            = "job-notify/template"
+
+	defaultMaxLogFiles          = 5
+	defaultMaxConcurrentUploads = 2
+	defaultMaxMessageLength     = 3000
 )
 
 var slackColors = map[string]string{
@@ -39,181 +94,469 @@ var slackColors = map[string]string{
 type slackClient interface {
 	PostMessage(channelID string, options ...slackapi.MsgOption) (string, string, error)
 	UploadFile(params slackapi.FileUploadParameters) (file *slackapi.File, err error)
+	GetPermalink(params *slackapi.PermalinkParameters) (string, error)
+	ScheduleMessage(channelID, postAt string, options ...slackapi.MsgOption) (string, string, error)
+	AddReaction(name string, item slackapi.ItemRef) error
 }
 
 type slack struct {
-	client   slackClient
-	channel  string
-	username string
+	client slackClient
+	config slackConfig
+	// channel is the channel of the current notification, config.channel unless the job
+	// is routed elsewhere.
+	channel string
+	// uploads limits the number of file uploads running at the same time.
+	uploads chan struct{}
+	// threads is set when every job event of a namespace is posted into a daily thread.
+	threads *namespaceThreads
+	// workspaces are additional Slack workspaces jobs can be routed to.
+	workspaces map[string]slackWorkspace
+	// userGroups resolves the user group handles of the mentions.
+	userGroups *userGroups
+	// logUploads reuses the previous upload of an unchanged log, nil when every log is uploaded.
+	logUploads *logUploads
+	// text is the message text shown above the attachment, e.g. mentions.
+	text string
 }
 
-func newSlack() slack {
-	token := os.Getenv("SLACK_TOKEN")
-	if token == "" {
+func init() {
+	// default notification
+	Register("slack", func() (Notification, bool) {
+		return newSlack(getSlackConfig()), true
+	})
+}
+
+func newSlack(config slackConfig) slack {
+	if config.token == "" {
 		panic("please set slack client")
 	}
 
-	client := slackapi.New(token)
+	client := newSlackAPI(config.token)
 
-	channel := os.Getenv("SLACK_CHANNEL")
+	groups := newUserGroups(client)
+	if len(config.failedMentions) > 0 {
+		if err := groups.refresh(); err != nil {
+			klog.Errorf("Get Slack user groups failed %s\n", err)
+		}
+		go groups.refreshEvery(getUserGroupsRefreshInterval())
+	}
 
-	username := os.Getenv("SLACK_USERNAME")
+	var threads *namespaceThreads
+	if config.namespaceThread {
+		threads = newNamespaceThreads()
+	}
 
 	return slack{
-		client:   client,
-		channel:  channel,
-		username: username,
+		client:     client,
+		config:     config,
+		channel:    config.channel,
+		uploads:    make(chan struct{}, config.maxConcurrentUploads),
+		threads:    threads,
+		workspaces: newSlackWorkspaces(config.workspaceTokens, config.workspaceChannels),
+		userGroups: groups,
+		logUploads: newLogUploads(config.logDedupWindow),
 	}
 
 }
 
-func (s slack) NotifyStart(messageParam MessageTemplateParam) (err error) {
+func (s slack) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
 
-	if !isNotifyFromEnv("SLACK_STARTED_NOTIFY") {
-		return nil
+	if s.config.disableStarted {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
 	}
 
 	if isNotificationSuppressed(messageParam.Annotations, suppressStartedAnnotationName) {
 		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
-		return nil
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
 	}
 
-	succeedChannel := os.Getenv("SLACK_SUCCEED_CHANNEL")
-	if succeedChannel != "" {
-		s.channel = succeedChannel
+	if s.config.succeedChannel != "" {
+		s.channel = s.config.succeedChannel
+	}
+	namespaceChannel := s.config.namespaceChannels[messageParam.Namespace]
+	if namespaceChannel != "" {
+		s.channel = namespaceChannel
 	}
+	s = s.useWorkspace(messageParam)
 	slackChannel := getSlackChannel(messageParam.Annotations, startedAnnotationName)
 	if slackChannel != "" {
 		s.channel = slackChannel
 	}
 
-	slackMessage, err := getSlackMessage(messageParam)
+	slackMessage, err := s.config.getMessage(messageParam)
 	if err != nil {
 		klog.Errorf("Template execute failed %s\n", err)
-		return err
+		return NotifyResult{}, err
 	}
 
 	attachment := slackapi.Attachment{
 		Color: slackColors["Normal"],
-		Title: "Job Start",
+		Title: s.config.translate("Job Start"),
 		Text:  slackMessage,
 	}
 
-	err = s.notify(attachment)
-	if err != nil {
-		return err
+	return s.notify(messageParam, attachment)
+}
+
+// mrkdwnEscaper keeps Slack from interpreting formatting characters in names.
+// html/template already escapes &, < and > which is what Slack expects for them,
+// but mrkdwn has no escape for *, _, ~ and `, so they are surrounded by zero width spaces.
+var mrkdwnEscaper = strings.NewReplacer(
+	"*", "\u200b*\u200b",
+	"_", "\u200b_\u200b",
+	"~", "\u200b~\u200b",
+	"`", "\u200b`\u200b",
+)
+
+func escapeMrkdwn(s string) string {
+	return mrkdwnEscaper.Replace(s)
+}
+
+// codeBlock renders lines as a preformatted block, ready to copy.
+func codeBlock(lines []string) string {
+	return "```\n" + strings.Join(lines, "\n") + "\n```"
+}
+
+// slackTemplateFuncs are the functions of the Slack message templates, rendering times with f.
+func slackTemplateFuncs(f timeFormat) template.FuncMap {
+	return template.FuncMap{"mrkdwn": escapeMrkdwn, "formatTime": f.format, "codeBlock": codeBlock}
+}
+
+// slackTemplates are the default message templates by locale.
+type slackTemplates struct {
+	message map[string]*template.Template
+	// details are the templates of the text below the attachment fields.
+	details map[string]*template.Template
+	// failure and failureDetails put the failure details first.
+	failure        map[string]*template.Template
+	failureDetails map[string]*template.Template
+}
+
+// defaultSlackTemplates render times with the default time format, for configurations
+// that weren't loaded from the environment.
+var defaultSlackTemplates = newSlackTemplates(timeFormat{})
+
+func newSlackTemplates(f timeFormat) *slackTemplates {
+	funcs := slackTemplateFuncs(f)
+	return &slackTemplates{
+		message:        parseSlackMessageTemplates(slackMessageTemplateSources, funcs),
+		details:        parseSlackMessageTemplates(slackDetailsTemplateSources, funcs),
+		failure:        parseSlackMessageTemplates(slackFailureTemplateSources, funcs),
+		failureDetails: parseSlackMessageTemplates(slackFailureDetailsTemplateSources, funcs),
 	}
-	return nil
 }
 
-func getSlackMessage(messageParam MessageTemplateParam) (slackMessage string, err error) {
-	var b bytes.Buffer
-	tpl, err := template.New("slack").Parse(SlackMessageTemplate)
-	if err != nil {
-		return "", err
+func parseSlackMessageTemplates(sources map[string]string, funcs template.FuncMap) map[string]*template.Template {
+	res := make(map[string]*template.Template, len(sources))
+	for locale, source := range sources {
+		res[locale] = template.Must(template.New("slack").Funcs(funcs).Parse(source))
 	}
+	return res
+}
+
+// getMessage renders the message text with SLACK_MESSAGE_TEMPLATE or the default template of the locale.
+// With SLACK_MESSAGE_FORMAT=fields the default template leaves out the lines shown as fields.
+// With SLACK_FAILURE_DETAILS_FIRST=true the default template of failures starts with the
+// reason, the exit code and the logs.
+func (c slackConfig) getMessage(messageParam MessageTemplateParam) (slackMessage string, err error) {
+	failureFirst := c.failureDetailsFirst && messageParam.Event == FAILED
+	templates := c.templates
+	if templates == nil {
+		templates = defaultSlackTemplates
+	}
+	defaults := templates.message
+	switch {
+	case c.messageFormat == messageFormatFields && failureFirst:
+		defaults = templates.failureDetails
+	case c.messageFormat == messageFormatFields:
+		defaults = templates.details
+	case failureFirst:
+		defaults = templates.failure
+	}
+	tpl := c.messageTemplate
+	if tpl == nil {
+		tpl = defaults[c.locale]
+	}
+	if tpl == nil {
+		tpl = defaults[localeEnglish]
+	}
+	if v := messageParam.Annotations[templateAnnotationName]; v != "" {
+		jobTpl, err := template.New("job").Funcs(slackTemplateFuncs(c.timeFormat)).Parse(v)
+		if err != nil {
+			klog.Errorf("Invalid %s annotation of %s, using the default template: %v", templateAnnotationName, messageParam.JobName, err)
+		} else {
+			tpl = jobTpl
+		}
+	}
+	var b bytes.Buffer
 	err = tpl.Execute(&b, messageParam)
 	if err != nil {
 		return "", err
 	}
+	if c.convertMarkdown {
+		return convertMarkdown(b.String()), nil
+	}
 	return b.String(), nil
 }
 
-func (s slack) NotifySuccess(messageParam MessageTemplateParam) (err error) {
+func (s slack) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
 
-	if !isNotifyFromEnv("SLACK_SUCCEEDED_NOTIFY") {
-		return nil
+	if s.config.disableSucceeded {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
 	}
 
 	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
 		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
-		return nil
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
 	}
 
-	succeedChannel := os.Getenv("SLACK_SUCCEED_CHANNEL")
-	if succeedChannel != "" {
-		s.channel = succeedChannel
+	if s.config.succeedChannel != "" {
+		s.channel = s.config.succeedChannel
 	}
+	namespaceChannel := s.config.namespaceChannels[messageParam.Namespace]
+	if namespaceChannel != "" {
+		s.channel = namespaceChannel
+	}
+	s = s.useWorkspace(messageParam)
 	slackChannel := getSlackChannel(messageParam.Annotations, successAnnotationName)
 	if slackChannel != "" {
 		s.channel = slackChannel
 	}
-	if messageParam.Log != "" {
-		file, err := s.uploadLog(messageParam)
+	if !s.config.uploadsLogs(SUCCESS) {
+		messageParam.Log, messageParam.PodLogs = "", nil
+	}
+	if messageParam.Log != "" || len(messageParam.PodLogs) > 0 {
+		messageParam.Log, err = s.uploadLogs(messageParam)
 		if err != nil {
 			klog.Errorf("Template execute failed %s\n", err)
-			return err
+			return NotifyResult{}, err
 		}
-		messageParam.Log = file.Permalink
 	}
 
 	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
 
-	slackMessage, err := getSlackMessage(messageParam)
+	slackMessage, err := s.config.getMessage(messageParam)
 	if err != nil {
 		klog.Errorf("Template execute failed %s\n", err)
-		return err
+		return NotifyResult{}, err
 	}
 	attachment := slackapi.Attachment{
 		Color: slackColors["Normal"],
-		Title: "Job Success",
+		Title: s.config.translate("Job Success"),
 		Text:  slackMessage,
 	}
+	if messageParam.Recovered {
+		attachment.Title = s.config.translate("Job Recovered")
+	}
 
-	err = s.notify(attachment)
-	if err != nil {
-		return err
+	if s.config.successScheduleAt != nil {
+		return s.schedule(messageParam, attachment, nextTimeOfDay(flextime.Now(), *s.config.successScheduleAt))
 	}
-	return nil
+	return s.notify(messageParam, attachment)
 }
 
-func (s slack) NotifyFailed(messageParam MessageTemplateParam) (err error) {
+func (s slack) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
 
-	if !isNotifyFromEnv("SLACK_FAILED_NOTIFY") {
-		return nil
+	if s.config.disableFailed {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
 	}
 
 	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
 		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
-		return nil
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
 	}
 
-	failedChannel := os.Getenv("SLACK_FAILED_CHANNEL")
-	if failedChannel != "" {
-		s.channel = failedChannel
+	if s.config.failedChannel != "" {
+		s.channel = s.config.failedChannel
+	}
+	namespaceChannel := s.config.namespaceChannels[messageParam.Namespace]
+	if namespaceChannel != "" {
+		s.channel = namespaceChannel
 	}
+	s = s.useWorkspace(messageParam)
 	slackChannel := getSlackChannel(messageParam.Annotations, failedAnnotationName)
 	if slackChannel != "" {
 		s.channel = slackChannel
 	}
-	if messageParam.Log != "" {
-		file, err := s.uploadLog(messageParam)
+	if !s.config.uploadsLogs(FAILED) {
+		messageParam.Log, messageParam.PodLogs = "", nil
+	}
+	if messageParam.Log != "" || len(messageParam.PodLogs) > 0 {
+		messageParam.Log, err = s.uploadLogs(messageParam)
 		if err != nil {
 			klog.Errorf("Template execute failed %s\n", err)
-			return err
+			return NotifyResult{}, err
+		}
+	}
+
+	if messageParam.JobYAML != "" && s.config.attachJobYAML {
+		file, err := s.uploadFile(messageParam.Namespace+"_"+messageParam.JobName+".yaml", messageParam.JobYAML, "yaml")
+		if err != nil {
+			klog.Errorf("Job yaml upload failed %s\n", err)
+			return NotifyResult{}, err
 		}
-		messageParam.Log = file.Permalink
+		messageParam.JobYAMLLink = file.Permalink
 	}
 
 	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	messageParam.RunbookURL = messageParam.Annotations[runbookURLAnnotationName]
+	messageParam.KubectlCommands = messageParam.kubectlCommands()
 
-	slackMessage, err := getSlackMessage(messageParam)
+	slackMessage, err := s.config.getMessage(messageParam)
 	if err != nil {
 		klog.Errorf("Template execute failed %s\n", err)
-		return err
+		return NotifyResult{}, err
 	}
 
 	attachment := slackapi.Attachment{
-		Color: slackColors["Danger"],
-		Title: "Job Failed",
+		Color: s.config.getFailedColor(messageParam),
+		Title: s.config.translate("Job Failed"),
 		Text:  slackMessage,
 	}
+	if !messageParam.retriesExhausted() {
+		attachment.Title = s.config.translate("Job Failed, Retrying")
+	}
+	if messageParam.TimedOut {
+		attachment.Color = slackColors["Warning"]
+		attachment.Title = s.config.translate("Job Timed Out")
+	} else if messageParam.OOMKilledContainer != "" {
+		attachment.Title = s.config.translate("Job Failed (OOMKilled)")
+	}
+	if messageParam.WaitingReason != "" {
+		attachment.Color = slackColors["Warning"]
+		attachment.Title = s.config.translate("Job Stuck") + " (" + messageParam.WaitingReason + ")"
+	}
+	if messageParam.LostPods > 0 {
+		attachment.Color = slackColors["Warning"]
+		attachment.Title = s.config.translate("Job Pods Lost")
+	}
+	if messageParam.RunbookURL != "" {
+		attachment.Actions = []slackapi.AttachmentAction{
+			{
+				Name:  "runbook",
+				Text:  s.config.translate("Open runbook"),
+				Type:  "button",
+				Style: "primary",
+				URL:   messageParam.RunbookURL,
+			},
+		}
+	}
 
-	err = s.notify(attachment)
+	// Retry, stuck pod and lost pod warnings don't page anyone unless the job is critical.
+	if messageParam.pages() {
+		s.text = s.failedMention(messageParam.Annotations)
+	}
+
+	result, err = s.notify(messageParam, attachment)
+	if err != nil || s.config.failureReaction == "" {
+		return result, err
+	}
+	// The message is already sent, a missing reaction only leaves it unflagged.
+	err = s.client.AddReaction(s.config.failureReaction, slackapi.NewRefToMessage(result.Channel, result.Timestamp))
 	if err != nil {
-		return err
+		klog.Errorf("Add reaction failed %s\n", err)
 	}
-	return nil
+	return result, nil
+}
+
+// getFailedColor escalates the failure color with the number of failed attempts.
+// Exhausted retries are always Danger, earlier failures are Warning unless
+// SLACK_FAILED_COLORS (e.g. "1:Warning,3:Danger") maps the failed count to another color.
+func (c slackConfig) getFailedColor(messageParam MessageTemplateParam) string {
+	if messageParam.retriesExhausted() {
+		return slackColors["Danger"]
+	}
+
+	color := slackColors["Warning"]
+	thresholds := c.failedColors
+	counts := make([]int, 0, len(thresholds))
+	for count := range thresholds {
+		counts = append(counts, count)
+	}
+	sort.Ints(counts)
+	for _, count := range counts {
+		if int32(count) > messageParam.FailedCount {
+			break
+		}
+		color = thresholds[count]
+	}
+	return color
+}
+
+func parseFailedColors(value string) (map[int]string, error) {
+	res := make(map[int]string)
+	var errs []error
+	for k, v := range parseKeyValues(value) {
+		count, err := strconv.Atoi(k)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid SLACK_FAILED_COLORS count %q: %w", k, err))
+			continue
+		}
+		color, ok := slackColors[v]
+		if !ok {
+			color = v
+		}
+		res[count] = color
+	}
+	return res, errors.Join(errs...)
+}
+
+func (s slack) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return s.notifySuspendState(messageParam, s.config.translate("Job Suspended"), slackColors["Warning"])
+}
+
+func (s slack) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return s.notifySuspendState(messageParam, s.config.translate("Job Resumed"), slackColors["Normal"])
+}
+
+func (s slack) notifySuspendState(messageParam MessageTemplateParam, title string, color string) (result NotifyResult, err error) {
+
+	if s.config.disableSuspended {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
+	}
+
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+
+	return s.notifyJobState(messageParam, title, color)
+}
+
+func (s slack) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSpecChangedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+
+	return s.notifyJobState(messageParam, s.config.translate("Job Spec Changed"), slackColors["Normal"])
+}
+
+// notifyJobState sends a change to a running job, e.g. a suspension, to the suspended channel.
+func (s slack) notifyJobState(messageParam MessageTemplateParam, title string, color string) (result NotifyResult, err error) {
+	namespaceChannel := s.config.namespaceChannels[messageParam.Namespace]
+	if namespaceChannel != "" {
+		s.channel = namespaceChannel
+	}
+	s = s.useWorkspace(messageParam)
+	slackChannel := getSlackChannel(messageParam.Annotations, suspendedAnnotationName)
+	if slackChannel != "" {
+		s.channel = slackChannel
+	}
+
+	slackMessage, err := s.config.getMessage(messageParam)
+	if err != nil {
+		klog.Errorf("Template execute failed %s\n", err)
+		return NotifyResult{}, err
+	}
+
+	attachment := slackapi.Attachment{
+		Color: color,
+		Title: title,
+		Text:  slackMessage,
+	}
+
+	return s.notify(messageParam, attachment)
 }
 
 func getSlackChannel(annotations map[string]string, annotationName string) string {
@@ -232,30 +575,240 @@ func isNotificationSuppressed(annotations map[string]string, annotationName stri
 	return a == "true"
 }
 
-func (s slack) notify(attachment slackapi.Attachment) (err error) {
+func (s slack) getUsername() string {
+	if username, ok := s.config.channelUsernames[s.channel]; ok {
+		return username
+	}
+	return s.config.username
+}
+
+func (s slack) notify(messageParam MessageTemplateParam, attachment slackapi.Attachment) (result NotifyResult, err error) {
+	attachment = s.withFields(messageParam, attachment)
+	attachment = s.withSummary(messageParam, attachment)
+	attachment, err = s.fitMessage(messageParam, attachment)
+	if err != nil {
+		return NotifyResult{}, err
+	}
+	options := []slackapi.MsgOption{
+		slackapi.MsgOptionText(s.text, false),
+		slackapi.MsgOptionAttachments(attachment),
+		slackapi.MsgOptionUsername(s.getUsername()),
+	}
+
+	if s.threads != nil && messageParam.Namespace != "" {
+		threadTS, err := s.getNamespaceThread(messageParam.Namespace)
+		if err != nil {
+			return NotifyResult{}, err
+		}
+		options = append(options, slackapi.MsgOptionTS(threadTS))
+	}
+
+	channelID, timestamp, err := s.client.PostMessage(s.channel, options...)
+
+	if err != nil {
+		klog.Errorf("Send messageParam failed %s\n", err)
+		return NotifyResult{}, err
+	}
+
+	klog.Infof("Message successfully sent to channel %s at %s", channelID, timestamp)
+	result = NotifyResult{Channel: channelID, Timestamp: timestamp}
+
+	// The message is already sent, a missing permalink only leaves the result incomplete.
+	result.Permalink, err = s.client.GetPermalink(&slackapi.PermalinkParameters{Channel: channelID, Ts: timestamp})
+	if err != nil {
+		klog.Errorf("Get permalink failed %s\n", err)
+	}
+	return result, nil
+}
+
+// withSummary sets the one-line fallback shown in notification popups, which would read
+// "[No Text]" otherwise, and the optional SLACK_PRETEXT shown above the attachment.
+func (s slack) withSummary(messageParam MessageTemplateParam, attachment slackapi.Attachment) slackapi.Attachment {
+	attachment.Fallback = attachment.Title + ": " + messageParam.JobName
+	if messageParam.Namespace != "" {
+		attachment.Fallback += " in " + messageParam.Namespace
+	}
+	if messageParam.ClusterName != "" {
+		attachment.Fallback += " (" + messageParam.ClusterName + ")"
+	}
+	attachment.Pretext = s.config.pretext
+	// The emoji leads the first line, the default template starts with empty lines.
+	if emoji := s.config.eventEmojis[messageParam.Event]; emoji != "" {
+		attachment.Text = emoji + " " + strings.TrimLeft(attachment.Text, "\n")
+	}
+	return attachment
+}
+
+// withFields shows the job, its status and its run as separate attachment fields with
+// SLACK_MESSAGE_FORMAT=fields. Slack lays short fields out side by side.
+func (s slack) withFields(messageParam MessageTemplateParam, attachment slackapi.Attachment) slackapi.Attachment {
+	if s.config.messageFormat != messageFormatFields {
+		return attachment
+	}
+	field := func(name string, value string) {
+		if value != "" {
+			attachment.Fields = append(attachment.Fields, slackapi.AttachmentField{Title: s.config.translate(name), Value: value, Short: true})
+		}
+	}
+	field("JobName", messageParam.JobName)
+	field("CronJobName", messageParam.CronJobName)
+	field("Namespace", messageParam.Namespace)
+	field("Cluster", messageParam.ClusterName)
+	field("Status", attachment.Title)
+	if messageParam.ExecutionTime > 0 {
+		field("ExecutionTime", messageParam.ExecutionTime.String())
+	}
+	field("StartTime", s.config.timeFormat.format(messageParam.StartTime))
+	field("CompletionTime", s.config.timeFormat.format(messageParam.CompletionTime))
+	return attachment
+}
+
+// fitMessage moves a message text longer than maxMessageLength, e.g. with inline logs, into
+// a file and keeps its beginning with a link to the file, as Slack rejects too long messages.
+func (s slack) fitMessage(messageParam MessageTemplateParam, attachment slackapi.Attachment) (slackapi.Attachment, error) {
+	if s.config.maxMessageLength <= 0 || utf8.RuneCountInString(attachment.Text) <= s.config.maxMessageLength {
+		return attachment, nil
+	}
+	file, err := s.uploadFile(messageParam.Namespace+"_"+messageParam.JobName+"_message.txt", attachment.Text, "txt")
+	if err != nil {
+		klog.Errorf("Message upload failed %s\n", err)
+		return attachment, err
+	}
+	suffix := "\n... message truncated, full message: " + file.Permalink
+	keep := s.config.maxMessageLength - utf8.RuneCountInString(suffix)
+	text := []rune(attachment.Text)
+	if keep < 0 {
+		keep = 0
+	}
+	attachment.Text = string(text[:keep]) + suffix
+	return attachment, nil
+}
 
-	channelID, timestamp, err := s.client.PostMessage(
+// schedule delivers the message at postAt with chat.scheduleMessage. Scheduled messages
+// are not posted into namespace threads, the thread of the delivery day doesn't exist yet.
+func (s slack) schedule(messageParam MessageTemplateParam, attachment slackapi.Attachment, postAt time.Time) (result NotifyResult, err error) {
+	attachment = s.withFields(messageParam, attachment)
+	attachment = s.withSummary(messageParam, attachment)
+	attachment, err = s.fitMessage(messageParam, attachment)
+	if err != nil {
+		return NotifyResult{}, err
+	}
+	channelID, scheduledAt, err := s.client.ScheduleMessage(
 		s.channel,
+		strconv.FormatInt(postAt.Unix(), 10),
 		slackapi.MsgOptionText("", true),
 		slackapi.MsgOptionAttachments(attachment),
-		slackapi.MsgOptionUsername(s.username),
+		slackapi.MsgOptionUsername(s.getUsername()),
 	)
+	if err != nil {
+		klog.Errorf("Schedule message failed %s\n", err)
+		return NotifyResult{}, err
+	}
 
+	klog.Infof("Message successfully scheduled to channel %s at %s", channelID, postAt.UTC().Format(time.RFC3339))
+	return NotifyResult{Channel: channelID, Timestamp: scheduledAt}, nil
+}
+
+// parseTimeOfDay parses "15:04" into the duration since midnight.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
 	if err != nil {
-		klog.Errorf("Send messageParam failed %s\n", err)
-		return
+		return 0, err
 	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
 
-	klog.Infof("Message successfully sent to channel %s at %s", channelID, timestamp)
-	return err
+// nextTimeOfDay returns the next time after now at the given time of day in UTC.
+func nextTimeOfDay(now time.Time, at time.Duration) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(at)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// getNamespaceThread returns the ts of today's thread of the namespace, starting a new one when needed.
+func (s slack) getNamespaceThread(namespace string) (threadTS string, err error) {
+	return s.threads.getOrStart(s.channel, namespace, func() (string, error) {
+		_, threadTS, err := s.client.PostMessage(
+			s.channel,
+			slackapi.MsgOptionText(namespaceThreadTitle(namespace, flextime.Now()), false),
+			slackapi.MsgOptionUsername(s.getUsername()),
+		)
+		if err != nil {
+			klog.Errorf("Start namespace thread failed %s\n", err)
+			return "", err
+		}
+		klog.Infof("Namespace thread for %s started at %s", namespace, threadTS)
+		return threadTS, nil
+	})
 }
 
-func (s slack) uploadLog(param MessageTemplateParam) (file *slackapi.File, err error) {
+// uploadLogs uploads the job log and returns the permalinks to show in the message.
+// When logs of several pods are given, one file is uploaded per pod up to maxLogFiles.
+func (s slack) uploadLogs(param MessageTemplateParam) (links string, err error) {
+	if len(param.PodLogs) <= 1 {
+		content := param.Log
+		if content == "" && len(param.PodLogs) == 1 {
+			content = param.PodLogs[0].Log
+		}
+		return s.uploadJobLog(param, 0, param.Namespace+"_"+param.JobName, content)
+	}
+
+	podLogs := param.PodLogs
+	if s.config.maxLogFiles > 0 && len(podLogs) > s.config.maxLogFiles {
+		klog.Infof("Uploading %d of %d pod logs for %s", s.config.maxLogFiles, len(podLogs), param.JobName)
+		podLogs = podLogs[:s.config.maxLogFiles]
+	}
+	permalinks := make([]string, 0, len(podLogs))
+	for i, podLog := range podLogs {
+		permalink, err := s.uploadJobLog(param, i, param.Namespace+"_"+podLog.PodName, podLog.Log)
+		if err != nil {
+			return "", err
+		}
+		permalinks = append(permalinks, permalink)
+	}
+	return strings.Join(permalinks, " "), nil
+}
+
+// uploadJobLog uploads the index-th log of the job and returns its permalink. The runs of a
+// CronJob share their previous uploads, the same log is linked instead of uploaded again.
+func (s slack) uploadJobLog(param MessageTemplateParam, index int, title string, content string) (string, error) {
+	name := param.CronJobName
+	if name == "" {
+		name = param.JobName
+	}
+	key := s.channel + "/" + param.Namespace + "/" + name + "/" + strconv.Itoa(index)
+	if permalink, ok := s.logUploads.permalink(key, content); ok {
+		klog.Infof("Log of %s unchanged since the previous upload, reusing %s", param.JobName, permalink)
+		return permalink, nil
+	}
+	file, err := s.uploadLog(title, content)
+	if err != nil {
+		return "", err
+	}
+	s.logUploads.record(key, content, file.Permalink)
+	return file.Permalink, nil
+}
+
+func (s slack) uploadLog(title string, content string) (file *slackapi.File, err error) {
+	return s.uploadFile(title, content, "txt")
+}
+
+// uploadFile shares the file to s.channel. The Notify methods resolve the event's channel
+// before uploading, so files land in the same channel as the message linking to them.
+func (s slack) uploadFile(title string, content string, filetype string) (file *slackapi.File, err error) {
+	if s.uploads != nil {
+		s.uploads <- struct{}{}
+		defer func() { <-s.uploads }()
+	}
+
 	file, err = s.client.UploadFile(
 		slackapi.FileUploadParameters{
-			Title:    param.Namespace + "_" + param.JobName,
-			Content:  param.Log,
-			Filetype: "txt",
+			Title:    title,
+			Content:  content,
+			Filetype: filetype,
 			Channels: []string{s.channel},
 		})
 	if err != nil {
@@ -267,10 +820,19 @@ func (s slack) uploadLog(param MessageTemplateParam) (file *slackapi.File, err e
 	return
 }
 
-func isNotifyFromEnv(key string) bool {
-	value := os.Getenv(key)
-	if value == "false" {
-		return false
+// parseKeyValues parses "key1:value1,key2:value2" settings.
+func parseKeyValues(value string) map[string]string {
+	res := make(map[string]string)
+	if value == "" {
+		return res
+	}
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			klog.Errorf("Invalid key value setting %q", item)
+			continue
+		}
+		res[kv[0]] = kv[1]
 	}
-	return true
+	return res
 }
@@ -1,7 +1,14 @@
 package notification
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,25 +25,24 @@ func TestNewSlack(t *testing.T) {
 	os.Setenv("SLACK_USERNAME", "slack_username")
 
 	expected := slack{
-		client:   slackapi.New("slack_token"),
-		channel:  "slack_channel",
-		username: "slack_username",
+		client:  slackapi.New("slack_token"),
+		channel: "slack_channel",
+		config:  slackConfig{username: "slack_username"},
 	}
-	actual := newSlack()
+	actual := newSlack(getTestSlackConfig(t))
 	assert.Equal(t, expected.channel, actual.channel)
-	assert.Equal(t, expected.username, actual.username)
+	assert.Equal(t, expected.config.username, actual.config.username)
 
 	os.Unsetenv("SLACK_CHANNEL")
 	os.Unsetenv("SLACK_USERNAME")
 
-	actual = newSlack()
+	actual = newSlack(getTestSlackConfig(t))
 	expected = slack{
-		client:   slackapi.New("slack_token"),
-		channel:  "",
-		username: "",
+		client:  slackapi.New("slack_token"),
+		channel: "",
 	}
 	assert.Equal(t, expected.channel, actual.channel)
-	assert.Equal(t, expected.username, actual.username)
+	assert.Equal(t, expected.config.username, actual.config.username)
 	// For panic test
 	defer func() {
 		err := recover()
@@ -45,7 +51,13 @@ func TestNewSlack(t *testing.T) {
 		}
 	}()
 	os.Unsetenv("SLACK_TOKEN")
-	actual = newSlack()
+	actual = newSlack(getTestSlackConfig(t))
+}
+
+func getTestSlackConfig(t *testing.T) slackConfig {
+	config, err := loadSlackConfig()
+	assert.NoError(t, err)
+	return config
 }
 
 func TestNotifyStart(t *testing.T) {
@@ -123,9 +135,11 @@ func TestNotifyStart(t *testing.T) {
 					Return(test.expectedChannel, "timestamp", nil)
 			}
 
-			slack := slack{client: mc, channel: defaultChannel, username: u}
+			config := getTestSlackConfig(t)
+			config.username = u
+			slack := slack{client: mc, config: config, channel: defaultChannel}
 
-			err := slack.NotifyStart(MessageTemplateParam{
+			_, err := slack.NotifyStart(MessageTemplateParam{
 				JobName:     "the-job",
 				Annotations: test.annotations,
 			})
@@ -214,9 +228,11 @@ func TestNotifySuccess(t *testing.T) {
 					Return(test.expectedChannel, "timestamp", nil)
 			}
 
-			slack := slack{client: mc, channel: defaultChannel, username: u}
+			config := getTestSlackConfig(t)
+			config.username = u
+			slack := slack{client: mc, config: config, channel: defaultChannel}
 
-			err := slack.NotifySuccess(MessageTemplateParam{
+			_, err := slack.NotifySuccess(MessageTemplateParam{
 				JobName:     "the-job",
 				Annotations: test.annotations,
 			})
@@ -305,9 +321,11 @@ func TestNotifyFailed(t *testing.T) {
 					Return(test.expectedChannel, "timestamp", nil)
 			}
 
-			slack := slack{client: mc, channel: defaultChannel, username: u}
+			config := getTestSlackConfig(t)
+			config.username = u
+			slack := slack{client: mc, config: config, channel: defaultChannel}
 
-			err := slack.NotifyFailed(MessageTemplateParam{
+			_, err := slack.NotifyFailed(MessageTemplateParam{
 				JobName:     "the-job",
 				Annotations: test.annotations,
 			})
@@ -335,6 +353,20 @@ func (c *MockSlackClient) UploadFile(params slackapi.FileUploadParameters) (file
 	return args.Get(0).(*slackapi.File), args.Error(1)
 }
 
+func (c *MockSlackClient) ScheduleMessage(channelID, postAt string, options ...slackapi.MsgOption) (string, string, error) {
+	args := c.Called(channelID, postAt, options)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (c *MockSlackClient) AddReaction(name string, item slackapi.ItemRef) error {
+	args := c.Called(name, item)
+	return args.Error(0)
+}
+
+func (c *MockSlackClient) GetPermalink(params *slackapi.PermalinkParameters) (string, error) {
+	return "https://example.slack.com/archives/" + params.Channel + "/p" + params.Ts, nil
+}
+
 func TestGetSlackMessage(t *testing.T) {
 	mockTime := time.Date(2020, 11, 28, 1, 2, 3, 123456000, time.UTC)
 	restore := flextime.Set(mockTime)
@@ -353,7 +385,7 @@ func TestGetSlackMessage(t *testing.T) {
 
 	input.CompletionTime, input.ExecutionTime = input.calculateExecutionTime()
 
-	actual, err := getSlackMessage(input)
+	actual, err := slackConfig{}.getMessage(input)
 
 	assert.Empty(t, err)
 	expect := `
@@ -384,7 +416,7 @@ func TestGetSlackMessage(t *testing.T) {
 
 	input.CompletionTime, input.ExecutionTime = input.calculateExecutionTime()
 
-	actual, err = getSlackMessage(input)
+	actual, err = slackConfig{}.getMessage(input)
 	assert.Empty(t, err)
 	expect = `
  *CronJobName*: CronJob
@@ -493,3 +525,1329 @@ func TestIsNotificationSuppressed(t *testing.T) {
 		})
 	}
 }
+
+func TestGetFailedColor(t *testing.T) {
+	tests := []struct {
+		Name         string
+		colorsEnv    string
+		failedCount  int32
+		backoffLimit int32
+		expected     string
+	}{
+		{"Unknown failed count", "", 0, 3, "danger"},
+		{"Retries exhausted", "", 4, 3, "danger"},
+		{"No retries allowed", "", 1, 0, "danger"},
+		{"First attempt failed", "", 1, 3, "warning"},
+		{"Configured color below threshold", "2:Danger", 1, 3, "warning"},
+		{"Configured color reached threshold", "2:Danger", 2, 3, "danger"},
+		{"Configured raw color", "1:#ff9900,3:Danger", 2, 5, "#ff9900"},
+		{"Invalid configuration is ignored", "foo,x:Danger", 1, 3, "warning"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			colors, _ := parseFailedColors(test.colorsEnv)
+
+			actual := slackConfig{failedColors: colors}.getFailedColor(MessageTemplateParam{
+				FailedCount:  test.failedCount,
+				BackoffLimit: test.backoffLimit,
+			})
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestGetSlackMessageWithIndexSummary(t *testing.T) {
+	actual, err := slackConfig{}.getMessage(MessageTemplateParam{
+		JobName:      "Job",
+		Namespace:    "namespace",
+		IndexSummary: "3/5 succeeded, 2 failed: index 1, 4",
+	})
+
+	assert.Empty(t, err)
+	assert.Contains(t, actual, "\n *Indexes*: 3/5 succeeded, 2 failed: index 1, 4\n")
+}
+
+func TestGetSlackMessageWithRunbook(t *testing.T) {
+	actual, err := slackConfig{}.getMessage(MessageTemplateParam{
+		JobName:    "Job",
+		Namespace:  "namespace",
+		Log:        "Log",
+		RunbookURL: "https://runbooks.example.com/job",
+	})
+
+	assert.Empty(t, err)
+	expect := `
+
+ *JobName*: Job
+ *Namespace*: namespace
+
+
+
+ *Loglink*: Log
+ *Runbook*: https://runbooks.example.com/job`
+	assert.Equal(t, expect, actual)
+}
+
+func TestNotifyFailedRunbookButton(t *testing.T) {
+
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, config: slackConfig{username: "job_notifier"}, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{
+		JobName: "the-job",
+		Annotations: map[string]string{
+			"job-notify/runbook-url": "https://runbooks.example.com/the-job",
+		},
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"url":"https://runbooks.example.com/the-job"`)
+	assert.Contains(t, values.Get("attachments"), "*Runbook*: https://runbooks.example.com/the-job")
+}
+
+func TestNotifySuspendState(t *testing.T) {
+	tests := []struct {
+		Name        string
+		notify      string
+		annotations map[string]string
+		resumed     bool
+
+		expectedChannel string
+		expectedTitle   string
+		expectedColor   string
+		notifyCalled    bool
+	}{
+		{"Notify turned off", "false", map[string]string{}, false, "default_channel", "", "", false},
+		{
+			"Notify suppressed in annotations", "true",
+			map[string]string{"kube-job-notifier/suppress-suspended-notification": "true"},
+			false, "default_channel", "", "", false,
+		},
+		{"Suspended", "true", map[string]string{}, false, "default_channel", "Job Suspended", "warning", true},
+		{"Resumed", "true", map[string]string{}, true, "default_channel", "Job Resumed", "good", true},
+		{
+			"Channel overwritten in annotations", "true",
+			map[string]string{"kube-job-notifier/suspended-channel": "from-annotations"},
+			false, "from-annotations", "Job Suspended", "warning", true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+
+			var options []slackapi.MsgOption
+			mc := &MockSlackClient{}
+			if test.notifyCalled {
+				mc.On("PostMessage", test.expectedChannel, mock.AnythingOfType("[]slack.MsgOption")).
+					Run(func(args mock.Arguments) {
+						options = args.Get(1).([]slackapi.MsgOption)
+					}).
+					Return(test.expectedChannel, "timestamp", nil)
+			}
+
+			s := slack{
+				client:  mc,
+				config:  slackConfig{username: "job_notifier", disableSuspended: test.notify == "false"},
+				channel: "default_channel",
+			}
+			param := MessageTemplateParam{JobName: "the-job", Annotations: test.annotations}
+			var err error
+			if test.resumed {
+				_, err = s.NotifyResumed(param)
+			} else {
+				_, err = s.NotifySuspended(param)
+			}
+
+			assert.NoError(t, err)
+			mc.AssertExpectations(t)
+			if test.notifyCalled {
+				_, values, err := slackapi.UnsafeApplyMsgOptions("token", test.expectedChannel, "https://slack.com/api/", options...)
+				assert.NoError(t, err)
+				assert.Contains(t, values.Get("attachments"), `"title":"`+test.expectedTitle+`"`)
+				assert.Contains(t, values.Get("attachments"), `"color":"`+test.expectedColor+`"`)
+			}
+		})
+	}
+}
+
+func TestUploadLogs(t *testing.T) {
+	podLogs := func(n int) []PodLog {
+		res := make([]PodLog, 0, n)
+		for i := 0; i < n; i++ {
+			res = append(res, PodLog{PodName: fmt.Sprintf("the-job-%d", i), Log: fmt.Sprintf("log %d", i)})
+		}
+		return res
+	}
+
+	tests := []struct {
+		Name            string
+		log             string
+		podLogs         []PodLog
+		maxLogFiles     int
+		expectedTitles  []string
+		expectedLogLink string
+	}{
+		{
+			"Single log",
+			"log",
+			nil,
+			5,
+			[]string{"namespace_the-job"},
+			"https://files/namespace_the-job",
+		},
+		{
+			"Single failed pod",
+			"",
+			podLogs(1),
+			5,
+			[]string{"namespace_the-job"},
+			"https://files/namespace_the-job",
+		},
+		{
+			"One file per failed pod",
+			"log",
+			podLogs(3),
+			5,
+			[]string{"namespace_the-job-0", "namespace_the-job-1", "namespace_the-job-2"},
+			"https://files/namespace_the-job-0 https://files/namespace_the-job-1 https://files/namespace_the-job-2",
+		},
+		{
+			"Capped by max log files",
+			"log",
+			podLogs(4),
+			2,
+			[]string{"namespace_the-job-0", "namespace_the-job-1"},
+			"https://files/namespace_the-job-0 https://files/namespace_the-job-1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mc := &MockSlackClient{}
+			for _, title := range test.expectedTitles {
+				mc.On("UploadFile", mock.MatchedBy(func(params slackapi.FileUploadParameters) bool {
+					return params.Title == title
+				})).Return(&slackapi.File{Name: title, Permalink: "https://files/" + title}, nil).Once()
+			}
+
+			s := slack{client: mc, config: slackConfig{maxLogFiles: test.maxLogFiles}, channel: "failed-channel"}
+			links, err := s.uploadLogs(MessageTemplateParam{
+				JobName:   "the-job",
+				Namespace: "namespace",
+				Log:       test.log,
+				PodLogs:   test.podLogs,
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedLogLink, links)
+			mc.AssertExpectations(t)
+			mc.AssertNumberOfCalls(t, "UploadFile", len(test.expectedTitles))
+		})
+	}
+}
+
+func TestUploadLogsDedup(t *testing.T) {
+	restore := flextime.Set(time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC))
+	defer restore()
+
+	mc := &MockSlackClient{}
+	for _, title := range []string{"namespace_the-cronjob-1", "namespace_the-cronjob-3", "namespace_the-cronjob-4"} {
+		mc.On("UploadFile", mock.MatchedBy(func(params slackapi.FileUploadParameters) bool {
+			return params.Title == title
+		})).Return(&slackapi.File{Name: title, Permalink: "https://files/" + title}, nil).Once()
+	}
+	s := slack{client: mc, channel: "failed-channel", logUploads: newLogUploads(time.Hour)}
+	upload := func(jobName string, log string) string {
+		links, err := s.uploadLogs(MessageTemplateParam{JobName: jobName, CronJobName: "the-cronjob", Namespace: "namespace", Log: log})
+		assert.NoError(t, err)
+		return links
+	}
+
+	assert.Equal(t, "https://files/namespace_the-cronjob-1", upload("the-cronjob-1", "connection refused"))
+	mc.AssertNumberOfCalls(t, "UploadFile", 1)
+
+	// The next run of the CronJob fails the same way.
+	assert.Equal(t, "https://files/namespace_the-cronjob-1", upload("the-cronjob-2", "connection refused"))
+	mc.AssertNumberOfCalls(t, "UploadFile", 1)
+
+	assert.Equal(t, "https://files/namespace_the-cronjob-3", upload("the-cronjob-3", "timeout"))
+	mc.AssertNumberOfCalls(t, "UploadFile", 2)
+
+	restore = flextime.Set(time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC))
+	defer restore()
+	assert.Equal(t, "https://files/namespace_the-cronjob-4", upload("the-cronjob-4", "timeout"), "the window passed")
+	mc.AssertNumberOfCalls(t, "UploadFile", 3)
+	mc.AssertExpectations(t)
+}
+
+func TestNotifyFailedJobYAML(t *testing.T) {
+	tests := []struct {
+		Name          string
+		attach        bool
+		uploadCalled  bool
+		expectedInMsg string
+	}{
+		{"Attach turned off", false, false, ""},
+		{"Attach turned on", true, true, "*JobYAML*: https://files/namespace_the-job.yaml"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+
+			var options []slackapi.MsgOption
+			mc := &MockSlackClient{}
+			if test.uploadCalled {
+				mc.On("UploadFile", slackapi.FileUploadParameters{
+					Title:    "namespace_the-job.yaml",
+					Content:  "kind: Job",
+					Filetype: "yaml",
+					Channels: []string{"default_channel"},
+				}).Return(&slackapi.File{Name: "namespace_the-job.yaml", Permalink: "https://files/namespace_the-job.yaml"}, nil)
+			}
+			mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+				Run(func(args mock.Arguments) {
+					options = args.Get(1).([]slackapi.MsgOption)
+				}).
+				Return("default_channel", "timestamp", nil)
+
+			s := slack{client: mc, config: slackConfig{attachJobYAML: test.attach}, channel: "default_channel"}
+			_, err := s.NotifyFailed(MessageTemplateParam{
+				JobName:   "the-job",
+				Namespace: "namespace",
+				JobYAML:   "kind: Job",
+			})
+
+			assert.NoError(t, err)
+			mc.AssertExpectations(t)
+			_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+			assert.NoError(t, err)
+			if test.uploadCalled {
+				assert.Contains(t, values.Get("attachments"), test.expectedInMsg)
+			} else {
+				assert.NotContains(t, values.Get("attachments"), "JobYAML")
+			}
+		})
+	}
+}
+
+func TestNotifyFailedTimedOut(t *testing.T) {
+
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{
+		JobName:               "the-job",
+		FailedCount:           1,
+		TimedOut:              true,
+		ActiveDeadlineSeconds: 600,
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"title":"Job Timed Out"`)
+	assert.Contains(t, values.Get("attachments"), `"color":"warning"`)
+	assert.Contains(t, values.Get("attachments"), "*ActiveDeadlineSeconds*: 600")
+}
+
+func TestParseKeyValues(t *testing.T) {
+	assert.Equal(t, map[string]string{}, parseKeyValues(""))
+	assert.Equal(t,
+		map[string]string{"ns1": "chan1", "ns2": "chan2"},
+		parseKeyValues("ns1:chan1, ns2:chan2,invalid,:empty"))
+}
+
+func TestNotifyUsernamePerChannel(t *testing.T) {
+	tests := []struct {
+		Name             string
+		annotations      map[string]string
+		expectedChannel  string
+		expectedUsername string
+	}{
+		{"Default channel uses SLACK_USERNAME", map[string]string{}, "default_channel", "job_notifier"},
+		{
+			"Routed channel uses its username",
+			map[string]string{"kube-job-notifier/started-channel": "payments"},
+			"payments",
+			"Payments Jobs",
+		},
+		{
+			"Routed channel without username falls back",
+			map[string]string{"kube-job-notifier/started-channel": "search"},
+			"search",
+			"job_notifier",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+
+			var options []slackapi.MsgOption
+			mc := &MockSlackClient{}
+			mc.On("PostMessage", test.expectedChannel, mock.AnythingOfType("[]slack.MsgOption")).
+				Run(func(args mock.Arguments) {
+					options = args.Get(1).([]slackapi.MsgOption)
+				}).
+				Return(test.expectedChannel, "timestamp", nil)
+
+			s := slack{
+				client: mc,
+				config: slackConfig{
+					username:         "job_notifier",
+					channelUsernames: map[string]string{"payments": "Payments Jobs"},
+				},
+				channel: "default_channel",
+			}
+			_, err := s.NotifyStart(MessageTemplateParam{JobName: "the-job", Annotations: test.annotations})
+			assert.NoError(t, err)
+			mc.AssertExpectations(t)
+
+			_, values, err := slackapi.UnsafeApplyMsgOptions("token", test.expectedChannel, "https://slack.com/api/", options...)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedUsername, values.Get("username"))
+		})
+	}
+}
+
+func TestGetSlackMessageEscapesMrkdwn(t *testing.T) {
+	actual, err := slackConfig{}.getMessage(MessageTemplateParam{
+		JobName:     "weird_*name",
+		CronJobName: "a<b>&c",
+		Namespace:   "name~space`",
+	})
+
+	assert.Empty(t, err)
+	expect := "\n" +
+		" *CronJobName*: a&lt;b&gt;&amp;c\n" +
+		" *JobName*: weird\u200b_\u200b\u200b*\u200bname\n" +
+		" *Namespace*: name\u200b~\u200bspace\u200b`\u200b\n\n\n\n"
+	assert.Equal(t, expect, actual)
+}
+
+func TestNotifyNamespaceThread(t *testing.T) {
+	restore := flextime.Set(time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	var threadTSs []string
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options := args.Get(1).([]slackapi.MsgOption)
+			_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+			assert.NoError(t, err)
+			threadTSs = append(threadTSs, values.Get("thread_ts"))
+		}).
+		Return("default_channel", "parent-1", nil).Once()
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options := args.Get(1).([]slackapi.MsgOption)
+			_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+			assert.NoError(t, err)
+			threadTSs = append(threadTSs, values.Get("thread_ts"))
+		}).
+		Return("default_channel", "parent-2", nil)
+
+	s := slack{client: mc, channel: "default_channel", threads: newNamespaceThreads()}
+	param := MessageTemplateParam{JobName: "the-job", Namespace: "namespace"}
+
+	// creates the thread, then replies into it
+	_, err := s.NotifyStart(param)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "parent-1"}, threadTSs)
+
+	// reuses the thread within the same day
+	restore = flextime.Set(time.Date(2020, 11, 28, 23, 59, 0, 0, time.UTC))
+	defer restore()
+	_, err = s.NotifyStart(param)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "parent-1", "parent-1"}, threadTSs)
+
+	// rolls over to a new thread the next day
+	restore = flextime.Set(time.Date(2020, 11, 29, 0, 0, 1, 0, time.UTC))
+	defer restore()
+	_, err = s.NotifyStart(param)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "parent-1", "parent-1", "", "parent-2"}, threadTSs)
+
+	// other namespaces get their own thread
+	_, err = s.NotifyStart(MessageTemplateParam{JobName: "the-job", Namespace: "other"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "parent-1", "parent-1", "", "parent-2", "", "parent-2"}, threadTSs)
+	assert.Equal(t, "parent-2", s.threads.get("default_channel", "other"))
+}
+
+func TestNotifyFailedOOMKilled(t *testing.T) {
+
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{
+		JobName:            "the-job",
+		FailedCount:        1,
+		OOMKilledContainer: "worker",
+		MemoryLimit:        "256Mi",
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"title":"Job Failed (OOMKilled)"`)
+	assert.Contains(t, values.Get("attachments"), ":boom: *OOMKilled*: worker (memory limit 256Mi)")
+}
+
+func TestNotifyFailedTimedOutOOMKilled(t *testing.T) {
+
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{
+		JobName:               "the-job",
+		FailedCount:           1,
+		TimedOut:              true,
+		ActiveDeadlineSeconds: 600,
+		OOMKilledContainer:    "worker",
+		MemoryLimit:           "256Mi",
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"title":"Job Timed Out"`)
+	assert.Contains(t, values.Get("attachments"), ":boom: *OOMKilled*: worker (memory limit 256Mi)")
+}
+
+func TestNotifyFailedRetrying(t *testing.T) {
+
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: 1, BackoffLimit: 3})
+	assert.NoError(t, err)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"title":"Job Failed, Retrying"`)
+	assert.Contains(t, values.Get("attachments"), `"color":"warning"`)
+}
+
+func TestNotifyFailedPodStuck(t *testing.T) {
+
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{
+		JobName:          "the-job",
+		WaitingContainer: "worker",
+		WaitingReason:    "ImagePullBackOff",
+		WaitingMessage:   "Back-off pulling image",
+	})
+	assert.NoError(t, err)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"title":"Job Stuck (ImagePullBackOff)"`)
+	assert.Contains(t, values.Get("attachments"), `"color":"warning"`)
+	assert.Contains(t, values.Get("attachments"), ":warning: *ImagePullBackOff*: worker (Back-off pulling image)")
+}
+
+func TestNotifyFailedPodsLost(t *testing.T) {
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{JobName: "the-job", LostPods: 3, PeakActivePods: 4})
+	assert.NoError(t, err)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"title":"Job Pods Lost"`)
+	assert.Contains(t, values.Get("attachments"), `"color":"warning"`)
+	assert.Contains(t, values.Get("attachments"), ":warning: *LostPods*: 3 of 4 running pods")
+}
+
+func TestNotifyAttachmentSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		notify   func(s slack, messageParam MessageTemplateParam) (NotifyResult, error)
+		fallback string
+	}{
+		{"start", slack.NotifyStart, "Job Start: the-job in test-ns"},
+		{"success", slack.NotifySuccess, "Job Success: the-job in test-ns"},
+		{"failed", slack.NotifyFailed, "Job Failed: the-job in test-ns"},
+		{"suspended", slack.NotifySuspended, "Job Suspended: the-job in test-ns"},
+		{"resumed", slack.NotifyResumed, "Job Resumed: the-job in test-ns"},
+		{"spec changed", slack.NotifySpecChanged, "Job Spec Changed: the-job in test-ns"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var options []slackapi.MsgOption
+			mc := &MockSlackClient{}
+			mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+				Run(func(args mock.Arguments) {
+					options = args.Get(1).([]slackapi.MsgOption)
+				}).
+				Return("default_channel", "timestamp", nil)
+
+			s := slack{client: mc, config: slackConfig{pretext: "Nightly batch"}, channel: "default_channel"}
+			_, err := test.notify(s, MessageTemplateParam{JobName: "the-job", Namespace: "test-ns", FailedCount: 1})
+			assert.NoError(t, err)
+
+			_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+			assert.NoError(t, err)
+			var attachments []slackapi.Attachment
+			assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+			assert.Equal(t, test.fallback, attachments[0].Fallback)
+			assert.Equal(t, "Nightly batch", attachments[0].Pretext)
+		})
+	}
+}
+
+func TestSlackClusterName(t *testing.T) {
+	message, err := slackConfig{}.getMessage(MessageTemplateParam{ClusterName: "prod-tokyo", JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(message, "\n *Cluster*: prod-tokyo\n\n *JobName*: the-job\n"), message)
+
+	message, err = slackConfig{}.getMessage(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.NotContains(t, message, "*Cluster*")
+
+	attachment := slack{}.withSummary(MessageTemplateParam{ClusterName: "prod-tokyo", JobName: "the-job", Namespace: "test-ns"}, slackapi.Attachment{Title: "Job Failed"})
+	assert.Equal(t, "Job Failed: the-job in test-ns (prod-tokyo)", attachment.Fallback)
+}
+
+func TestNotifyMessageFormatFields(t *testing.T) {
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, config: slackConfig{messageFormat: messageFormatFields}, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job-28000000",
+		CronJobName: "the-job",
+		Namespace:   "test-ns",
+		StartTime:   &metav1.Time{Time: time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)},
+		NodeName:    "node-1",
+	})
+	assert.NoError(t, err)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	var attachments []slackapi.Attachment
+	assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+	assert.Equal(t, []slackapi.AttachmentField{
+		{Title: "JobName", Value: "the-job-28000000", Short: true},
+		{Title: "CronJobName", Value: "the-job", Short: true},
+		{Title: "Namespace", Value: "test-ns", Short: true},
+		{Title: "Status", Value: "Job Failed", Short: true},
+		{Title: "ExecutionTime", Value: attachments[0].Fields[4].Value, Short: true},
+		{Title: "StartTime", Value: "2020/11/28 01:00:00 UTC", Short: true},
+		{Title: "CompletionTime", Value: attachments[0].Fields[6].Value, Short: true},
+	}, attachments[0].Fields)
+	// Only the details are left in the text.
+	assert.Equal(t, "\n *Node*: node-1\n\n *Investigate*:\n```\nkubectl logs -n test-ns job/the-job-28000000\nkubectl describe job -n test-ns the-job-28000000\n```", attachments[0].Text)
+}
+
+func TestNotifyMessageFormatText(t *testing.T) {
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, config: slackConfig{messageFormat: messageFormatText}, channel: "default_channel"}
+	_, err := s.NotifyStart(MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+	assert.NoError(t, err)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.NotContains(t, values.Get("attachments"), `"fields"`)
+	assert.Contains(t, values.Get("attachments"), "*JobName*: the-job")
+}
+
+func TestNotifyEventEmoji(t *testing.T) {
+	tests := []struct {
+		name     string
+		notify   func(s slack, messageParam MessageTemplateParam) (NotifyResult, error)
+		event    string
+		expected string
+	}{
+		{"start", slack.NotifyStart, START, ":rocket:  *JobName*: the-job\n"},
+		{"success", slack.NotifySuccess, SUCCESS, ":tada:  *JobName*: the-job\n"},
+		{"failed", slack.NotifyFailed, FAILED, ":x:  *JobName*: the-job\n"},
+		{"suspended", slack.NotifySuspended, SUSPENDED, "\n\n *JobName*: the-job\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var options []slackapi.MsgOption
+			mc := &MockSlackClient{}
+			mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+				Run(func(args mock.Arguments) {
+					options = args.Get(1).([]slackapi.MsgOption)
+				}).
+				Return("default_channel", "timestamp", nil)
+
+			config := slackConfig{eventEmojis: map[string]string{START: ":rocket:", SUCCESS: ":tada:", FAILED: ":x:"}}
+			s := slack{client: mc, config: config, channel: "default_channel"}
+			_, err := test.notify(s, MessageTemplateParam{Event: test.event, JobName: "the-job", FailedCount: 1})
+			assert.NoError(t, err)
+
+			_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+			assert.NoError(t, err)
+			var attachments []slackapi.Attachment
+			assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+			assert.True(t, strings.HasPrefix(attachments[0].Text, test.expected), attachments[0].Text)
+		})
+	}
+}
+
+func TestGetSlackMessageNode(t *testing.T) {
+	message, err := slackConfig{}.getMessage(MessageTemplateParam{JobName: "the-job", NodeName: "node-a", Zone: "us-east-1a"})
+	assert.NoError(t, err)
+	assert.Contains(t, message, " *Node*: node-a (us-east-1a)")
+
+	message, err = slackConfig{}.getMessage(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.NotContains(t, message, "*Node*")
+}
+
+func TestGetSlackMessageFailureDetailsFirst(t *testing.T) {
+	param := MessageTemplateParam{
+		Event:     FAILED,
+		JobName:   "the-job",
+		Namespace: "test-ns",
+		Reason:    "BackoffLimitExceeded",
+		ExitCode:  137,
+		Log:       "https://files.slack.com/the-job.log",
+		LogURL:    "https://logs.example.com/the-job",
+	}
+
+	tests := []struct {
+		name     string
+		config   slackConfig
+		event    string
+		expected []string
+	}{
+		{
+			"details first",
+			slackConfig{failureDetailsFirst: true},
+			FAILED,
+			[]string{" *Reason*: BackoffLimitExceeded", " *ExitCode*: 137", " *Loglink*: ", " *Logs*: ", " *JobName*: the-job", " *Namespace*: test-ns"},
+		},
+		{
+			"details first with fields",
+			slackConfig{failureDetailsFirst: true, messageFormat: messageFormatFields},
+			FAILED,
+			[]string{" *Reason*: BackoffLimitExceeded", " *ExitCode*: 137", " *Loglink*: ", " *Logs*: "},
+		},
+		{
+			"details first in Japanese",
+			slackConfig{failureDetailsFirst: true, locale: localeJapanese},
+			FAILED,
+			[]string{" *理由*: BackoffLimitExceeded", " *終了コード*: 137", " *ログ*: ", " *Job名*: the-job"},
+		},
+		{
+			"metadata first by default",
+			slackConfig{},
+			FAILED,
+			[]string{" *JobName*: the-job", " *Namespace*: test-ns", " *Loglink*: "},
+		},
+		{
+			"metadata first for successes",
+			slackConfig{failureDetailsFirst: true},
+			SUCCESS,
+			[]string{" *JobName*: the-job", " *Loglink*: "},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			param.Event = test.event
+			message, err := test.config.getMessage(param)
+			assert.NoError(t, err)
+
+			last := -1
+			for _, line := range test.expected {
+				i := strings.Index(message, line)
+				assert.Greater(t, i, last, "%q is out of order in %q", line, message)
+				last = i
+			}
+			// Every line is shown once.
+			assert.Equal(t, 1, strings.Count(message, param.LogURL))
+		})
+	}
+}
+
+func TestGetSlackMessageBatchSummary(t *testing.T) {
+	message, err := slackConfig{}.getMessage(MessageTemplateParam{JobName: "nightly-b", BatchSummary: "3 jobs: 2 succeeded, 1 failed (nightly-b)"})
+	assert.NoError(t, err)
+	assert.Contains(t, message, "\n *Batch*: 3 jobs: 2 succeeded, 1 failed (nightly-b)")
+
+	message, err = slackConfig{}.getMessage(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.NotContains(t, message, "*Batch*")
+}
+
+func TestGetSlackMessageSpecChanges(t *testing.T) {
+	message, err := slackConfig{}.getMessage(MessageTemplateParam{
+		JobName:     "the-job",
+		SpecChanges: []string{"image of container worker: worker:1.0 → worker:1.1", "parallelism: 1 → 3"},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, message, "\n *Changes*:\n • image of container worker: worker:1.0 → worker:1.1\n • parallelism: 1 → 3")
+
+	message, err = slackConfig{}.getMessage(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.NotContains(t, message, "*Changes*")
+}
+
+func TestGetSlackMessageEvents(t *testing.T) {
+	message, err := slackConfig{}.getMessage(MessageTemplateParam{
+		JobName: "the-job",
+		Events: []string{
+			"FailedScheduling (Pod/the-job-abcde): 0/3 nodes are available",
+			"BackOff (Pod/the-job-abcde): Back-off restarting failed container",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, message, "\n *Events*:\n • FailedScheduling (Pod/the-job-abcde): 0/3 nodes are available\n • BackOff (Pod/the-job-abcde): Back-off restarting failed container\n")
+
+	message, err = slackConfig{}.getMessage(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.NotContains(t, message, "*Events*")
+}
+
+func TestNotifyFailedReaction(t *testing.T) {
+
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Return("C0123", "1234.5678", nil)
+	mc.On("AddReaction", "fire", slackapi.NewRefToMessage("C0123", "1234.5678")).
+		Return(errors.New("already_reacted")).Once()
+
+	s := slack{client: mc, config: slackConfig{failureReaction: "fire"}, channel: "default_channel"}
+	result, err := s.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: 1})
+
+	// A failed reaction doesn't fail the notification.
+	assert.NoError(t, err)
+	assert.Equal(t, "1234.5678", result.Timestamp)
+	mc.AssertExpectations(t)
+}
+
+func TestNotifyFailedWithoutReaction(t *testing.T) {
+
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Return("C0123", "1234.5678", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: 1})
+
+	assert.NoError(t, err)
+	mc.AssertNotCalled(t, "AddReaction", mock.Anything, mock.Anything)
+}
+
+func TestNotifyNamespaceChannel(t *testing.T) {
+	tests := []struct {
+		Name            string
+		namespace       string
+		annotations     map[string]string
+		failedChannel   string
+		expectedChannel string
+	}{
+		{"Unmapped namespace uses default channel", "other", nil, "", "default_channel"},
+		{"Mapped namespace", "payments", nil, "", "payments-jobs"},
+		{"Mapped namespace overrides SLACK_FAILED_CHANNEL", "payments", nil, "failed_channel", "payments-jobs"},
+		{"Unmapped namespace uses SLACK_FAILED_CHANNEL", "other", nil, "failed_channel", "failed_channel"},
+		{
+			"Annotation overrides mapped namespace",
+			"payments",
+			map[string]string{"kube-job-notifier/failed-channel": "annotated"},
+			"",
+			"annotated",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mc := &MockSlackClient{}
+			mc.On("PostMessage", test.expectedChannel, mock.AnythingOfType("[]slack.MsgOption")).
+				Return(test.expectedChannel, "timestamp", nil)
+
+			s := slack{
+				client: mc,
+				config: slackConfig{
+					failedChannel:     test.failedChannel,
+					namespaceChannels: parseKeyValues("payments:payments-jobs,search:search-jobs"),
+				},
+				channel: "default_channel",
+			}
+			_, err := s.NotifyFailed(MessageTemplateParam{
+				JobName:     "the-job",
+				Namespace:   test.namespace,
+				Annotations: test.annotations,
+				FailedCount: 1,
+			})
+			assert.NoError(t, err)
+			mc.AssertExpectations(t)
+		})
+	}
+}
+
+type blockingSlackClient struct {
+	mu        sync.Mutex
+	running   int
+	maxActive int
+	release   chan struct{}
+}
+
+func (c *blockingSlackClient) PostMessage(channelID string, options ...slackapi.MsgOption) (string, string, error) {
+	return channelID, "timestamp", nil
+}
+
+func (c *blockingSlackClient) ScheduleMessage(channelID, postAt string, options ...slackapi.MsgOption) (string, string, error) {
+	return channelID, postAt, nil
+}
+
+func (c *blockingSlackClient) AddReaction(name string, item slackapi.ItemRef) error {
+	return nil
+}
+
+func (c *blockingSlackClient) GetPermalink(params *slackapi.PermalinkParameters) (string, error) {
+	return "", nil
+}
+
+func (c *blockingSlackClient) UploadFile(params slackapi.FileUploadParameters) (file *slackapi.File, err error) {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.maxActive {
+		c.maxActive = c.running
+	}
+	c.mu.Unlock()
+
+	<-c.release
+
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	return &slackapi.File{Name: params.Title}, nil
+}
+
+func (c *blockingSlackClient) active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running
+}
+
+func TestUploadConcurrencyLimit(t *testing.T) {
+	const limit = 2
+	mc := &blockingSlackClient{release: make(chan struct{})}
+	s := slack{client: mc, channel: "default_channel", uploads: make(chan struct{}, limit)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.uploadLog(fmt.Sprintf("log-%d", i), "log")
+			assert.NoError(t, err)
+		}(i)
+	}
+
+	assert.Eventually(t, func() bool { return mc.active() == limit }, time.Second, time.Millisecond)
+	// the remaining uploads are queued while the first ones are blocked
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, limit, mc.active())
+
+	close(mc.release)
+	wg.Wait()
+	assert.Equal(t, limit, mc.maxActive)
+}
+
+func TestNotifyResult(t *testing.T) {
+	tests := []struct {
+		Name        string
+		notify      string
+		annotations map[string]string
+		expected    NotifyResult
+	}{
+		{
+			"Sent",
+			"true",
+			nil,
+			NotifyResult{
+				Channel:   "C0123",
+				Timestamp: "1606525323.000100",
+				Permalink: "https://example.slack.com/archives/C0123/p1606525323.000100",
+			},
+		},
+		{"Disabled", "false", nil, NotifyResult{SkippedReason: SkippedDisabled}},
+		{
+			"Suppressed",
+			"true",
+			map[string]string{"kube-job-notifier/suppress-failed-notification": "true"},
+			NotifyResult{SkippedReason: SkippedSuppressed},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mc := &MockSlackClient{}
+			mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+				Return("C0123", "1606525323.000100", nil).Maybe()
+
+			s := slack{client: mc, config: slackConfig{disableFailed: test.notify == "false"}, channel: "default_channel"}
+			result, err := s.NotifyFailed(MessageTemplateParam{JobName: "the-job", Annotations: test.annotations, FailedCount: 1})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func TestUploadLogChannel(t *testing.T) {
+	tests := []struct {
+		Name            string
+		failed          bool
+		annotations     map[string]string
+		expectedChannel string
+	}{
+		{"Failed job uses SLACK_FAILED_CHANNEL", true, nil, "failed_channel"},
+		{"Succeeded job uses SLACK_SUCCEED_CHANNEL", false, nil, "succeed_channel"},
+		{
+			"Annotation channel",
+			true,
+			map[string]string{"kube-job-notifier/failed-channel": "annotated"},
+			"annotated",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mc := &MockSlackClient{}
+			mc.On("UploadFile", mock.MatchedBy(func(params slackapi.FileUploadParameters) bool {
+				return assert.Equal(t, []string{test.expectedChannel}, params.Channels)
+			})).Return(&slackapi.File{Name: "log", Permalink: "https://files/log"}, nil).Once()
+			mc.On("PostMessage", test.expectedChannel, mock.AnythingOfType("[]slack.MsgOption")).
+				Return(test.expectedChannel, "timestamp", nil).Once()
+
+			s := slack{
+				client: mc,
+				config: slackConfig{
+					succeedChannel: "succeed_channel",
+					failedChannel:  "failed_channel",
+					maxLogFiles:    defaultMaxLogFiles,
+				},
+				channel: "default_channel",
+			}
+			param := MessageTemplateParam{JobName: "the-job", Namespace: "namespace", Log: "log", Annotations: test.annotations, FailedCount: 1}
+			var err error
+			if test.failed {
+				_, err = s.NotifyFailed(param)
+			} else {
+				_, err = s.NotifySuccess(param)
+			}
+
+			assert.NoError(t, err)
+			mc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetSlackMessageResourceWarnings(t *testing.T) {
+	message, err := slackConfig{}.getMessage(MessageTemplateParam{
+		JobName:          "the-job",
+		ResourceWarnings: []string{"container worker peaked at 240Mi of its 256Mi memory limit (93%)"},
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, message, "\n :warning: container worker peaked at 240Mi of its 256Mi memory limit (93%)")
+}
+
+func TestGetSlackMessageConvertMarkdown(t *testing.T) {
+	param := MessageTemplateParam{JobName: "the-job", Log: "[job-log](https://files/log)"}
+
+	message, err := slackConfig{}.getMessage(param)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "*Loglink*: [job-log](https://files/log)")
+
+	message, err = slackConfig{convertMarkdown: true}.getMessage(param)
+	assert.NoError(t, err)
+	assert.Contains(t, message, "*JobName*: the-job")
+	assert.Contains(t, message, "*Loglink*: <https://files/log|job-log>")
+}
+
+func TestGetSlackMessageTemplateAnnotation(t *testing.T) {
+	config := slackConfig{messageTemplate: template.Must(template.New("slack").Parse("global {{.JobName}}"))}
+
+	message, err := config.getMessage(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{templateAnnotationName: "{{.JobName}} in {{.Namespace}}"},
+		Namespace:   "batch",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "the-job in batch", message)
+
+	message, err = config.getMessage(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{templateAnnotationName: "{{.JobName"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "global the-job", message, "an invalid annotation falls back to the global template")
+
+	message, err = slackConfig{}.getMessage(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Contains(t, message, "*JobName*: the-job")
+}
+
+func TestNextTimeOfDay(t *testing.T) {
+	at, err := parseTimeOfDay("09:30")
+	assert.NoError(t, err)
+	assert.Equal(t, 9*time.Hour+30*time.Minute, at)
+
+	_, err = parseTimeOfDay("9am")
+	assert.Error(t, err)
+
+	tests := []struct {
+		Name     string
+		now      time.Time
+		expected time.Time
+	}{
+		{"Later today", time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC), time.Date(2020, 11, 28, 9, 30, 0, 0, time.UTC)},
+		{"Tomorrow", time.Date(2020, 11, 28, 10, 0, 0, 0, time.UTC), time.Date(2020, 11, 29, 9, 30, 0, 0, time.UTC)},
+		{"Exactly now is tomorrow", time.Date(2020, 11, 28, 9, 30, 0, 0, time.UTC), time.Date(2020, 11, 29, 9, 30, 0, 0, time.UTC)},
+		{"Other time zone", time.Date(2020, 11, 28, 10, 0, 0, 0, time.FixedZone("JST", 9*60*60)), time.Date(2020, 11, 28, 9, 30, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.expected, nextTimeOfDay(test.now, at))
+		})
+	}
+}
+
+func TestNotifySuccessScheduled(t *testing.T) {
+	restore := flextime.Set(time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	at := 9 * time.Hour
+	postAt := strconv.FormatInt(time.Date(2020, 11, 28, 9, 0, 0, 0, time.UTC).Unix(), 10)
+	mc := &MockSlackClient{}
+	mc.On("ScheduleMessage", "default_channel", postAt, mock.AnythingOfType("[]slack.MsgOption")).
+		Return("default_channel", postAt, nil).Once()
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Return("default_channel", "timestamp", nil).Once()
+
+	s := slack{client: mc, config: slackConfig{successScheduleAt: &at}, channel: "default_channel"}
+	result, err := s.NotifySuccess(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Equal(t, NotifyResult{Channel: "default_channel", Timestamp: postAt}, result)
+
+	// failures are posted immediately
+	_, err = s.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: 1})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+}
+
+func TestUploadLogsOn(t *testing.T) {
+	tests := []struct {
+		uploadLogsOn    string
+		successUploaded bool
+		failedUploaded  bool
+	}{
+		{"", true, true},
+		{uploadLogsAlways, true, true},
+		{uploadLogsFailure, false, true},
+		{uploadLogsNever, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.uploadLogsOn, func(t *testing.T) {
+			for _, failed := range []bool{false, true} {
+				var options []slackapi.MsgOption
+				mc := &MockSlackClient{}
+				mc.On("UploadFile", mock.AnythingOfType("slack.FileUploadParameters")).
+					Return(&slackapi.File{Name: "log", Permalink: "https://files/log"}, nil)
+				mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+					Run(func(args mock.Arguments) {
+						options = args.Get(1).([]slackapi.MsgOption)
+					}).
+					Return("default_channel", "timestamp", nil)
+
+				s := slack{client: mc, config: slackConfig{uploadLogsOn: test.uploadLogsOn}, channel: "default_channel"}
+				param := MessageTemplateParam{JobName: "the-job", Namespace: "namespace", Log: "the log"}
+				uploaded := test.successUploaded
+				var err error
+				if failed {
+					uploaded = test.failedUploaded
+					_, err = s.NotifyFailed(param)
+				} else {
+					_, err = s.NotifySuccess(param)
+				}
+				assert.NoError(t, err)
+
+				_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+				assert.NoError(t, err)
+				if uploaded {
+					mc.AssertNumberOfCalls(t, "UploadFile", 1)
+					assert.Contains(t, values.Get("attachments"), "https://files/log")
+				} else {
+					mc.AssertNotCalled(t, "UploadFile", mock.Anything)
+					assert.NotContains(t, values.Get("attachments"), "the log")
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyLongMessageMovedToFile(t *testing.T) {
+	longName := strings.Repeat("x", 300)
+
+	var options []slackapi.MsgOption
+	var uploaded slackapi.FileUploadParameters
+	mc := &MockSlackClient{}
+	mc.On("UploadFile", mock.AnythingOfType("slack.FileUploadParameters")).
+		Run(func(args mock.Arguments) {
+			uploaded = args.Get(0).(slackapi.FileUploadParameters)
+		}).
+		Return(&slackapi.File{Name: "message", Permalink: "https://files/message"}, nil)
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, config: slackConfig{maxMessageLength: 200}, channel: "default_channel"}
+	_, err := s.NotifyStart(MessageTemplateParam{JobName: longName, Namespace: "namespace"})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	assert.Contains(t, uploaded.Content, longName)
+	assert.Equal(t, []string{"default_channel"}, uploaded.Channels)
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	var attachments []slackapi.Attachment
+	assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+	assert.Len(t, []rune(attachments[0].Text), 200)
+	assert.True(t, strings.HasSuffix(attachments[0].Text, "\n... message truncated, full message: https://files/message"))
+}
+
+func TestNotifyShortMessageNotMovedToFile(t *testing.T) {
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, config: slackConfig{maxMessageLength: defaultMaxMessageLength}, channel: "default_channel"}
+	_, err := s.NotifyStart(MessageTemplateParam{JobName: "the-job", Namespace: "namespace"})
+
+	assert.NoError(t, err)
+	mc.AssertNotCalled(t, "UploadFile", mock.Anything)
+}
+
+func TestGetSlackMessageEventTemplate(t *testing.T) {
+	t.Setenv("SLACK_MESSAGE_TEMPLATE", `{{.JobName}} {{if eq .Event "failed"}}failed after {{.FailedCount}} attempts{{else}}is {{.Event}}{{end}}`)
+	config := getTestSlackConfig(t)
+
+	tests := []struct {
+		event    string
+		expected string
+	}{
+		{START, "the-job is start"},
+		{SUCCESS, "the-job is success"},
+		{FAILED, "the-job failed after 3 attempts"},
+	}
+	for _, test := range tests {
+		t.Run(test.event, func(t *testing.T) {
+			actual, err := config.getMessage(MessageTemplateParam{Event: test.event, JobName: "the-job", FailedCount: 3})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestGetSlackMessageInvalidTemplate(t *testing.T) {
+	t.Setenv("SLACK_MESSAGE_TEMPLATE", `{{if .Event}}`)
+
+	config, err := loadSlackConfig()
+	assert.ErrorContains(t, err, "invalid SLACK_MESSAGE_TEMPLATE")
+	assert.Nil(t, config.messageTemplate)
+
+	actual, err := config.getMessage(MessageTemplateParam{Event: START, JobName: "the-job"})
+
+	assert.NoError(t, err)
+	assert.Contains(t, actual, " *JobName*: the-job")
+}
+
+func TestNotifySuccessRecovered(t *testing.T) {
+	var options []slackapi.MsgOption
+	mc := &MockSlackClient{}
+	mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+		Run(func(args mock.Arguments) {
+			options = args.Get(1).([]slackapi.MsgOption)
+		}).
+		Return("default_channel", "timestamp", nil)
+
+	s := slack{client: mc, channel: "default_channel"}
+	_, err := s.NotifySuccess(MessageTemplateParam{JobName: "the-job", Recovered: true})
+	assert.NoError(t, err)
+
+	_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+	assert.NoError(t, err)
+	assert.Contains(t, values.Get("attachments"), `"title":"Job Recovered"`)
+}
@@ -0,0 +1,159 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+const (
+	SUSPENDED    = "suspended"
+	RESUMED      = "resumed"
+	SPEC_CHANGED = "spec_changed"
+)
+
+// slackWorkflow triggers a Slack Workflow Builder webhook. Workflow variables have to be
+// flat text, so every field of the message is sent as a string.
+type slackWorkflow struct {
+	url        string
+	timeFormat timeFormat
+	client     *http.Client
+}
+
+func init() {
+	Register("slack_workflow", func() (Notification, bool) {
+		url := os.Getenv("SLACK_WORKFLOW_URL")
+		return newSlackWorkflow(url), url != ""
+	})
+}
+
+func newSlackWorkflow(url string) slackWorkflow {
+	return slackWorkflow{
+		url:        url,
+		timeFormat: getTimeFormat(),
+		client:     newSlackHTTPClient(),
+	}
+}
+
+func (w slackWorkflow) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressStartedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, w.trigger(START, messageParam)
+}
+
+func (w slackWorkflow) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	return NotifyResult{}, w.trigger(SUCCESS, messageParam)
+}
+
+func (w slackWorkflow) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	messageParam.RunbookURL = messageParam.Annotations[runbookURLAnnotationName]
+	return NotifyResult{}, w.trigger(FAILED, messageParam)
+}
+
+func (w slackWorkflow) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, w.trigger(SUSPENDED, messageParam)
+}
+
+func (w slackWorkflow) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuspendedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, w.trigger(RESUMED, messageParam)
+}
+
+func (w slackWorkflow) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSpecChangedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	return NotifyResult{}, w.trigger(SPEC_CHANGED, messageParam)
+}
+
+func (w slackWorkflow) trigger(event string, messageParam MessageTemplateParam) (err error) {
+	body, err := json.Marshal(getSlackWorkflowVariables(event, w.timeFormat, messageParam))
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("Slack workflow trigger failed %s\n", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("slack workflow returned %s", resp.Status)
+		klog.Errorf("Slack workflow trigger failed %s\n", err)
+		return err
+	}
+
+	klog.Infof("Slack workflow successfully triggered for %s", messageParam.JobName)
+	return nil
+}
+
+func getSlackWorkflowVariables(event string, format timeFormat, messageParam MessageTemplateParam) map[string]string {
+	variables := map[string]string{
+		"event":                   event,
+		"job_name":                messageParam.JobName,
+		"cronjob_name":            messageParam.CronJobName,
+		"namespace":               messageParam.Namespace,
+		"start_time":              "",
+		"completion_time":         "",
+		"execution_time":          "",
+		"log":                     messageParam.Log,
+		"log_url":                 messageParam.LogURL,
+		"index_summary":           messageParam.IndexSummary,
+		"failed_indices":          messageParam.FailedIndices,
+		"completion_warning":      messageParam.CompletionWarning,
+		"waiting_container":       messageParam.WaitingContainer,
+		"waiting_reason":          messageParam.WaitingReason,
+		"waiting_message":         messageParam.WaitingMessage,
+		"lost_pods":               strconv.Itoa(int(messageParam.LostPods)),
+		"peak_active_pods":        strconv.Itoa(int(messageParam.PeakActivePods)),
+		"failed_count":            strconv.Itoa(int(messageParam.FailedCount)),
+		"backoff_limit":           strconv.Itoa(int(messageParam.BackoffLimit)),
+		"runbook_url":             messageParam.RunbookURL,
+		"timed_out":               strconv.FormatBool(messageParam.TimedOut),
+		"active_deadline_seconds": strconv.FormatInt(messageParam.ActiveDeadlineSeconds, 10),
+		"oom_killed_container":    messageParam.OOMKilledContainer,
+		"memory_limit":            messageParam.MemoryLimit,
+		"node_name":               messageParam.NodeName,
+		"zone":                    messageParam.Zone,
+		"spec_changes":            strings.Join(messageParam.SpecChanges, "\n"),
+		"batch_summary":           messageParam.BatchSummary,
+	}
+	if messageParam.StartTime != nil {
+		variables["start_time"] = format.format(messageParam.StartTime)
+	}
+	if messageParam.CompletionTime != nil {
+		variables["completion_time"] = format.format(messageParam.CompletionTime)
+	}
+	if messageParam.ExecutionTime > 0 {
+		variables["execution_time"] = messageParam.ExecutionTime.String()
+	}
+	return variables
+}
@@ -0,0 +1,149 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSlackWorkflowVariables(t *testing.T) {
+	mockTime := time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC)
+	restore := flextime.Set(mockTime)
+	defer restore()
+
+	var received []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var variables map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&variables))
+		received = append(received, variables)
+	}))
+	defer server.Close()
+
+	param := MessageTemplateParam{
+		JobName:     "the-job-28472940",
+		CronJobName: "the-job",
+		Namespace:   "namespace",
+		StartTime:   &metav1.Time{Time: mockTime.Add(-90 * time.Second)},
+		Annotations: map[string]string{runbookURLAnnotationName: "https://runbooks.example.com/the-job"},
+	}
+	failedParam := param
+	failedParam.FailedCount = 2
+	failedParam.BackoffLimit = 1
+	failedParam.OOMKilledContainer = "worker"
+	failedParam.MemoryLimit = "256Mi"
+
+	w := newSlackWorkflow(server.URL)
+	_, err := w.NotifyStart(param)
+	assert.NoError(t, err)
+	_, err = w.NotifySuccess(param)
+	assert.NoError(t, err)
+	_, err = w.NotifyFailed(failedParam)
+	assert.NoError(t, err)
+	_, err = w.NotifySuspended(param)
+	assert.NoError(t, err)
+	_, err = w.NotifyResumed(param)
+	assert.NoError(t, err)
+	specChangedParam := param
+	specChangedParam.SpecChanges = []string{"parallelism: 1 → 3", "completions: 5 → 10"}
+	_, err = w.NotifySpecChanged(specChangedParam)
+	assert.NoError(t, err)
+
+	base := map[string]string{
+		"event":                   "",
+		"job_name":                "the-job-28472940",
+		"cronjob_name":            "the-job",
+		"namespace":               "namespace",
+		"start_time":              "2020/11/28 01:00:33 UTC",
+		"completion_time":         "",
+		"execution_time":          "",
+		"log":                     "",
+		"log_url":                 "",
+		"index_summary":           "",
+		"completion_warning":      "",
+		"failed_indices":          "",
+		"waiting_container":       "",
+		"waiting_reason":          "",
+		"waiting_message":         "",
+		"lost_pods":               "0",
+		"peak_active_pods":        "0",
+		"failed_count":            "0",
+		"backoff_limit":           "0",
+		"runbook_url":             "",
+		"timed_out":               "false",
+		"active_deadline_seconds": "0",
+		"oom_killed_container":    "",
+		"memory_limit":            "",
+		"node_name":               "",
+		"zone":                    "",
+		"spec_changes":            "",
+		"batch_summary":           "",
+	}
+	with := func(values map[string]string) map[string]string {
+		expected := make(map[string]string)
+		for k, v := range base {
+			expected[k] = v
+		}
+		for k, v := range values {
+			expected[k] = v
+		}
+		return expected
+	}
+	finished := map[string]string{
+		"completion_time": "2020/11/28 01:02:03 UTC",
+		"execution_time":  "1m30s",
+	}
+
+	assert.Equal(t, []map[string]string{
+		with(map[string]string{"event": "start"}),
+		with(map[string]string{"event": "success", "completion_time": finished["completion_time"], "execution_time": finished["execution_time"]}),
+		with(map[string]string{
+			"event":                "failed",
+			"completion_time":      finished["completion_time"],
+			"execution_time":       finished["execution_time"],
+			"failed_count":         "2",
+			"backoff_limit":        "1",
+			"runbook_url":          "https://runbooks.example.com/the-job",
+			"oom_killed_container": "worker",
+			"memory_limit":         "256Mi",
+		}),
+		with(map[string]string{"event": "suspended"}),
+		with(map[string]string{"event": "resumed"}),
+		with(map[string]string{"event": "spec_changed", "spec_changes": "parallelism: 1 → 3\ncompletions: 5 → 10"}),
+	}, received)
+}
+
+func TestSlackWorkflowSuppressed(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	w := newSlackWorkflow(server.URL)
+	_, err := w.NotifyFailed(MessageTemplateParam{
+		JobName:     "the-job",
+		Annotations: map[string]string{suppressFailedAnnotationName: "true"},
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, called)
+}
+
+func TestSlackWorkflowErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w := newSlackWorkflow(server.URL)
+	_, err := w.NotifyStart(MessageTemplateParam{JobName: "the-job"})
+
+	assert.EqualError(t, err, "slack workflow returned 400 Bad Request")
+}
@@ -0,0 +1,176 @@
+package notification
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+const (
+	defaultThreadTTL        = 48 * time.Hour
+	defaultThreadMaxEntries = 1000
+)
+
+var threadStoreSize = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "kube_job_notifier_slack_thread_store_size",
+	Help: "Number of Slack thread timestamps kept in memory.",
+})
+
+func init() {
+	prometheus.MustRegister(threadStoreSize)
+}
+
+type namespaceThread struct {
+	ts        string
+	day       string
+	createdAt time.Time
+}
+
+// namespaceThreads keeps the ts of the current daily thread per channel and namespace.
+// Entries older than ttl are dropped, and the oldest entries are evicted once maxEntries is reached.
+type namespaceThreads struct {
+	mu         sync.Mutex
+	threads    map[string]namespaceThread
+	ttl        time.Duration
+	maxEntries int
+}
+
+func newNamespaceThreads() *namespaceThreads {
+	return &namespaceThreads{
+		threads:    make(map[string]namespaceThread),
+		ttl:        getThreadTTL(),
+		maxEntries: getThreadMaxEntries(),
+	}
+}
+
+func getThreadTTL() time.Duration {
+	value := os.Getenv("SLACK_THREAD_TTL")
+	if value == "" {
+		return defaultThreadTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		klog.Errorf("Invalid SLACK_THREAD_TTL %q, using default %s: %v", value, defaultThreadTTL, err)
+		return defaultThreadTTL
+	}
+	return ttl
+}
+
+func getThreadMaxEntries() int {
+	value := os.Getenv("SLACK_THREAD_MAX_ENTRIES")
+	if value == "" {
+		return defaultThreadMaxEntries
+	}
+	maxEntries, err := strconv.Atoi(value)
+	if err != nil || maxEntries <= 0 {
+		klog.Errorf("Invalid SLACK_THREAD_MAX_ENTRIES %q, using default %d: %v", value, defaultThreadMaxEntries, err)
+		return defaultThreadMaxEntries
+	}
+	return maxEntries
+}
+
+func namespaceThreadKey(channel string, namespace string) string {
+	return channel + "/" + namespace
+}
+
+func today() string {
+	return flextime.Now().UTC().Format("2006-01-02")
+}
+
+// get returns the ts of today's thread, or an empty string when a new thread has to be started.
+func (t *namespaceThreads) get(channel string, namespace string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.lookup(channel, namespace)
+}
+
+// getOrStart returns the ts of today's thread, calling start to start it when there is none.
+// The lock is held while the thread is started, so that concurrent notifications of a
+// namespace don't start several threads.
+func (t *namespaceThreads) getOrStart(channel string, namespace string, start func() (string, error)) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ts := t.lookup(channel, namespace); ts != "" {
+		return ts, nil
+	}
+	ts, err := start()
+	if err != nil {
+		return "", err
+	}
+	t.store(channel, namespace, ts)
+	return ts, nil
+}
+
+// lookup returns the ts of today's thread. The caller must hold mu.
+func (t *namespaceThreads) lookup(channel string, namespace string) string {
+	t.expire()
+	thread, ok := t.threads[namespaceThreadKey(channel, namespace)]
+	if !ok || thread.day != today() {
+		return ""
+	}
+	return thread.ts
+}
+
+func (t *namespaceThreads) set(channel string, namespace string, ts string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.store(channel, namespace, ts)
+}
+
+// store records the ts of today's thread. The caller must hold mu.
+func (t *namespaceThreads) store(channel string, namespace string, ts string) {
+	t.expire()
+	key := namespaceThreadKey(channel, namespace)
+	if _, ok := t.threads[key]; !ok {
+		for t.maxEntries > 0 && len(t.threads) >= t.maxEntries {
+			t.evictOldest()
+		}
+	}
+	t.threads[key] = namespaceThread{ts: ts, day: today(), createdAt: flextime.Now()}
+	threadStoreSize.Set(float64(len(t.threads)))
+}
+
+// expire drops the entries older than ttl. The caller must hold mu.
+func (t *namespaceThreads) expire() {
+	if t.ttl <= 0 {
+		return
+	}
+	now := flextime.Now()
+	for key, thread := range t.threads {
+		if now.Sub(thread.createdAt) >= t.ttl {
+			delete(t.threads, key)
+		}
+	}
+	threadStoreSize.Set(float64(len(t.threads)))
+}
+
+// evictOldest drops the entry created first. The caller must hold mu.
+func (t *namespaceThreads) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for key, thread := range t.threads {
+		if oldestKey == "" || thread.createdAt.Before(oldest) {
+			oldestKey, oldest = key, thread.createdAt
+		}
+	}
+	delete(t.threads, oldestKey)
+}
+
+func (t *namespaceThreads) size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.threads)
+}
+
+func namespaceThreadTitle(namespace string, now time.Time) string {
+	return "Job activity in " + namespace + " on " + now.UTC().Format("2006/1/2")
+}
@@ -0,0 +1,83 @@
+package notification
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceThreadsExpireByTTL(t *testing.T) {
+	now := time.Date(2020, 9, 6, 1, 0, 0, 0, time.UTC)
+	restore := flextime.Fix(now)
+	defer restore()
+
+	threads := &namespaceThreads{threads: make(map[string]namespaceThread), ttl: time.Hour, maxEntries: 10}
+	threads.set("channel", "first", "ts-1")
+	flextime.Fix(now.Add(30 * time.Minute))
+	threads.set("channel", "second", "ts-2")
+
+	flextime.Fix(now.Add(time.Hour))
+	assert.Equal(t, "", threads.get("channel", "first"))
+	assert.Equal(t, "ts-2", threads.get("channel", "second"))
+	assert.Equal(t, 1, threads.size())
+	assert.Equal(t, float64(1), testutil.ToFloat64(threadStoreSize))
+}
+
+func TestNamespaceThreadsEvictOldestAtMaxEntries(t *testing.T) {
+	now := time.Date(2020, 9, 6, 1, 0, 0, 0, time.UTC)
+	restore := flextime.Fix(now)
+	defer restore()
+
+	threads := &namespaceThreads{threads: make(map[string]namespaceThread), ttl: 24 * time.Hour, maxEntries: 2}
+	threads.set("channel", "first", "ts-1")
+	flextime.Fix(now.Add(time.Minute))
+	threads.set("channel", "second", "ts-2")
+	flextime.Fix(now.Add(2 * time.Minute))
+	threads.set("channel", "third", "ts-3")
+
+	assert.Equal(t, 2, threads.size())
+	assert.Equal(t, "", threads.get("channel", "first"))
+	assert.Equal(t, "ts-2", threads.get("channel", "second"))
+	assert.Equal(t, "ts-3", threads.get("channel", "third"))
+
+	// Replacing an existing entry doesn't evict another one.
+	threads.set("channel", "second", "ts-4")
+	assert.Equal(t, 2, threads.size())
+	assert.Equal(t, "ts-3", threads.get("channel", "third"))
+	assert.Equal(t, float64(2), testutil.ToFloat64(threadStoreSize))
+}
+
+func TestNamespaceThreadsGetOrStart(t *testing.T) {
+	threads := &namespaceThreads{threads: make(map[string]namespaceThread), ttl: time.Hour, maxEntries: 10}
+
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts, err := threads.getOrStart("channel", "namespace", func() (string, error) {
+				started.Add(1)
+				time.Sleep(10 * time.Millisecond)
+				return "ts-1", nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "ts-1", ts)
+		}()
+	}
+	wg.Wait()
+
+	// Concurrent notifications of a namespace start a single thread.
+	assert.Equal(t, int32(1), started.Load())
+
+	// A failed start is retried by the next notification.
+	_, err := threads.getOrStart("channel", "other", func() (string, error) { return "", errors.New("rate limited") })
+	assert.Error(t, err)
+	assert.Equal(t, "", threads.get("channel", "other"))
+}
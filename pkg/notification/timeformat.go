@@ -0,0 +1,61 @@
+package notification
+
+import (
+	"fmt"
+	"os"
+	"time"
+	// Embedded so that NOTIFY_TZ works in images without a zoneinfo database.
+	_ "time/tzdata"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const defaultTimeFormat = "2006/1/2 15:04:05 MST"
+
+// timeFormat renders the times of messages in the NOTIFY_TZ time zone with the
+// NOTIFY_TIME_FORMAT Go layout. The zero value uses UTC and the default layout.
+type timeFormat struct {
+	location *time.Location
+	layout   string
+}
+
+// loadTimeFormat reads NOTIFY_TZ and NOTIFY_TIME_FORMAT. An invalid time zone is reported
+// in the returned error and replaced with UTC.
+func loadTimeFormat() (timeFormat, error) {
+	f := timeFormat{layout: os.Getenv("NOTIFY_TIME_FORMAT")}
+	value := os.Getenv("NOTIFY_TZ")
+	if value == "" {
+		return f, nil
+	}
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return f, fmt.Errorf("invalid NOTIFY_TZ %q, using UTC: %w", value, err)
+	}
+	f.location = location
+	return f, nil
+}
+
+// getTimeFormat loads the time format of a notifier, reporting an invalid time zone.
+func getTimeFormat() timeFormat {
+	f, err := loadTimeFormat()
+	if err != nil {
+		klog.Errorf("Invalid time format: %v", err)
+	}
+	return f
+}
+
+func (f timeFormat) format(t *metav1.Time) string {
+	if t == nil {
+		return ""
+	}
+	location := f.location
+	if location == nil {
+		location = time.UTC
+	}
+	layout := f.layout
+	if layout == "" {
+		layout = defaultTimeFormat
+	}
+	return t.In(location).Format(layout)
+}
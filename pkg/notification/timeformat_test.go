@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFormatTime(t *testing.T) {
+	at := &metav1.Time{Time: time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC)}
+
+	tests := []struct {
+		Name     string
+		tz       string
+		format   string
+		expected string
+		isError  bool
+	}{
+		{"Default", "", "", "2020/11/28 01:02:03 UTC", false},
+		{"Tokyo", "Asia/Tokyo", "", "2020/11/28 10:02:03 JST", false},
+		{"New York", "America/New_York", "", "2020/11/27 20:02:03 EST", false},
+		{"Format", "Europe/Berlin", "2006-01-02 15:04 MST", "2020-11-28 02:02 CET", false},
+		{"RFC3339", "Asia/Kolkata", time.RFC3339, "2020-11-28T06:32:03+05:30", false},
+		{"Invalid time zone", "Mars/Olympus_Mons", "", "2020/11/28 01:02:03 UTC", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Setenv("NOTIFY_TZ", test.tz)
+			t.Setenv("NOTIFY_TIME_FORMAT", test.format)
+
+			f, err := loadTimeFormat()
+
+			assert.Equal(t, test.isError, err != nil)
+			assert.Equal(t, test.expected, f.format(at))
+		})
+	}
+	assert.Equal(t, "", timeFormat{}.format(nil))
+	assert.Equal(t, "2020/11/28 01:02:03 UTC", timeFormat{}.format(at))
+}
+
+func TestGetSlackMessageTimeZone(t *testing.T) {
+	t.Setenv("NOTIFY_TZ", "Asia/Tokyo")
+	t.Setenv("NOTIFY_TIME_FORMAT", "2006-01-02 15:04:05 MST")
+	config, err := loadSlackConfig()
+	assert.NoError(t, err)
+	// The time format is read once, when the configuration is loaded.
+	t.Setenv("NOTIFY_TZ", "UTC")
+
+	actual, err := config.getMessage(MessageTemplateParam{
+		JobName:        "Job",
+		StartTime:      &metav1.Time{Time: time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC)},
+		CompletionTime: &metav1.Time{Time: time.Date(2020, 11, 28, 15, 2, 3, 0, time.UTC)},
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, actual, " *StartTime*: 2020-11-28 10:02:03 JST")
+	assert.Contains(t, actual, " *CompletionTime*: 2020-11-29 00:02:03 JST")
+}
+
+func TestGetLarkMessageTimeZone(t *testing.T) {
+	t.Setenv("NOTIFY_TZ", "America/Los_Angeles")
+
+	actual := getLarkMessage(localeEnglish, getTimeFormat(), MessageTemplateParam{
+		JobName:   "Job",
+		StartTime: &metav1.Time{Time: time.Date(2020, 7, 1, 1, 2, 3, 0, time.UTC)},
+	})
+
+	assert.Contains(t, actual, "**StartTime**: 2020/6/30 18:02:03 PDT")
+}
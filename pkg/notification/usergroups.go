@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	slackapi "github.com/slack-go/slack"
+	"k8s.io/klog"
+)
+
+const defaultUserGroupsRefreshInterval = time.Hour
+
+type userGroupsClient interface {
+	GetUserGroups(options ...slackapi.GetUserGroupsOption) ([]slackapi.UserGroup, error)
+}
+
+// userGroups caches the IDs of the Slack user groups by handle, so that mentions can be
+// configured with handles such as @payments-oncall instead of subteam IDs.
+type userGroups struct {
+	client userGroupsClient
+
+	mu  sync.RWMutex
+	ids map[string]string
+	// loaded is set once the handles were listed, mentions load them on first use when
+	// SLACK_FAILED_MENTIONS didn't.
+	loaded bool
+}
+
+func newUserGroups(client userGroupsClient) *userGroups {
+	return &userGroups{client: client, ids: make(map[string]string)}
+}
+
+// refresh reloads the handles with usergroups.list. The previous handles are kept on errors.
+func (g *userGroups) refresh() error {
+	groups, err := g.client.GetUserGroups()
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]string, len(groups))
+	for _, group := range groups {
+		ids[group.Handle] = group.ID
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ids = ids
+	g.loaded = true
+	return nil
+}
+
+// refreshEvery refreshes the handles periodically for the lifetime of the process.
+func (g *userGroups) refreshEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := g.refresh(); err != nil {
+			klog.Errorf("Refresh Slack user groups failed %s\n", err)
+		}
+	}
+}
+
+// mention formats a user group given by its @handle or subteam ID as a Slack mention.
+// Unknown handles are kept as plain text.
+func (g *userGroups) mention(value string) string {
+	handle, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return "<!subteam^" + value + ">"
+	}
+	g.mu.RLock()
+	loaded := g.loaded
+	g.mu.RUnlock()
+	if !loaded {
+		if err := g.refresh(); err != nil {
+			klog.Errorf("Get Slack user groups failed %s\n", err)
+		}
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	id, ok := g.ids[handle]
+	if !ok {
+		klog.Errorf("Unknown Slack user group %s", value)
+		return value
+	}
+	return "<!subteam^" + id + ">"
+}
+
+// getUserGroupsRefreshInterval reads SLACK_USERGROUPS_REFRESH_INTERVAL.
+func getUserGroupsRefreshInterval() time.Duration {
+	value := os.Getenv("SLACK_USERGROUPS_REFRESH_INTERVAL")
+	if value == "" {
+		return defaultUserGroupsRefreshInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		klog.Errorf("Invalid SLACK_USERGROUPS_REFRESH_INTERVAL %q, using default %s: %v", value, defaultUserGroupsRefreshInterval, err)
+		return defaultUserGroupsRefreshInterval
+	}
+	return interval
+}
+
+// parseMentions splits a comma separated list of user group handles and IDs.
+func parseMentions(value string) []string {
+	var mentions []string
+	for _, mention := range strings.Split(value, ",") {
+		mention = strings.TrimSpace(mention)
+		if mention != "" {
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
+
+// failedMention returns the text mentioning the SLACK_FAILED_MENTIONS user groups, or those
+// of the failedMentionsAnnotationName annotation of the job.
+func (s slack) failedMention(annotations map[string]string) string {
+	failedMentions := s.config.failedMentions
+	if v, ok := annotations[failedMentionsAnnotationName]; ok {
+		failedMentions = parseMentions(v)
+	}
+	if len(failedMentions) == 0 {
+		return ""
+	}
+	mentions := make([]string, 0, len(failedMentions))
+	for _, m := range failedMentions {
+		mentions = append(mentions, s.userGroups.mention(m))
+	}
+	return strings.Join(mentions, " ")
+}
@@ -0,0 +1,107 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+
+	slackapi "github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeUserGroupsClient struct {
+	groups []slackapi.UserGroup
+	err    error
+}
+
+func (c *fakeUserGroupsClient) GetUserGroups(options ...slackapi.GetUserGroupsOption) ([]slackapi.UserGroup, error) {
+	return c.groups, c.err
+}
+
+func TestUserGroupsMention(t *testing.T) {
+	client := &fakeUserGroupsClient{groups: []slackapi.UserGroup{
+		{ID: "S0123", Handle: "payments-oncall"},
+		{ID: "S0456", Handle: "platform"},
+	}}
+	g := newUserGroups(client)
+	assert.NoError(t, g.refresh())
+
+	assert.Equal(t, "<!subteam^S0123>", g.mention("@payments-oncall"))
+	assert.Equal(t, "<!subteam^S0789>", g.mention("S0789"))
+	assert.Equal(t, "@unknown", g.mention("@unknown"))
+
+	// A renamed handle resolves to the same group after a refresh.
+	client.groups = []slackapi.UserGroup{{ID: "S0123", Handle: "payments-primary"}}
+	assert.NoError(t, g.refresh())
+	assert.Equal(t, "<!subteam^S0123>", g.mention("@payments-primary"))
+
+	// The cached handles are kept when usergroups.list fails.
+	client.err = errors.New("ratelimited")
+	assert.Error(t, g.refresh())
+	assert.Equal(t, "<!subteam^S0123>", g.mention("@payments-primary"))
+}
+
+func TestParseMentions(t *testing.T) {
+	assert.Nil(t, parseMentions(""))
+	assert.Equal(t, []string{"@payments-oncall", "S0123"}, parseMentions(" @payments-oncall, ,S0123"))
+}
+
+func TestNotifyFailedMentions(t *testing.T) {
+	t.Setenv("SLACK_FAILED_NOTIFY", "true")
+	tests := []struct {
+		Name         string
+		failedCount  int32
+		severity     string
+		expectedText string
+	}{
+		{"Retries exhausted", 3, "", "<!subteam^S0123> <!subteam^S0456>"},
+		{"Retrying", 1, "", ""},
+		{"Retrying critical job", 1, "critical", "<!subteam^S0123> <!subteam^S0456>"},
+		{"Info job", 3, "info", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var options []slackapi.MsgOption
+			mc := &MockSlackClient{}
+			mc.On("PostMessage", "default_channel", mock.AnythingOfType("[]slack.MsgOption")).
+				Run(func(args mock.Arguments) {
+					options = args.Get(1).([]slackapi.MsgOption)
+				}).
+				Return("default_channel", "timestamp", nil)
+
+			groups := newUserGroups(&fakeUserGroupsClient{groups: []slackapi.UserGroup{{ID: "S0123", Handle: "payments-oncall"}}})
+			assert.NoError(t, groups.refresh())
+			s := slack{
+				client:     mc,
+				config:     slackConfig{failedMentions: []string{"@payments-oncall", "S0456"}},
+				channel:    "default_channel",
+				userGroups: groups,
+			}
+			annotations := map[string]string{}
+			if test.severity != "" {
+				annotations[severityAnnotationName] = test.severity
+			}
+			_, err := s.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: test.failedCount, BackoffLimit: 2, Annotations: annotations})
+			assert.NoError(t, err)
+
+			_, values, err := slackapi.UnsafeApplyMsgOptions("token", "default_channel", "https://slack.com/api/", options...)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedText, values.Get("text"))
+		})
+	}
+}
+
+func TestFailedMentionAnnotation(t *testing.T) {
+	client := &fakeUserGroupsClient{groups: []slackapi.UserGroup{{ID: "S0123", Handle: "payments-oncall"}}}
+	s := slack{
+		config:     slackConfig{failedMentions: []string{"S0456"}},
+		userGroups: newUserGroups(client),
+	}
+
+	assert.Equal(t, "<!subteam^S0456>", s.failedMention(nil))
+	assert.Equal(t, "<!subteam^S0123> <!subteam^S0789>",
+		s.failedMention(map[string]string{failedMentionsAnnotationName: "@payments-oncall,S0789"}),
+		"the handles are loaded on first use")
+	assert.Equal(t, "", s.failedMention(map[string]string{failedMentionsAnnotationName: ""}))
+}
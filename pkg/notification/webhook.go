@@ -0,0 +1,226 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// webhookSignatureHeader carries the hex encoded HMAC-SHA256 of the request body.
+const webhookSignatureHeader = "X-Signature"
+
+// webhookPayload follows the Grafana OnCall formatted webhook: an alerting state opens an
+// alert group for alert_uid and an ok state resolves it. The job fields are added for other
+// receivers.
+type webhookPayload struct {
+	AlertUID              string `json:"alert_uid"`
+	Title                 string `json:"title"`
+	State                 string `json:"state"`
+	Message               string `json:"message"`
+	LinkToUpstreamDetails string `json:"link_to_upstream_details,omitempty"`
+
+	Event       string `json:"event"`
+	ClusterName string `json:"clusterName,omitempty"`
+	JobName     string `json:"jobName"`
+	CronJobName string `json:"cronJobName,omitempty"`
+	Namespace   string `json:"namespace"`
+	FailedCount int32  `json:"failedCount,omitempty"`
+	ExitCode    int32  `json:"exitCode,omitempty"`
+}
+
+// webhook posts an alert to WEBHOOK_URL when a job failed and resolves it once the job
+// succeeds. With WEBHOOK_SIGNING_SECRET the body is signed, so that the receiver can verify
+// that it was sent by the controller.
+type webhook struct {
+	url    string
+	secret []byte
+	client *http.Client
+	// alerting are the alert UIDs that were alerted and not resolved yet.
+	alerting *webhookAlerts
+}
+
+// webhookAlerts keeps the open alerts in memory, so that only the successes of jobs that
+// alerted are resolved.
+type webhookAlerts struct {
+	mu   sync.Mutex
+	uids map[string]bool
+}
+
+func (a *webhookAlerts) open(uid string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.uids[uid] = true
+}
+
+func (a *webhookAlerts) isOpen(uid string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.uids[uid]
+}
+
+func (a *webhookAlerts) resolve(uid string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.uids, uid)
+}
+
+func init() {
+	Register("webhook", func() (Notification, bool) {
+		url := os.Getenv("WEBHOOK_URL")
+		if url == "" {
+			return nil, false
+		}
+		return newWebhook(url, os.Getenv("WEBHOOK_SIGNING_SECRET")), true
+	})
+}
+
+func newWebhook(url string, secret string) webhook {
+	if !strings.HasPrefix(url, "https://") {
+		klog.Warningf("WEBHOOK_URL is not an https URL, job notifications are sent in clear text")
+	}
+	return webhook{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// A redirect would send the signed body to another host.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		alerting: &webhookAlerts{uids: make(map[string]bool)},
+	}
+}
+
+func (w webhook) NotifyStart(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (w webhook) NotifySuccess(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressSuccessAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	// Most successes have no alert to resolve.
+	uid := getPagerDutyDedupKey(messageParam)
+	if !w.alerting.isOpen(uid) {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	if err := w.send(SUCCESS, "ok", "succeeded", messageParam); err != nil {
+		return NotifyResult{}, err
+	}
+	w.alerting.resolve(uid)
+	return NotifyResult{}, nil
+}
+
+func (w webhook) NotifyFailed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	if isNotificationSuppressed(messageParam.Annotations, suppressFailedAnnotationName) {
+		klog.Infof("Notification for %s is suppressed", messageParam.JobName)
+		return NotifyResult{SkippedReason: SkippedSuppressed}, nil
+	}
+	// Retry warnings and info jobs don't page anyone.
+	if !messageParam.pages() {
+		return NotifyResult{SkippedReason: SkippedDisabled}, nil
+	}
+	messageParam.CompletionTime, messageParam.ExecutionTime = messageParam.calculateExecutionTime()
+	if err := w.send(FAILED, "alerting", "failed", messageParam); err != nil {
+		return NotifyResult{}, err
+	}
+	w.alerting.open(getPagerDutyDedupKey(messageParam))
+	return NotifyResult{}, nil
+}
+
+func (w webhook) NotifySuspended(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (w webhook) NotifyResumed(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func (w webhook) NotifySpecChanged(messageParam MessageTemplateParam) (result NotifyResult, err error) {
+	return NotifyResult{SkippedReason: SkippedDisabled}, nil
+}
+
+func getWebhookPayload(event string, state string, verb string, messageParam MessageTemplateParam) webhookPayload {
+	name := messageParam.CronJobName
+	if name == "" {
+		name = messageParam.JobName
+	}
+	lines := []string{"Job: " + messageParam.JobName, "Namespace: " + messageParam.Namespace}
+	if messageParam.ClusterName != "" {
+		lines = append(lines, "Cluster: "+messageParam.ClusterName)
+	}
+	if messageParam.ExecutionTime != 0 {
+		lines = append(lines, "ExecutionTime: "+messageParam.ExecutionTime.String())
+	}
+	if messageParam.ExitCode != 0 {
+		lines = append(lines, fmt.Sprintf("ExitCode: %d", messageParam.ExitCode))
+	}
+	link := messageParam.JobDetailURL
+	if link == "" {
+		link = messageParam.LogURL
+	}
+	return webhookPayload{
+		// The runs of a CronJob share one alert group, like PagerDuty incidents.
+		AlertUID:              getPagerDutyDedupKey(messageParam),
+		Title:                 name + " " + verb + " in " + messageParam.Namespace,
+		State:                 state,
+		Message:               strings.Join(lines, "\n"),
+		LinkToUpstreamDetails: link,
+		Event:                 event,
+		ClusterName:           messageParam.ClusterName,
+		JobName:               messageParam.JobName,
+		CronJobName:           messageParam.CronJobName,
+		Namespace:             messageParam.Namespace,
+		FailedCount:           messageParam.FailedCount,
+		ExitCode:              messageParam.ExitCode,
+	}
+}
+
+// signWebhookBody returns the hex encoded HMAC-SHA256 of body with secret.
+func signWebhookBody(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w webhook) send(event string, state string, verb string, messageParam MessageTemplateParam) error {
+	body, err := json.Marshal(getWebhookPayload(event, state, verb, messageParam))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		req.Header.Set(webhookSignatureHeader, signWebhookBody(w.secret, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		klog.Errorf("Send webhook failed %s\n", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("webhook returned %s", resp.Status)
+		klog.Errorf("Send webhook failed %s\n", err)
+		return err
+	}
+	klog.Infof("Webhook %s event successfully sent for %s", state, messageParam.JobName)
+	return nil
+}
@@ -0,0 +1,127 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookRequest is a request received by the test server.
+type webhookRequest struct {
+	payload   webhookPayload
+	body      []byte
+	signature string
+}
+
+func newWebhookServer(t *testing.T, received *[]webhookRequest, statusCode int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var payload webhookPayload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		*received = append(*received, webhookRequest{payload: payload, body: body, signature: r.Header.Get(webhookSignatureHeader)})
+		w.WriteHeader(statusCode)
+	}))
+}
+
+func TestWebhookAlertAndResolve(t *testing.T) {
+	var received []webhookRequest
+	server := newWebhookServer(t, &received, http.StatusOK)
+	defer server.Close()
+
+	w := newWebhook(server.URL, "shared-secret")
+	param := MessageTemplateParam{JobName: "payments-etl-123", CronJobName: "payments-etl", Namespace: "prod", ExitCode: 2, FailedCount: 1}
+	_, err := w.NotifyFailed(param)
+	assert.NoError(t, err)
+	_, err = w.NotifySuccess(param)
+	assert.NoError(t, err)
+	// The alert is resolved once.
+	result, err := w.NotifySuccess(param)
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, result.SkippedReason)
+
+	assert.Len(t, received, 2)
+	assert.Equal(t, "kube-job-notifier/prod/payments-etl", received[0].payload.AlertUID)
+	assert.Equal(t, "payments-etl failed in prod", received[0].payload.Title)
+	assert.Equal(t, "alerting", received[0].payload.State)
+	assert.Equal(t, "Job: payments-etl-123\nNamespace: prod\nExitCode: 2", received[0].payload.Message)
+	assert.Equal(t, FAILED, received[0].payload.Event)
+	assert.Equal(t, received[0].payload.AlertUID, received[1].payload.AlertUID)
+	assert.Equal(t, "ok", received[1].payload.State)
+
+	// The receiver verifies the body with the shared secret.
+	for _, r := range received {
+		mac := hmac.New(sha256.New, []byte("shared-secret"))
+		mac.Write(r.body)
+		assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), r.signature)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	var received []webhookRequest
+	server := newWebhookServer(t, &received, http.StatusOK)
+	defer server.Close()
+
+	_, err := newWebhook(server.URL, "").NotifyFailed(MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+
+	assert.NoError(t, err)
+	assert.Len(t, received, 1)
+	assert.Empty(t, received[0].signature)
+}
+
+func TestWebhookSkipped(t *testing.T) {
+	var received []webhookRequest
+	server := newWebhookServer(t, &received, http.StatusOK)
+	defer server.Close()
+	w := newWebhook(server.URL, "shared-secret")
+
+	result, err := w.NotifyStart(MessageTemplateParam{JobName: "the-job"})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, result.SkippedReason)
+
+	// A job that didn't alert has nothing to resolve.
+	result, err = w.NotifySuccess(MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, result.SkippedReason)
+
+	// A failed attempt that is retried doesn't alert.
+	result, err = w.NotifyFailed(MessageTemplateParam{JobName: "the-job", FailedCount: 1, BackoffLimit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedDisabled, result.SkippedReason)
+
+	result, err = w.NotifyFailed(MessageTemplateParam{JobName: "the-job", Annotations: map[string]string{suppressFailedAnnotationName: "true"}})
+	assert.NoError(t, err)
+	assert.Equal(t, SkippedSuppressed, result.SkippedReason)
+
+	assert.Empty(t, received)
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	var received []webhookRequest
+	server := newWebhookServer(t, &received, http.StatusUnauthorized)
+	defer server.Close()
+
+	_, err := newWebhook(server.URL, "wrong-secret").NotifyFailed(MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+
+	assert.EqualError(t, err, "webhook returned 401 Unauthorized")
+}
+
+func TestWebhookRedirectNotFollowed(t *testing.T) {
+	var received []webhookRequest
+	target := newWebhookServer(t, &received, http.StatusOK)
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	_, err := newWebhook(redirect.URL, "shared-secret").NotifyFailed(MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"})
+
+	assert.EqualError(t, err, "webhook returned 307 Temporary Redirect")
+	assert.Empty(t, received)
+}
@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"k8s.io/klog"
+)
+
+const workspaceAnnotationName = "kube-job-notifier/slack-workspace"
+
+// slackWorkspace is a Slack workspace other than the default one, with its own token
+// and default channel.
+type slackWorkspace struct {
+	client  slackClient
+	channel string
+}
+
+// newSlackWorkspaces creates the workspaces of SLACK_WORKSPACE_TOKENS and
+// SLACK_WORKSPACE_CHANNELS, both mapping a workspace name to its token or channel.
+func newSlackWorkspaces(tokens map[string]string, channels map[string]string) map[string]slackWorkspace {
+	workspaces := make(map[string]slackWorkspace, len(tokens))
+	for name, token := range tokens {
+		workspaces[name] = slackWorkspace{
+			client:  newSlackAPI(token),
+			channel: channels[name],
+		}
+	}
+	return workspaces
+}
+
+// useWorkspace switches to the workspace the job is routed to by its annotation or its
+// namespace. The default workspace is kept when the job isn't routed.
+func (s slack) useWorkspace(messageParam MessageTemplateParam) slack {
+	name, ok := messageParam.Annotations[workspaceAnnotationName]
+	if !ok {
+		name = s.config.namespaceWorkspaces[messageParam.Namespace]
+	}
+	if name == "" {
+		return s
+	}
+	workspace, ok := s.workspaces[name]
+	if !ok {
+		klog.Errorf("Unknown Slack workspace %s for job %s, using the default workspace", name, messageParam.JobName)
+		return s
+	}
+	s.client = workspace.client
+	s.channel = workspace.channel
+	return s
+}
@@ -0,0 +1,81 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewSlackWorkspaces(t *testing.T) {
+	workspaces := newSlackWorkspaces(
+		parseKeyValues("team-a:xoxb-a,team-b:xoxb-b"),
+		parseKeyValues("team-a:CA,team-b:CB"),
+	)
+
+	assert.Len(t, workspaces, 2)
+	assert.Equal(t, "CA", workspaces["team-a"].channel)
+	assert.Equal(t, "CB", workspaces["team-b"].channel)
+}
+
+func TestNotifyRoutesToWorkspace(t *testing.T) {
+	tests := []struct {
+		name              string
+		namespace         string
+		annotations       map[string]string
+		expectedWorkspace string
+		expectedChannel   string
+	}{
+		{"Unmapped namespace uses default workspace", "other", nil, "default", "default_channel"},
+		{"Mapped namespace", "payments", nil, "team-a", "CA"},
+		{"Annotation overrides namespace", "payments", map[string]string{workspaceAnnotationName: "team-b"}, "team-b", "CB"},
+		{"Unknown workspace uses default workspace", "other", map[string]string{workspaceAnnotationName: "team-c"}, "default", "default_channel"},
+		{
+			"Channel annotation overrides workspace channel",
+			"payments",
+			map[string]string{"kube-job-notifier/failed-channel": "annotated"},
+			"team-a",
+			"annotated",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("SLACK_FAILED_NOTIFY", "true")
+			t.Setenv("SLACK_FAILED_CHANNEL", "")
+
+			clients := map[string]*MockSlackClient{
+				"default": {},
+				"team-a":  {},
+				"team-b":  {},
+			}
+			clients[test.expectedWorkspace].On("PostMessage", test.expectedChannel, mock.AnythingOfType("[]slack.MsgOption")).
+				Return(test.expectedChannel, "timestamp", nil)
+
+			s := slack{
+				client:  clients["default"],
+				config:  slackConfig{namespaceWorkspaces: map[string]string{"payments": "team-a"}},
+				channel: "default_channel",
+				workspaces: map[string]slackWorkspace{
+					"team-a": {client: clients["team-a"], channel: "CA"},
+					"team-b": {client: clients["team-b"], channel: "CB"},
+				},
+			}
+			_, err := s.NotifyFailed(MessageTemplateParam{
+				JobName:     "the-job",
+				Namespace:   test.namespace,
+				Annotations: test.annotations,
+				FailedCount: 1,
+			})
+
+			assert.NoError(t, err)
+			for name, mc := range clients {
+				if name == test.expectedWorkspace {
+					mc.AssertExpectations(t)
+				} else {
+					mc.AssertNotCalled(t, "PostMessage", mock.Anything, mock.Anything)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+const defaultPodLossThreshold = 0.5
+
+// podLossTracker keeps the peak of the running pods of each job to detect pods lost before
+// completion, e.g. to evictions or preemptions. Pods finished by succeeding don't count as
+// lost. Each job is warned about at most once.
+type podLossTracker struct {
+	// threshold is the share of the peak running pods that must be lost for a warning.
+	threshold float64
+
+	mu   sync.Mutex
+	jobs map[string]*podLossState
+}
+
+type podLossState struct {
+	peak int32
+	// succeededAtPeak is the succeeded pod count when the peak was observed.
+	succeededAtPeak int32
+	warned          bool
+}
+
+func newPodLossTracker(threshold float64) *podLossTracker {
+	return &podLossTracker{threshold: threshold, jobs: make(map[string]*podLossState)}
+}
+
+// getPodLossTracker returns nil unless NOTIFY_POD_LOSS=true.
+func getPodLossTracker() *podLossTracker {
+	if os.Getenv("NOTIFY_POD_LOSS") != "true" {
+		return nil
+	}
+	return newPodLossTracker(getPodLossThreshold())
+}
+
+func getPodLossThreshold() float64 {
+	value := os.Getenv("POD_LOSS_THRESHOLD")
+	if value == "" {
+		return defaultPodLossThreshold
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		klog.Errorf("Invalid POD_LOSS_THRESHOLD %q, using default %v", value, defaultPodLossThreshold)
+		return defaultPodLossThreshold
+	}
+	return threshold
+}
+
+// observe records the running pods of an unfinished job and returns the pods lost since
+// the peak when they reach the threshold for the first time.
+func (t *podLossTracker) observe(job *batchv1.Job) (lost, peak int32) {
+	key := job.Namespace + "/" + job.Name
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state, ok := t.jobs[key]
+	if !ok {
+		state = &podLossState{}
+		t.jobs[key] = state
+	}
+	if job.Status.Active >= state.peak {
+		state.peak = job.Status.Active
+		state.succeededAtPeak = job.Status.Succeeded
+		return 0, 0
+	}
+	if state.warned {
+		return 0, 0
+	}
+	lost = state.peak - job.Status.Active - (job.Status.Succeeded - state.succeededAtPeak)
+	if lost <= 0 || lost < int32(math.Ceil(float64(state.peak)*t.threshold)) {
+		return 0, 0
+	}
+	state.warned = true
+	return lost, state.peak
+}
+
+// forget drops the job once it finished or was deleted.
+func (t *podLossTracker) forget(job *batchv1.Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.jobs, job.Namespace+"/"+job.Name)
+}
+
+// notifyPodsLost sends a warning through the failed notification of every notifier. As with
+// stuck pods, the job isn't marked as notified, so that its final result is still notified.
+func (c *Controller) notifyPodsLost(job *batchv1.Job, lost, peak int32, observedAt time.Time) {
+	klog.Warningf("Job lost pods: %s lost=%d peak=%d active=%d", jobLogFields(job, notification.FAILED), lost, peak, job.Status.Active)
+
+	cronJobName, err := getCronJobNameFromOwnerReferences(c.kubeclientset, job)
+	if err != nil {
+		klog.Errorf("Get cronjob failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		c.errorReporter.report(controllerErrorStageOwner, job.Name, err)
+	}
+
+	messageParam := notification.MessageTemplateParam{
+		ClusterName:    c.clusterName,
+		Event:          notification.FAILED,
+		JobName:        job.Name,
+		CronJobName:    cronJobName,
+		Namespace:      job.Namespace,
+		StartTime:      job.Status.StartTime,
+		LogURL:         getLogURL(job, cronJobName),
+		JobDetailURL:   getJobDetailURL(job, cronJobName, c.clusterName),
+		Annotations:    c.jobAnnotations(job),
+		FailedCount:    job.Status.Failed,
+		LostPods:       lost,
+		PeakActivePods: peak,
+	}
+	if job.Spec.BackoffLimit != nil {
+		messageParam.BackoffLimit = *job.Spec.BackoffLimit
+	}
+	// The events tell why the pods went away, e.g. Evicted or Preempting.
+	if c.eventCount > 0 {
+		if pods, err := getJobPods(c.kubeclientset, job); err != nil {
+			klog.Errorf("Get pods failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		} else if messageParam.Events, err = getJobEvents(c.kubeclientset, job, pods, c.eventCount); err != nil {
+			klog.Errorf("Get events failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		}
+	}
+	// The lost pods warning dedups under its own key, so the job's final failure still goes out.
+	if c.isMutedNotification(job, notification.FAILED, messageParam, observedAt) || c.isDuplicateNotification(job, notification.FAILED, messageParam) {
+		return
+	}
+	c.resends.record(job, messageParam)
+	for name, n := range c.jobNotifications(job) {
+		result, err := n.NotifyFailed(messageParam)
+		c.recordNotifyResult(name, notification.FAILED, job, observedAt, result, err)
+	}
+}
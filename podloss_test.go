@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func runningJob(active, succeeded int32) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"},
+		Status:     batchv1.JobStatus{Active: active, Succeeded: succeeded},
+	}
+}
+
+func TestGetPodLossTracker(t *testing.T) {
+	assert.Nil(t, getPodLossTracker())
+
+	t.Setenv("NOTIFY_POD_LOSS", "true")
+	assert.Equal(t, defaultPodLossThreshold, getPodLossTracker().threshold)
+
+	t.Setenv("POD_LOSS_THRESHOLD", "0.25")
+	assert.Equal(t, 0.25, getPodLossTracker().threshold)
+
+	for _, value := range []string{"invalid", "0", "1.5"} {
+		t.Setenv("POD_LOSS_THRESHOLD", value)
+		assert.Equal(t, defaultPodLossThreshold, getPodLossTracker().threshold, value)
+	}
+}
+
+func TestPodLossTracker(t *testing.T) {
+	t.Run("evicted pods", func(t *testing.T) {
+		tracker := newPodLossTracker(0.5)
+		for _, active := range []int32{0, 2, 4} {
+			lost, _ := tracker.observe(runningJob(active, 0))
+			assert.Zero(t, lost)
+		}
+
+		lost, _ := tracker.observe(runningJob(3, 0))
+		assert.Zero(t, lost, "below the threshold")
+
+		lost, peak := tracker.observe(runningJob(1, 0))
+		assert.Equal(t, int32(3), lost)
+		assert.Equal(t, int32(4), peak)
+
+		lost, _ = tracker.observe(runningJob(0, 0))
+		assert.Zero(t, lost, "warned once")
+	})
+
+	t.Run("succeeded pods", func(t *testing.T) {
+		tracker := newPodLossTracker(0.5)
+		tracker.observe(runningJob(4, 0))
+
+		lost, _ := tracker.observe(runningJob(1, 3))
+		assert.Zero(t, lost)
+
+		lost, peak := tracker.observe(runningJob(0, 3))
+		assert.Zero(t, lost, "1 of 4 pods lost")
+		assert.Zero(t, peak)
+	})
+
+	t.Run("forget", func(t *testing.T) {
+		tracker := newPodLossTracker(0.5)
+		tracker.observe(runningJob(4, 0))
+		tracker.forget(runningJob(4, 0))
+
+		lost, _ := tracker.observe(runningJob(1, 0))
+		assert.Zero(t, lost)
+	})
+}
+
+func TestNotifyPodsLost(t *testing.T) {
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(),
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+	}
+
+	c.notifyPodsLost(runningJob(1, 0), 3, 4, time.Now())
+
+	assert.Equal(t, []string{"failed"}, n.events)
+	assert.Equal(t, "the-job", n.params[0].JobName)
+	assert.Equal(t, int32(3), n.params[0].LostPods)
+	assert.Equal(t, int32(4), n.params[0].PeakActivePods)
+	// The final result of the job is still notified.
+	assert.False(t, c.isNotified("the-job"))
+}
+
+func TestNotifyPodsLostDoesNotDedupFailure(t *testing.T) {
+	t.Setenv("DEDUP_WINDOW", "10m")
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod),
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+		deduper:       newNotificationDeduper(),
+	}
+	job := runningJob(1, 0)
+	job.UID = "test"
+	job.Spec.BackoffLimit = utilpointer.Int32(0)
+
+	c.notifyPodsLost(job, 3, 4, time.Now())
+	failedJob := runningJob(0, 0)
+	failedJob.UID = "test"
+	failedJob.Spec.BackoffLimit = utilpointer.Int32(0)
+	failedJob.Status.Failed = 1
+	c.handleFailed(failedJob, time.Now())
+
+	assert.Equal(t, []string{"failed", "failed"}, n.events)
+	assert.Equal(t, int32(3), n.params[0].LostPods)
+	assert.Zero(t, n.params[1].LostPods)
+}
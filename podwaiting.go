@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const defaultPodStuckThreshold = 2 * time.Minute
+
+// stuckWaitingReasons are the waiting reasons of containers that won't start without a fix,
+// e.g. a bad image tag or a missing secret.
+var stuckWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"CreateContainerConfigError": true,
+}
+
+// podWaiting is a container of a pod stuck in one of stuckWaitingReasons.
+type podWaiting struct {
+	container string
+	reason    string
+	message   string
+}
+
+// getPodStuckThreshold returns how long a pod may wait before it is reported as stuck,
+// 0 when POD_STUCK_THRESHOLD=0 disables the warning.
+func getPodStuckThreshold() time.Duration {
+	value := os.Getenv("POD_STUCK_THRESHOLD")
+	if value == "" {
+		return defaultPodStuckThreshold
+	}
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold < 0 {
+		klog.Errorf("Invalid POD_STUCK_THRESHOLD %q, using default %s: %v", value, defaultPodStuckThreshold, err)
+		return defaultPodStuckThreshold
+	}
+	return threshold
+}
+
+// getStuckWaiting returns the first container of the pod stuck in one of stuckWaitingReasons
+// once the pod was created at least threshold before now.
+func getStuckWaiting(pod *corev1.Pod, now time.Time, threshold time.Duration) (podWaiting, bool) {
+	if now.Sub(pod.CreationTimestamp.Time) < threshold {
+		return podWaiting{}, false
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		waiting := status.State.Waiting
+		if waiting != nil && stuckWaitingReasons[waiting.Reason] {
+			return podWaiting{container: status.Name, reason: waiting.Reason, message: waiting.Message}, true
+		}
+	}
+	return podWaiting{}, false
+}
+
+// notifyPodStuck sends a warning through the failed notification of every notifier. The job
+// isn't marked as notified, so that its final result is still notified.
+func (c *Controller) notifyPodStuck(job *batchv1.Job, pod *corev1.Pod, waiting podWaiting, observedAt time.Time) {
+	klog.Infof("Job pod stuck: %s pod=%s container=%s reason=%s", jobLogFields(job, notification.FAILED), pod.Name, waiting.container, waiting.reason)
+
+	cronJobName, err := getCronJobNameFromOwnerReferences(c.kubeclientset, job)
+	if err != nil {
+		klog.Errorf("Get cronjob failed: %s: %v", jobLogFields(job, notification.FAILED), err)
+		c.errorReporter.report(controllerErrorStageOwner, job.Name, err)
+	}
+
+	messageParam := notification.MessageTemplateParam{
+		ClusterName:      c.clusterName,
+		Event:            notification.FAILED,
+		JobName:          job.Name,
+		CronJobName:      cronJobName,
+		Namespace:        job.Namespace,
+		StartTime:        job.Status.StartTime,
+		LogURL:           getLogURL(job, cronJobName),
+		JobDetailURL:     getJobDetailURL(job, cronJobName, c.clusterName),
+		Annotations:      c.jobAnnotations(job),
+		NodeName:         pod.Spec.NodeName,
+		WaitingContainer: waiting.container,
+		WaitingReason:    waiting.reason,
+		WaitingMessage:   waiting.message,
+	}
+	if job.Spec.BackoffLimit != nil {
+		messageParam.BackoffLimit = *job.Spec.BackoffLimit
+	}
+	// The stuck pod warning dedups under its own key, so the job's final failure still goes out.
+	if c.isMutedNotification(job, notification.FAILED, messageParam, observedAt) || c.isDuplicateNotification(job, notification.FAILED, messageParam) {
+		return
+	}
+	c.resends.record(job, messageParam)
+	for name, n := range c.jobNotifications(job) {
+		result, err := n.NotifyFailed(messageParam)
+		c.recordNotifyResult(name, notification.FAILED, job, observedAt, result, err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func waitingPod(created time.Time, reason string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abc", Namespace: "test-ns", CreationTimestamp: metav1.NewTime(created)},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "worker",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: "the message"}},
+				},
+			},
+		},
+	}
+}
+
+func TestGetStuckWaiting(t *testing.T) {
+	now := time.Date(2020, 9, 6, 1, 0, 0, 0, time.UTC)
+
+	for _, reason := range []string{"ImagePullBackOff", "ErrImagePull", "CreateContainerConfigError"} {
+		t.Run(reason, func(t *testing.T) {
+			waiting, ok := getStuckWaiting(waitingPod(now.Add(-3*time.Minute), reason), now, 2*time.Minute)
+
+			assert.True(t, ok)
+			assert.Equal(t, podWaiting{container: "worker", reason: reason, message: "the message"}, waiting)
+		})
+	}
+
+	t.Run("before the threshold", func(t *testing.T) {
+		_, ok := getStuckWaiting(waitingPod(now.Add(-time.Minute), "ImagePullBackOff"), now, 2*time.Minute)
+		assert.False(t, ok)
+	})
+
+	t.Run("still creating", func(t *testing.T) {
+		_, ok := getStuckWaiting(waitingPod(now.Add(-3*time.Minute), "ContainerCreating"), now, 2*time.Minute)
+		assert.False(t, ok)
+	})
+
+	t.Run("init container", func(t *testing.T) {
+		pod := waitingPod(now.Add(-3*time.Minute), "PodInitializing")
+		pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+			{
+				Name:  "init",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"}},
+			},
+		}
+
+		waiting, ok := getStuckWaiting(pod, now, 2*time.Minute)
+
+		assert.True(t, ok)
+		assert.Equal(t, podWaiting{container: "init", reason: "ErrImagePull"}, waiting)
+	})
+}
+
+func TestGetPodStuckThreshold(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", defaultPodStuckThreshold},
+		{"5m", 5 * time.Minute},
+		{"0", 0},
+		{"invalid", defaultPodStuckThreshold},
+		{"-1m", defaultPodStuckThreshold},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv("POD_STUCK_THRESHOLD", test.value)
+			assert.Equal(t, test.expected, getPodStuckThreshold())
+		})
+	}
+}
+
+func TestNotifyPodStuck(t *testing.T) {
+	for _, reason := range []string{"ImagePullBackOff", "ErrImagePull", "CreateContainerConfigError"} {
+		t.Run(reason, func(t *testing.T) {
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(),
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+			}
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns"}}
+			pod := waitingPod(time.Now().Add(-3*time.Minute), reason)
+			waiting, ok := getStuckWaiting(pod, time.Now(), 2*time.Minute)
+			assert.True(t, ok)
+
+			c.notifyPodStuck(job, pod, waiting, time.Now())
+
+			assert.Equal(t, []string{"failed"}, n.events)
+			assert.Equal(t, "the-job", n.params[0].JobName)
+			assert.Equal(t, "worker", n.params[0].WaitingContainer)
+			assert.Equal(t, reason, n.params[0].WaitingReason)
+			assert.Equal(t, "the message", n.params[0].WaitingMessage)
+			// The final result of the job is still notified.
+			assert.False(t, c.isNotified("the-job"))
+		})
+	}
+}
+
+func TestNotifyPodStuckDoesNotDedupFailure(t *testing.T) {
+	t.Setenv("DEDUP_WINDOW", "10m")
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(failedPod),
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+		deduper:       newNotificationDeduper(),
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+	}
+	pod := waitingPod(time.Now().Add(-3*time.Minute), "ImagePullBackOff")
+	waiting, ok := getStuckWaiting(pod, time.Now(), 2*time.Minute)
+	assert.True(t, ok)
+
+	c.notifyPodStuck(job, pod, waiting, time.Now())
+	// A repeated warning is deduplicated.
+	c.notifyPodStuck(job, pod, waiting, time.Now())
+	failedJob := job.DeepCopy()
+	failedJob.Status.Failed = 1
+	c.handleFailed(failedJob, time.Now())
+
+	assert.Equal(t, []string{"failed", "failed"}, n.events)
+	assert.Equal(t, "ImagePullBackOff", n.params[0].WaitingReason)
+	assert.Empty(t, n.params[1].WaitingReason)
+}
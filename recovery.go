@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	successNotifyAlways   = "always"
+	successNotifyRecovery = "recovery"
+)
+
+// recoveryTracker remembers which jobs failed on their last run so that the first success
+// after a failure is notified as a recovery. Only failures are kept, a job without an
+// entry last succeeded or was never seen.
+type recoveryTracker struct {
+	// recoveryOnly drops the successes that don't follow a failure (SUCCESS_NOTIFY_MODE=recovery).
+	recoveryOnly bool
+
+	mu sync.Mutex
+	// failedSince is the time of the first failure since the last success by job.
+	failedSince map[string]time.Time
+}
+
+// newRecoveryTracker reads SUCCESS_NOTIFY_MODE, an invalid value notifies every success.
+func newRecoveryTracker() *recoveryTracker {
+	t := &recoveryTracker{failedSince: make(map[string]time.Time)}
+	switch value := os.Getenv("SUCCESS_NOTIFY_MODE"); value {
+	case "", successNotifyAlways:
+	case successNotifyRecovery:
+		t.recoveryOnly = true
+	default:
+		klog.Errorf("Invalid SUCCESS_NOTIFY_MODE %q, notifying every success", value)
+	}
+	return t
+}
+
+// recoveryKey identifies the runs of the same job: the jobs of a CronJob share its name,
+// other jobs are matched by their own name.
+func recoveryKey(namespace, cronJobName, jobName string) string {
+	if cronJobName != "" {
+		return namespace + "/" + cronJobName
+	}
+	return namespace + "/" + jobName
+}
+
+// recordFailure records a failure at, keeping the first one of consecutive failures.
+func (t *recoveryTracker) recordFailure(key string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.failedSince[key]; !ok {
+		t.failedSince[key] = at
+	}
+}
+
+// recordSuccess returns the time the job has been failing since when its previous run
+// failed, i.e. the job recovered.
+func (t *recoveryTracker) recordSuccess(key string) (failedSince time.Time, recovered bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	failedSince, recovered = t.failedSince[key]
+	delete(t.failedSince, key)
+	return failedSince, recovered
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func TestNewRecoveryTracker(t *testing.T) {
+	assert.False(t, newRecoveryTracker().recoveryOnly)
+
+	t.Setenv("SUCCESS_NOTIFY_MODE", "always")
+	assert.False(t, newRecoveryTracker().recoveryOnly)
+
+	t.Setenv("SUCCESS_NOTIFY_MODE", "sometimes")
+	assert.False(t, newRecoveryTracker().recoveryOnly)
+
+	t.Setenv("SUCCESS_NOTIFY_MODE", "recovery")
+	assert.True(t, newRecoveryTracker().recoveryOnly)
+}
+
+func TestRecoveryKey(t *testing.T) {
+	assert.Equal(t, "test-ns/the-cronjob", recoveryKey("test-ns", "the-cronjob", "the-cronjob-28000000"))
+	assert.Equal(t, "test-ns/the-job", recoveryKey("test-ns", "", "the-job"))
+}
+
+func TestRecoveryTracker(t *testing.T) {
+	tracker := newRecoveryTracker()
+	firstFailure := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+
+	_, recovered := tracker.recordSuccess("test-ns/the-cronjob")
+	assert.False(t, recovered, "no previous run")
+	_, recovered = tracker.recordSuccess("test-ns/the-cronjob")
+	assert.False(t, recovered, "success after success")
+
+	tracker.recordFailure("test-ns/the-cronjob", firstFailure)
+	tracker.recordFailure("test-ns/the-cronjob", firstFailure.Add(time.Hour))
+	_, recovered = tracker.recordSuccess("test-ns/other")
+	assert.False(t, recovered)
+
+	failedSince, recovered := tracker.recordSuccess("test-ns/the-cronjob")
+	assert.True(t, recovered, "success after failure")
+	assert.Equal(t, firstFailure, failedSince)
+	_, recovered = tracker.recordSuccess("test-ns/the-cronjob")
+	assert.False(t, recovered, "recovery is notified once")
+}
+
+var recoveryTestPod = &corev1.Pod{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "the-job-abcde",
+		Namespace: "test-ns",
+		Labels:    map[string]string{searchLabel: "test"},
+	},
+}
+
+func newRecoveryTestJob(failed bool) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test"},
+		Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+		Status:     batchv1.JobStatus{Succeeded: 1},
+	}
+	if failed {
+		job.Status = batchv1.JobStatus{
+			Failed:     1,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+		}
+	}
+	return job
+}
+
+func TestHandleSucceededRecoveryMode(t *testing.T) {
+	newJob := newRecoveryTestJob
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(recoveryTestPod.DeepCopy()),
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+		recoveries:    &recoveryTracker{recoveryOnly: true, failedSince: make(map[string]time.Time)},
+	}
+
+	c.handleSucceeded(newJob(false), time.Now())
+	c.handleSucceeded(newJob(false), time.Now())
+	assert.Empty(t, n.events, "steady successes are not notified")
+
+	c.handleFailed(newJob(true), time.Now())
+	c.handleSucceeded(newJob(false), time.Now())
+	c.handleSucceeded(newJob(false), time.Now())
+
+	assert.Equal(t, []string{"failed", "success"}, n.events)
+	assert.True(t, n.params[1].Recovered)
+}
+
+func TestHandleSucceededRecovered(t *testing.T) {
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(recoveryTestPod.DeepCopy()),
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+		recoveries:    newRecoveryTracker(),
+	}
+	firstFailure := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+
+	c.handleSucceeded(newRecoveryTestJob(false), firstFailure.Add(-time.Hour))
+	c.handleFailed(newRecoveryTestJob(true), firstFailure)
+	c.handleFailed(newRecoveryTestJob(true), firstFailure.Add(time.Hour))
+	c.handleSucceeded(newRecoveryTestJob(false), firstFailure.Add(90*time.Minute))
+	c.handleSucceeded(newRecoveryTestJob(false), firstFailure.Add(2*time.Hour))
+
+	assert.Equal(t, []string{"success", "failed", "failed", "success", "success"}, n.events)
+	assert.False(t, n.params[0].Recovered, "success without a failure")
+	assert.True(t, n.params[3].Recovered, "success after failure")
+	assert.Equal(t, 90*time.Minute, n.params[3].FailingFor)
+	assert.False(t, n.params[4].Recovered, "success after success")
+	assert.Zero(t, n.params[4].FailingFor)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// defaultLogRedactPatterns match common credentials that should not leave the cluster.
+var defaultLogRedactPatterns = []string{
+	// AWS access key IDs
+	`\b(AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// AWS secret access keys
+	`(?i)aws_secret_access_key\s*[=:]\s*\S+`,
+	// Bearer tokens in Authorization headers
+	`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`,
+	// password=..., token: ... and similar assignments
+	`(?i)\b(password|passwd|secret|token|api_key|apikey)\s*[=:]\s*\S+`,
+}
+
+// getLogRedactPatterns returns the default patterns plus the newline separated
+// patterns of LOG_REDACT_PATTERNS, compiled once when the log fetcher is built. Invalid
+// patterns are skipped.
+func getLogRedactPatterns() []*regexp.Regexp {
+	patterns := defaultLogRedactPatterns
+	if v := os.Getenv("LOG_REDACT_PATTERNS"); v != "" {
+		patterns = append(append([]string{}, patterns...), strings.Split(v, "\n")...)
+	}
+
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			klog.Errorf("Invalid LOG_REDACT_PATTERNS pattern %q: %v", p, err)
+			continue
+		}
+		res = append(res, re)
+	}
+	return res
+}
+
+// redactLog replaces the matches of the patterns in the log.
+func redactLog(patterns []*regexp.Regexp, log string) string {
+	for _, re := range patterns {
+		log = re.ReplaceAllString(log, redactedValue)
+	}
+	return log
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+// renotifyAnnotationName set or changed on a completed job, e.g. to the current time, sends
+// the notification of its result again.
+const renotifyAnnotationName = "job-notify-controller/renotify"
+
+// renotifyRequested reports whether the renotify annotation was set or changed by the update.
+func renotifyRequested(oldJob, newJob *batchv1.Job) bool {
+	value := newJob.Annotations[renotifyAnnotationName]
+	return value != "" && value != oldJob.Annotations[renotifyAnnotationName]
+}
+
+// renotify sends the notification of the result of a completed job again. It returns false
+// when the job has no result yet, leaving the update to be handled as usual.
+func (c *Controller) renotify(job *batchv1.Job, observedAt time.Time) bool {
+	if isSkippedJob(job) {
+		return false
+	}
+	switch c.getJobResult(job) {
+	case jobSucceeded:
+		klog.Infof("Renotification requested: %s renotify=%s", jobLogFields(job, notification.SUCCESS), job.Annotations[renotifyAnnotationName])
+		c.notifySucceeded(job, observedAt, true)
+	case jobFailed:
+		klog.Infof("Renotification requested: %s renotify=%s", jobLogFields(job, notification.FAILED), job.Annotations[renotifyAnnotationName])
+		c.notifyFailed(job, observedAt, true)
+	default:
+		klog.Infof("Renotification ignored, the job is not completed: %s", jobLogFields(job, "updated"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func TestRenotifyRequested(t *testing.T) {
+	job := func(renotify string) *batchv1.Job {
+		job := &batchv1.Job{}
+		if renotify != "" {
+			job.Annotations = map[string]string{renotifyAnnotationName: renotify}
+		}
+		return job
+	}
+
+	assert.False(t, renotifyRequested(job(""), job("")))
+	assert.True(t, renotifyRequested(job(""), job("2020-11-28T01:00:00Z")))
+	assert.True(t, renotifyRequested(job("2020-11-28T01:00:00Z"), job("2020-11-28T02:00:00Z")))
+	assert.False(t, renotifyRequested(job("2020-11-28T01:00:00Z"), job("2020-11-28T01:00:00Z")))
+	assert.False(t, renotifyRequested(job("2020-11-28T01:00:00Z"), job("")))
+}
+
+func TestHandleUpdateRenotify(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+	t.Setenv("DEDUP_WINDOW", "1h")
+	defer func(original time.Time) { serverStartTime = original }(serverStartTime)
+	serverStartTime = time.Now()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	job := func(renotify string, status batchv1.JobStatus) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "the-job",
+				Namespace: "test-ns",
+				UID:       "test",
+				// Created before the controller started, the missed notification can still be re-sent.
+				CreationTimestamp: metav1.NewTime(serverStartTime.Add(-time.Hour)),
+				Annotations:       map[string]string{renotifyAnnotationName: renotify},
+			},
+			Spec:   batchv1.JobSpec{BackoffLimit: utilpointer.Int32(0)},
+			Status: status,
+		}
+	}
+	failed := batchv1.JobStatus{
+		Failed:     1,
+		Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+	}
+	succeeded := batchv1.JobStatus{
+		Succeeded:  1,
+		Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+	}
+	running := batchv1.JobStatus{Active: 1}
+
+	tests := []struct {
+		name           string
+		oldJob         *batchv1.Job
+		newJob         *batchv1.Job
+		expectedEvents []string
+	}{
+		{"Failed job", job("", failed), job("1", failed), []string{"failed"}},
+		{"Succeeded job", job("1", succeeded), job("2", succeeded), []string{"success"}},
+		{"Unchanged annotation", job("1", failed), job("1", failed), nil},
+		{"Running job", job("", running), job("1", running), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &recordingNotification{}
+			sub := &fakeSubscription{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(pod.DeepCopy()),
+				notifications: map[string]notification.Notification{"recording": n},
+				subscriptions: map[string]monitoring.Subscription{"fake": sub},
+				// The job was already notified and a duplicate would be dropped.
+				notifiedJobs: map[string]bool{"the-job": true},
+				deduper:      newNotificationDeduper(),
+				recoveries:   newRecoveryTracker(),
+			}
+			notified := notification.MessageTemplateParam{JobName: "the-job", Namespace: "test-ns"}
+			c.deduper.isDuplicate(notification.FAILED, notified)
+			c.deduper.isDuplicate(notification.SUCCESS, notified)
+
+			c.handleUpdate(test.oldJob, test.newJob)
+
+			assert.Equal(t, test.expectedEvents, n.events)
+			assert.Empty(t, sub.failed, "renotifications are not reported to the subscriptions")
+		})
+	}
+}
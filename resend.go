@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+// resendCache keeps the last notification of each job so that it can be sent again through
+// POST /resend, e.g. after fixing a misconfigured channel.
+type resendCache struct {
+	// token is the bearer token the resend requests must carry.
+	token string
+
+	mu   sync.Mutex
+	last map[string]sentNotification
+}
+
+type sentNotification struct {
+	job          *batchv1.Job
+	messageParam notification.MessageTemplateParam
+}
+
+// newResendCache returns nil unless RESEND_TOKEN is set.
+func newResendCache() *resendCache {
+	token := os.Getenv("RESEND_TOKEN")
+	if token == "" {
+		return nil
+	}
+	return &resendCache{token: token, last: make(map[string]sentNotification)}
+}
+
+// record keeps the notification about to be sent as the last one of the job.
+func (r *resendCache) record(job *batchv1.Job, messageParam notification.MessageTemplateParam) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last[job.Namespace+"/"+job.Name] = sentNotification{job: job, messageParam: messageParam}
+}
+
+func (r *resendCache) get(namespace, jobName string) (sentNotification, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sent, ok := r.last[namespace+"/"+jobName]
+	return sent, ok
+}
+
+// forget drops the job once it was deleted.
+func (r *resendCache) forget(job *batchv1.Job) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.last, job.Namespace+"/"+job.Name)
+}
+
+func (r *resendCache) authorized(req *http.Request) bool {
+	return bearerAuthorized(req, r.token)
+}
+
+// bearerAuthorized reports whether the request carries the token as a bearer token.
+func bearerAuthorized(req *http.Request, token string) bool {
+	bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
+
+// resendHandler serves /resend, which sends the last notification of a job again to every
+// notifier of the job, e.g.
+// curl -X POST -H "Authorization: Bearer $RESEND_TOKEN" "localhost:9090/resend?namespace=default&job=my-job".
+func (c *Controller) resendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.resends.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	namespace, jobName := r.URL.Query().Get("namespace"), r.URL.Query().Get("job")
+	if namespace == "" || jobName == "" {
+		http.Error(w, "namespace and job are required", http.StatusBadRequest)
+		return
+	}
+	sent, ok := c.resends.get(namespace, jobName)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no notification of job %s/%s", namespace, jobName), http.StatusNotFound)
+		return
+	}
+	klog.Infof("Resend requested: %s", jobLogFields(sent.job, sent.messageParam.Event))
+	if failed := c.resend(sent, time.Now()); len(failed) > 0 {
+		http.Error(w, fmt.Sprintf("failed notifiers: %v", failed), http.StatusBadGateway)
+		return
+	}
+	fmt.Fprintln(w, sent.messageParam.Event)
+}
+
+// resend sends the notification again and returns the notifiers that failed. Deduplication
+// doesn't apply, the notification is resent on purpose.
+func (c *Controller) resend(sent sentNotification, observedAt time.Time) []string {
+	var failed []string
+	for name, n := range c.jobNotifications(sent.job) {
+		var result notification.NotifyResult
+		var err error
+		switch sent.messageParam.Event {
+		case notification.START:
+			result, err = n.NotifyStart(sent.messageParam)
+		case notification.SUCCESS:
+			result, err = n.NotifySuccess(sent.messageParam)
+		case notification.FAILED:
+			result, err = n.NotifyFailed(sent.messageParam)
+		case notification.SUSPENDED:
+			result, err = n.NotifySuspended(sent.messageParam)
+		case notification.RESUMED:
+			result, err = n.NotifyResumed(sent.messageParam)
+		}
+		c.recordNotifyResult(name, sent.messageParam.Event, sent.job, observedAt, result, err)
+		if err != nil {
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
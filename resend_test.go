@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewResendCache(t *testing.T) {
+	assert.Nil(t, newResendCache())
+
+	t.Setenv("RESEND_TOKEN", "secret")
+	assert.Equal(t, "secret", newResendCache().token)
+}
+
+func TestResendHandler(t *testing.T) {
+	n := &recordingNotification{}
+	c := &Controller{
+		kubeclientset: fake.NewSimpleClientset(),
+		notifications: map[string]notification.Notification{"recording": n},
+		notifiedJobs:  make(map[string]bool),
+		resends:       &resendCache{token: "secret", last: make(map[string]sentNotification)},
+	}
+	c.notifyPodsLost(runningJob(1, 0), 3, 4, time.Now())
+	n.events = nil
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		query    string
+		code     int
+		expected string
+	}{
+		{"resend", http.MethodPost, "secret", "?namespace=test-ns&job=the-job", http.StatusOK, "failed\n"},
+		{"invalid token", http.MethodPost, "wrong", "?namespace=test-ns&job=the-job", http.StatusUnauthorized, "unauthorized\n"},
+		{"no token", http.MethodPost, "", "?namespace=test-ns&job=the-job", http.StatusUnauthorized, "unauthorized\n"},
+		{"missing job", http.MethodPost, "secret", "?namespace=test-ns", http.StatusBadRequest, "namespace and job are required\n"},
+		{"unknown job", http.MethodPost, "secret", "?namespace=test-ns&job=other-job", http.StatusNotFound, "no notification of job test-ns/other-job\n"},
+		{"method not allowed", http.MethodGet, "secret", "?namespace=test-ns&job=the-job", http.StatusMethodNotAllowed, "method not allowed\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/resend"+test.query, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			rec := httptest.NewRecorder()
+			c.resendHandler(rec, req)
+
+			assert.Equal(t, test.code, rec.Code)
+			assert.Equal(t, test.expected, rec.Body.String())
+		})
+	}
+
+	// Only the authorized request resent the warning.
+	assert.Equal(t, []string{"failed"}, n.events)
+	assert.Equal(t, int32(3), n.params[len(n.params)-1].LostPods)
+
+	c.resends.forget(runningJob(1, 0))
+	_, ok := c.resends.get("test-ns", "the-job")
+	assert.False(t, ok)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	defaultResourceWarnThreshold = 0.9
+	resourceSampleInterval       = 30 * time.Second
+	// resourcePeakRetention keeps the peak of a container that is no longer sampled long enough
+	// for the success of its job to be handled, the peaks of failed and deleted jobs expire.
+	resourcePeakRetention = 10 * time.Minute
+)
+
+// containerMemoryUsage is the memory working set of a container reported by metrics-server.
+type containerMemoryUsage struct {
+	Namespace string
+	Pod       string
+	Container string
+	Bytes     int64
+}
+
+type memoryUsageLister func(ctx context.Context) ([]containerMemoryUsage, error)
+
+// resourceMonitor samples the memory usage of job pods from metrics-server and keeps the
+// peak per container, so jobs that nearly ran out of memory can be flagged when they succeed.
+// metrics-server only reports running pods, the peak is the highest sampled value.
+type resourceMonitor struct {
+	list      memoryUsageLister
+	threshold float64
+	now       func() time.Time
+
+	mu    sync.Mutex
+	peaks map[string]containerPeak
+}
+
+type containerPeak struct {
+	bytes    int64
+	lastSeen time.Time
+}
+
+func newResourceMonitor(list memoryUsageLister, threshold float64) *resourceMonitor {
+	return &resourceMonitor{
+		list:      list,
+		threshold: threshold,
+		now:       time.Now,
+		peaks:     make(map[string]containerPeak),
+	}
+}
+
+// getResourceMonitor returns nil unless NOTIFY_RESOURCE_WARNINGS=true.
+func getResourceMonitor(kubeclientset kubernetes.Interface) *resourceMonitor {
+	if os.Getenv("NOTIFY_RESOURCE_WARNINGS") != "true" {
+		return nil
+	}
+	return newResourceMonitor(newMetricsMemoryUsageLister(kubeclientset, os.Getenv("NAMESPACE")), getResourceWarnThreshold())
+}
+
+func (m *resourceMonitor) run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := m.sample(context.TODO()); err != nil {
+			klog.Errorf("Sample job pod metrics failed: %v", err)
+		}
+	}, resourceSampleInterval, stopCh)
+}
+
+func (m *resourceMonitor) sample(ctx context.Context) error {
+	usages, err := m.list(ctx)
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, usage := range usages {
+		key := containerUsageKey(usage.Namespace, usage.Pod, usage.Container)
+		peak := m.peaks[key]
+		if usage.Bytes > peak.bytes {
+			peak.bytes = usage.Bytes
+		}
+		peak.lastSeen = now
+		m.peaks[key] = peak
+	}
+	for key, peak := range m.peaks {
+		if now.Sub(peak.lastSeen) > resourcePeakRetention {
+			delete(m.peaks, key)
+		}
+	}
+	return nil
+}
+
+// getWarnings returns a warning for every container whose peak memory exceeded the threshold
+// of its limit and forgets the peaks of the given pods.
+func (m *resourceMonitor) getWarnings(pods []corev1.Pod) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var warnings []string
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			key := containerUsageKey(pod.Namespace, pod.Name, container.Name)
+			peak, ok := m.peaks[key]
+			delete(m.peaks, key)
+			limit, found := container.Resources.Limits[corev1.ResourceMemory]
+			if !ok || !found || limit.Value() == 0 {
+				continue
+			}
+			ratio := float64(peak.bytes) / float64(limit.Value())
+			if ratio < m.threshold {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("container %s peaked at %s of its %s memory limit (%d%%)",
+				container.Name, resource.NewQuantity(peak.bytes, resource.BinarySI).String(), limit.String(), int(ratio*100)))
+		}
+	}
+	return warnings
+}
+
+func containerUsageKey(namespace, pod, container string) string {
+	return namespace + "/" + pod + "/" + container
+}
+
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Containers []struct {
+			Name  string            `json:"name"`
+			Usage map[string]string `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// newMetricsMemoryUsageLister lists the memory usage of job pods from the metrics.k8s.io API.
+func newMetricsMemoryUsageLister(kubeclientset kubernetes.Interface, namespace string) memoryUsageLister {
+	path := "/apis/metrics.k8s.io/v1beta1/pods"
+	if namespace != "" {
+		path = "/apis/metrics.k8s.io/v1beta1/namespaces/" + namespace + "/pods"
+	}
+	return func(ctx context.Context) ([]containerMemoryUsage, error) {
+		body, err := kubeclientset.CoreV1().RESTClient().Get().
+			AbsPath(path).
+			Param("labelSelector", searchLabel).
+			DoRaw(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return parsePodMetrics(body)
+	}
+}
+
+func parsePodMetrics(body []byte) ([]containerMemoryUsage, error) {
+	var list podMetricsList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	var usages []containerMemoryUsage
+	for _, item := range list.Items {
+		for _, container := range item.Containers {
+			memory, err := resource.ParseQuantity(container.Usage["memory"])
+			if err != nil {
+				klog.V(4).Infof("Invalid memory usage of %s/%s: %v", item.Metadata.Name, container.Name, err)
+				continue
+			}
+			usages = append(usages, containerMemoryUsage{
+				Namespace: item.Metadata.Namespace,
+				Pod:       item.Metadata.Name,
+				Container: container.Name,
+				Bytes:     memory.Value(),
+			})
+		}
+	}
+	return usages, nil
+}
+
+func getResourceWarnThreshold() float64 {
+	value := os.Getenv("RESOURCE_WARN_THRESHOLD")
+	if value == "" {
+		return defaultResourceWarnThreshold
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		klog.Errorf("Invalid RESOURCE_WARN_THRESHOLD %q, using default %v", value, defaultResourceWarnThreshold)
+		return defaultResourceWarnThreshold
+	}
+	return threshold
+}
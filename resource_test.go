@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourceMonitorWarnings(t *testing.T) {
+	const mi = 1024 * 1024
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "near", Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				}},
+				{Name: "below", Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				}},
+				{Name: "unlimited"},
+			},
+		},
+	}
+
+	samples := [][]containerMemoryUsage{
+		{
+			{Namespace: "test-ns", Pod: "the-job-abcde", Container: "near", Bytes: 100 * mi},
+			{Namespace: "test-ns", Pod: "the-job-abcde", Container: "below", Bytes: 200 * mi},
+			{Namespace: "test-ns", Pod: "the-job-abcde", Container: "unlimited", Bytes: 900 * mi},
+		},
+		{
+			{Namespace: "test-ns", Pod: "the-job-abcde", Container: "near", Bytes: 240 * mi},
+			{Namespace: "test-ns", Pod: "the-job-abcde", Container: "below", Bytes: 150 * mi},
+		},
+		{
+			{Namespace: "test-ns", Pod: "the-job-abcde", Container: "near", Bytes: 120 * mi},
+		},
+	}
+	calls := 0
+	m := newResourceMonitor(func(ctx context.Context) ([]containerMemoryUsage, error) {
+		usages := samples[calls]
+		calls++
+		return usages, nil
+	}, 0.9)
+	for range samples {
+		assert.NoError(t, m.sample(context.TODO()))
+	}
+
+	warnings := m.getWarnings([]corev1.Pod{pod})
+
+	assert.Equal(t, []string{"container near peaked at 240Mi of its 256Mi memory limit (93%)"}, warnings)
+	assert.Empty(t, m.peaks)
+}
+
+func TestResourceMonitorPrunesStalePeaks(t *testing.T) {
+	now := time.Now()
+	samples := [][]containerMemoryUsage{
+		{
+			{Namespace: "test-ns", Pod: "failed-job-abcde", Container: "worker", Bytes: 100},
+			{Namespace: "test-ns", Pod: "running-job-abcde", Container: "worker", Bytes: 100},
+		},
+		{
+			{Namespace: "test-ns", Pod: "running-job-abcde", Container: "worker", Bytes: 50},
+		},
+	}
+	calls := 0
+	m := newResourceMonitor(func(ctx context.Context) ([]containerMemoryUsage, error) {
+		usages := samples[calls]
+		calls++
+		return usages, nil
+	}, 0.9)
+	m.now = func() time.Time { return now }
+
+	assert.NoError(t, m.sample(context.TODO()))
+	now = now.Add(resourcePeakRetention + time.Second)
+	assert.NoError(t, m.sample(context.TODO()))
+
+	assert.Equal(t, map[string]containerPeak{
+		"test-ns/running-job-abcde/worker": {bytes: 100, lastSeen: now},
+	}, m.peaks)
+}
+
+func TestParsePodMetrics(t *testing.T) {
+	body := []byte(`{
+		"kind": "PodMetricsList",
+		"items": [{
+			"metadata": {"name": "the-job-abcde", "namespace": "test-ns"},
+			"containers": [
+				{"name": "worker", "usage": {"cpu": "12m", "memory": "240Mi"}},
+				{"name": "broken", "usage": {"cpu": "1m"}}
+			]
+		}]
+	}`)
+
+	usages, err := parsePodMetrics(body)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []containerMemoryUsage{
+		{Namespace: "test-ns", Pod: "the-job-abcde", Container: "worker", Bytes: 240 * 1024 * 1024},
+	}, usages)
+}
+
+func TestGetResourceWarnThreshold(t *testing.T) {
+	t.Setenv("RESOURCE_WARN_THRESHOLD", "")
+	assert.Equal(t, defaultResourceWarnThreshold, getResourceWarnThreshold())
+
+	t.Setenv("RESOURCE_WARN_THRESHOLD", "0.8")
+	assert.Equal(t, 0.8, getResourceWarnThreshold())
+
+	t.Setenv("RESOURCE_WARN_THRESHOLD", "80")
+	assert.Equal(t, defaultResourceWarnThreshold, getResourceWarnThreshold())
+}
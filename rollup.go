@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// rollupBucket groups the jobs a CronJob created in the same minute, the resolution of
+	// CronJob schedules, into one batch.
+	rollupBucket = time.Minute
+	// maxRollupFailedJobs is the number of failed jobs named in a rollup summary.
+	maxRollupFailedJobs = 5
+)
+
+// rollupResult is the result of a job of a batch, notified with the batch.
+type rollupResult struct {
+	job          *batchv1.Job
+	messageParam notification.MessageTemplateParam
+	observedAt   time.Time
+}
+
+// cronJobRollup collects the results of the jobs a CronJob created together and notifies
+// them once as a batch, CRONJOB_ROLLUP_WINDOW after the first of them finished.
+type cronJobRollup struct {
+	window time.Duration
+	// inflight counts the pending batches so that shutdown waits for them.
+	inflight *sync.WaitGroup
+	send     func(results []rollupResult)
+
+	mu      sync.Mutex
+	batches map[string][]rollupResult
+
+	afterFunc func(d time.Duration, f func()) stopper
+}
+
+// newCronJobRollup returns nil unless CRONJOB_ROLLUP_WINDOW is set.
+func newCronJobRollup(inflight *sync.WaitGroup, send func(results []rollupResult)) *cronJobRollup {
+	value := os.Getenv("CRONJOB_ROLLUP_WINDOW")
+	if value == "" {
+		return nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		klog.Errorf("Invalid CRONJOB_ROLLUP_WINDOW %q, notifying every job: %v", value, err)
+		return nil
+	}
+	return &cronJobRollup{
+		window:   window,
+		inflight: inflight,
+		send:     send,
+		batches:  make(map[string][]rollupResult),
+		afterFunc: func(d time.Duration, f func()) stopper {
+			return time.AfterFunc(d, f)
+		},
+	}
+}
+
+// rollupKey identifies the batch of a job by its CronJob and creation time.
+func rollupKey(job *batchv1.Job, cronJobName string) string {
+	bucket := job.CreationTimestamp.Truncate(rollupBucket).Unix()
+	return job.Namespace + "/" + cronJobName + "/" + strconv.FormatInt(bucket, 10)
+}
+
+// add collects the result of a job created by a CronJob and reports whether it is notified
+// with its batch. Other jobs are notified on their own.
+func (r *cronJobRollup) add(job *batchv1.Job, messageParam notification.MessageTemplateParam, observedAt time.Time) bool {
+	if r == nil || messageParam.CronJobName == "" {
+		return false
+	}
+	key := rollupKey(job, messageParam.CronJobName)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results, ok := r.batches[key]
+	r.batches[key] = append(results, rollupResult{job: job, messageParam: messageParam, observedAt: observedAt})
+	if ok {
+		klog.Infof("Job result added to batch: %s batch=%s", jobLogFields(job, messageParam.Event), key)
+		return true
+	}
+	klog.Infof("Batch notification delayed: %s batch=%s window=%s", jobLogFields(job, messageParam.Event), key, r.window)
+	r.inflight.Add(1)
+	r.afterFunc(r.window, func() {
+		defer r.inflight.Done()
+		r.mu.Lock()
+		results := r.batches[key]
+		delete(r.batches, key)
+		r.mu.Unlock()
+		r.send(results)
+	})
+	return true
+}
+
+// getRollupSummary summarizes the results of a batch, e.g.
+// "4 jobs: 3 succeeded, 1 failed (nightly-28472940-b)".
+func getRollupSummary(results []rollupResult) string {
+	var failed []string
+	for _, result := range results {
+		if result.messageParam.Event == notification.FAILED {
+			failed = append(failed, result.messageParam.JobName)
+		}
+	}
+	summary := fmt.Sprintf("%d jobs: %d succeeded, %d failed", len(results), len(results)-len(failed), len(failed))
+	if len(failed) == 0 {
+		return summary
+	}
+	if len(failed) > maxRollupFailedJobs {
+		failed = append(failed[:maxRollupFailedJobs], fmt.Sprintf("and %d more", len(failed)-maxRollupFailedJobs))
+	}
+	return summary + " (" + strings.Join(failed, ", ") + ")"
+}
+
+// notifyRollup notifies a batch as its first failed job, or its first job when every job
+// succeeded, with the summary of the batch. A batch of one job is notified as that job.
+func (c *Controller) notifyRollup(results []rollupResult) {
+	if len(results) == 0 {
+		return
+	}
+	first := results[0]
+	for _, result := range results {
+		if result.messageParam.Event == notification.FAILED {
+			first = result
+			break
+		}
+	}
+	messageParam := first.messageParam
+	if len(results) > 1 {
+		messageParam.BatchSummary = getRollupSummary(results)
+	}
+	klog.Infof("Batch notified: %s summary=%q", jobLogFields(first.job, messageParam.Event), messageParam.BatchSummary)
+	for name, n := range c.jobNotifications(first.job) {
+		var result notification.NotifyResult
+		var err error
+		if messageParam.Event == notification.FAILED {
+			result, err = n.NotifyFailed(messageParam)
+		} else {
+			result, err = n.NotifySuccess(messageParam)
+		}
+		c.recordNotifyResult(name, messageParam.Event, first.job, first.observedAt, result, err)
+	}
+}
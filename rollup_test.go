@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewCronJobRollup(t *testing.T) {
+	var wg sync.WaitGroup
+	assert.Nil(t, newCronJobRollup(&wg, nil))
+
+	t.Setenv("CRONJOB_ROLLUP_WINDOW", "invalid")
+	assert.Nil(t, newCronJobRollup(&wg, nil))
+
+	t.Setenv("CRONJOB_ROLLUP_WINDOW", "2m")
+	assert.Equal(t, 2*time.Minute, newCronJobRollup(&wg, nil).window)
+}
+
+func TestCronJobRollup(t *testing.T) {
+	created := time.Date(2020, 11, 28, 1, 0, 0, 0, time.UTC)
+	job := func(name string, createdAt time.Time) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", CreationTimestamp: metav1.NewTime(createdAt)}}
+	}
+	param := func(event string, name string, cronJobName string) notification.MessageTemplateParam {
+		return notification.MessageTemplateParam{Event: event, JobName: name, CronJobName: cronJobName, Namespace: "test-ns"}
+	}
+
+	var wg sync.WaitGroup
+	var batches [][]rollupResult
+	clock := &fakeClock{}
+	r := &cronJobRollup{
+		window:    time.Minute,
+		inflight:  &wg,
+		send:      func(results []rollupResult) { batches = append(batches, results) },
+		batches:   make(map[string][]rollupResult),
+		afterFunc: clock.afterFunc,
+	}
+
+	assert.True(t, r.add(job("nightly-a", created), param(notification.SUCCESS, "nightly-a", "nightly"), created))
+	assert.True(t, r.add(job("nightly-b", created.Add(5*time.Second)), param(notification.FAILED, "nightly-b", "nightly"), created))
+	// The next run of the CronJob and another CronJob are other batches.
+	assert.True(t, r.add(job("nightly-c", created.Add(time.Minute)), param(notification.SUCCESS, "nightly-c", "nightly"), created))
+	assert.True(t, r.add(job("hourly-a", created), param(notification.SUCCESS, "hourly-a", "hourly"), created))
+	// Jobs not created by a CronJob are notified on their own.
+	assert.False(t, r.add(job("the-job", created), param(notification.SUCCESS, "the-job", ""), created))
+
+	// One window is started per batch.
+	assert.Len(t, clock.funcs, 3)
+	clock.fire()
+	wg.Wait()
+
+	assert.Len(t, batches, 3)
+	var names [][]string
+	for _, batch := range batches {
+		var batchNames []string
+		for _, result := range batch {
+			batchNames = append(batchNames, result.messageParam.JobName)
+		}
+		names = append(names, batchNames)
+	}
+	assert.Equal(t, [][]string{{"nightly-a", "nightly-b"}, {"nightly-c"}, {"hourly-a"}}, names)
+	assert.Empty(t, r.batches)
+}
+
+func TestCronJobRollupDisabled(t *testing.T) {
+	var r *cronJobRollup
+	assert.False(t, r.add(&batchv1.Job{}, notification.MessageTemplateParam{CronJobName: "nightly"}, time.Now()))
+}
+
+func TestGetRollupSummary(t *testing.T) {
+	result := func(event string, name string) rollupResult {
+		return rollupResult{messageParam: notification.MessageTemplateParam{Event: event, JobName: name}}
+	}
+
+	assert.Equal(t, "2 jobs: 2 succeeded, 0 failed",
+		getRollupSummary([]rollupResult{result(notification.SUCCESS, "a"), result(notification.SUCCESS, "b")}))
+	assert.Equal(t, "3 jobs: 1 succeeded, 2 failed (b, c)",
+		getRollupSummary([]rollupResult{result(notification.SUCCESS, "a"), result(notification.FAILED, "b"), result(notification.FAILED, "c")}))
+
+	var many []rollupResult
+	for i := 0; i < 7; i++ {
+		many = append(many, result(notification.FAILED, fmt.Sprintf("job-%d", i)))
+	}
+	assert.Equal(t, "7 jobs: 0 succeeded, 7 failed (job-0, job-1, job-2, job-3, job-4, and 2 more)", getRollupSummary(many))
+}
+
+func TestNotifyRollup(t *testing.T) {
+	result := func(event string, name string) rollupResult {
+		return rollupResult{
+			job:          &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"}},
+			messageParam: notification.MessageTemplateParam{Event: event, JobName: name, CronJobName: "nightly", Namespace: "test-ns"},
+			observedAt:   time.Now(),
+		}
+	}
+
+	tests := []struct {
+		name            string
+		results         []rollupResult
+		expectedEvents  []string
+		expectedJobName string
+		expectedSummary string
+	}{
+		{
+			"failed batch",
+			[]rollupResult{result(notification.SUCCESS, "nightly-a"), result(notification.FAILED, "nightly-b"), result(notification.SUCCESS, "nightly-c")},
+			[]string{"failed"},
+			"nightly-b",
+			"3 jobs: 2 succeeded, 1 failed (nightly-b)",
+		},
+		{
+			"succeeded batch",
+			[]rollupResult{result(notification.SUCCESS, "nightly-a"), result(notification.SUCCESS, "nightly-b")},
+			[]string{"success"},
+			"nightly-a",
+			"2 jobs: 2 succeeded, 0 failed",
+		},
+		{
+			"single job",
+			[]rollupResult{result(notification.FAILED, "nightly-a")},
+			[]string{"failed"},
+			"nightly-a",
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &recordingNotification{}
+			c := &Controller{notifications: map[string]notification.Notification{"recording": n}}
+
+			c.notifyRollup(test.results)
+
+			assert.Equal(t, test.expectedEvents, n.events)
+			assert.Equal(t, test.expectedJobName, n.params[0].JobName)
+			assert.Equal(t, test.expectedSummary, n.params[0].BatchSummary)
+		})
+	}
+}
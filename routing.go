@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// The routing rules are applied as the annotations a job could set itself, so that
+// every backend honours them the same way.
+const (
+	routeChannelAnnotationName  = "kube-job-notifier/default-channel"
+	routeMentionsAnnotationName = "kube-job-notifier/failed-mentions"
+	routeSeverityAnnotationName = "kube-job-notifier/severity"
+)
+
+// routingConfig is the CONFIG_FILE. The first rule matching a job routes it, jobs matching
+// no rule use the default route.
+type routingConfig struct {
+	Rules   []routingRule `json:"rules"`
+	Default route         `json:"default"`
+}
+
+type routingRule struct {
+	Match routeMatch `json:"match"`
+	route
+}
+
+// routeMatch matches the jobs of a namespace carrying all the labels and annotations.
+// An empty field matches any job.
+type routeMatch struct {
+	Namespace   string            `json:"namespace"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// route is where the notifications of a job go. Empty fields keep the environment settings.
+type route struct {
+	// Channel is the Slack channel, used unless the job sets a channel annotation.
+	Channel string `json:"channel"`
+	// Mentions are the Slack user groups mentioned on failures instead of SLACK_FAILED_MENTIONS.
+	Mentions []string `json:"mentions"`
+	// Severity is critical, warning or info, used unless the job sets the severity annotation.
+	Severity string `json:"severity"`
+	// Backends are the names of the notifiers to use, e.g. slack or pagerduty, all of them when empty.
+	Backends []string `json:"backends"`
+}
+
+// getRoutingConfig loads CONFIG_FILE, returning nil when it is unset or invalid.
+func getRoutingConfig() *routingConfig {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+	config, err := loadRoutingConfig(path)
+	if err != nil {
+		klog.Errorf("Invalid CONFIG_FILE %s, routing with the environment settings: %v", path, err)
+		return nil
+	}
+	klog.Infof("Loaded %d routing rules from %s", len(config.Rules), path)
+	return config
+}
+
+func loadRoutingConfig(path string) (*routingConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &routingConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+	if err := config.Default.validate(); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	for i, rule := range config.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return config, nil
+}
+
+func (r route) validate() error {
+	switch r.Severity {
+	case "", "critical", "warning", "info":
+	default:
+		return fmt.Errorf("invalid severity %q, must be critical, warning or info", r.Severity)
+	}
+	for _, name := range r.Backends {
+		if !notification.IsRegistered(name) {
+			return fmt.Errorf("unknown backend %q", name)
+		}
+	}
+	return nil
+}
+
+// route returns the route of the first rule matching the job.
+func (c *routingConfig) route(job *batchv1.Job) route {
+	for _, rule := range c.Rules {
+		if rule.Match.matches(job) {
+			return rule.route
+		}
+	}
+	return c.Default
+}
+
+func (m routeMatch) matches(job *batchv1.Job) bool {
+	if m.Namespace != "" && m.Namespace != job.Namespace {
+		return false
+	}
+	for key, value := range m.Labels {
+		if v, ok := job.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	for key, value := range m.Annotations {
+		v, ok := job.Spec.Template.Annotations[key]
+		if !ok {
+			v, ok = job.Annotations[key]
+		}
+		if !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// annotations returns a copy of the job annotations completed by the route. The annotations
+// set on the job take precedence.
+func (r route) annotations(jobAnnotations map[string]string) map[string]string {
+	annotations := make(map[string]string, len(jobAnnotations)+3)
+	for key, value := range jobAnnotations {
+		annotations[key] = value
+	}
+	setDefault := func(key, value string) {
+		if _, ok := annotations[key]; !ok && value != "" {
+			annotations[key] = value
+		}
+	}
+	setDefault(routeChannelAnnotationName, r.Channel)
+	setDefault(routeMentionsAnnotationName, strings.Join(r.Mentions, ","))
+	setDefault(routeSeverityAnnotationName, r.Severity)
+	return annotations
+}
+
+// allows reports whether the notifier name is one of the route backends.
+func (r route) allows(name string) bool {
+	return len(r.Backends) == 0 || slices.Contains(r.Backends, name)
+}
+
+// jobAnnotations returns the annotations the job is notified with: those of its pod template,
+// completed by its routing rule.
+func (c *Controller) jobAnnotations(job *batchv1.Job) map[string]string {
+	if c.routes == nil {
+		return job.Spec.Template.ObjectMeta.Annotations
+	}
+	return c.routes.route(job).annotations(job.Spec.Template.ObjectMeta.Annotations)
+}
+
+// jobNotifications returns the notifications the job is routed to.
+func (c *Controller) jobNotifications(job *batchv1.Job) map[string]notification.Notification {
+	if c.routes == nil {
+		return c.notifications
+	}
+	r := c.routes.route(job)
+	notifications := make(map[string]notification.Notification, len(c.notifications))
+	for name, n := range c.notifications {
+		if r.allows(name) {
+			notifications[name] = n
+		}
+	}
+	return notifications
+}
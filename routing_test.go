@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testRoutingConfig = `
+rules:
+  - match:
+      namespace: payments
+      labels:
+        tier: critical
+    channel: "#payments-oncall"
+    mentions: ["@payments-oncall"]
+    severity: critical
+    backends: [slack, pagerduty]
+  - match:
+      namespace: payments
+    channel: "#payments"
+    backends: [slack]
+  - match:
+      annotations:
+        team: data
+    channel: "#data-jobs"
+    severity: info
+default:
+  channel: "#jobs"
+`
+
+func writeRoutingConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func newRoutedJob(namespace string, labels, annotations map[string]string) *batchv1.Job {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: namespace, Labels: labels}}
+	job.Spec.Template.Annotations = annotations
+	return job
+}
+
+func TestRoutingConfig(t *testing.T) {
+	config, err := loadRoutingConfig(writeRoutingConfig(t, testRoutingConfig))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		job      *batchv1.Job
+		expected route
+	}{
+		{
+			"First match wins",
+			newRoutedJob("payments", map[string]string{"tier": "critical"}, map[string]string{"team": "data"}),
+			route{Channel: "#payments-oncall", Mentions: []string{"@payments-oncall"}, Severity: "critical", Backends: []string{"slack", "pagerduty"}},
+		},
+		{
+			"Namespace",
+			newRoutedJob("payments", map[string]string{"tier": "batch"}, nil),
+			route{Channel: "#payments", Backends: []string{"slack"}},
+		},
+		{
+			"Annotation",
+			newRoutedJob("analytics", nil, map[string]string{"team": "data"}),
+			route{Channel: "#data-jobs", Severity: "info"},
+		},
+		{
+			"Default",
+			newRoutedJob("analytics", nil, map[string]string{"team": "ml"}),
+			route{Channel: "#jobs"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, config.route(test.job))
+		})
+	}
+}
+
+func TestLoadRoutingConfigInvalid(t *testing.T) {
+	_, err := loadRoutingConfig(writeRoutingConfig(t, "rules:\n  - channel: \"#jobs\"\n    severity: urgent\n"))
+	assert.EqualError(t, err, `rule 1: invalid severity "urgent", must be critical, warning or info`)
+
+	_, err = loadRoutingConfig(writeRoutingConfig(t, "default:\n  backends: [carrier-pigeon]\n"))
+	assert.EqualError(t, err, `default: unknown backend "carrier-pigeon"`)
+
+	_, err = loadRoutingConfig(writeRoutingConfig(t, "rules:\n  - match:\n      namespaces: [payments]\n"))
+	assert.Error(t, err, "unknown fields are rejected")
+
+	_, err = loadRoutingConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestGetRoutingConfig(t *testing.T) {
+	assert.Nil(t, getRoutingConfig())
+
+	t.Setenv("CONFIG_FILE", writeRoutingConfig(t, "rules: [}"))
+	assert.Nil(t, getRoutingConfig())
+
+	t.Setenv("CONFIG_FILE", writeRoutingConfig(t, testRoutingConfig))
+	assert.Len(t, getRoutingConfig().Rules, 3)
+}
+
+func TestRouteAnnotations(t *testing.T) {
+	r := route{Channel: "#payments-oncall", Mentions: []string{"@payments-oncall", "S0123"}, Severity: "critical"}
+
+	assert.Equal(t, map[string]string{
+		routeChannelAnnotationName:  "#payments-oncall",
+		routeMentionsAnnotationName: "@payments-oncall,S0123",
+		routeSeverityAnnotationName: "critical",
+	}, r.annotations(nil))
+
+	jobAnnotations := map[string]string{routeSeverityAnnotationName: "info", "kube-job-notifier/failed-channel": "#mine"}
+	assert.Equal(t, map[string]string{
+		routeChannelAnnotationName:         "#payments-oncall",
+		routeMentionsAnnotationName:        "@payments-oncall,S0123",
+		routeSeverityAnnotationName:        "info",
+		"kube-job-notifier/failed-channel": "#mine",
+	}, r.annotations(jobAnnotations), "the job annotations take precedence")
+	assert.Len(t, jobAnnotations, 2, "the job annotations are not modified")
+
+	assert.Empty(t, route{}.annotations(nil))
+}
+
+func TestJobNotificationsRouted(t *testing.T) {
+	config, err := loadRoutingConfig(writeRoutingConfig(t, testRoutingConfig))
+	assert.NoError(t, err)
+	slack, lark, pagerduty := &recordingNotification{}, &recordingNotification{}, &recordingNotification{}
+	c := &Controller{
+		notifications: map[string]notification.Notification{"slack": slack, "lark": lark, "pagerduty": pagerduty},
+		routes:        config,
+	}
+
+	assert.Equal(t, map[string]notification.Notification{"slack": slack, "pagerduty": pagerduty},
+		c.jobNotifications(newRoutedJob("payments", map[string]string{"tier": "critical"}, nil)))
+	assert.Equal(t, map[string]notification.Notification{"slack": slack},
+		c.jobNotifications(newRoutedJob("payments", nil, nil)))
+	assert.Len(t, c.jobNotifications(newRoutedJob("analytics", nil, nil)), 3)
+	assert.Equal(t, map[string]string{routeChannelAnnotationName: "#payments", "team": "data"},
+		c.jobAnnotations(newRoutedJob("payments", nil, map[string]string{"team": "data"})))
+
+	c.routes = nil
+	assert.Len(t, c.jobNotifications(newRoutedJob("payments", nil, nil)), 3)
+	assert.Nil(t, c.jobAnnotations(newRoutedJob("payments", nil, nil)))
+}
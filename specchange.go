@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// getSpecChanges describes the changes to the container images, parallelism and completions
+// between two versions of a job, e.g. "parallelism: 1 → 3". Status-only updates have none.
+func getSpecChanges(oldJob, newJob *batchv1.Job) []string {
+	// The generation is only bumped by spec changes.
+	if oldJob.Generation != 0 && oldJob.Generation == newJob.Generation {
+		return nil
+	}
+	var changes []string
+	changes = append(changes, getImageChanges("init container", oldJob.Spec.Template.Spec.InitContainers, newJob.Spec.Template.Spec.InitContainers)...)
+	changes = append(changes, getImageChanges("container", oldJob.Spec.Template.Spec.Containers, newJob.Spec.Template.Spec.Containers)...)
+	if change, ok := getCountChange("parallelism", oldJob.Spec.Parallelism, newJob.Spec.Parallelism); ok {
+		changes = append(changes, change)
+	}
+	if change, ok := getCountChange("completions", oldJob.Spec.Completions, newJob.Spec.Completions); ok {
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// getImageChanges compares the images of the containers of the same name.
+func getImageChanges(kind string, oldContainers, newContainers []corev1.Container) []string {
+	oldImages := make(map[string]string, len(oldContainers))
+	for _, container := range oldContainers {
+		oldImages[container.Name] = container.Image
+	}
+	var changes []string
+	for _, container := range newContainers {
+		oldImage, ok := oldImages[container.Name]
+		if ok && oldImage != container.Image {
+			changes = append(changes, fmt.Sprintf("image of %s %s: %s → %s", kind, container.Name, oldImage, container.Image))
+		}
+	}
+	return changes
+}
+
+func getCountChange(field string, oldCount, newCount *int32) (string, bool) {
+	oldValue, newValue := formatCount(oldCount), formatCount(newCount)
+	if oldValue == newValue {
+		return "", false
+	}
+	return field + ": " + oldValue + " → " + newValue, true
+}
+
+// formatCount formats an optional count of the job spec, "unset" when it is nil.
+func formatCount(count *int32) string {
+	if count == nil {
+		return "unset"
+	}
+	return strconv.Itoa(int(*count))
+}
+
+// notifySpecChanged sends an info notification describing the changes to the spec of a
+// running job. NOTIFY_ON_SPEC_CHANGE=true enables it.
+func (c *Controller) notifySpecChanged(oldJob, newJob *batchv1.Job, observedAt time.Time) {
+	if os.Getenv("NOTIFY_ON_SPEC_CHANGE") != "true" {
+		return
+	}
+	changes := getSpecChanges(oldJob, newJob)
+	if len(changes) == 0 {
+		return
+	}
+
+	cronJobName, err := getCronJobNameFromOwnerReferences(c.kubeclientset, newJob)
+	if err != nil {
+		klog.Errorf("Get cronjob failed: %s: %v", jobLogFields(newJob, notification.SPEC_CHANGED), err)
+		c.errorReporter.report(controllerErrorStageOwner, newJob.Name, err)
+	}
+
+	messageParam := notification.MessageTemplateParam{
+		Event:        notification.SPEC_CHANGED,
+		ClusterName:  c.clusterName,
+		JobName:      newJob.Name,
+		CronJobName:  cronJobName,
+		Namespace:    newJob.Namespace,
+		StartTime:    newJob.Status.StartTime,
+		Annotations:  c.jobAnnotations(newJob),
+		JobDetailURL: getJobDetailURL(newJob, cronJobName, c.clusterName),
+		SpecChanges:  changes,
+	}
+	// Every change is notified, so the notification isn't deduplicated nor resent.
+	if c.isMutedNotification(newJob, notification.SPEC_CHANGED, messageParam, observedAt) {
+		return
+	}
+	klog.Infof("Job spec changed: %s changes=%q", jobLogFields(newJob, notification.SPEC_CHANGED), changes)
+	for name, n := range c.jobNotifications(newJob) {
+		result, err := n.NotifySpecChanged(messageParam)
+		c.recordNotifyResult(name, notification.SPEC_CHANGED, newJob, observedAt, result, err)
+	}
+}
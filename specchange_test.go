@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+func newSpecChangeJob(generation int64, image string, parallelism int32, completions *int32) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "the-job",
+			Namespace:         "test-ns",
+			UID:               "test",
+			Generation:        generation,
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: utilpointer.Int32(0),
+			Parallelism:  utilpointer.Int32(parallelism),
+			Completions:  completions,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "migrate", Image: "migrate:1.0"}},
+					Containers:     []corev1.Container{{Name: "worker", Image: image}, {Name: "sidecar", Image: "proxy:1.0"}},
+				},
+			},
+		},
+		Status: batchv1.JobStatus{Active: parallelism},
+	}
+}
+
+func TestGetSpecChanges(t *testing.T) {
+	statusOnly := newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5))
+	statusOnly.Status.Active = 0
+	statusOnly.Status.Failed = 1
+
+	tests := []struct {
+		name     string
+		oldJob   *batchv1.Job
+		newJob   *batchv1.Job
+		expected []string
+	}{
+		{
+			"status only",
+			newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5)),
+			statusOnly,
+			nil,
+		},
+		{
+			"image",
+			newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5)),
+			newSpecChangeJob(2, "worker:1.1", 1, utilpointer.Int32(5)),
+			[]string{"image of container worker: worker:1.0 → worker:1.1"},
+		},
+		{
+			"parallelism and completions",
+			newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5)),
+			newSpecChangeJob(2, "worker:1.0", 3, utilpointer.Int32(10)),
+			[]string{"parallelism: 1 → 3", "completions: 5 → 10"},
+		},
+		{
+			"completions set",
+			newSpecChangeJob(1, "worker:1.0", 1, nil),
+			newSpecChangeJob(2, "worker:1.0", 1, utilpointer.Int32(5)),
+			[]string{"completions: unset → 5"},
+		},
+		{
+			"same generation",
+			newSpecChangeJob(2, "worker:1.0", 1, utilpointer.Int32(5)),
+			newSpecChangeJob(2, "worker:1.1", 1, utilpointer.Int32(5)),
+			nil,
+		},
+		{
+			"unchanged spec",
+			newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5)),
+			newSpecChangeJob(2, "worker:1.0", 1, utilpointer.Int32(5)),
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getSpecChanges(test.oldJob, test.newJob))
+		})
+	}
+
+	oldJob := newSpecChangeJob(1, "worker:1.0", 1, nil)
+	newJob := newSpecChangeJob(2, "worker:1.0", 1, nil)
+	newJob.Spec.Template.Spec.InitContainers[0].Image = "migrate:2.0"
+	assert.Equal(t, []string{"image of init container migrate: migrate:1.0 → migrate:2.0"}, getSpecChanges(oldJob, newJob))
+}
+
+func TestHandleUpdateSpecChanged(t *testing.T) {
+	defer func(original time.Time) { serverStartTime = original }(serverStartTime)
+	serverStartTime = time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name           string
+		enabled        string
+		oldJob         *batchv1.Job
+		newJob         *batchv1.Job
+		expectedEvents []string
+		expectedParam  notification.MessageTemplateParam
+	}{
+		{
+			name:           "spec changed",
+			enabled:        "true",
+			oldJob:         newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5)),
+			newJob:         newSpecChangeJob(2, "worker:1.1", 3, utilpointer.Int32(5)),
+			expectedEvents: []string{"spec_changed"},
+			expectedParam: notification.MessageTemplateParam{
+				Event:       notification.SPEC_CHANGED,
+				JobName:     "the-job",
+				Namespace:   "test-ns",
+				SpecChanges: []string{"image of container worker: worker:1.0 → worker:1.1", "parallelism: 1 → 3"},
+			},
+		},
+		{
+			name:    "status only",
+			enabled: "true",
+			oldJob:  newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5)),
+			newJob:  newSpecChangeJob(1, "worker:1.0", 2, utilpointer.Int32(5)),
+		},
+		{
+			name:    "disabled",
+			enabled: "",
+			oldJob:  newSpecChangeJob(1, "worker:1.0", 1, utilpointer.Int32(5)),
+			newJob:  newSpecChangeJob(2, "worker:1.1", 3, utilpointer.Int32(5)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_ON_SPEC_CHANGE", test.enabled)
+			runningPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "the-job-abcde", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(runningPod),
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+			}
+
+			c.handleUpdate(test.oldJob, test.newJob)
+
+			assert.Equal(t, test.expectedEvents, n.events)
+			if test.expectedEvents != nil {
+				assert.Equal(t, test.expectedParam, n.params[0])
+			}
+		})
+	}
+}
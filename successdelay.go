@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/klog"
+)
+
+// stopper is the part of *time.Timer used to cancel a delayed success notification.
+type stopper interface {
+	Stop() bool
+}
+
+type pendingSuccess struct {
+	job        *batchv1.Job
+	observedAt time.Time
+	timer      stopper
+}
+
+// successConfirmer delays success notifications so that jobs leaving the succeeded state
+// shortly after reporting success, e.g. when they are re-run, are not notified.
+type successConfirmer struct {
+	delay time.Duration
+	// inflight counts the pending notifications so that shutdown waits for them.
+	inflight *sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[string]*pendingSuccess
+
+	afterFunc func(d time.Duration, f func()) stopper
+}
+
+// newSuccessConfirmer returns nil unless SUCCESS_CONFIRM_DELAY is set.
+func newSuccessConfirmer(inflight *sync.WaitGroup) *successConfirmer {
+	value := os.Getenv("SUCCESS_CONFIRM_DELAY")
+	if value == "" {
+		return nil
+	}
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay <= 0 {
+		klog.Errorf("Invalid SUCCESS_CONFIRM_DELAY %q, notifying success right away: %v", value, err)
+		return nil
+	}
+	return &successConfirmer{
+		delay:    delay,
+		inflight: inflight,
+		pending:  make(map[string]*pendingSuccess),
+		afterFunc: func(d time.Duration, f func()) stopper {
+			return time.AfterFunc(d, f)
+		},
+	}
+}
+
+func successKey(job *batchv1.Job) string {
+	return job.Namespace + "/" + job.Name
+}
+
+// schedule calls confirm once the delay has passed unless the job is cancelled in the meantime.
+// A job that is already pending keeps its deadline and is confirmed with its latest state.
+func (s *successConfirmer) schedule(job *batchv1.Job, observedAt time.Time, confirm func(job *batchv1.Job, observedAt time.Time)) {
+	key := successKey(job)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pending[key]; ok {
+		p.job = job
+		return
+	}
+	klog.Infof("Success notification delayed: %s delay=%s", jobLogFields(job, "success"), s.delay)
+	p := &pendingSuccess{job: job, observedAt: observedAt}
+	s.pending[key] = p
+	s.inflight.Add(1)
+	p.timer = s.afterFunc(s.delay, func() {
+		s.mu.Lock()
+		current, ok := s.pending[key]
+		if ok && current == p {
+			delete(s.pending, key)
+		}
+		s.mu.Unlock()
+		if !ok || current != p {
+			return
+		}
+		defer s.inflight.Done()
+		confirm(p.job, p.observedAt)
+	})
+}
+
+// cancel drops the pending success notification of the job, if any.
+func (s *successConfirmer) cancel(job *batchv1.Job) {
+	if s == nil {
+		return
+	}
+	key := successKey(job)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[key]
+	if !ok {
+		return
+	}
+	p.timer.Stop()
+	delete(s.pending, key)
+	s.inflight.Done()
+	klog.Infof("Success notification cancelled: %s status=%v", jobLogFields(job, "success"), job.Status)
+}
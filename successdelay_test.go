@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+type fakeTimer struct {
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.stopped = true
+	return true
+}
+
+// fakeClock fires the delayed functions when the test advances it, stopped or not.
+type fakeClock struct {
+	funcs []func()
+}
+
+func (c *fakeClock) afterFunc(d time.Duration, f func()) stopper {
+	c.funcs = append(c.funcs, f)
+	return &fakeTimer{}
+}
+
+func (c *fakeClock) fire() {
+	funcs := c.funcs
+	c.funcs = nil
+	for _, f := range funcs {
+		f()
+	}
+}
+
+func TestNewSuccessConfirmer(t *testing.T) {
+	var wg sync.WaitGroup
+	assert.Nil(t, newSuccessConfirmer(&wg))
+
+	t.Setenv("SUCCESS_CONFIRM_DELAY", "invalid")
+	assert.Nil(t, newSuccessConfirmer(&wg))
+
+	t.Setenv("SUCCESS_CONFIRM_DELAY", "30s")
+	assert.Equal(t, 30*time.Second, newSuccessConfirmer(&wg).delay)
+}
+
+func TestSuccessConfirmDelay(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "the-job-a", Namespace: "test-ns", Labels: map[string]string{searchLabel: "test"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+	}
+	job := func(succeeded int32, conditions ...batchv1.JobConditionType) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "the-job", Namespace: "test-ns", UID: "test", CreationTimestamp: metav1.Now()},
+			Spec:       batchv1.JobSpec{BackoffLimit: utilpointer.Int32(3)},
+			Status:     batchv1.JobStatus{Succeeded: succeeded},
+		}
+		for _, c := range conditions {
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{Type: c, Status: corev1.ConditionTrue})
+		}
+		return job
+	}
+
+	tests := []struct {
+		name     string
+		versions []*batchv1.Job
+		expected []string
+	}{
+		{"confirmed", []*batchv1.Job{job(0), job(1), job(1, batchv1.JobComplete)}, []string{"success"}},
+		{"superseded", []*batchv1.Job{job(0), job(1), job(0)}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{}
+			n := &recordingNotification{}
+			c := &Controller{
+				kubeclientset: fake.NewSimpleClientset(pod),
+				notifications: map[string]notification.Notification{"recording": n},
+				notifiedJobs:  make(map[string]bool),
+			}
+			c.successes = &successConfirmer{
+				delay:     time.Minute,
+				inflight:  &c.inflight,
+				pending:   make(map[string]*pendingSuccess),
+				afterFunc: clock.afterFunc,
+			}
+
+			for i := 1; i < len(test.versions); i++ {
+				c.handleUpdate(test.versions[i-1], test.versions[i])
+			}
+			// Nothing is notified before the delay has passed.
+			assert.Empty(t, n.events)
+
+			clock.fire()
+
+			assert.Equal(t, test.expected, n.events)
+			assert.Empty(t, c.successes.pending)
+
+			done := make(chan struct{})
+			go func() {
+				c.inflight.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("pending success notification was not released")
+			}
+		})
+	}
+}
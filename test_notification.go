@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// sendTestNotifications sends a sample of every job notification to validate
+// tokens, channels and templates without waiting for a real job.
+func sendTestNotifications(notifications map[string]notification.Notification) error {
+	startTime := metav1.NewTime(time.Now().Add(-1 * time.Minute))
+	completionTime := metav1.Now()
+	messageParam := notification.MessageTemplateParam{
+		Event:       notification.START,
+		JobName:     "kube-job-notifier-test-28472940",
+		CronJobName: "kube-job-notifier-test",
+		Namespace:   "default",
+		StartTime:   &startTime,
+	}
+
+	var failed []string
+	for name, n := range notifications {
+		klog.Infof("Sending test notifications to %s", name)
+		if _, err := n.NotifyStart(messageParam); err != nil {
+			klog.Errorf("Failed %s start notification: %v", name, err)
+			failed = append(failed, name+"/start")
+		}
+
+		completed := messageParam
+		completed.Event = notification.SUCCESS
+		completed.CompletionTime = &completionTime
+		completed.Log = "This is a test notification sent by kube-job-notifier."
+		if _, err := n.NotifySuccess(completed); err != nil {
+			klog.Errorf("Failed %s success notification: %v", name, err)
+			failed = append(failed, name+"/success")
+		}
+		completed.Event = notification.FAILED
+		completed.FailedCount = 1
+		if _, err := n.NotifyFailed(completed); err != nil {
+			klog.Errorf("Failed %s failed notification: %v", name, err)
+			failed = append(failed, name+"/failed")
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed test notifications: %v", failed)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/notification"
+)
+
+type failingNotification struct {
+	recordingNotification
+}
+
+func (n *failingNotification) NotifySuccess(messageParam notification.MessageTemplateParam) (result notification.NotifyResult, err error) {
+	return notification.NotifyResult{}, errors.New("invalid_auth")
+}
+
+func TestSendTestNotifications(t *testing.T) {
+	n := &recordingNotification{}
+
+	err := sendTestNotifications(map[string]notification.Notification{"recording": n})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "success", "failed"}, n.events)
+	for _, p := range n.params {
+		assert.NotEmpty(t, p.JobName)
+		assert.NotEmpty(t, p.Namespace)
+		assert.NotNil(t, p.StartTime)
+	}
+	assert.NotEmpty(t, n.params[1].Log)
+	assert.NotNil(t, n.params[2].CompletionTime)
+}
+
+func TestSendTestNotificationsError(t *testing.T) {
+	n := &failingNotification{}
+
+	err := sendTestNotifications(map[string]notification.Notification{"failing": n})
+
+	assert.EqualError(t, err, "failed test notifications: [failing/success]")
+	assert.Equal(t, []string{"start", "failed"}, n.events)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	watchErrorInitialBackoff = 1 * time.Second
+	watchErrorMaxBackoff     = 5 * time.Minute
+	// watchErrorResetPeriod is the quiet period after which the backoff starts over.
+	watchErrorResetPeriod = 10 * time.Minute
+)
+
+// watchErrorHandler logs informer watch errors by cause, records them as a metric and
+// backs off on consecutive failures before the reflector retries.
+type watchErrorHandler struct {
+	subscriptions map[string]monitoring.Subscription
+
+	mu        sync.Mutex
+	backoff   time.Duration
+	lastError time.Time
+
+	// stopped is closed on shutdown to end a pending backoff.
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+func newWatchErrorHandler(subscriptions map[string]monitoring.Subscription) *watchErrorHandler {
+	return &watchErrorHandler{
+		subscriptions: subscriptions,
+		stopped:       make(chan struct{}),
+		now:           time.Now,
+		after:         time.After,
+	}
+}
+
+func (h *watchErrorHandler) handle(r *cache.Reflector, err error) {
+	reason := getWatchErrorReason(err)
+	switch reason {
+	case "expired", "eof":
+		// The reflector relists on its own, these are part of the normal watch lifecycle.
+		klog.V(4).Infof("Watch of jobs closed with: %v", err)
+		return
+	case "forbidden", "unauthorized":
+		klog.Errorf("Watch of jobs is not permitted, please check the RBAC settings of the controller: %v", err)
+	default:
+		klog.Errorf("Watch of jobs failed: %v", err)
+	}
+
+	if monitoring.Enabled() {
+		for name, s := range h.subscriptions {
+			if err := s.WatchErrorEvent(reason); err != nil {
+				klog.Errorf("Failed %s watch error subscribe: %v", name, err)
+			}
+		}
+	}
+
+	backoff := h.nextBackoff()
+	klog.Infof("Retrying watch of jobs in %s", backoff)
+	select {
+	case <-h.after(backoff):
+	case <-h.stopped:
+		klog.V(4).Info("Watch backoff of jobs ended by shutdown")
+	}
+}
+
+// stop ends a pending backoff and skips the following ones, so the reflector can stop.
+func (h *watchErrorHandler) stop() {
+	if h == nil {
+		return
+	}
+	h.stopOnce.Do(func() { close(h.stopped) })
+}
+
+func (h *watchErrorHandler) nextBackoff() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if h.backoff == 0 || now.Sub(h.lastError) > watchErrorResetPeriod {
+		h.backoff = watchErrorInitialBackoff
+	} else {
+		h.backoff *= 2
+		if h.backoff > watchErrorMaxBackoff {
+			h.backoff = watchErrorMaxBackoff
+		}
+	}
+	h.lastError = now
+	return h.backoff
+}
+
+func getWatchErrorReason(err error) string {
+	switch {
+	case apierrors.IsResourceExpired(err) || apierrors.IsGone(err):
+		return "expired"
+	case errors.Is(err, io.EOF):
+		return "eof"
+	case apierrors.IsForbidden(err):
+		return "forbidden"
+	case apierrors.IsUnauthorized(err):
+		return "unauthorized"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "unexpected_eof"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yutachaos/kube-job-notifier/pkg/monitoring"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetWatchErrorReason(t *testing.T) {
+	resource := schema.GroupResource{Group: "batch", Resource: "jobs"}
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"expired", apierrors.NewResourceExpired("too old"), "expired"},
+		{"gone", apierrors.NewGone("gone"), "expired"},
+		{"eof", io.EOF, "eof"},
+		{"forbidden", apierrors.NewForbidden(resource, "", errors.New("rbac")), "forbidden"},
+		{"unauthorized", apierrors.NewUnauthorized("token"), "unauthorized"},
+		{"unexpected eof", io.ErrUnexpectedEOF, "unexpected_eof"},
+		{"other", errors.New("connection refused"), "other"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, getWatchErrorReason(test.err))
+		})
+	}
+}
+
+func TestWatchErrorHandler(t *testing.T) {
+	t.Setenv("DATADOG_ENABLE", "true")
+
+	now := time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC)
+	var slept []time.Duration
+	sub := &fakeSubscription{}
+	h := newWatchErrorHandler(map[string]monitoring.Subscription{"fake": sub})
+	h.now = func() time.Time { return now }
+	h.after = func(d time.Duration) <-chan time.Time {
+		slept = append(slept, d)
+		fired := make(chan time.Time, 1)
+		fired <- now
+		return fired
+	}
+
+	h.handle(nil, errors.New("connection refused"))
+	h.handle(nil, io.EOF)
+	h.handle(nil, apierrors.NewUnauthorized("token"))
+	h.handle(nil, errors.New("connection refused"))
+
+	now = now.Add(watchErrorResetPeriod + time.Second)
+	h.handle(nil, errors.New("connection refused"))
+
+	assert.Equal(t, []string{"other", "unauthorized", "other", "other"}, sub.watchErrors)
+	assert.Equal(t, []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 1 * time.Second}, slept)
+}
+
+func TestWatchErrorHandlerStop(t *testing.T) {
+	h := newWatchErrorHandler(nil)
+	// The backoff never ends on its own.
+	h.after = func(time.Duration) <-chan time.Time { return nil }
+
+	done := make(chan struct{})
+	go func() {
+		h.handle(nil, errors.New("connection refused"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("handle returned before the backoff ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+	h.stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handle didn't return on stop")
+	}
+
+	// Later errors don't back off once stopped.
+	h.handle(nil, errors.New("connection refused"))
+	h.stop()
+}
+
+func TestWatchErrorHandlerMaxBackoff(t *testing.T) {
+	h := newWatchErrorHandler(nil)
+	h.now = func() time.Time { return time.Date(2020, 11, 28, 1, 2, 3, 0, time.UTC) }
+
+	var backoff time.Duration
+	for i := 0; i < 20; i++ {
+		backoff = h.nextBackoff()
+	}
+	assert.Equal(t, watchErrorMaxBackoff, backoff)
+}